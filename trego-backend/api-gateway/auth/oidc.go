@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew is the tolerance applied to token expiry and issue times
+const clockSkew = 2 * time.Minute
+
+// googleIssuerHost is Google's issuer, whose ID tokens may name it with or
+// without the https:// scheme
+const googleIssuerHost = "accounts.google.com"
+
+// ErrInvalidIDToken is returned when an ID token fails verification
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// Provider is an OpenID Connect identity provider that can start an
+// authorization-code flow and turn the returned code into verified claims.
+// It is an interface so that a local fake provider can be used in tests.
+type Provider interface {
+	// AuthCodeURL returns the URL the browser must visit to log in
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems an authorization code and returns the verified ID token claims
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDTokenClaims, error)
+}
+
+// ProviderConfig holds the settings needed to talk to an OpenID Connect provider
+type ProviderConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// JWKSURL overrides the jwks_uri advertised by the discovery document
+	JWKSURL    string
+	Scopes     []string
+	HTTPClient *http.Client
+}
+
+// IDTokenClaims are the ID token claims Trego relies on
+type IDTokenClaims struct {
+	Issuer        string       `json:"iss"`
+	Subject       string       `json:"sub"`
+	Audience      audience     `json:"aud"`
+	ExpiresAt     int64        `json:"exp"`
+	IssuedAt      int64        `json:"iat"`
+	Nonce         string       `json:"nonce"`
+	Email         string       `json:"email"`
+	EmailVerified flexibleBool `json:"email_verified"`
+	Name          string       `json:"name"`
+	Picture       string       `json:"picture"`
+}
+
+// discoveryDocument is the subset of the OpenID provider metadata we use
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider is a Provider backed by any standards-compliant OpenID Connect
+// issuer (Google by default). Endpoints are read from the issuer's discovery
+// document on first use.
+type OIDCProvider struct {
+	conf   ProviderConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      *keySet
+}
+
+// NewOIDCProvider creates a new OpenID Connect provider
+func NewOIDCProvider(conf ProviderConfig) *OIDCProvider {
+	client := conf.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(conf.Scopes) == 0 {
+		conf.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &OIDCProvider{
+		conf:   conf,
+		client: client,
+	}
+}
+
+// AuthCodeURL returns the authorization endpoint URL for an S256 PKCE login
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.conf.ClientID},
+		"redirect_uri":          {p.conf.RedirectURL},
+		"scope":                 {strings.Join(p.conf.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems the authorization code at the token endpoint and verifies
+// the returned ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDTokenClaims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.conf.RedirectURL},
+		"client_id":     {p.conf.ClientID},
+		"client_secret": {p.conf.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+	if tokenResponse.IDToken == "" {
+		return nil, fmt.Errorf("token response did not include an id_token")
+	}
+
+	return p.verifyIDToken(ctx, discovery, tokenResponse.IDToken, nonce)
+}
+
+// verifyIDToken checks the signature and standard claims of an ID token
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, discovery *discoveryDocument, rawToken, nonce string) (*IDTokenClaims, error) {
+	token, err := parseJWT(rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if token.header.Algorithm != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidIDToken, token.header.Algorithm)
+	}
+
+	key, err := p.keys.key(ctx, token.header.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	digest := sha256.Sum256([]byte(token.signingInput))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], token.signature); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidIDToken)
+	}
+
+	var claims IDTokenClaims
+	if err := json.Unmarshal(token.payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	now := time.Now()
+	switch {
+	case !issuerMatches(claims.Issuer, discovery.Issuer):
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	case !claims.Audience.contains(p.conf.ClientID):
+		return nil, fmt.Errorf("%w: token was not issued for this client", ErrInvalidIDToken)
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)):
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidIDToken)
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return nil, fmt.Errorf("%w: token issued in the future", ErrInvalidIDToken)
+	case claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	case claims.Email == "":
+		return nil, fmt.Errorf("%w: token has no email claim", ErrInvalidIDToken)
+	case !bool(claims.EmailVerified):
+		return nil, fmt.Errorf("%w: email address is not verified", ErrInvalidIDToken)
+	}
+
+	return &claims, nil
+}
+
+// issuerMatches reports whether an ID token's iss claim names the issuer.
+// Google documents both https://accounts.google.com and accounts.google.com.
+func issuerMatches(claimed, issuer string) bool {
+	if claimed == issuer {
+		return true
+	}
+	return issuer == "https://"+googleIssuerHost && claimed == googleIssuerHost
+}
+
+// discover loads and caches the provider's discovery document
+func (p *OIDCProvider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.conf.IssuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build discovery request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch discovery document: unexpected status %d", resp.StatusCode)
+	}
+
+	var discovery discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery document: %w", err)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" {
+		return nil, fmt.Errorf("discovery document is missing required endpoints")
+	}
+
+	jwksURL := discovery.JWKSURI
+	if p.conf.JWKSURL != "" {
+		jwksURL = p.conf.JWKSURL
+	}
+	if jwksURL == "" {
+		return nil, fmt.Errorf("no JWKS URL configured or advertised")
+	}
+
+	p.discovery = &discovery
+	p.keys = newKeySet(jwksURL, p.client)
+	return p.discovery, nil
+}
@@ -0,0 +1,330 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID    = "trego-test"
+	testRedirectURL = "https://trego.example/api/v1/google-login/callback"
+	testSigningKID  = "test-key"
+)
+
+// authorization is a login the fake provider was asked for
+type authorization struct {
+	challenge string
+	nonce     string
+}
+
+// fakeOIDCServer is a local OpenID Connect provider. It issues codes for
+// the authorization URLs it is shown, redeems them with the PKCE verifier
+// and answers with RS256 ID tokens whose claims the test can tamper with.
+type fakeOIDCServer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// issuer is the issuer of the discovery document; the server's URL if
+	// empty
+	issuer string
+	// signer signs the ID tokens in place of key if set, e.g. an attacker's key
+	signer *rsa.PrivateKey
+	// tamper changes the claims of the ID tokens if set
+	tamper func(claims map[string]interface{})
+
+	mu     sync.Mutex
+	codes  map[string]authorization
+	issued int
+}
+
+// startFakeOIDCServer runs a fake provider for testClientID
+func startFakeOIDCServer(t *testing.T) *fakeOIDCServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+	s := &fakeOIDCServer{key: key, codes: map[string]authorization{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("POST /token", s.token)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// provider returns an OIDCProvider for the fake server
+func (s *fakeOIDCServer) provider() *OIDCProvider {
+	return NewOIDCProvider(ProviderConfig{
+		IssuerURL:    s.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  testRedirectURL,
+		HTTPClient:   s.Client(),
+	})
+}
+
+func (s *fakeOIDCServer) discovery(w http.ResponseWriter, _ *http.Request) {
+	issuer := s.issuer
+	if issuer == "" {
+		issuer = s.URL
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *fakeOIDCServer) jwks(w http.ResponseWriter, _ *http.Request) {
+	public := s.key.PublicKey
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testSigningKID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize plays the browser's visit to an authorization URL and returns
+// the code and state the provider redirects back with
+func (s *fakeOIDCServer) authorize(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Invalid authorization URL %q: %v", authURL, err)
+	}
+	params := u.Query()
+	if u.Path != "/authorize" || params.Get("response_type") != "code" || params.Get("client_id") != testClientID ||
+		params.Get("redirect_uri") != testRedirectURL || params.Get("code_challenge_method") != "S256" {
+		t.Fatalf("Unexpected authorization URL %s", authURL)
+	}
+	if params.Get("state") == "" || params.Get("nonce") == "" || params.Get("code_challenge") == "" {
+		t.Fatalf("Authorization URL %s lacks state, nonce or PKCE challenge", authURL)
+	}
+
+	code := RandomToken(16)
+	s.mu.Lock()
+	s.codes[code] = authorization{challenge: params.Get("code_challenge"), nonce: params.Get("nonce")}
+	s.mu.Unlock()
+	return code, params.Get("state")
+}
+
+func (s *fakeOIDCServer) token(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	login, ok := s.codes[r.FormValue("code")]
+	delete(s.codes, r.FormValue("code"))
+	s.mu.Unlock()
+
+	switch {
+	case !ok, r.FormValue("grant_type") != "authorization_code", r.FormValue("redirect_uri") != testRedirectURL,
+		r.FormValue("client_id") != testClientID:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	case CodeChallengeS256(r.FormValue("code_verifier")) != login.challenge:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":            s.URL,
+		"sub":            "google-user-1",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          login.nonce,
+		"email":          "ana@example.com",
+		"email_verified": true,
+		"name":           "Ana",
+	}
+	if s.issuer != "" {
+		claims["iss"] = s.issuer
+	}
+	if s.tamper != nil {
+		s.tamper(claims)
+	}
+	signer := s.key
+	if s.signer != nil {
+		signer = s.signer
+	}
+
+	header, _ := json.Marshal(jwtHeader{Algorithm: "RS256", KeyID: testSigningKID, Type: "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, signer, crypto.SHA256, digest[:])
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	s.issued++
+	s.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]string{
+		"id_token": signingInput + "." + base64.RawURLEncoding.EncodeToString(signature),
+	})
+}
+
+// startLogin starts a login as the login handler does and returns the code
+// the provider issued with the verifier and nonce kept for the callback
+func startLogin(t *testing.T, server *fakeOIDCServer, provider *OIDCProvider) (code, verifier, nonce string) {
+	t.Helper()
+	state, verifier, nonce := RandomToken(32), NewCodeVerifier(), RandomToken(32)
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, CodeChallengeS256(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	code, returnedState := server.authorize(t, authURL)
+	if returnedState != state {
+		t.Fatalf("state = %q, want %q", returnedState, state)
+	}
+	return code, verifier, nonce
+}
+
+func TestOIDCProviderLogin(t *testing.T) {
+	server := startFakeOIDCServer(t)
+	provider := server.provider()
+
+	code, verifier, nonce := startLogin(t, server, provider)
+	claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if claims.Subject != "google-user-1" || claims.Email != "ana@example.com" || claims.Name != "Ana" {
+		t.Errorf("claims = %+v, want Ana's", claims)
+	}
+
+	// Codes are redeemed once
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil {
+		t.Error("Exchange of a used code succeeded")
+	}
+}
+
+func TestOIDCProviderRejectsBadLogins(t *testing.T) {
+	attackerKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		// setup changes the fake provider before the login
+		setup func(s *fakeOIDCServer)
+		// verifier and nonce replace the ones kept for the callback if set
+		verifier string
+		nonce    string
+		// invalidToken is set when the ID token must be rejected, rather
+		// than the code exchange
+		invalidToken bool
+	}{
+		{name: "wrong PKCE verifier", verifier: NewCodeVerifier()},
+		{name: "nonce mismatch", nonce: "replayed-nonce", invalidToken: true},
+		{
+			name: "unexpected issuer",
+			setup: func(s *fakeOIDCServer) {
+				s.tamper = func(c map[string]interface{}) { c["iss"] = "https://evil.example" }
+			},
+			invalidToken: true,
+		},
+		{
+			name:         "other audience",
+			setup:        func(s *fakeOIDCServer) { s.tamper = func(c map[string]interface{}) { c["aud"] = "someone-else" } },
+			invalidToken: true,
+		},
+		{
+			name: "expired token",
+			setup: func(s *fakeOIDCServer) {
+				s.tamper = func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }
+			},
+			invalidToken: true,
+		},
+		{
+			name:         "unverified email",
+			setup:        func(s *fakeOIDCServer) { s.tamper = func(c map[string]interface{}) { c["email_verified"] = "false" } },
+			invalidToken: true,
+		},
+		{
+			name:         "foreign signature",
+			setup:        func(s *fakeOIDCServer) { s.signer = attackerKey },
+			invalidToken: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startFakeOIDCServer(t)
+			if tt.setup != nil {
+				tt.setup(server)
+			}
+			provider := server.provider()
+
+			code, verifier, nonce := startLogin(t, server, provider)
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			_, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if err == nil {
+				t.Fatal("Exchange succeeded")
+			}
+			if errors.Is(err, ErrInvalidIDToken) != tt.invalidToken {
+				t.Errorf("Exchange = %v, want ErrInvalidIDToken: %v", err, tt.invalidToken)
+			}
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if !tt.invalidToken && server.issued > 0 {
+				t.Error("The provider issued an ID token")
+			}
+		})
+	}
+}
+
+func TestOIDCProviderGoogleIssuerAlias(t *testing.T) {
+	tests := []struct {
+		issuer string
+		valid  bool
+	}{
+		{issuer: "https://accounts.google.com", valid: true},
+		{issuer: "accounts.google.com", valid: true},
+		{issuer: "http://accounts.google.com", valid: false},
+		{issuer: "evil.example", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.issuer, func(t *testing.T) {
+			server := startFakeOIDCServer(t)
+			server.issuer = "https://accounts.google.com"
+			server.tamper = func(c map[string]interface{}) { c["iss"] = tt.issuer }
+			provider := server.provider()
+
+			code, verifier, nonce := startLogin(t, server, provider)
+			_, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if (err == nil) != tt.valid {
+				t.Errorf("Exchange with iss %q = %v, want valid: %v", tt.issuer, err, tt.valid)
+			}
+		})
+	}
+}
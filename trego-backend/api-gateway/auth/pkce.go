@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// RandomToken returns a URL-safe random string built from n random bytes.
+// It is used for OAuth state values, nonces and PKCE code verifiers.
+func RandomToken(n int) string {
+	buf := make([]byte, n)
+	// crypto/rand.Read never returns an error on supported platforms
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// NewCodeVerifier returns a PKCE code verifier (RFC 7636, 43 characters)
+func NewCodeVerifier() string {
+	return RandomToken(32)
+}
+
+// CodeChallengeS256 derives the S256 PKCE code challenge for a verifier
+func CodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,348 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"trego-backend/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// TokenTypeAccess marks tokens accepted by the auth middleware
+	TokenTypeAccess = "access"
+	// TokenTypeRefresh marks tokens accepted by the refresh endpoint
+	TokenTypeRefresh = "refresh"
+
+	// tokenIssuer is the "iss" claim of every Trego token
+	tokenIssuer = "trego-api-gateway"
+
+	// keyCacheTTL bounds how long a rotation done by another gateway
+	// instance can go unnoticed when signing
+	keyCacheTTL = time.Minute
+
+	// keyReloadThrottle stops tokens with made-up key IDs from forcing a
+	// database round trip on every request
+	keyReloadThrottle = 5 * time.Second
+)
+
+var (
+	// ErrInvalidToken is returned when a token is malformed, badly signed,
+	// expired or of the wrong type
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrSessionRevoked is returned when the token's session was logged out
+	ErrSessionRevoked = errors.New("session revoked")
+)
+
+// TokenClaims are the claims carried by Trego access and refresh tokens
+type TokenClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	TokenID   string `json:"jti"`
+	Type      string `json:"typ"`
+	Admin     bool   `json:"adm,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// TokenPair is the access/refresh token pair handed to clients
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// TokenConfig configures token lifetimes
+type TokenConfig struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// TokenManager issues, verifies, refreshes and revokes signed bearer tokens.
+// Signing keys and sessions live in the database so that every gateway
+// instance accepts the same tokens and sees the same revocations.
+type TokenManager struct {
+	store *repository.AuthRepository
+	conf  TokenConfig
+
+	mu         sync.RWMutex
+	keys       map[string][]byte
+	currentKID string
+	loadedAt   time.Time
+}
+
+// NewTokenManager creates a new token manager
+func NewTokenManager(store *repository.AuthRepository, conf TokenConfig) *TokenManager {
+	return &TokenManager{
+		store: store,
+		conf:  conf,
+		keys:  make(map[string][]byte),
+	}
+}
+
+// Issue starts a new session for the user and returns its first token pair
+func (m *TokenManager) Issue(ctx context.Context, userID string, admin bool) (*TokenPair, error) {
+	session := repository.AuthSession{
+		SessionID:      uuid.New().String(),
+		UserID:         userID,
+		RefreshTokenID: uuid.New().String(),
+		IsAdmin:        admin,
+		ExpiresAt:      time.Now().Add(m.conf.RefreshTokenTTL),
+	}
+	if err := m.store.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return m.signPair(ctx, session.UserID, session.SessionID, session.RefreshTokenID, session.IsAdmin)
+}
+
+// Refresh exchanges a refresh token for a new pair. Refresh tokens are single
+// use: presenting one that was already exchanged revokes the whole session,
+// since it means the token leaked.
+func (m *TokenManager) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := m.verify(ctx, refreshToken, TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := m.store.GetSession(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrSessionRevoked
+		}
+		return nil, err
+	}
+
+	newTokenID := uuid.New().String()
+	rotated, err := m.store.RotateRefreshToken(ctx, session.SessionID, claims.TokenID, newTokenID, time.Now().Add(m.conf.RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		if session.RevokedAt == nil {
+			if err := m.store.RevokeSession(ctx, session.SessionID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrSessionRevoked
+	}
+
+	return m.signPair(ctx, session.UserID, session.SessionID, newTokenID, session.IsAdmin)
+}
+
+// VerifyAccessToken validates an access token and checks that its session is
+// still active
+func (m *TokenManager) VerifyAccessToken(ctx context.Context, accessToken string) (*TokenClaims, error) {
+	claims, err := m.verify(ctx, accessToken, TokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := m.store.IsSessionActive(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrSessionRevoked
+	}
+	return claims, nil
+}
+
+// RevokeSession logs out a single session
+func (m *TokenManager) RevokeSession(ctx context.Context, sessionID string) error {
+	return m.store.RevokeSession(ctx, sessionID)
+}
+
+// RevokeAllSessions logs a user out everywhere
+func (m *TokenManager) RevokeAllSessions(ctx context.Context, userID string) error {
+	return m.store.RevokeUserSessions(ctx, userID)
+}
+
+// RotateSigningKey makes a fresh key current. Older keys keep verifying
+// tokens for one refresh-token lifetime so nobody is logged out.
+func (m *TokenManager) RotateSigningKey(ctx context.Context) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	kid := uuid.New().String()
+	if err := m.store.RotateSigningKey(ctx, repository.SigningKey{KeyID: kid, Secret: secret}, m.conf.RefreshTokenTTL); err != nil {
+		return "", err
+	}
+
+	if err := m.loadKeys(ctx); err != nil {
+		return "", err
+	}
+	return kid, nil
+}
+
+// signPair signs an access and a refresh token for the session
+func (m *TokenManager) signPair(ctx context.Context, userID, sessionID, refreshTokenID string, admin bool) (*TokenPair, error) {
+	kid, secret, err := m.signingKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	access := TokenClaims{
+		Issuer:    tokenIssuer,
+		Subject:   userID,
+		SessionID: sessionID,
+		TokenID:   uuid.New().String(),
+		Type:      TokenTypeAccess,
+		Admin:     admin,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(m.conf.AccessTokenTTL).Unix(),
+	}
+	refresh := TokenClaims{
+		Issuer:    tokenIssuer,
+		Subject:   userID,
+		SessionID: sessionID,
+		TokenID:   refreshTokenID,
+		Type:      TokenTypeRefresh,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(m.conf.RefreshTokenTTL).Unix(),
+	}
+
+	accessToken, err := signHS256(kid, access, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+	refreshToken, err := signHS256(kid, refresh, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(m.conf.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// verify checks the signature, expiry and type of a token
+func (m *TokenManager) verify(ctx context.Context, rawToken, tokenType string) (*TokenClaims, error) {
+	token, err := parseJWT(rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if token.header.Algorithm != "HS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, token.header.Algorithm)
+	}
+
+	secret, err := m.verificationKey(ctx, token.header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(token.signature, hmacSHA256(secret, token.signingInput)) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims TokenClaims
+	if err := json.Unmarshal(token.payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	switch {
+	case claims.Issuer != tokenIssuer:
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	case claims.Type != tokenType:
+		return nil, fmt.Errorf("%w: expected a %s token", ErrInvalidToken, tokenType)
+	case time.Now().After(time.Unix(claims.ExpiresAt, 0)):
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	case claims.Subject == "" || claims.SessionID == "":
+		return nil, fmt.Errorf("%w: missing subject or session", ErrInvalidToken)
+	}
+
+	return &claims, nil
+}
+
+// signingKey returns the current signing key, creating the very first key
+// if the database has none yet
+func (m *TokenManager) signingKey(ctx context.Context) (string, []byte, error) {
+	m.mu.RLock()
+	kid, secret, fresh := m.currentKID, m.keys[m.currentKID], time.Since(m.loadedAt) < keyCacheTTL
+	m.mu.RUnlock()
+	if kid != "" && fresh {
+		return kid, secret, nil
+	}
+
+	if err := m.loadKeys(ctx); err != nil {
+		return "", nil, err
+	}
+
+	m.mu.RLock()
+	kid, secret = m.currentKID, m.keys[m.currentKID]
+	m.mu.RUnlock()
+	if kid != "" {
+		return kid, secret, nil
+	}
+
+	if _, err := m.RotateSigningKey(ctx); err != nil {
+		return "", nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.currentKID, m.keys[m.currentKID], nil
+}
+
+// verificationKey returns the key with the given ID, reloading the key ring
+// once in case another instance rotated keys
+func (m *TokenManager) verificationKey(ctx context.Context, kid string) ([]byte, error) {
+	m.mu.RLock()
+	secret, ok := m.keys[kid]
+	recentlyLoaded := time.Since(m.loadedAt) < keyReloadThrottle
+	m.mu.RUnlock()
+	if ok {
+		return secret, nil
+	}
+	if recentlyLoaded {
+		return nil, fmt.Errorf("%w: unknown signing key", ErrInvalidToken)
+	}
+
+	if err := m.loadKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if secret, ok := m.keys[kid]; ok {
+		return secret, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key", ErrInvalidToken)
+}
+
+// loadKeys refreshes the cached key ring from the database
+func (m *TokenManager) loadKeys(ctx context.Context) error {
+	keys, err := m.store.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	ring := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		ring[k.KeyID] = k.Secret
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = ring
+	m.currentKID = ""
+	if len(keys) > 0 {
+		m.currentKID = keys[0].KeyID
+	}
+	m.loadedAt = time.Now()
+	return nil
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +16,88 @@ type Config struct {
 	GinMode     string
 	LogLevel    string
 	BuildVersion string
+
+	// FrontendURL is where the browser is sent back to after login
+	FrontendURL string
+	// PublicURL is the externally reachable base URL of this API, used in
+	// links it hands out such as calendar feeds
+	PublicURL string
+
+	// OpenID Connect identity provider settings
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCJWKSURL      string
+
+	// Bearer token settings
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// AdminEmails lists the accounts that receive admin tokens on login
+	AdminEmails []string
+
+	// WaitlistCutoff is how long before start_time waitlisted players stop
+	// being promoted automatically
+	WaitlistCutoff time.Duration
+
+	// InviteLinkSecret signs shareable invite link tokens
+	InviteLinkSecret string
+
+	// AttendanceLockWindow is how long after end_time hosts may mark attendance
+	AttendanceLockWindow time.Duration
+
+	// RatingEditWindow is how long after submission a peer rating can be changed
+	RatingEditWindow time.Duration
+	// RatingHourlyLimit caps the new peer ratings one user can submit per hour
+	RatingHourlyLimit int
+
+	// SeriesHorizon is how far ahead occurrences of recurring series are
+	// created as games
+	SeriesHorizon time.Duration
+	// SeriesMaterializeInterval is how often series create their occurrences
+	// up to the horizon; 0 disables the job
+	SeriesMaterializeInterval time.Duration
+
+	// GameLifecycleInterval is how often games are moved to in progress and
+	// completed as their start and end times pass; 0 disables the job
+	GameLifecycleInterval time.Duration
+
+	// GameEventHeartbeat is how often idle game event streams send a
+	// keep-alive and check for missed events; always positive
+	GameEventHeartbeat time.Duration
+	// GameEventRetention is how long game events are kept for reconnecting
+	// streams to resume from; 0 keeps them forever
+	GameEventRetention time.Duration
+
+	// NotificationInterval is how often game events are turned into
+	// notifications and pending emails and pushes are sent; 0 disables the job
+	NotificationInterval time.Duration
+	// SMTP relay notification emails are sent through; email is off without
+	// a host
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// VAPIDPrivateKey is the base64url-encoded P-256 key web push messages
+	// are signed with; push is off without it
+	VAPIDPrivateKey string
+	// VAPIDSubject is the mailto: or https: contact given to push services
+	VAPIDSubject string
+	// PushAllowedHosts are push service hosts subscriptions may point at over
+	// plain HTTP or at private addresses, e.g. a fake push service in tests
+	PushAllowedHosts []string
+	// ReminderOffsets are how long before start_time players are reminded
+	// of their games
+	ReminderOffsets []time.Duration
+
+	// JobPollInterval is how often the job worker checks the queue for due
+	// jobs; 0 disables the worker
+	JobPollInterval time.Duration
+	// JobMaxConcurrency caps the jobs one instance runs at once
+	JobMaxConcurrency int
+	// JobRetention is how long completed jobs are kept; 0 keeps them forever
+	JobRetention time.Duration
 }
 
 // New creates a new configuration instance with default values
@@ -23,6 +108,51 @@ func New() *Config {
 		GinMode:      getEnv("GIN_MODE", gin.ReleaseMode),
 		LogLevel:     getEnv("LOG_LEVEL", "info"),
 		BuildVersion: getEnv("BUILD_VERSION", "1.0.0"),
+
+		FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
+		PublicURL:   getEnv("PUBLIC_URL", "http://localhost:8080"),
+
+		OIDCIssuerURL:    getEnv("OIDC_ISSUER_URL", "https://accounts.google.com"),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", "http://localhost:8080/api/v1/google-login/callback"),
+		OIDCJWKSURL:      getEnv("OIDC_JWKS_URL", ""),
+
+		AccessTokenTTL:  getEnvAsDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvAsDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		AdminEmails:     getEnvAsList("ADMIN_EMAILS"),
+
+		WaitlistCutoff: getEnvAsDuration("WAITLIST_CUTOFF", time.Hour),
+
+		InviteLinkSecret: getEnv("INVITE_LINK_SECRET", ""),
+
+		AttendanceLockWindow: getEnvAsDuration("ATTENDANCE_LOCK_WINDOW", 72*time.Hour),
+
+		RatingEditWindow:  getEnvAsDuration("RATING_EDIT_WINDOW", 24*time.Hour),
+		RatingHourlyLimit: getEnvAsInt("RATING_HOURLY_LIMIT", 20),
+
+		SeriesHorizon:             getEnvAsDuration("SERIES_HORIZON", 8*7*24*time.Hour),
+		SeriesMaterializeInterval: getEnvAsDuration("SERIES_MATERIALIZE_INTERVAL", time.Hour),
+
+		GameLifecycleInterval: getEnvAsDuration("GAME_LIFECYCLE_INTERVAL", time.Minute),
+
+		GameEventHeartbeat: getEnvAsPositiveDuration("GAME_EVENT_HEARTBEAT", 15*time.Second),
+		GameEventRetention: getEnvAsDuration("GAME_EVENT_RETENTION", 7*24*time.Hour),
+
+		NotificationInterval: getEnvAsDuration("NOTIFICATION_INTERVAL", 10*time.Second),
+		SMTPHost:             getEnv("SMTP_HOST", ""),
+		SMTPPort:             getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername:         getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:         getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:             getEnv("SMTP_FROM", "Trego <no-reply@localhost>"),
+		VAPIDPrivateKey:      getEnv("VAPID_PRIVATE_KEY", ""),
+		VAPIDSubject:         getEnv("VAPID_SUBJECT", "mailto:admin@localhost"),
+		PushAllowedHosts:     getEnvAsList("PUSH_ALLOWED_HOSTS"),
+		ReminderOffsets:      getEnvAsDurationList("REMINDER_OFFSETS", []time.Duration{24 * time.Hour, time.Hour}),
+
+		JobPollInterval:   getEnvAsDuration("JOB_POLL_INTERVAL", time.Second),
+		JobMaxConcurrency: getEnvAsInt("JOB_MAX_CONCURRENCY", 10),
+		JobRetention:      getEnvAsDuration("JOB_RETENTION", 7*24*time.Hour),
 	}
 
 	return config
@@ -45,3 +175,72 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsDuration gets an environment variable as duration (e.g. "15m") with a fallback default value
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsPositiveDuration gets an environment variable as a positive duration with a fallback
+// default value; zero, negative and invalid values are ignored with a warning
+func getEnvAsPositiveDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Ignoring %s %q: not a positive duration, using the default %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
+// getEnvAsDurationList gets a comma-separated environment variable as a list of positive durations
+// (e.g. "24h,1h") with a fallback default value; invalid entries are ignored with a warning, and the
+// default is used if none is valid
+func getEnvAsDurationList(key string, defaultValue []time.Duration) []time.Duration {
+	values := getEnvAsList(key)
+	if len(values) == 0 {
+		return defaultValue
+	}
+	var durations []time.Duration
+	for _, value := range values {
+		if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+			durations = append(durations, duration)
+		} else {
+			log.Printf("Ignoring %s entry %q: not a positive duration", key, value)
+		}
+	}
+	if len(durations) == 0 {
+		log.Printf("No valid %s entries, using the default %v", key, defaultValue)
+		return defaultValue
+	}
+	return durations
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list of trimmed, non-empty values
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// IsAdminEmail reports whether the email belongs to a configured admin
+func (c *Config) IsAdminEmail(email string) bool {
+	for _, admin := range c.AdminEmails {
+		if strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
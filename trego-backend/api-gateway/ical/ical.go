@@ -0,0 +1,202 @@
+// Package ical renders iCalendar (RFC 5545) documents for calendar
+// subscriptions and downloads
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// ContentType is the media type of iCalendar documents
+	ContentType = "text/calendar; charset=utf-8"
+
+	prodID = "-//Trego//Trego Games//EN"
+	// maxLineOctets is where content lines are folded
+	maxLineOctets = 75
+
+	utcFormat   = "20060102T150405Z"
+	localFormat = "20060102T150405"
+)
+
+// Event statuses
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// Calendar is an iCalendar document
+type Calendar struct {
+	// Name is shown by calendar apps for subscribed calendars
+	Name string
+	// RefreshInterval hints how often subscribers should poll; 0 omits it
+	RefreshInterval time.Duration
+	Events          []Event
+}
+
+// Event is a VEVENT. Start and End are shown in Location: UTC times are
+// written as such, any other zone with a TZID and a matching VTIMEZONE.
+type Event struct {
+	UID         string
+	Start, End  time.Time
+	Location    *time.Location
+	Summary     string
+	Description string
+	Place       string
+	// Latitude and Longitude set GEO when both are present
+	Latitude, Longitude *float64
+	Status              string
+	Created             time.Time
+	LastModified        time.Time
+}
+
+// Encode renders the calendar with CRLF line endings and folded lines
+func (c *Calendar) Encode() []byte {
+	w := &writer{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + prodID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		w.line("X-WR-CALNAME:" + escapeText(c.Name))
+	}
+	if c.RefreshInterval > 0 {
+		w.line("REFRESH-INTERVAL;VALUE=DURATION:" + duration(c.RefreshInterval))
+		w.line("X-PUBLISHED-TTL:" + duration(c.RefreshInterval))
+	}
+
+	for _, tz := range c.timezones() {
+		writeTimezone(w, tz.loc, tz.from, tz.to)
+	}
+	for _, e := range c.Events {
+		writeEvent(w, e)
+	}
+
+	w.line("END:VCALENDAR")
+	return w.buf.Bytes()
+}
+
+// zoneRange is a time zone used by the events and the span it must cover
+type zoneRange struct {
+	loc      *time.Location
+	from, to time.Time
+}
+
+// timezones returns the non-UTC zones of the events in first use order
+func (c *Calendar) timezones() []zoneRange {
+	var zones []zoneRange
+	index := map[string]int{}
+	for _, e := range c.Events {
+		if isUTC(e.Location) {
+			continue
+		}
+		name := e.Location.String()
+		i, ok := index[name]
+		if !ok {
+			index[name] = len(zones)
+			zones = append(zones, zoneRange{loc: e.Location, from: e.Start, to: e.End})
+			continue
+		}
+		if e.Start.Before(zones[i].from) {
+			zones[i].from = e.Start
+		}
+		if e.End.After(zones[i].to) {
+			zones[i].to = e.End
+		}
+	}
+	return zones
+}
+
+// writeEvent writes a VEVENT
+func writeEvent(w *writer, e Event) {
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + escapeText(e.UID))
+	w.line("DTSTAMP:" + e.LastModified.UTC().Format(utcFormat))
+	w.line(dateTime("DTSTART", e.Start, e.Location))
+	w.line(dateTime("DTEND", e.End, e.Location))
+	w.line("SUMMARY:" + escapeText(e.Summary))
+	if e.Description != "" {
+		w.line("DESCRIPTION:" + escapeText(e.Description))
+	}
+	if e.Place != "" {
+		w.line("LOCATION:" + escapeText(e.Place))
+	}
+	if e.Latitude != nil && e.Longitude != nil {
+		w.line(fmt.Sprintf("GEO:%.6f;%.6f", *e.Latitude, *e.Longitude))
+	}
+	if e.Status != "" {
+		w.line("STATUS:" + e.Status)
+	}
+	if !e.Created.IsZero() {
+		w.line("CREATED:" + e.Created.UTC().Format(utcFormat))
+	}
+	w.line("LAST-MODIFIED:" + e.LastModified.UTC().Format(utcFormat))
+	w.line("END:VEVENT")
+}
+
+// dateTime renders a DATE-TIME property in loc
+func dateTime(name string, t time.Time, loc *time.Location) string {
+	if isUTC(loc) {
+		return name + ":" + t.UTC().Format(utcFormat)
+	}
+	return name + ";TZID=" + loc.String() + ":" + t.In(loc).Format(localFormat)
+}
+
+// isUTC reports whether times in loc are written in UTC
+func isUTC(loc *time.Location) bool {
+	return loc == nil || loc == time.UTC || loc.String() == "UTC"
+}
+
+// duration renders a positive duration as an iCalendar DURATION
+func duration(d time.Duration) string {
+	d = d.Round(time.Second)
+	out := "PT"
+	if h := d / time.Hour; h > 0 {
+		out += fmt.Sprintf("%dH", h)
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		out += fmt.Sprintf("%dM", m)
+		d -= m * time.Minute
+	}
+	if s := d / time.Second; s > 0 || out == "PT" {
+		out += fmt.Sprintf("%dS", s)
+	}
+	return out
+}
+
+// textEscaper escapes the characters TEXT values cannot hold as is
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escapeText escapes a TEXT value
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// writer accumulates content lines
+type writer struct {
+	buf bytes.Buffer
+}
+
+// line writes a content line, folding it after maxLineOctets octets without
+// splitting a UTF-8 character
+func (w *writer) line(s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.buf.WriteString(s[:cut])
+		w.buf.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with the folding space
+		limit = maxLineOctets - 1
+	}
+	w.buf.WriteString(s)
+	w.buf.WriteString("\r\n")
+}
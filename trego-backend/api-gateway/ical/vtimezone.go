@@ -0,0 +1,109 @@
+package ical
+
+import (
+	"fmt"
+	"time"
+)
+
+// transition is a change of a zone's UTC offset
+type transition struct {
+	at         time.Time
+	fromOffset int
+	toOffset   int
+	name       string
+	dst        bool
+}
+
+// writeTimezone writes a VTIMEZONE for loc with one observance per offset
+// change between the start of from's year and the end of to's, taken from
+// the Go time zone database, plus the observance in effect at the start.
+// Explicit observances avoid guessing RRULEs for historic or changed rules.
+func writeTimezone(w *writer, loc *time.Location, from, to time.Time) {
+	start := time.Date(from.In(loc).Year(), time.January, 1, 0, 0, 0, 0, loc)
+	end := time.Date(to.In(loc).Year()+1, time.January, 1, 0, 0, 0, 0, loc)
+
+	name, offset := start.Zone()
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + loc.String())
+	writeObservance(w, transition{
+		at:         start,
+		fromOffset: offset,
+		toOffset:   offset,
+		name:       name,
+		dst:        start.IsDST(),
+	})
+	for _, t := range zoneTransitions(loc, start, end) {
+		writeObservance(w, t)
+	}
+	w.line("END:VTIMEZONE")
+}
+
+// writeObservance writes a STANDARD or DAYLIGHT observance starting at the
+// transition. DTSTART is the local time before the change.
+func writeObservance(w *writer, t transition) {
+	kind := "STANDARD"
+	if t.dst {
+		kind = "DAYLIGHT"
+	}
+	local := t.at.UTC().Add(time.Duration(t.fromOffset) * time.Second)
+	w.line("BEGIN:" + kind)
+	w.line("DTSTART:" + local.Format(localFormat))
+	w.line("TZOFFSETFROM:" + utcOffset(t.fromOffset))
+	w.line("TZOFFSETTO:" + utcOffset(t.toOffset))
+	if t.name != "" {
+		w.line("TZNAME:" + escapeText(t.name))
+	}
+	w.line("END:" + kind)
+}
+
+// zoneTransitions finds the offset changes of loc in [start, end). Days are
+// scanned for a change, which is then located to the second.
+func zoneTransitions(loc *time.Location, start, end time.Time) []transition {
+	var transitions []transition
+	_, offset := start.In(loc).Zone()
+	for day := start; day.Before(end); {
+		next := day.Add(24 * time.Hour)
+		if _, nextOffset := next.In(loc).Zone(); nextOffset == offset {
+			day = next
+			continue
+		}
+
+		// The offset at lo is the old one and at hi the new one
+		lo, hi := day, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, midOffset := mid.In(loc).Zone(); midOffset == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		// Offsets change on whole seconds
+		at := hi.Truncate(time.Second).In(loc)
+		name, newOffset := at.Zone()
+		transitions = append(transitions, transition{
+			at:         at,
+			fromOffset: offset,
+			toOffset:   newOffset,
+			name:       name,
+			dst:        at.IsDST(),
+		})
+		offset = newOffset
+		day = hi
+	}
+	return transitions
+}
+
+// utcOffset renders an offset in seconds east of UTC as +hhmm or +hhmmss
+func utcOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	out := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+	if s := seconds % 60; s != 0 {
+		out += fmt.Sprintf("%02d", s)
+	}
+	return out
+}
@@ -5,3 +5,15 @@ const GinContextLoggerKey = "logger"
 
 // GinContextTraceIDKey is the key used to store trace ID in Gin context
 const GinContextTraceIDKey = "trace_id"
+
+// GinContextUserIDKey is the key used to store the authenticated user ID in Gin context
+const GinContextUserIDKey = "user_id"
+
+// GinContextSessionIDKey is the key used to store the authenticated session ID in Gin context
+const GinContextSessionIDKey = "session_id"
+
+// GinContextIsAdminKey is the key used to store whether the caller is an admin in Gin context
+const GinContextIsAdminKey = "is_admin"
+
+// GinContextAuthErrorKey is the key used to store why a bearer token was rejected in Gin context
+const GinContextAuthErrorKey = "auth_error"
@@ -0,0 +1,116 @@
+package ginmiddleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/internal/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewAuthMiddleware creates a middleware that authenticates the bearer token
+// from the Authorization header and stores the caller in context.
+// Requests without a token, or with a malformed, expired or revoked one,
+// continue anonymously so public routes keep working; RequireAuth rejects them
+// with the reason the token was not accepted.
+func NewAuthMiddleware(tokens *auth.TokenManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Request.Header.Get(constant.AuthHeader)
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			c.Set(constant.GinContextAuthErrorKey, "malformed authorization header")
+			c.Next()
+			return
+		}
+
+		claims, err := tokens.VerifyAccessToken(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrSessionRevoked) {
+				c.Set(constant.GinContextAuthErrorKey, "invalid or expired token")
+				c.Next()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "failed to verify token"})
+			return
+		}
+
+		c.Set(constant.GinContextUserIDKey, claims.Subject)
+		c.Set(constant.GinContextSessionIDKey, claims.SessionID)
+		c.Set(constant.GinContextIsAdminKey, claims.Admin)
+		c.Next()
+	}
+}
+
+// RequireAuth rejects requests that were not authenticated by the auth middleware
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetUserIDFromContext(c) == "" {
+			abortUnauthenticated(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests whose caller is not an admin
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetUserIDFromContext(c) == "" {
+			abortUnauthenticated(c)
+			return
+		}
+		if !IsAdminFromContext(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// abortUnauthenticated responds 401, saying why the bearer token was rejected
+// if the request sent one
+func abortUnauthenticated(c *gin.Context) {
+	message := "authentication required"
+	if reason, ok := c.Get(constant.GinContextAuthErrorKey); ok {
+		message = reason.(string)
+	}
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+}
+
+// GetUserIDFromContext retrieves the authenticated user ID from Gin context
+func GetUserIDFromContext(c *gin.Context) string {
+	if userID, exists := c.Get(constant.GinContextUserIDKey); exists {
+		if id, ok := userID.(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// GetSessionIDFromContext retrieves the authenticated session ID from Gin context
+func GetSessionIDFromContext(c *gin.Context) string {
+	if sessionID, exists := c.Get(constant.GinContextSessionIDKey); exists {
+		if id, ok := sessionID.(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// IsAdminFromContext reports whether the authenticated caller is an admin
+func IsAdminFromContext(c *gin.Context) bool {
+	if isAdmin, exists := c.Get(constant.GinContextIsAdminKey); exists {
+		if admin, ok := isAdmin.(bool); ok {
+			return admin
+		}
+	}
+	return false
+}
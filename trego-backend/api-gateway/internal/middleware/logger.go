@@ -12,6 +12,9 @@ func NewLoggerMiddleware(log logger.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		traceID := GetTraceIDFromContext(c)
 		entry := log.WithField("trace_id", traceID)
+		if userID := GetUserIDFromContext(c); userID != "" {
+			entry = entry.WithField("user_id", userID)
+		}
 		c.Set(constant.GinContextLoggerKey, entry)
 		c.Next()
 	}
@@ -4,6 +4,7 @@ package ginmiddleware
 // Individual middleware functions have been moved to separate files for better organization:
 // - logger.go: Context-based logger middleware
 // - trace_id.go: Trace ID generation and retrieval (using UUID)
+// - auth.go: Bearer token authentication and route guards
 // - cors.go: CORS middleware
 // - recovery.go: Panic recovery middleware
 // - ordered_middleware.go: Middleware ordering and setup
@@ -1,15 +1,17 @@
 package ginmiddleware
 
 import (
+	"trego-backend/api-gateway/auth"
 	"trego-backend/api-gateway/logger"
 
 	"github.com/gin-gonic/gin"
 )
 
 // GetOrderedMiddleware returns middleware in the correct order
-func GetOrderedMiddleware(logger logger.Logger) []gin.HandlerFunc {
+func GetOrderedMiddleware(logger logger.Logger, tokens *auth.TokenManager) []gin.HandlerFunc {
 	return []gin.HandlerFunc{
 		NewTraceIDMiddleware(),
+		NewAuthMiddleware(tokens),
 		NewLoggerMiddleware(logger),
 		// RecoveryMiddleware(),
 		// CORSMiddleware(),
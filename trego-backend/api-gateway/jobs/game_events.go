@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"trego-backend/api-gateway/logger"
+	"trego-backend/repository"
+)
+
+// RunGameEventPruning deletes game events older than retention, once right
+// away and then every interval until ctx is done. Streams resuming from a
+// pruned event are told to reload instead.
+func RunGameEventPruning(ctx context.Context, events *repository.GameEventRepository, interval, retention time.Duration, log logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := events.Prune(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Error("Failed to prune game events", logger.Field{Key: "error", Value: err.Error()})
+		} else if pruned > 0 {
+			log.Info("Game events pruned", logger.Field{Key: "pruned", Value: pruned})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"trego-backend/api-gateway/logger"
+	"trego-backend/repository"
+)
+
+// RunGameLifecycle moves games to in progress and completed as their start
+// and end times pass, once right away and then every interval until ctx is
+// done. Every instance may run it; the transitions are idempotent.
+func RunGameLifecycle(ctx context.Context, games *repository.GameRepository, interval time.Duration, log logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		started, completed, err := games.AdvanceLifecycle(ctx)
+		if err != nil {
+			log.Error("Failed to advance game lifecycle", logger.Field{Key: "error", Value: err.Error()})
+		} else if started > 0 || completed > 0 {
+			log.Info("Game lifecycle advanced",
+				logger.Field{Key: "started", Value: started},
+				logger.Field{Key: "completed", Value: completed})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"trego-backend/api-gateway/logger"
+	"trego-backend/api-gateway/notify"
+	"trego-backend/api-gateway/queue"
+	"trego-backend/repository"
+)
+
+// RunNotifications turns new game events into notifications, once right
+// away and then every interval until ctx is done. Every instance may run it:
+// events are claimed so each is handled once. The notifications are sent by
+// notification delivery jobs, and game reminders are jobs of their own.
+func RunNotifications(ctx context.Context, notifier *notify.Notifier, interval time.Duration, log logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		made, err := notifier.ProcessEvents(ctx)
+		if err != nil {
+			log.Error("Failed to create notifications", logger.Field{Key: "error", Value: err.Error()})
+		} else if made > 0 {
+			log.Info("Notifications created", logger.Field{Key: "notifications", Value: made})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScheduleGameReminders queues the reminder jobs of every upcoming game that
+// are not queued or sent yet, e.g. at offsets added to the configuration
+// since the games were created. It runs once, on start.
+func ScheduleGameReminders(ctx context.Context, notifier *notify.Notifier, log logger.Logger) {
+	scheduled, err := notifier.ScheduleReminders(ctx)
+	if err != nil {
+		log.Error("Failed to schedule game reminders", logger.Field{Key: "error", Value: err.Error()})
+		return
+	}
+	log.Info("Game reminders scheduled", logger.Field{Key: "games", Value: scheduled})
+}
+
+// SendGameReminder returns the handler of game reminder jobs
+func SendGameReminder(notifier *notify.Notifier, log logger.Logger) queue.Handler[repository.GameReminderJob] {
+	return func(ctx context.Context, job queue.Job[repository.GameReminderJob]) error {
+		made, err := notifier.SendReminder(ctx, job.Args)
+		if err != nil {
+			return err
+		}
+		if made > 0 {
+			log.Info("Game reminder sent",
+				logger.Field{Key: "game_id", Value: job.Args.GameID},
+				logger.Field{Key: "notifications", Value: made})
+		}
+		return nil
+	}
+}
+
+// DeliverNotification returns the handler of notification delivery jobs.
+// Failed sends are retried by the delivery's own backoff, so the job only
+// fails when the delivery could not be claimed or recorded.
+func DeliverNotification(notifier *notify.Notifier) queue.Handler[repository.NotificationDeliveryJob] {
+	return func(ctx context.Context, job queue.Job[repository.NotificationDeliveryJob]) error {
+		return notifier.Deliver(ctx, job.Args)
+	}
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"trego-backend/api-gateway/queue"
+	"trego-backend/repository"
+)
+
+// ReputationRecomputeArgs queue a rebuild of users.reputation from the
+// reputation ledger, of one user or, without a user, of everyone
+type ReputationRecomputeArgs struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+// Kind names the reputation recompute job
+func (ReputationRecomputeArgs) Kind() string { return "reputation_recompute" }
+
+// UniqueKey keeps one recompute of the same scope queued at a time
+func (a ReputationRecomputeArgs) UniqueKey() string {
+	if a.UserID == "" {
+		return "all"
+	}
+	return "user:" + a.UserID
+}
+
+// RecomputeReputation returns the handler of reputation recompute jobs
+func RecomputeReputation(reputation *repository.ReputationRepository) queue.Handler[ReputationRecomputeArgs] {
+	return func(ctx context.Context, job queue.Job[ReputationRecomputeArgs]) error {
+		if job.Args.UserID == "" {
+			_, err := reputation.RecomputeAll(ctx)
+			return err
+		}
+		err := reputation.Recompute(ctx, job.Args.UserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return queue.Permanent(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"trego-backend/api-gateway/logger"
+	"trego-backend/repository"
+)
+
+// RunSeriesMaterialization creates the upcoming occurrences of every
+// recurring series up to the series horizon, once right away and then every
+// interval until ctx is done, so series keep creating games as time passes.
+// Every instance may run it; series are locked while their occurrences are
+// created.
+func RunSeriesMaterialization(ctx context.Context, series *repository.SeriesRepository, interval time.Duration, log logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		created, err := series.MaterializeAll(ctx)
+		if err != nil {
+			log.Error("Failed to materialize series",
+				logger.Field{Key: "created", Value: created},
+				logger.Field{Key: "error", Value: err.Error()})
+		} else if created > 0 {
+			log.Info("Series occurrences created", logger.Field{Key: "created", Value: created})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Package notify turns game events into inbox notifications and delivers
+// them by email and web push
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"trego-backend/repository"
+)
+
+// ErrNoAddress is returned by channels that have nowhere to reach the
+// recipient, e.g. a user without push subscriptions
+var ErrNoAddress = errors.New("recipient has no address on this channel")
+
+// Channel delivers notifications outside the app
+type Channel interface {
+	// Name is the channel's name, a models.NotificationChannel* constant
+	Name() string
+	// Send delivers the message to the recipient, or returns ErrNoAddress
+	// if the recipient cannot be reached on the channel
+	Send(ctx context.Context, to Recipient, msg Message) error
+}
+
+// Recipient is the user a message is sent to
+type Recipient struct {
+	UserID string
+	Name   string
+	Email  string
+	// PushEndpoints are the user's browsers registered for web push
+	PushEndpoints []repository.PushEndpoint
+}
+
+// Message is a notification rendered for a channel. Push messages carry it
+// as JSON for the service worker to show.
+type Message struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// URL is the page the notification links to, if any
+	URL string `json:"url,omitempty"`
+}
@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"trego-backend/models"
+)
+
+// smtpTimeout bounds the whole exchange with the relay for one email
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfig holds the settings of an SMTP relay
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password authenticate with PLAIN auth when Username is
+	// set; net/smtp only sends them over TLS or to localhost
+	Username string
+	Password string
+	// From is the sender address, e.g. "Trego <no-reply@example.com>"
+	From string
+}
+
+// SMTPChannel sends notifications as plain-text emails through an SMTP
+// relay. STARTTLS is used when the server offers it, so a local SMTP sink
+// without TLS works too. Each email must be sent within smtpTimeout.
+type SMTPChannel struct {
+	conf SMTPConfig
+}
+
+// NewSMTPChannel creates an email channel sending through the relay
+func NewSMTPChannel(conf SMTPConfig) *SMTPChannel {
+	return &SMTPChannel{conf: conf}
+}
+
+// Name returns the email channel's name
+func (c *SMTPChannel) Name() string {
+	return models.NotificationChannelEmail
+}
+
+// Send emails the message to the recipient
+func (c *SMTPChannel) Send(ctx context.Context, to Recipient, msg Message) error {
+	if to.Email == "" {
+		return ErrNoAddress
+	}
+	from, err := mail.ParseAddress(c.conf.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address %q: %w", c.conf.From, err)
+	}
+
+	content, err := emailContent(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	return c.sendMail(ctx, from.Address, to.Email, content)
+}
+
+// sendMail delivers one email through the relay, as smtp.SendMail does,
+// on a connection whose deadline is the context's. Cancelling the context
+// interrupts the exchange.
+func (c *SMTPChannel) sendMail(ctx context.Context, from, to string, content []byte) error {
+	addr := net.JoinHostPort(c.conf.Host, strconv.Itoa(c.conf.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	client, err := smtp.NewClient(conn, c.conf.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.conf.Host}); err != nil {
+			return err
+		}
+	}
+	if c.conf.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(smtp.PlainAuth("", c.conf.Username, c.conf.Password, c.conf.Host)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// emailContent renders the message as a plain-text UTF-8 email
+func emailContent(from *mail.Address, to Recipient, msg Message) ([]byte, error) {
+	recipient := mail.Address{Name: to.Name, Address: to.Email}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", recipient.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	text := msg.Body + "\r\n"
+	if msg.URL != "" {
+		text += "\r\n" + msg.URL + "\r\n"
+	}
+	if _, err := body.Write([]byte(text)); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
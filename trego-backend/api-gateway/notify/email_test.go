@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sinkMail is an email received by the SMTP sink
+type sinkMail struct {
+	from string
+	to   string
+	data string
+}
+
+// startSMTPSink runs a local SMTP server without TLS or auth that accepts
+// every email, like MailHog, and returns its port and the received emails
+func startSMTPSink(t *testing.T) (int, <-chan sinkMail) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan sinkMail, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, received)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, received
+}
+
+// serveSMTP answers one SMTP session of the sink
+func serveSMTP(conn net.Conn, received chan<- sinkMail) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 sink ESMTP")
+
+	var m sinkMail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			text.PrintfLine("250-sink")
+			text.PrintfLine("250 8BITMIME")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			m.from = envelopeAddress(line)
+			text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			m.to = envelopeAddress(line)
+			text.PrintfLine("250 OK")
+		case command == "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			m.data = string(data)
+			text.PrintfLine("250 OK")
+			received <- m
+		case command == "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// envelopeAddress returns the address in the angle brackets of a MAIL FROM
+// or RCPT TO command
+func envelopeAddress(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func TestSMTPChannelSend(t *testing.T) {
+	port, received := startSMTPSink(t)
+	channel := NewSMTPChannel(SMTPConfig{Host: "127.0.0.1", Port: port, From: "Trego <no-reply@trego.example>"})
+
+	to := Recipient{UserID: "user-1", Name: "Ana", Email: "ana@example.com"}
+	msg := Message{Title: "Game at Café cancelled", Body: "Sunday's game is off.", URL: "https://trego.example/games/1"}
+	if err := channel.Send(context.Background(), to, msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var got sinkMail
+	select {
+	case got = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The sink received no email")
+	}
+	if got.from != "no-reply@trego.example" || got.to != "ana@example.com" {
+		t.Errorf("envelope = %q -> %q, want no-reply@trego.example -> ana@example.com", got.from, got.to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("Failed to parse the email: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Title {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, msg.Title)
+	}
+	if recipient := parsed.Header.Get("To"); !strings.Contains(recipient, "<ana@example.com>") {
+		t.Errorf("To = %q, want Ana's address", recipient)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if err != nil {
+		t.Fatalf("Failed to decode the body: %v", err)
+	}
+	if !strings.Contains(string(body), msg.Body) || !strings.Contains(string(body), msg.URL) {
+		t.Errorf("body = %q, want the message and its URL", body)
+	}
+}
+
+func TestSMTPChannelSendWithoutAddress(t *testing.T) {
+	channel := NewSMTPChannel(SMTPConfig{Host: "127.0.0.1", Port: 25, From: "no-reply@trego.example"})
+	err := channel.Send(context.Background(), Recipient{UserID: "user-1"}, Message{Title: "Hi"})
+	if !errors.Is(err, ErrNoAddress) {
+		t.Errorf("Send = %v, want ErrNoAddress", err)
+	}
+}
+
+func TestSMTPChannelSendToSilentRelay(t *testing.T) {
+	// The relay accepts connections but never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	channel := NewSMTPChannel(SMTPConfig{
+		Host: "127.0.0.1",
+		Port: listener.Addr().(*net.TCPAddr).Port,
+		From: "no-reply@trego.example",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = channel.Send(ctx, Recipient{Email: "ana@example.com"}, Message{Title: "Hi", Body: "Hello"})
+	if err == nil {
+		t.Fatal("Send to a silent relay succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Send gave up after %s, want the context's deadline", elapsed)
+	}
+}
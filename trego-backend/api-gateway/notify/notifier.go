@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trego-backend/api-gateway/config"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+)
+
+// eventBatch is how many game events are turned into notifications per
+// transaction
+const eventBatch = 100
+
+// Notifier turns game events into inbox notifications and delivers them on
+// the configured channels
+type Notifier struct {
+	notifications *repository.NotificationRepository
+	channels      map[string]Channel
+	frontendURL   string
+	log           logger.Logger
+	// reminderOffsets are how long before start_time players are reminded
+	// of games
+	reminderOffsets []time.Duration
+}
+
+// NewNotifier creates a notifier delivering on the given channels.
+// frontendURL is the base of the game links in notifications.
+func NewNotifier(notifications *repository.NotificationRepository, frontendURL string, log logger.Logger, channels ...Channel) *Notifier {
+	n := &Notifier{
+		notifications: notifications,
+		channels:      make(map[string]Channel),
+		frontendURL:   strings.TrimRight(frontendURL, "/"),
+		log:           log,
+	}
+	for _, channel := range channels {
+		n.channels[channel.Name()] = channel
+	}
+	return n
+}
+
+// NewNotifierFromConfig creates a notifier delivering by email when an SMTP
+// host is configured and by web push when a VAPID key is
+func NewNotifierFromConfig(conf *config.Config, notifications *repository.NotificationRepository, log logger.Logger) (*Notifier, error) {
+	var channels []Channel
+	if conf.SMTPHost != "" {
+		channels = append(channels, NewSMTPChannel(SMTPConfig{
+			Host:     conf.SMTPHost,
+			Port:     conf.SMTPPort,
+			Username: conf.SMTPUsername,
+			Password: conf.SMTPPassword,
+			From:     conf.SMTPFrom,
+		}))
+	}
+	if conf.VAPIDPrivateKey != "" {
+		push, err := NewWebPushChannel(conf.VAPIDPrivateKey, conf.VAPIDSubject, conf.PushAllowedHosts)
+		if err != nil {
+			return nil, err
+		}
+		push.Gone = func(ctx context.Context, endpoint string) {
+			if err := notifications.DeletePushEndpoint(ctx, endpoint); err != nil {
+				log.Error("Failed to delete gone push subscription", logger.Field{Key: "error", Value: err.Error()})
+			}
+		}
+		channels = append(channels, push)
+	}
+	return NewNotifier(notifications, conf.FrontendURL, log, channels...).WithReminderOffsets(conf.ReminderOffsets), nil
+}
+
+// WithReminderOffsets sets how long before start_time players are reminded
+// of games, and returns the notifier
+func (n *Notifier) WithReminderOffsets(offsets []time.Duration) *Notifier {
+	n.reminderOffsets = offsets
+	return n
+}
+
+// ProcessEvents turns every pending game event into notifications and
+// returns how many notifications it made
+func (n *Notifier) ProcessEvents(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		handled, made, err := n.notifications.HandleGameEvents(ctx, eventBatch, n.channelNames(), compose)
+		total += made
+		if err != nil || handled < eventBatch {
+			return total, err
+		}
+	}
+}
+
+// SendReminder notifies the players of the game of a reminder job and
+// returns how many notifications it made
+func (n *Notifier) SendReminder(ctx context.Context, job repository.GameReminderJob) (int64, error) {
+	return n.notifications.SendGameReminder(ctx, job, n.reminderOffsets, n.channelNames(), composeReminder)
+}
+
+// ScheduleReminders enqueues the reminders of every upcoming game at the
+// configured offsets that are not queued or sent yet, and returns how many
+// games it scheduled
+func (n *Notifier) ScheduleReminders(ctx context.Context) (int, error) {
+	return n.notifications.ScheduleReminders(ctx, n.reminderOffsets)
+}
+
+// channelNames returns the names of the configured channels
+func (n *Notifier) channelNames() []string {
+	names := make([]string, 0, len(n.channels))
+	for name := range n.channels {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Deliver sends the delivery of a delivery job if it is due
+func (n *Notifier) Deliver(ctx context.Context, job repository.NotificationDeliveryJob) error {
+	return n.notifications.DeliverNotification(ctx, job, func(d repository.PendingDelivery) repository.DeliveryOutcome {
+		return n.send(ctx, d)
+	})
+}
+
+// send delivers one notification on its channel, unless the user turned the
+// channel off or is in their quiet hours
+func (n *Notifier) send(ctx context.Context, d repository.PendingDelivery) repository.DeliveryOutcome {
+	channel, ok := n.channels[d.Channel]
+	if !ok {
+		return repository.DeliveryOutcome{Skipped: true, Err: fmt.Errorf("%s notifications are not configured", d.Channel)}
+	}
+	if (d.Channel == models.NotificationChannelEmail && !d.Preferences.EmailEnabled) ||
+		(d.Channel == models.NotificationChannelPush && !d.Preferences.PushEnabled) {
+		return repository.DeliveryOutcome{Skipped: true}
+	}
+	if until := quietUntil(d.Preferences, time.Now()); until != nil {
+		return repository.DeliveryOutcome{DeferUntil: until}
+	}
+
+	msg := Message{Title: d.Notification.Title, Body: d.Notification.Body}
+	if d.Notification.GameID != nil {
+		msg.URL = n.frontendURL + "/games/" + *d.Notification.GameID
+	}
+	to := Recipient{UserID: d.UserID, Name: d.Name, Email: d.Email, PushEndpoints: d.PushEndpoints}
+	if err := channel.Send(ctx, to, msg); err != nil {
+		if errors.Is(err, ErrNoAddress) {
+			return repository.DeliveryOutcome{Skipped: true, Err: err}
+		}
+		n.log.Warn("Failed to send notification",
+			logger.Field{Key: "notification_id", Value: d.Notification.NotificationID},
+			logger.Field{Key: "channel", Value: d.Channel},
+			logger.Field{Key: "attempt", Value: d.Attempts + 1},
+			logger.Field{Key: "error", Value: err.Error()})
+		outcome := repository.DeliveryOutcome{Err: err}
+		var pushErr *PushError
+		if errors.As(err, &pushErr) {
+			outcome.Pushed = pushErr.Delivered
+		}
+		return outcome
+	}
+	return repository.DeliveryOutcome{}
+}
+
+// quietUntil returns when the quiet hours that now falls in end, or nil
+// outside quiet hours. Quiet hours starting after they end span midnight.
+func quietUntil(prefs models.NotificationPreferences, now time.Time) *time.Time {
+	if prefs.QuietHoursStart == nil || prefs.QuietHoursEnd == nil {
+		return nil
+	}
+	start, errStart := time.Parse("15:04", *prefs.QuietHoursStart)
+	end, errEnd := time.Parse("15:04", *prefs.QuietHoursEnd)
+	if errStart != nil || errEnd != nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	var endsTomorrow bool
+	switch {
+	case startMinute < endMinute:
+		if minute < startMinute || minute >= endMinute {
+			return nil
+		}
+	case minute >= startMinute:
+		endsTomorrow = true
+	case minute >= endMinute:
+		return nil
+	}
+
+	day := local
+	if endsTomorrow {
+		day = day.AddDate(0, 0, 1)
+	}
+	until := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+	return &until
+}
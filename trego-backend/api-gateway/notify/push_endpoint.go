@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), internal to
+// providers like the private ranges
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// errPrivateAddress is returned when a push endpoint resolves to an address
+// that is not on the public internet
+var errPrivateAddress = errors.New("push endpoint resolves to a non-public address")
+
+// CheckPushEndpoint checks that a push subscription's endpoint is an https
+// URL that does not name a loopback, private or link-local address. Hosts
+// in allowedHosts pass as they are, e.g. a fake push service in tests.
+// Names are resolved only when messages are sent, where the dialer checks
+// the addresses again.
+func CheckPushEndpoint(endpoint string, allowedHosts []string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return errors.New("must be an absolute URL")
+	}
+	host := u.Hostname()
+	if hostAllowed(host, allowedHosts) {
+		return nil
+	}
+	if u.Scheme != "https" {
+		return errors.New("must be an https URL")
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("must not point at a loopback address")
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !publicAddr(ip) {
+		return errors.New("must not point at a loopback, private or link-local address")
+	}
+	return nil
+}
+
+// hostAllowed reports whether host is in allowedHosts, ignoring case
+func hostAllowed(host string, allowedHosts []string) bool {
+	for _, allowed := range allowedHosts {
+		if strings.EqualFold(allowed, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// publicAddr reports whether ip is a unicast address on the public internet
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// newPushClient returns the HTTP client push messages are sent with. It
+// connects directly, without a proxy, and refuses to dial non-public
+// addresses unless the host is in allowedHosts, so a name resolving to an
+// internal address is caught after the subscription was checked. Redirects
+// are not followed.
+func newPushClient(allowedHosts []string) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	publicDialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddr(addrPort.Addr()) {
+				return fmt.Errorf("dial %s: %w", address, errPrivateAddress)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err == nil && hostAllowed(host, allowedHosts) {
+			return dialer.DialContext(ctx, network, address)
+		}
+		return publicDialer.DialContext(ctx, network, address)
+	}
+
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
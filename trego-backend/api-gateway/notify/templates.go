@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+
+	"trego-backend/models"
+	"trego-backend/repository"
+)
+
+// timeFormat is how game times are written in notifications, in the time
+// zone the game is played in
+const timeFormat = "Mon 2 Jan 15:04 MST"
+
+// notificationTemplate renders the title and body of the notifications of
+// one event type
+type notificationTemplate struct {
+	title *template.Template
+	body  *template.Template
+}
+
+// templateData is what notification templates are executed with
+type templateData struct {
+	Game models.CalendarGame
+	// Player is the name of the player a roster event is about
+	Player string
+	// Start and End are the game's times in its time zone
+	Start string
+	End   string
+	// Reason describes why a cancelled game was cancelled
+	Reason string
+	// In is how soon a game a reminder is about starts, e.g. "1 hour"
+	In string
+}
+
+// templates are the notification templates by game event type
+var templates = map[string]notificationTemplate{
+	models.GameEventPlayerJoined: newTemplate(
+		`{{.Player}} joined {{.Game.Title}}`,
+		`{{.Player}} joined your game on {{.Start}}. {{.Game.PlayerCount}} of {{.Game.Capacity}} spots are taken.`),
+	models.GameEventPlayerLeft: newTemplate(
+		`{{.Player}} left {{.Game.Title}}`,
+		`{{.Player}} left your game on {{.Start}}. {{.Game.PlayerCount}} of {{.Game.Capacity}} spots are taken.`),
+	models.GameEventPlayerPromoted: newTemplate(
+		`You're in: {{.Game.Title}}`,
+		`A spot opened up and you moved off the waitlist for {{.Game.Title}} on {{.Start}} at {{.Game.Location}}.`),
+	models.GameEventGameCancelled: newTemplate(
+		`{{.Game.Title}} is cancelled`,
+		`{{.Game.Title}} on {{.Start}} was cancelled{{with .Reason}} because {{.}}{{end}}.`+
+			`{{with .Game.CancellationNote}} "{{.}}"{{end}}`),
+	models.GameEventGameRescheduled: newTemplate(
+		`{{.Game.Title}} was rescheduled`,
+		`{{.Game.Title}} now takes place from {{.Start}} to {{.End}} at {{.Game.Location}}.`),
+}
+
+// reminderTemplate renders the reminders sent before games
+var reminderTemplate = newTemplate(
+	`{{.Game.Title}} starts in {{.In}}`,
+	`Reminder: {{.Game.Title}} starts {{.Start}} at {{.Game.Location}}. {{.Game.PlayerCount}} of {{.Game.Capacity}} spots are taken.`)
+
+// cancellationReasons describe the reasons hosts cancel games for
+var cancellationReasons = map[string]string{
+	models.CancellationHostUnavailable:  "the host is unavailable",
+	models.CancellationNotEnoughPlayers: "not enough players signed up",
+	models.CancellationWeather:          "of the weather",
+	models.CancellationVenueUnavailable: "the venue is unavailable",
+	models.CancellationScheduleChanged:  "the schedule changed",
+}
+
+// newTemplate parses the title and body templates of an event type
+func newTemplate(title, body string) notificationTemplate {
+	return notificationTemplate{
+		title: template.Must(template.New("title").Parse(title)),
+		body:  template.Must(template.New("body").Parse(body)),
+	}
+}
+
+// compose renders the notifications of a game event
+func compose(notice repository.GameEventNotice) (repository.NotificationContent, error) {
+	tmpl, ok := templates[notice.Event.Type]
+	if !ok {
+		return repository.NotificationContent{}, fmt.Errorf("no notification template for %s events", notice.Event.Type)
+	}
+
+	data := gameTemplateData(notice.Game)
+	data.Player = notice.ActorName
+	if notice.Game.CancellationReason != nil {
+		data.Reason = cancellationReasons[*notice.Game.CancellationReason]
+	}
+	if data.Player == "" {
+		data.Player = "A player"
+	}
+
+	return render(tmpl, data)
+}
+
+// composeReminder renders the reminders of a game. The time left is
+// measured now, as reminders may go out late after downtime.
+func composeReminder(notice repository.GameReminderNotice) (repository.NotificationContent, error) {
+	data := gameTemplateData(notice.Game)
+	data.In = untilText(time.Until(notice.Game.StartTime))
+	return render(reminderTemplate, data)
+}
+
+// gameTemplateData returns the template data describing a game
+func gameTemplateData(game models.CalendarGame) templateData {
+	loc, err := time.LoadLocation(game.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return templateData{
+		Game:  game,
+		Start: game.StartTime.In(loc).Format(timeFormat),
+		End:   game.EndTime.In(loc).Format(timeFormat),
+	}
+}
+
+// render executes a notification template
+func render(tmpl notificationTemplate, data templateData) (repository.NotificationContent, error) {
+	var title, body bytes.Buffer
+	if err := tmpl.title.Execute(&title, data); err != nil {
+		return repository.NotificationContent{}, err
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return repository.NotificationContent{}, err
+	}
+	return repository.NotificationContent{Title: title.String(), Body: body.String()}, nil
+}
+
+// untilText writes a time left in minutes, or in whole hours from 90
+// minutes or when it is within 5 minutes of a whole hour
+func untilText(d time.Duration) string {
+	minutes := max(int(d.Round(time.Minute)/time.Minute), 1)
+	hours := (minutes + 30) / 60
+	if minutes >= 90 || (hours > 0 && minutes >= hours*60-5 && minutes <= hours*60+5) {
+		return plural(hours, "hour")
+	}
+	return plural(minutes, "minute")
+}
+
+// plural writes a count of a unit
+func plural(count int, unit string) string {
+	if count == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", count, unit)
+}
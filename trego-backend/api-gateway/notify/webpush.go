@@ -0,0 +1,272 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"trego-backend/models"
+	"trego-backend/repository"
+)
+
+const (
+	// pushRecordSize is the aes128gcm record size advertised to push services
+	pushRecordSize = 4096
+	// pushTTL is how long push services keep a message for an offline browser
+	pushTTL = 24 * time.Hour
+	// vapidTokenTTL is the lifetime of the VAPID tokens sent with messages
+	vapidTokenTTL = 12 * time.Hour
+)
+
+// WebPushChannel sends notifications to browsers with the Web Push protocol
+// (RFC 8030), encrypting them for each subscription (RFC 8291) and
+// identifying the server with VAPID (RFC 8292). Subscriptions the push
+// service reports gone are handed to Gone. Endpoints must pass
+// CheckPushEndpoint and only public addresses are dialed, unless their host
+// is allowed.
+type WebPushChannel struct {
+	key          *ecdsa.PrivateKey
+	subject      string
+	allowedHosts []string
+	client       *http.Client
+	// Gone is called with endpoints that no longer exist, if set
+	Gone func(ctx context.Context, endpoint string)
+}
+
+// NewWebPushChannel creates a push channel signing with the VAPID private
+// key, a base64url-encoded P-256 scalar as generated by the web-push tools.
+// subject is a mailto: or https: URL push services can reach the operator at.
+// allowedHosts are push service hosts exempt from the endpoint checks.
+func NewWebPushChannel(privateKey, subject string, allowedHosts []string) (*WebPushChannel, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(privateKey, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	return &WebPushChannel{
+		key:          key,
+		subject:      subject,
+		allowedHosts: allowedHosts,
+		client:       newPushClient(allowedHosts),
+	}, nil
+}
+
+// PublicKey returns the base64url-encoded VAPID public key browsers pass as
+// applicationServerKey when subscribing
+func (c *WebPushChannel) PublicKey() string {
+	public, _ := c.key.PublicKey.Bytes()
+	return base64.RawURLEncoding.EncodeToString(public)
+}
+
+// Name returns the push channel's name
+func (c *WebPushChannel) Name() string {
+	return models.NotificationChannelPush
+}
+
+// Send pushes the message to every browser of the recipient. It succeeds
+// once each of them accepted it or is gone; otherwise the error is a
+// *PushError naming the browsers that got it.
+func (c *WebPushChannel) Send(ctx context.Context, to Recipient, msg Message) error {
+	if len(to.PushEndpoints) == 0 {
+		return ErrNoAddress
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	var delivered []string
+	for _, endpoint := range to.PushEndpoints {
+		err := c.push(ctx, endpoint, payload)
+		switch {
+		case err == nil:
+			delivered = append(delivered, endpoint.Endpoint)
+		case errors.Is(err, errPushGone):
+			if c.Gone != nil {
+				c.Gone(ctx, endpoint.Endpoint)
+			}
+		default:
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return &PushError{Delivered: delivered, Err: errors.Join(errs...)}
+	}
+	if len(delivered) == 0 {
+		// Every subscription was gone
+		return ErrNoAddress
+	}
+	return nil
+}
+
+// PushError is returned when a message could not be pushed to some of the
+// recipient's browsers. Retries should skip the Delivered endpoints, which
+// already got it.
+type PushError struct {
+	Delivered []string
+	Err       error
+}
+
+func (e *PushError) Error() string { return e.Err.Error() }
+func (e *PushError) Unwrap() error { return e.Err }
+
+// errPushGone is returned for subscriptions the push service no longer has
+var errPushGone = errors.New("push subscription is gone")
+
+// push sends one encrypted message to a subscription
+func (c *WebPushChannel) push(ctx context.Context, endpoint repository.PushEndpoint, payload []byte) error {
+	if err := CheckPushEndpoint(endpoint.Endpoint, c.allowedHosts); err != nil {
+		return fmt.Errorf("push endpoint %s: %w", endpoint.Endpoint, err)
+	}
+	body, err := encryptPush(endpoint, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := c.vapidAuthorization(endpoint.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprint(int(pushTTL.Seconds())))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errPushGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("push service answered %s", resp.Status)
+	}
+	return nil
+}
+
+// vapidAuthorization returns the Authorization header identifying this
+// server to the push service of the endpoint
+func (c *WebPushChannel) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid push endpoint: %w", err)
+	}
+
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(vapidTokenTTL).Unix(),
+		"sub": c.subject,
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS ES256 signatures are R and S as fixed-size big-endian integers
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	token := signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, c.PublicKey()), nil
+}
+
+// encryptPush encrypts a payload for a subscription as a single aes128gcm
+// record (RFC 8188) keyed as RFC 8291 describes
+func encryptPush(endpoint repository.PushEndpoint, payload []byte) ([]byte, error) {
+	uaPublicBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(endpoint.P256dh, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(endpoint.Auth, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublicBytes := asPrivate.PublicKey().Bytes()
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublicBytes...)
+	keyInfo = append(keyInfo, asPublicBytes...)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// The single record ends with the last-record delimiter
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	if len(plaintext)+gcm.Overhead() > pushRecordSize {
+		return nil, fmt.Errorf("push payload of %d bytes is too large", len(payload))
+	}
+
+	header := make([]byte, 0, 16+4+1+len(asPublicBytes))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(asPublicBytes)))
+	header = append(header, asPublicBytes...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
@@ -0,0 +1,337 @@
+package notify
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"trego-backend/repository"
+)
+
+// fakeBrowser is a push subscription's key pair and auth secret, as a
+// browser holds them
+type fakeBrowser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+// newFakeBrowser generates the keys of a push subscription
+func newFakeBrowser(t *testing.T) *fakeBrowser {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate browser key: %v", err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return &fakeBrowser{key: key, auth: auth}
+}
+
+// endpoint returns the subscription of the browser at a push endpoint URL
+func (b *fakeBrowser) endpoint(endpointURL string) repository.PushEndpoint {
+	return repository.PushEndpoint{
+		Endpoint: endpointURL,
+		P256dh:   base64.RawURLEncoding.EncodeToString(b.key.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(b.auth),
+	}
+}
+
+// decrypt reverses encryptPush as the browser does (RFC 8291)
+func (b *fakeBrowser) decrypt(body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("body too short")
+	}
+	salt, keyLength := body[:16], int(body[20])
+	if binary.BigEndian.Uint32(body[16:20]) != pushRecordSize || len(body) < 21+keyLength {
+		return nil, errors.New("bad aes128gcm header")
+	}
+	asPublicBytes, ciphertext := body[21:21+keyLength], body[21+keyLength:]
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := b.key.ECDH(asPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), b.key.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, asPublicBytes...)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, b.auth, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		return nil, errors.New("missing last-record delimiter")
+	}
+	return plaintext[:len(plaintext)-1], nil
+}
+
+// fakePushService is a local push service. Messages to /ok are accepted,
+// /gone subscriptions no longer exist and /fail answers an error.
+type fakePushService struct {
+	*httptest.Server
+	browser *fakeBrowser
+	// vapidKey is the public key messages must be signed with
+	vapidKey string
+
+	mu       sync.Mutex
+	received []Message
+	errs     []error
+}
+
+// startFakePushService runs a push service for the browser's subscriptions
+func startFakePushService(t *testing.T, browser *fakeBrowser) *fakePushService {
+	t.Helper()
+	s := &fakePushService{browser: browser}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakePushService) serve(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/gone":
+		w.WriteHeader(http.StatusGone)
+		return
+	case "/fail":
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	msg, err := s.open(r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errs = append(s.errs, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.received = append(s.received, *msg)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// open checks the VAPID signature and headers of a push request and
+// decrypts its message
+func (s *fakePushService) open(r *http.Request) (*Message, error) {
+	if r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "" {
+		return nil, fmt.Errorf("unexpected headers %v", r.Header)
+	}
+	if err := s.checkVAPID(r.Header.Get("Authorization")); err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := s.browser.decrypt(body)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// checkVAPID verifies the VAPID token of an Authorization header (RFC 8292)
+func (s *fakePushService) checkVAPID(authorization string) error {
+	var token, key string
+	for _, part := range strings.Split(strings.TrimPrefix(authorization, "vapid "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "t":
+			token = value
+		case "k":
+			key = value
+		}
+	}
+	s.mu.Lock()
+	want := s.vapidKey
+	s.mu.Unlock()
+	if key != want {
+		return fmt.Errorf("VAPID key = %q, want %q", key, want)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed VAPID token")
+	}
+	rawKey, _ := base64.RawURLEncoding.DecodeString(key)
+	public, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), rawKey)
+	if err != nil {
+		return fmt.Errorf("invalid VAPID key: %w", err)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(signature) != 64 || !ecdsa.Verify(public, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		return errors.New("bad VAPID signature")
+	}
+
+	var claims struct {
+		Audience string `json:"aud"`
+		Subject  string `json:"sub"`
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return err
+	}
+	if claims.Audience != s.URL || claims.Subject == "" {
+		return fmt.Errorf("VAPID claims = %+v, want aud %s", claims, s.URL)
+	}
+	return nil
+}
+
+// newTestPushChannel creates a push channel with a fresh VAPID key, allowed
+// to push to the hosts, and has the push service expect the key
+func newTestPushChannel(t *testing.T, service *fakePushService, allowedHosts []string) *WebPushChannel {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate VAPID key: %v", err)
+	}
+	raw, err := key.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to encode VAPID key: %v", err)
+	}
+	channel, err := NewWebPushChannel(base64.RawURLEncoding.EncodeToString(raw), "mailto:ops@trego.example", allowedHosts)
+	if err != nil {
+		t.Fatalf("NewWebPushChannel failed: %v", err)
+	}
+	service.mu.Lock()
+	service.vapidKey = channel.PublicKey()
+	service.mu.Unlock()
+	return channel
+}
+
+// serviceHost returns the host of a test server's URL
+func serviceHost(t *testing.T, serverURL string) string {
+	t.Helper()
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", serverURL, err)
+	}
+	return u.Hostname()
+}
+
+func TestWebPushChannelSend(t *testing.T) {
+	browser := newFakeBrowser(t)
+	service := startFakePushService(t, browser)
+	channel := newTestPushChannel(t, service, []string{serviceHost(t, service.URL)})
+
+	msg := Message{Title: "Game cancelled", Body: "Sunday's game is off.", URL: "https://trego.example/games/1"}
+	to := Recipient{UserID: "user-1", PushEndpoints: []repository.PushEndpoint{browser.endpoint(service.URL + "/ok")}}
+	if err := channel.Send(context.Background(), to, msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	if len(service.errs) > 0 {
+		t.Fatalf("The push service rejected the message: %v", service.errs)
+	}
+	if len(service.received) != 1 || service.received[0] != msg {
+		t.Errorf("received %+v, want %+v", service.received, msg)
+	}
+}
+
+func TestWebPushChannelSendPartialFailure(t *testing.T) {
+	browser := newFakeBrowser(t)
+	service := startFakePushService(t, browser)
+	channel := newTestPushChannel(t, service, []string{serviceHost(t, service.URL)})
+
+	var gone []string
+	channel.Gone = func(_ context.Context, endpoint string) { gone = append(gone, endpoint) }
+
+	ok, missing, failing := service.URL+"/ok", service.URL+"/gone", service.URL+"/fail"
+	to := Recipient{UserID: "user-1", PushEndpoints: []repository.PushEndpoint{
+		browser.endpoint(ok), browser.endpoint(missing), browser.endpoint(failing),
+	}}
+	err := channel.Send(context.Background(), to, Message{Title: "Hi", Body: "Hello"})
+
+	var pushErr *PushError
+	if !errors.As(err, &pushErr) {
+		t.Fatalf("Send = %v, want a *PushError", err)
+	}
+	if len(pushErr.Delivered) != 1 || pushErr.Delivered[0] != ok {
+		t.Errorf("Delivered = %v, want [%s]", pushErr.Delivered, ok)
+	}
+	if len(gone) != 1 || gone[0] != missing {
+		t.Errorf("Gone called with %v, want [%s]", gone, missing)
+	}
+}
+
+func TestWebPushChannelSendAllGone(t *testing.T) {
+	browser := newFakeBrowser(t)
+	service := startFakePushService(t, browser)
+	channel := newTestPushChannel(t, service, []string{serviceHost(t, service.URL)})
+
+	to := Recipient{UserID: "user-1", PushEndpoints: []repository.PushEndpoint{browser.endpoint(service.URL + "/gone")}}
+	if err := channel.Send(context.Background(), to, Message{Title: "Hi"}); !errors.Is(err, ErrNoAddress) {
+		t.Errorf("Send = %v, want ErrNoAddress", err)
+	}
+}
+
+func TestWebPushChannelRefusesPrivateAddresses(t *testing.T) {
+	browser := newFakeBrowser(t)
+	service := startFakePushService(t, browser)
+	channel := newTestPushChannel(t, service, nil)
+
+	to := Recipient{UserID: "user-1", PushEndpoints: []repository.PushEndpoint{browser.endpoint(service.URL + "/ok")}}
+	if err := channel.Send(context.Background(), to, Message{Title: "Hi"}); err == nil {
+		t.Error("Send to a loopback endpoint succeeded")
+	}
+
+	// A name that passed the subscription checks but resolves to a private
+	// address is refused when dialing
+	_, err := newPushClient(nil).Get(service.URL)
+	if !errors.Is(err, errPrivateAddress) {
+		t.Errorf("dialing %s = %v, want errPrivateAddress", service.URL, err)
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	if len(service.received) > 0 {
+		t.Errorf("The push service received %d message(s)", len(service.received))
+	}
+}
@@ -0,0 +1,83 @@
+// Package queue runs background work through the jobs table. Jobs are
+// enqueued with typed arguments, claimed by workers with FOR UPDATE SKIP
+// LOCKED so every instance of the gateway can work the queue, retried with
+// exponential backoff and dead-lettered once out of attempts.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"trego-backend/models"
+	"trego-backend/repository"
+)
+
+// Args are the arguments of a kind of job. Kind names the job's handler and
+// must not depend on the receiver's value, as workers call it on the zero
+// value. Args are stored as JSON.
+type Args interface {
+	Kind() string
+}
+
+// Job is a claimed job with its decoded arguments
+type Job[T Args] struct {
+	ID string
+	// Attempt counts from 1 and includes this attempt
+	Attempt     int
+	MaxAttempts int
+	Args        T
+}
+
+// Handler runs jobs of one kind. Returning an error retries the job with a
+// backoff, or dead-letters it if it is out of attempts or the error is
+// wrapped with Permanent.
+type Handler[T Args] func(ctx context.Context, job Job[T]) error
+
+// EnqueueOptions are the optional settings of an enqueued job
+type EnqueueOptions struct {
+	// UniqueKey, if set, makes enqueueing a no-op while a pending or running
+	// job of the kind has the same key
+	UniqueKey string
+	// ScheduledAt is when the job runs; now if zero
+	ScheduledAt time.Time
+	// MaxAttempts is how often the job is tried; 5 if zero
+	MaxAttempts int
+}
+
+// Enqueue adds a job to the queue and returns it. If the unique key is held
+// by a pending or running job, that job is returned and enqueued is false.
+func Enqueue[T Args](ctx context.Context, jobs *repository.JobRepository, args T, opts EnqueueOptions) (*models.Job, bool, error) {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return nil, false, err
+	}
+	return jobs.Enqueue(ctx, repository.NewJob{
+		Kind:        args.Kind(),
+		Args:        payload,
+		UniqueKey:   opts.UniqueKey,
+		MaxAttempts: opts.MaxAttempts,
+		ScheduledAt: opts.ScheduledAt,
+	})
+}
+
+// permanentError marks a failure retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps a handler error so the job is dead-lettered right away
+// instead of retried
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// isPermanent reports whether a handler error was wrapped with Permanent
+func isPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
@@ -0,0 +1,259 @@
+package queue
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+)
+
+const (
+	// defaultTimeout is how long a job may run unless its handler sets one
+	defaultTimeout = 5 * time.Minute
+	// leaseGrace is how much longer than its timeout a claimed job stays
+	// locked, so jobs are only rescued from workers that died or hung
+	leaseGrace = time.Minute
+	// retryBaseDelay is the wait before the second attempt of a job; it
+	// doubles with every further attempt up to maxRetryDelay
+	retryBaseDelay = 10 * time.Second
+	maxRetryDelay  = time.Hour
+	// maintenanceInterval is how often expired leases are rescued and old
+	// completed jobs are pruned
+	maintenanceInterval = time.Minute
+)
+
+// HandlerOptions configure how the jobs of a kind are run
+type HandlerOptions struct {
+	// Concurrency is how many jobs of the kind one worker runs at once; 1 if
+	// zero
+	Concurrency int
+	// Timeout is how long a job may run before its context is cancelled; 5
+	// minutes if zero
+	Timeout time.Duration
+}
+
+// kindHandler runs the jobs of one kind
+type kindHandler struct {
+	kind    string
+	timeout time.Duration
+	// slots holds a token per running job of the kind
+	slots chan struct{}
+	run   func(ctx context.Context, job models.Job) error
+}
+
+// Worker claims and runs the jobs of its registered kinds
+type Worker struct {
+	jobs         *repository.JobRepository
+	log          logger.Logger
+	id           string
+	pollInterval time.Duration
+	// retention is how long completed jobs are kept; 0 keeps them forever
+	retention time.Duration
+	// slots holds a token per running job across all kinds
+	slots    chan struct{}
+	handlers []*kindHandler
+	running  sync.WaitGroup
+}
+
+// NewWorker creates a worker that polls for due jobs every pollInterval and
+// runs at most maxConcurrency jobs at once
+func NewWorker(jobs *repository.JobRepository, log logger.Logger, pollInterval time.Duration, maxConcurrency int) *Worker {
+	return &Worker{
+		jobs:         jobs,
+		log:          log,
+		id:           workerID(),
+		pollInterval: pollInterval,
+		slots:        make(chan struct{}, max(maxConcurrency, 1)),
+	}
+}
+
+// WithRetention makes the worker delete completed jobs once they are older
+// than retention
+func (w *Worker) WithRetention(retention time.Duration) *Worker {
+	w.retention = retention
+	return w
+}
+
+// Register sets the handler of the jobs whose arguments are T. It must be
+// called before Run, once per kind.
+func Register[T Args](w *Worker, handler Handler[T], opts HandlerOptions) {
+	var zero T
+	kind := zero.Kind()
+	for _, h := range w.handlers {
+		if h.kind == kind {
+			panic(fmt.Sprintf("queue: handler for %s jobs registered twice", kind))
+		}
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	w.handlers = append(w.handlers, &kindHandler{
+		kind:    kind,
+		timeout: opts.Timeout,
+		slots:   make(chan struct{}, opts.Concurrency),
+		run: func(ctx context.Context, job models.Job) error {
+			var args T
+			if err := json.Unmarshal(job.Args, &args); err != nil {
+				return Permanent(fmt.Errorf("decode args: %w", err))
+			}
+			return handler(ctx, Job[T]{ID: job.JobID, Attempt: job.Attempts, MaxAttempts: job.MaxAttempts, Args: args})
+		},
+	})
+}
+
+// Run claims and runs due jobs until ctx is done, then waits for the
+// running jobs, whose contexts are cancelled, to finish
+func (w *Worker) Run(ctx context.Context) {
+	w.log.Info("Job worker started", logger.Field{Key: "worker_id", Value: w.id})
+	defer w.running.Wait()
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	var lastMaintenance time.Time
+
+	for {
+		if time.Since(lastMaintenance) >= maintenanceInterval {
+			w.maintain(ctx)
+			lastMaintenance = time.Now()
+		}
+		for _, h := range w.handlers {
+			w.poll(ctx, h)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll claims as many due jobs of a kind as the worker has room for and
+// starts them
+func (w *Worker) poll(ctx context.Context, h *kindHandler) {
+	// Only Run takes slots, so the room can only grow while claiming
+	free := min(cap(h.slots)-len(h.slots), cap(w.slots)-len(w.slots))
+	if free <= 0 {
+		return
+	}
+
+	claimed, err := w.jobs.Claim(ctx, h.kind, w.id, free, h.timeout+leaseGrace)
+	if err != nil {
+		if ctx.Err() == nil {
+			w.log.Error("Failed to claim jobs",
+				logger.Field{Key: "kind", Value: h.kind},
+				logger.Field{Key: "error", Value: err.Error()})
+		}
+		return
+	}
+
+	for _, job := range claimed {
+		h.slots <- struct{}{}
+		w.slots <- struct{}{}
+		w.running.Add(1)
+		go func() {
+			defer func() {
+				<-w.slots
+				<-h.slots
+				w.running.Done()
+			}()
+			w.execute(ctx, h, job)
+		}()
+	}
+}
+
+// execute runs a claimed job and records its outcome
+func (w *Worker) execute(ctx context.Context, h *kindHandler, job models.Job) {
+	log := w.log.WithFields(map[string]interface{}{"job_id": job.JobID, "kind": job.Kind, "attempt": job.Attempts})
+
+	jobCtx, cancel := context.WithTimeout(ctx, h.timeout)
+	err := safeRun(jobCtx, h, job)
+	cancel()
+
+	// Record the outcome even if the worker is shutting down
+	ctx = context.WithoutCancel(ctx)
+	var held bool
+	var recordErr error
+	switch {
+	case err == nil:
+		held, recordErr = w.jobs.Complete(ctx, job.JobID, w.id)
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		log.Error("Job failed and was dead-lettered", logger.Field{Key: "error", Value: err.Error()})
+		held, recordErr = w.jobs.Fail(ctx, job.JobID, w.id, err.Error(), nil)
+	default:
+		retryAt := time.Now().Add(retryDelay(job.Attempts))
+		log.Warn("Job failed and will be retried",
+			logger.Field{Key: "error", Value: err.Error()},
+			logger.Field{Key: "retry_at", Value: retryAt})
+		held, recordErr = w.jobs.Fail(ctx, job.JobID, w.id, err.Error(), &retryAt)
+	}
+
+	if recordErr != nil {
+		log.Error("Failed to record job outcome", logger.Field{Key: "error", Value: recordErr.Error()})
+	} else if !held {
+		log.Warn("Job lease expired before it finished; its outcome was dropped")
+	}
+}
+
+// safeRun runs a job's handler, turning a panic into an error
+func safeRun(ctx context.Context, h *kindHandler, job models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h.run(ctx, job)
+}
+
+// maintain rescues jobs of dead workers and prunes old completed jobs
+func (w *Worker) maintain(ctx context.Context) {
+	rescued, err := w.jobs.RescueExpired(ctx)
+	if err != nil {
+		w.log.Error("Failed to rescue expired jobs", logger.Field{Key: "error", Value: err.Error()})
+	} else if rescued > 0 {
+		w.log.Warn("Jobs with expired leases rescued", logger.Field{Key: "jobs", Value: rescued})
+	}
+
+	if w.retention <= 0 {
+		return
+	}
+	pruned, err := w.jobs.PruneCompleted(ctx, time.Now().Add(-w.retention))
+	if err != nil {
+		w.log.Error("Failed to prune completed jobs", logger.Field{Key: "error", Value: err.Error()})
+	} else if pruned > 0 {
+		w.log.Info("Completed jobs pruned", logger.Field{Key: "jobs", Value: pruned})
+	}
+}
+
+// retryDelay is how long to wait before retrying a job that failed the
+// given attempt: exponential from retryBaseDelay, capped at maxRetryDelay,
+// with up to 20% jitter so failures of a batch do not retry in lockstep
+func retryDelay(attempt int) time.Duration {
+	delay := maxRetryDelay
+	if shift := attempt - 1; shift < 16 {
+		delay = min(retryBaseDelay<<max(shift, 0), maxRetryDelay)
+	}
+	return delay + rand.N(delay/5+1)
+}
+
+// workerID identifies a worker in locked_by as host, process and a random
+// suffix, so workers of one process restarted with the same PID differ
+func workerID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = cryptorand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
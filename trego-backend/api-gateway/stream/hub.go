@@ -0,0 +1,145 @@
+// Package stream fans game event notifications out to the streams open on
+// this instance
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"trego-backend/api-gateway/logger"
+	"trego-backend/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// reconnectDelay is the first wait before listening again after the
+	// connection was lost; it doubles up to maxReconnectDelay
+	reconnectDelay    = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Hub listens for the notifications sent when game events are recorded, on
+// any instance, and wakes the subscribers of the game. Notifications carry no
+// events: woken subscribers read the new ones from the event log, so a
+// notification lost while reconnecting only delays them.
+type Hub struct {
+	db  *pgxpool.Pool
+	log logger.Logger
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+// NewHub creates a hub; Run must be started for it to wake anyone
+func NewHub(db *pgxpool.Pool, log logger.Logger) *Hub {
+	return &Hub{
+		db:          db,
+		log:         log,
+		subscribers: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives a value whenever events may have
+// been recorded for the game, and a function ending the subscription.
+// Wake-ups arriving while one is pending are merged.
+func (h *Hub) Subscribe(gameID string) (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.subscribers[gameID] == nil {
+		h.subscribers[gameID] = make(map[chan struct{}]struct{})
+	}
+	h.subscribers[gameID][wake] = struct{}{}
+	h.mu.Unlock()
+
+	return wake, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[gameID], wake)
+		if len(h.subscribers[gameID]) == 0 {
+			delete(h.subscribers, gameID)
+		}
+	}
+}
+
+// Run listens for notifications until ctx is done, reconnecting with backoff
+// when the connection is lost
+func (h *Hub) Run(ctx context.Context) {
+	delay := reconnectDelay
+	for {
+		listening, err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if listening {
+			delay = reconnectDelay
+		}
+		h.log.Error("Game event listener disconnected",
+			logger.Field{Key: "error", Value: err.Error()},
+			logger.Field{Key: "retry_in", Value: delay.String()})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxReconnectDelay)
+	}
+}
+
+// listen holds a connection out of the pool listening for notifications
+// until it fails. It reports whether it got to listen.
+func (h *Hub) listen(ctx context.Context) (bool, error) {
+	pooled, err := h.db.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	// The connection stays in LISTEN mode, so it must not go back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+repository.GameEventsChannel); err != nil {
+		return false, err
+	}
+	// Events recorded while nobody listened were not announced
+	h.wakeAll()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		h.wake(notification.Payload)
+	}
+}
+
+// wake wakes the subscribers of a game
+func (h *Hub) wake(gameID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for wake := range h.subscribers[gameID] {
+		notify(wake)
+	}
+}
+
+// wakeAll wakes every subscriber
+func (h *Hub) wakeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subscribers := range h.subscribers {
+		for wake := range subscribers {
+			notify(wake)
+		}
+	}
+}
+
+// notify signals a subscriber without blocking; a pending signal covers the
+// new one
+func notify(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type attendanceAPIHandler struct {
+	Conf       *config.Config
+	Attendance *repository.AttendanceRepository
+}
+
+// @Summary		Mark attendance
+// @Description	Marks attendance ("true", "false" or "none") for players on the roster in bulk. Host only.
+// @Description	Allowed from end_time until the attendance lock window closes. Returns the updated roster
+// @Tags			Games
+// @Router			/api/v1/games/{id}/attendance [put]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id			path		string							true	"Game ID"
+// @Param			attendance	body		models.MarkAttendanceRequest	true	"Attendance per player"
+// @Success		200			{array}		models.GamePlayer
+// @Failure		409			{object}	string	"{"error": "attendance can only be marked after the game has ended"}"
+func (h *attendanceAPIHandler) markAttendance(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.MarkAttendanceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	gameID := ctx.Param("id")
+	players, err := h.Attendance.Mark(ctx.Request.Context(), gameID, ginmiddleware.GetUserIDFromContext(ctx), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Attendance marked",
+		logger.Field{Key: "game_id", Value: gameID},
+		logger.Field{Key: "players", Value: len(req.Players)},
+	)
+	ctx.JSON(http.StatusOK, players)
+}
+
+// @Summary		List attendance history
+// @Description	Returns the finished games a user played with their marked attendance, most recent first.
+// @Description	Users can only see their own history unless they are admins
+// @Tags			Users
+// @Router			/api/v1/users/{id}/attendance [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string	true	"User ID"
+// @Param			limit	query		int		false	"Page size (default 20, max 100)"
+// @Param			offset	query		int		false	"Number of records to skip"
+// @Success		200		{array}		models.AttendanceRecord
+func (h *attendanceAPIHandler) listAttendanceHistory(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := targetUserID(ctx)
+	if !canManageUser(ctx, userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "you can only view your own attendance history"})
+		return
+	}
+
+	var filters models.AttendanceHistoryFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	records, err := h.Attendance.History(ctx.Request.Context(), userID, filters)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, records)
+}
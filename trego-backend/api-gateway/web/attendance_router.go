@@ -0,0 +1,30 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	gameAttendanceURL = "/games/:id/attendance"
+	userAttendanceURL = "/users/:id/attendance"
+	myAttendanceURL   = "/users/me/attendance"
+)
+
+func setupAttendanceHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &attendanceAPIHandler{
+		Conf:       conf,
+		Attendance: repository.NewAttendanceRepository(db).WithLockWindow(conf.AttendanceLockWindow),
+	}
+	routerGroup.PUT(gameAttendanceURL, ginmiddleware.RequireAuth(), handler.markAttendance)
+	routerGroup.GET(myAttendanceURL, ginmiddleware.RequireAuth(), handler.listAttendanceHistory)
+	routerGroup.GET(userAttendanceURL, ginmiddleware.RequireAuth(), handler.listAttendanceHistory)
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type authAPIHandler struct {
+	Conf   *config.Config
+	Tokens *auth.TokenManager
+}
+
+// refreshRequest is the payload of the token refresh endpoint
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// logoutRequest is the optional payload of the logout endpoint
+type logoutRequest struct {
+	// AllSessions logs the user out of every device instead of just this one
+	AllSessions bool `json:"all_sessions"`
+}
+
+// @Summary		Refresh tokens
+// @Description	Exchanges a refresh token for a new access/refresh token pair. Each refresh token can only be used once
+// @Tags			Auth
+// @Router			/api/v1/auth/refresh [post]
+// @Accept			json
+// @Produce		json
+// @Success		200	{object}	auth.TokenPair
+// @Failure		401	{object}	string	"{"error": "invalid or expired refresh token"}"
+func (h *authAPIHandler) refresh(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req refreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pair, err := h.Tokens.Refresh(ctx.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrSessionRevoked) {
+			log.Warn("Refresh token rejected", logger.Field{Key: "error", Value: err.Error()})
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+			return
+		}
+		log.Error("Failed to refresh tokens", logger.Field{Key: "error", Value: err.Error()})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh tokens"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, pair)
+}
+
+// @Summary		Logout
+// @Description	Revokes the caller's session, or every session of the caller with all_sessions
+// @Tags			Auth
+// @Router			/api/v1/auth/logout [post]
+// @Accept			json
+// @Security		BearerAuth
+// @Success		204
+func (h *authAPIHandler) logout(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req logoutRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var err error
+	if req.AllSessions {
+		err = h.Tokens.RevokeAllSessions(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx))
+	} else {
+		err = h.Tokens.RevokeSession(ctx.Request.Context(), ginmiddleware.GetSessionIDFromContext(ctx))
+	}
+	if err != nil {
+		log.Error("Failed to revoke session", logger.Field{Key: "error", Value: err.Error()})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+		return
+	}
+
+	log.Info("User logged out", logger.Field{Key: "all_sessions", Value: req.AllSessions})
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary		Rotate signing key
+// @Description	Makes a new token signing key current. Tokens signed with older keys stay valid until they expire
+// @Tags			Auth
+// @Router			/api/v1/auth/keys/rotate [post]
+// @Produce		json
+// @Security		BearerAuth
+// @Success		200	{object}	string	"{"key_id": "..."}"
+func (h *authAPIHandler) rotateSigningKey(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	kid, err := h.Tokens.RotateSigningKey(ctx.Request.Context())
+	if err != nil {
+		log.Error("Failed to rotate signing key", logger.Field{Key: "error", Value: err.Error()})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate signing key"})
+		return
+	}
+
+	log.Info("Signing key rotated", logger.Field{Key: "key_id", Value: kid})
+	ctx.JSON(http.StatusOK, gin.H{"key_id": kid})
+}
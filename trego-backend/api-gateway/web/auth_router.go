@@ -0,0 +1,26 @@
+package web
+
+import (
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	authRefreshURL    = "/auth/refresh"
+	authLogoutURL     = "/auth/logout"
+	authRotateKeysURL = "/auth/keys/rotate"
+)
+
+func setupAuthHandler(routerGroup *gin.RouterGroup, conf *config.Config, tokens *auth.TokenManager, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &authAPIHandler{Conf: conf, Tokens: tokens}
+	routerGroup.POST(authRefreshURL, handler.refresh)
+	routerGroup.POST(authLogoutURL, ginmiddleware.RequireAuth(), handler.logout)
+	routerGroup.POST(authRotateKeysURL, ginmiddleware.RequireAdmin(), handler.rotateSigningKey)
+}
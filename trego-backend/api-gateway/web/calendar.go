@@ -0,0 +1,251 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/config"
+	"trego-backend/api-gateway/ical"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// calendarFormat is part of every calendar ETag, so changing how games
+	// are rendered invalidates the copies clients hold
+	calendarFormat = "v1"
+	// calendarRefreshInterval is how often subscribed calendar apps are
+	// asked to poll the feed
+	calendarRefreshInterval = time.Hour
+	// calendarCacheControl makes clients revalidate every time; unchanged
+	// calendars are answered with 304
+	calendarCacheControl = "private, no-cache"
+)
+
+type calendarAPIHandler struct {
+	Conf     *config.Config
+	Calendar *repository.CalendarRepository
+}
+
+// @Summary		Create calendar feed
+// @Description	Creates the caller's secret iCalendar subscription URL listing every game they host or joined,
+// @Description	cancelled ones included. Creating it again replaces the URL and the old one stops working.
+// @Description	The URL is only returned here
+// @Tags			Calendar
+// @Router			/api/v1/users/me/calendar-feed [post]
+// @Produce		json
+// @Security		BearerAuth
+// @Success		201	{object}	models.CalendarFeed
+func (h *calendarAPIHandler) createFeed(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := ginmiddleware.GetUserIDFromContext(ctx)
+	token := auth.RandomToken(32)
+	feed, err := h.Calendar.CreateFeed(ctx.Request.Context(), userID, token)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	feed.URL = strings.TrimRight(h.Conf.PublicURL, "/") + "/api/v1/calendar/" + token + ".ics"
+	feed.WebcalURL = "webcal://" + strings.TrimPrefix(strings.TrimPrefix(feed.URL, "https://"), "http://")
+
+	log.Info("Calendar feed created", logger.Field{Key: "user_id", Value: userID})
+	ctx.JSON(http.StatusCreated, feed)
+}
+
+// @Summary		Get calendar feed
+// @Description	Returns when the caller's calendar feed was created. The URL itself is not stored
+// @Tags			Calendar
+// @Router			/api/v1/users/me/calendar-feed [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Success		200	{object}	models.CalendarFeed
+func (h *calendarAPIHandler) getFeed(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	feed, err := h.Calendar.GetFeed(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, feed)
+}
+
+// @Summary		Delete calendar feed
+// @Description	Deletes the caller's calendar feed; its URL stops working
+// @Tags			Calendar
+// @Router			/api/v1/users/me/calendar-feed [delete]
+// @Security		BearerAuth
+// @Success		204
+func (h *calendarAPIHandler) deleteFeed(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := ginmiddleware.GetUserIDFromContext(ctx)
+	if err := h.Calendar.DeleteFeed(ctx.Request.Context(), userID); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Calendar feed deleted", logger.Field{Key: "user_id", Value: userID})
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary		Calendar feed
+// @Description	iCalendar feed of the games the token's owner hosts or joined. The secret token in the URL is the
+// @Description	only credential. Supports If-None-Match and If-Modified-Since
+// @Tags			Calendar
+// @Router			/api/v1/calendar/{token}.ics [get]
+// @Produce		text/calendar
+// @Param			token	path	string	true	"Feed token"
+// @Success		200
+// @Success		304
+func (h *calendarAPIHandler) getFeedCalendar(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	token := strings.TrimSuffix(ctx.Param("token"), ".ics")
+	userID, err := h.Calendar.FeedOwner(ctx.Request.Context(), token)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	version, err := h.Calendar.FeedVersion(ctx.Request.Context(), userID)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	if notModified(ctx, version) {
+		return
+	}
+
+	games, err := h.Calendar.FeedGames(ctx.Request.Context(), userID)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	cal := &ical.Calendar{Name: "Trego games", RefreshInterval: calendarRefreshInterval}
+	locations := map[string]*time.Location{}
+	for _, g := range games {
+		cal.Events = append(cal.Events, calendarEvent(g, locations))
+	}
+	ctx.Data(http.StatusOK, ical.ContentType, cal.Encode())
+}
+
+// @Summary		Download game calendar event
+// @Description	Returns the game as an .ics file to import into a calendar. Supports If-None-Match and
+// @Description	If-Modified-Since
+// @Tags			Games
+// @Router			/api/v1/games/{id}/calendar.ics [get]
+// @Produce		text/calendar
+// @Security		BearerAuth
+// @Param			id	path	string	true	"Game ID"
+// @Success		200
+// @Success		304
+func (h *gameAPIHandler) getGameCalendar(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	if _, ok := h.visibleGame(ctx, log); !ok {
+		return
+	}
+	game, err := h.Calendar.Game(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(game.GameID + "|" + game.UpdatedAt.UTC().Format(time.RFC3339Nano) + "|" + game.Timezone))
+	version := &models.CalendarVersion{ETag: hex.EncodeToString(sum[:16]), LastModified: game.UpdatedAt}
+	if notModified(ctx, version) {
+		return
+	}
+
+	cal := &ical.Calendar{Events: []ical.Event{calendarEvent(*game, map[string]*time.Location{})}}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="trego-%s.ics"`, game.GameID))
+	ctx.Data(http.StatusOK, ical.ContentType, cal.Encode())
+}
+
+// notModified sets the validators of a calendar response and answers 304 if
+// the client's copy is current. If-None-Match takes precedence over
+// If-Modified-Since. It reports whether the response was written.
+func notModified(ctx *gin.Context, version *models.CalendarVersion) bool {
+	etag := `"` + calendarFormat + "-" + version.ETag + `"`
+	ctx.Header("Cache-Control", calendarCacheControl)
+	ctx.Header("ETag", etag)
+	if !version.LastModified.IsZero() {
+		ctx.Header("Last-Modified", version.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	current := false
+	if match := ctx.GetHeader("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				current = true
+			}
+		}
+	} else if since, err := http.ParseTime(ctx.GetHeader("If-Modified-Since")); err == nil && !version.LastModified.IsZero() {
+		current = !version.LastModified.Truncate(time.Second).After(since)
+	}
+	if current {
+		ctx.Status(http.StatusNotModified)
+	}
+	return current
+}
+
+// calendarEvent renders a game as a calendar event in its time zone.
+// locations caches the zones loaded so far.
+func calendarEvent(g models.CalendarGame, locations map[string]*time.Location) ical.Event {
+	loc, ok := locations[g.Timezone]
+	if !ok {
+		var err error
+		if loc, err = time.LoadLocation(g.Timezone); err != nil {
+			loc = time.UTC
+		}
+		locations[g.Timezone] = loc
+	}
+
+	event := ical.Event{
+		UID:          g.GameID + "@trego",
+		Start:        g.StartTime,
+		End:          g.EndTime,
+		Location:     loc,
+		Summary:      g.Title,
+		Place:        g.Location,
+		Latitude:     g.Latitude,
+		Longitude:    g.Longitude,
+		Status:       ical.StatusConfirmed,
+		Created:      g.CreatedAt,
+		LastModified: g.UpdatedAt,
+	}
+
+	var details []string
+	if g.Status == models.GameStatusCancelled {
+		event.Status = ical.StatusCancelled
+		event.Summary = "Cancelled: " + g.Title
+		cancelled := "Cancelled"
+		if g.CancellationReason != nil {
+			cancelled += " (" + strings.ReplaceAll(*g.CancellationReason, "_", " ") + ")"
+		}
+		if g.CancellationNote != nil {
+			cancelled += ": " + *g.CancellationNote
+		}
+		details = append(details, cancelled)
+	}
+	if g.Description != nil {
+		details = append(details, *g.Description)
+	}
+	details = append(details, "Sport: "+g.SportName)
+	if g.SkillLevel != nil {
+		details = append(details, "Level: "+*g.SkillLevel)
+	}
+	event.Description = strings.Join(details, "\n\n")
+	return event
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	myCalendarFeedURL = "/users/me/calendar-feed"
+	// calendarFeedURL matches /calendar/<token>.ics; the token is the credential
+	calendarFeedURL = "/calendar/:token"
+)
+
+func setupCalendarHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &calendarAPIHandler{
+		Conf:     conf,
+		Calendar: repository.NewCalendarRepository(db),
+	}
+	routerGroup.POST(myCalendarFeedURL, ginmiddleware.RequireAuth(), handler.createFeed)
+	routerGroup.GET(myCalendarFeedURL, ginmiddleware.RequireAuth(), handler.getFeed)
+	routerGroup.DELETE(myCalendarFeedURL, ginmiddleware.RequireAuth(), handler.deleteFeed)
+
+	routerGroup.GET(calendarFeedURL, handler.getFeedCalendar)
+}
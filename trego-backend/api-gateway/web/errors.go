@@ -0,0 +1,43 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"trego-backend/api-gateway/logger"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError maps a repository error to an HTTP error response.
+// Unexpected errors are logged and reported without internal details.
+func respondError(ctx *gin.Context, log logger.Logger, err error) {
+	var validationErr *repository.ValidationError
+	var conflictErr *repository.ConflictError
+
+	switch {
+	case errors.As(err, &validationErr):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error(), "field": validationErr.Field})
+	case errors.Is(err, repository.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrRateLimited):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.As(err, &conflictErr):
+		response := gin.H{"error": conflictErr.Message}
+		if conflictErr.Details != nil {
+			response["details"] = conflictErr.Details
+		}
+		ctx.JSON(http.StatusConflict, response)
+	default:
+		log.Error("Request failed", logger.Field{Key: "error", Value: err.Error()})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// respondBindingError reports a request body or query that failed to bind
+func respondBindingError(ctx *gin.Context, err error) {
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// gameEventBatch is how many events a stream reads from the log at once
+	gameEventBatch = 100
+	// gameEventRetry is the reconnection delay suggested to clients
+	gameEventRetry = 3 * time.Second
+
+	// gameStreamReady starts a stream; its ID is the position the stream
+	// resumes from
+	gameStreamReady = "ready"
+	// gameStreamReset replaces ready when the events after Last-Event-ID
+	// were pruned; the client should reload the game
+	gameStreamReset = "reset"
+)
+
+// @Summary		Stream game events
+// @Description	Server-Sent Events stream of changes to the game: player_joined, player_left, player_promoted,
+// @Description	waitlist_joined, waitlist_left, game_updated, game_rescheduled, game_started, game_completed,
+// @Description	game_cancelled and game_deleted, which ends the stream. Each event's data is a models.GameEvent
+// @Description	and its id the event_id. The stream opens with a ready event, or reset if events after
+// @Description	Last-Event-ID are no longer kept. Reconnect with the Last-Event-ID header (or last_event_id
+// @Description	query parameter) to receive the events missed meanwhile
+// @Tags			Games
+// @Router			/api/v1/games/{id}/events [get]
+// @Produce		text/event-stream
+// @Security		BearerAuth
+// @Param			id				path		string	true	"Game ID"
+// @Param			Last-Event-ID	header		int		false	"ID of the last event received"
+// @Param			last_event_id	query		int		false	"Same as Last-Event-ID, for clients that cannot set headers"
+// @Success		200				{object}	models.GameEvent
+func (h *gameAPIHandler) streamGameEvents(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	game, ok := h.visibleGame(ctx, log)
+	if !ok {
+		return
+	}
+	lastID, resuming, err := lastEventID(ctx)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	// Subscribe before reading the log so no event falls in between
+	wake, unsubscribe := h.Hub.Subscribe(game.GameID)
+	defer unsubscribe()
+
+	reqCtx := ctx.Request.Context()
+	first := gameStreamReady
+	if resuming {
+		retained, err := h.Events.Retained(reqCtx, game.GameID, lastID)
+		if err != nil {
+			respondError(ctx, log, err)
+			return
+		}
+		if !retained {
+			first, resuming = gameStreamReset, false
+		}
+	}
+	if !resuming {
+		if lastID, err = h.Events.Latest(reqCtx, game.GameID); err != nil {
+			respondError(ctx, log, err)
+			return
+		}
+	}
+
+	ctx.Header("Cache-Control", "no-cache")
+	// Keep reverse proxies from buffering the stream
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Render(http.StatusOK, sse.Event{
+		Event: first,
+		Id:    strconv.FormatInt(lastID, 10),
+		Retry: uint(gameEventRetry.Milliseconds()),
+		Data:  gin.H{"game_id": game.GameID, "last_event_id": lastID},
+	})
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.Conf.GameEventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		events, err := h.Events.After(reqCtx, game.GameID, lastID, gameEventBatch)
+		if err != nil {
+			if reqCtx.Err() == nil {
+				log.Error("Game event stream failed",
+					logger.Field{Key: "game_id", Value: game.GameID},
+					logger.Field{Key: "error", Value: err.Error()})
+			}
+			return
+		}
+		for _, event := range events {
+			ctx.Render(-1, sse.Event{
+				Event: event.Type,
+				Id:    strconv.FormatInt(event.EventID, 10),
+				Data:  event,
+			})
+			lastID = event.EventID
+			if event.Type == models.GameEventGameDeleted {
+				ctx.Writer.Flush()
+				return
+			}
+		}
+		ctx.Writer.Flush()
+		if len(events) == gameEventBatch {
+			continue
+		}
+
+		// The heartbeat also catches up on events whose notification was lost
+		select {
+		case <-reqCtx.Done():
+			return
+		case <-wake:
+		case <-heartbeat.C:
+			fmt.Fprint(ctx.Writer, ": keep-alive\n\n")
+		}
+	}
+}
+
+// lastEventID returns the event ID a reconnecting client has seen, from the
+// Last-Event-ID header or the last_event_id query parameter, and whether it
+// gave one
+func lastEventID(ctx *gin.Context) (int64, bool, error) {
+	field := "Last-Event-ID"
+	value := ctx.GetHeader(field)
+	if value == "" {
+		field = "last_event_id"
+		value = ctx.Query(field)
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, &repository.ValidationError{Field: field, Message: "must be an event ID"}
+	}
+	return id, true, nil
+}
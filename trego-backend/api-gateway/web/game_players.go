@@ -0,0 +1,160 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary		Join game
+// @Description	Adds the caller to the roster. When the game is full the caller is put on its waitlist
+// @Description	and 202 is returned with their position. Fails with 409 when the game has started, or
+// @Description	when it is full and past the waitlist cutoff. Joining a game twice returns 200 with the unchanged game.
+// @Description	Invite-only games require an invitation or a signed invite_token from an invite link
+// @Tags			Games
+// @Router			/api/v1/games/{id}/join [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string					true	"Game ID"
+// @Param			options	body		models.JoinGameOptions	false	"Invite link token"
+// @Success		201	{object}	models.Game
+// @Success		200	{object}	models.Game
+// @Success		202	{object}	models.WaitlistEntry
+// @Failure		403	{object}	string	"{"error": "an invitation is required to join this game: forbidden"}"
+// @Failure		409	{object}	string	"{"error": "game has already started"}"
+func (h *gameAPIHandler) joinGame(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.JoinGameOptions
+	if ctx.Request.ContentLength != 0 {
+		// The body is optional; an empty chunked body binds to no options
+		if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			respondBindingError(ctx, err)
+			return
+		}
+	}
+
+	var game *models.Game
+	var opts repository.JoinOptions
+	if req.InviteToken != "" {
+		// A valid invite link grants access to a game the caller cannot see yet
+		linkID, err := h.InviteLinks.Verify(req.InviteToken)
+		if err != nil {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "invalid invite token"})
+			return
+		}
+		opts.InviteLinkID = linkID
+		if game, err = h.Games.Get(ctx.Request.Context(), ctx.Param("id")); err != nil {
+			respondError(ctx, log, err)
+			return
+		}
+	} else {
+		var ok bool
+		if game, ok = h.visibleGame(ctx, log); !ok {
+			return
+		}
+	}
+
+	result, err := h.Games.Join(ctx.Request.Context(), game.GameID, ginmiddleware.GetUserIDFromContext(ctx), opts)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	if result.Waitlist != nil {
+		log.Info("Player waitlisted for game",
+			logger.Field{Key: "game_id", Value: game.GameID},
+			logger.Field{Key: "position", Value: result.Waitlist.Position},
+		)
+		ctx.JSON(http.StatusAccepted, result.Waitlist)
+		return
+	}
+
+	status := http.StatusOK
+	if result.Joined {
+		status = http.StatusCreated
+		log.Info("Player joined game", logger.Field{Key: "game_id", Value: game.GameID})
+	}
+	ctx.JSON(status, result.Game)
+}
+
+// @Summary		Leave game
+// @Description	Removes the caller from the roster or waitlist. A freed spot goes to the first waitlisted player
+// @Description	until the waitlist cutoff. Leaving a game you are not on is a no-op
+// @Tags			Games
+// @Router			/api/v1/games/{id}/leave [post]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string	true	"Game ID"
+// @Success		200	{object}	models.Game
+// @Failure		409	{object}	string	"{"error": "game has already started"}"
+func (h *gameAPIHandler) leaveGame(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	gameID := ctx.Param("id")
+	game, err := h.Games.Leave(ctx.Request.Context(), gameID, ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Player left game", logger.Field{Key: "game_id", Value: gameID})
+	ctx.JSON(http.StatusOK, game)
+}
+
+// @Summary		List waitlist
+// @Description	Returns the waitlist of a game in queue order with each user's position
+// @Tags			Games
+// @Router			/api/v1/games/{id}/waitlist [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string	true	"Game ID"
+// @Success		200	{array}		models.WaitlistEntry
+func (h *gameAPIHandler) listWaitlist(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	game, ok := h.visibleGame(ctx, log)
+	if !ok {
+		return
+	}
+
+	entries, err := h.Games.ListWaitlist(ctx.Request.Context(), game.GameID)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// @Summary		Get waitlist position
+// @Description	Returns the caller's position on the waitlist of a game, or 404 if they are not waitlisted
+// @Tags			Games
+// @Router			/api/v1/games/{id}/waitlist/me [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string	true	"Game ID"
+// @Success		200	{object}	models.WaitlistEntry
+// @Failure		404	{object}	string	"{"error": "waitlist entry \"...\" not found"}"
+func (h *gameAPIHandler) getWaitlistPosition(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	game, ok := h.visibleGame(ctx, log)
+	if !ok {
+		return
+	}
+
+	entry, err := h.Games.GetWaitlistEntry(ctx.Request.Context(), game.GameID, ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entry)
+}
@@ -0,0 +1,251 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/api-gateway/stream"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type gameAPIHandler struct {
+	Conf        *config.Config
+	Games       *repository.GameRepository
+	Calendar    *repository.CalendarRepository
+	Teams       *repository.TeamRepository
+	InviteLinks *auth.InviteLinkSigner
+	Events      *repository.GameEventRepository
+	Hub         *stream.Hub
+}
+
+// @Summary		Create game
+// @Description	Creates a game hosted by the caller. end_time must be after start_time
+// @Tags			Games
+// @Router			/api/v1/games [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			game	body		models.CreateGameRequest	true	"Game"
+// @Success		201		{object}	models.Game
+// @Failure		400		{object}	string	"{"error": "end_time: must be after start_time", "field": "end_time"}"
+func (h *gameAPIHandler) createGame(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CreateGameRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	game, err := h.Games.Create(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Game created", logger.Field{Key: "game_id", Value: game.GameID})
+	ctx.JSON(http.StatusCreated, game)
+}
+
+// @Summary		Search games
+// @Description	Searches games by sport, location, skill level, visibility, start window and host, ordered by start time.
+// @Description	Only upcoming games are returned unless start_after is set. Pages are chained with next_cursor.
+// @Description	Geo searches (lat/lng, radius_km or a min/max lat/lng box) only match games with coordinates and are
+// @Description	ordered by distance_km from lat/lng, the caller's saved coordinates or the box center.
+// @Description	Invite-only games are only returned to their host, players and invitees
+// @Tags			Games
+// @Router			/api/v1/games [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			sport_name		query		string	false	"Sport name"
+// @Param			location		query		string	false	"Exact location"
+// @Param			skill_level		query		string	false	"beginner, intermediate or advanced"
+// @Param			visibility		query		string	false	"public or invite-only"
+// @Param			start_after		query		string	false	"RFC 3339 lower bound of start_time (default: now)"
+// @Param			start_before	query		string	false	"RFC 3339 upper bound of start_time"
+// @Param			host_id			query		string	false	"Host user ID"
+// @Param			venue_id		query		string	false	"Venue ID"
+// @Param			series_id		query		string	false	"Recurring series ID"
+// @Param			status			query		string	false	"scheduled, in_progress, completed or cancelled (default: all but cancelled)"
+// @Param			lat				query		number	false	"Latitude to sort and measure distance from"
+// @Param			lng				query		number	false	"Longitude to sort and measure distance from"
+// @Param			radius_km		query		number	false	"Only games within this distance (max 1000)"
+// @Param			min_lat			query		number	false	"Bounding box south edge"
+// @Param			min_lng			query		number	false	"Bounding box west edge"
+// @Param			max_lat			query		number	false	"Bounding box north edge"
+// @Param			max_lng			query		number	false	"Bounding box east edge"
+// @Param			limit			query		int		false	"Page size (default 20, max 100)"
+// @Param			cursor			query		string	false	"Cursor from the previous page"
+// @Success		200				{object}	models.GamePage
+func (h *gameAPIHandler) searchGames(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var filters models.GameFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	page, err := h.Games.Search(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx), filters)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// @Summary		Get game
+// @Description	Returns a game with its player count. include=players embeds the roster.
+// @Description	Invite-only games are only visible to their host, players and invitees
+// @Tags			Games
+// @Router			/api/v1/games/{id} [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string	true	"Game ID"
+// @Param			include	query		string	false	"Comma-separated expansions (players)"
+// @Success		200		{object}	models.Game
+func (h *gameAPIHandler) getGame(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	game, ok := h.visibleGame(ctx, log)
+	if !ok {
+		return
+	}
+
+	if includes(ctx, "players") {
+		players, err := h.Games.ListPlayers(ctx.Request.Context(), game.GameID)
+		if err != nil {
+			respondError(ctx, log, err)
+			return
+		}
+		game.Players = players
+	}
+
+	ctx.JSON(http.StatusOK, game)
+}
+
+// @Summary		Update game
+// @Description	Partially updates a scheduled game. Host only. Capacity cannot drop below the current player count
+// @Tags			Games
+// @Router			/api/v1/games/{id} [patch]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string						true	"Game ID"
+// @Param			game	body		models.UpdateGameRequest	true	"Fields to update"
+// @Success		200		{object}	models.Game
+// @Failure		403		{object}	string	"{"error": "only the host can edit this game: forbidden"}"
+// @Failure		409		{object}	string	"{"error": "cancelled games cannot be edited"}"
+func (h *gameAPIHandler) updateGame(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.UpdateGameRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	game, err := h.Games.Update(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Game updated", logger.Field{Key: "game_id", Value: game.GameID})
+	ctx.JSON(http.StatusOK, game)
+}
+
+// @Summary		Cancel game
+// @Description	Cancels a scheduled or in-progress game with a reason. The game and its roster are kept with
+// @Description	status cancelled; it no longer takes joins or holds its court. Cancelling an upcoming game with
+// @Description	players costs the host reputation. Host only
+// @Tags			Games
+// @Router			/api/v1/games/{id}/cancel [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id				path		string						true	"Game ID"
+// @Param			cancellation	body		models.CancelGameRequest	true	"Cancellation reason"
+// @Success		200				{object}	models.Game
+// @Failure		409				{object}	string	"{"error": "completed games cannot be cancelled"}"
+func (h *gameAPIHandler) cancelGame(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CancelGameRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	game, err := h.Games.Cancel(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Game cancelled",
+		logger.Field{Key: "game_id", Value: game.GameID},
+		logger.Field{Key: "reason", Value: req.Reason})
+	ctx.JSON(http.StatusOK, game)
+}
+
+// @Summary		Delete game
+// @Description	Deletes a game and its roster. Host only
+// @Tags			Games
+// @Router			/api/v1/games/{id} [delete]
+// @Security		BearerAuth
+// @Param			id	path	string	true	"Game ID"
+// @Success		204
+func (h *gameAPIHandler) deleteGame(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	gameID := ctx.Param("id")
+	if err := h.Games.Delete(ctx.Request.Context(), gameID, ginmiddleware.GetUserIDFromContext(ctx)); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Game deleted", logger.Field{Key: "game_id", Value: gameID})
+	ctx.Status(http.StatusNoContent)
+}
+
+// canViewGame reports whether the user may see the game. Public games are
+// visible to everyone; invite-only games only to their host, players,
+// waitlisted users and invitees.
+func (h *gameAPIHandler) canViewGame(ctx context.Context, game *models.Game, userID string) (bool, error) {
+	if game.Visibility == "public" || game.HostID == userID {
+		return true, nil
+	}
+	return h.Games.CanView(ctx, game.GameID, userID)
+}
+
+// visibleGame loads the game named by the id path parameter and responds
+// with 404 if it does not exist or the caller may not see it. It reports
+// whether the handler should continue.
+func (h *gameAPIHandler) visibleGame(ctx *gin.Context, log logger.Logger) (*models.Game, bool) {
+	game, err := h.Games.Get(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, log, err)
+		return nil, false
+	}
+
+	visible, err := h.canViewGame(ctx.Request.Context(), game, ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return nil, false
+	}
+	if !visible {
+		// Do not reveal that the invite-only game exists
+		respondError(ctx, log, &repository.NotFoundError{Resource: "game", ID: game.GameID})
+		return nil, false
+	}
+	return game, true
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/stream"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	gamesURL = "/games"
+	gameURL  = "/games/:id"
+
+	gameJoinURL   = "/games/:id/join"
+	gameLeaveURL  = "/games/:id/leave"
+	gameCancelURL = "/games/:id/cancel"
+
+	gameWaitlistURL   = "/games/:id/waitlist"
+	gameWaitlistMeURL = "/games/:id/waitlist/me"
+
+	gameCalendarURL = "/games/:id/calendar.ics"
+
+	gameTeamsURL        = "/games/:id/teams"
+	gameTeamsShuffleURL = "/games/:id/teams/shuffle"
+
+	gameEventsURL = "/games/:id/events"
+)
+
+func setupGameHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, inviteLinks *auth.InviteLinkSigner, hub *stream.Hub, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &gameAPIHandler{
+		Conf:        conf,
+		Games:       repository.NewGameRepository(db).WithWaitlistCutoff(conf.WaitlistCutoff).WithReminderOffsets(conf.ReminderOffsets),
+		Calendar:    repository.NewCalendarRepository(db),
+		Teams:       repository.NewTeamRepository(db),
+		InviteLinks: inviteLinks,
+		Events:      repository.NewGameEventRepository(db),
+		Hub:         hub,
+	}
+	routerGroup.GET(gamesURL, ginmiddleware.RequireAuth(), handler.searchGames)
+	routerGroup.POST(gamesURL, ginmiddleware.RequireAuth(), handler.createGame)
+	routerGroup.GET(gameURL, ginmiddleware.RequireAuth(), handler.getGame)
+	routerGroup.PATCH(gameURL, ginmiddleware.RequireAuth(), handler.updateGame)
+	routerGroup.DELETE(gameURL, ginmiddleware.RequireAuth(), handler.deleteGame)
+
+	routerGroup.POST(gameJoinURL, ginmiddleware.RequireAuth(), handler.joinGame)
+	routerGroup.POST(gameLeaveURL, ginmiddleware.RequireAuth(), handler.leaveGame)
+	routerGroup.POST(gameCancelURL, ginmiddleware.RequireAuth(), handler.cancelGame)
+	routerGroup.GET(gameWaitlistURL, ginmiddleware.RequireAuth(), handler.listWaitlist)
+	routerGroup.GET(gameWaitlistMeURL, ginmiddleware.RequireAuth(), handler.getWaitlistPosition)
+	routerGroup.GET(gameCalendarURL, ginmiddleware.RequireAuth(), handler.getGameCalendar)
+
+	routerGroup.POST(gameTeamsURL, ginmiddleware.RequireAuth(), handler.generateTeams)
+	routerGroup.GET(gameTeamsURL, ginmiddleware.RequireAuth(), handler.getTeams)
+	routerGroup.DELETE(gameTeamsURL, ginmiddleware.RequireAuth(), handler.deleteTeams)
+	routerGroup.POST(gameTeamsShuffleURL, ginmiddleware.RequireAuth(), handler.shuffleTeams)
+
+	routerGroup.GET(gameEventsURL, ginmiddleware.RequireAuth(), handler.streamGameEvents)
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginStateTTL is how long a user has to complete the provider login
+const loginStateTTL = 10 * time.Minute
+
+type googleLoginAPIHandler struct {
+	Conf     *config.Config
+	Provider auth.Provider
+	States   *repository.OAuthStateRepository
+	Users    *repository.UserRepository
+	Tokens   *auth.TokenManager
+}
+
+// @Summary		Start Google login
+// @Description	Starts the OpenID Connect authorization-code flow and returns the provider URL to redirect the browser to
+// @Tags			Auth
+// @Router			/api/v1/google-login [post]
+// @Produce		json
+// @Success		200	{object}	string	"{"redirectUrl": "https://accounts.google.com/o/oauth2/v2/auth?..."}"
+// @Failure		502	{object}	string	"{"error": "identity provider unavailable"}"
+func (h *googleLoginAPIHandler) startLogin(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	state := auth.RandomToken(32)
+	nonce := auth.RandomToken(32)
+	verifier := auth.NewCodeVerifier()
+
+	err := h.States.Save(ctx.Request.Context(), repository.OAuthLoginState{
+		State:        state,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(loginStateTTL),
+	})
+	if err != nil {
+		log.Error("Failed to save login state", logger.Field{Key: "error", Value: err.Error()})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
+	}
+
+	redirectURL, err := h.Provider.AuthCodeURL(ctx.Request.Context(), state, nonce, auth.CodeChallengeS256(verifier))
+	if err != nil {
+		log.Error("Failed to build provider login URL", logger.Field{Key: "error", Value: err.Error()})
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"redirectUrl": redirectURL})
+}
+
+// @Summary		Google login callback
+// @Description	Provider redirect target. Validates state and PKCE, verifies the ID token, upserts the user and redirects back
+// @Description	to the web app with an access/refresh token pair in the URL fragment
+// @Tags			Auth
+// @Router			/api/v1/google-login/callback [get]
+// @Param			code	query	string	true	"Authorization code"
+// @Param			state	query	string	true	"Login state"
+// @Success		302
+func (h *googleLoginAPIHandler) callback(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	if providerError := ctx.Query("error"); providerError != "" {
+		log.Warn("Identity provider returned an error", logger.Field{Key: "error", Value: providerError})
+		h.redirectToLogin(ctx, "access_denied")
+		return
+	}
+
+	code := ctx.Query("code")
+	stateParam := ctx.Query("state")
+	if code == "" || stateParam == "" {
+		h.redirectToLogin(ctx, "invalid_request")
+		return
+	}
+
+	state, err := h.States.Consume(ctx.Request.Context(), stateParam)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Error("Failed to load login state", logger.Field{Key: "error", Value: err.Error()})
+		}
+		h.redirectToLogin(ctx, "invalid_state")
+		return
+	}
+
+	claims, err := h.Provider.Exchange(ctx.Request.Context(), code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Warn("Failed to exchange authorization code", logger.Field{Key: "error", Value: err.Error()})
+		h.redirectToLogin(ctx, "login_failed")
+		return
+	}
+
+	var picture *string
+	if claims.Picture != "" {
+		picture = &claims.Picture
+	}
+	name := claims.Name
+	if name == "" {
+		name = strings.Split(claims.Email, "@")[0]
+	}
+
+	email := strings.ToLower(claims.Email)
+	user, err := h.Users.UpsertByEmail(ctx.Request.Context(), name, email, picture)
+	if err != nil {
+		log.Error("Failed to upsert user", logger.Field{Key: "error", Value: err.Error()})
+		h.redirectToLogin(ctx, "server_error")
+		return
+	}
+
+	pair, err := h.Tokens.Issue(ctx.Request.Context(), user.UserID, h.Conf.IsAdminEmail(email))
+	if err != nil {
+		log.Error("Failed to issue tokens", logger.Field{Key: "error", Value: err.Error()})
+		h.redirectToLogin(ctx, "server_error")
+		return
+	}
+
+	log.Info("User logged in", logger.Field{Key: "user_id", Value: user.UserID})
+
+	// Tokens travel in the fragment so they never reach server logs or Referer headers
+	fragment := url.Values{
+		"access_token":  {pair.AccessToken},
+		"refresh_token": {pair.RefreshToken},
+		"token_type":    {pair.TokenType},
+		"expires_in":    {strconv.Itoa(pair.ExpiresIn)},
+	}
+	ctx.Redirect(http.StatusFound, strings.TrimSuffix(h.Conf.FrontendURL, "/")+"/dashboard#"+fragment.Encode())
+}
+
+// redirectToLogin sends the browser back to the web app login page with an error code
+func (h *googleLoginAPIHandler) redirectToLogin(ctx *gin.Context, reason string) {
+	target := strings.TrimSuffix(h.Conf.FrontendURL, "/") + "/login?" + url.Values{"error": {reason}}.Encode()
+	ctx.Redirect(http.StatusFound, target)
+}
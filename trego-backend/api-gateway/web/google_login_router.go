@@ -0,0 +1,31 @@
+package web
+
+import (
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/config"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	googleLoginURL         = "/google-login"
+	googleLoginCallbackURL = "/google-login/callback"
+)
+
+func setupGoogleLoginHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, provider auth.Provider, tokens *auth.TokenManager, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &googleLoginAPIHandler{
+		Conf:     conf,
+		Provider: provider,
+		States:   repository.NewOAuthStateRepository(db),
+		Users:    repository.NewUserRepository(db),
+		Tokens:   tokens,
+	}
+	routerGroup.POST(googleLoginURL, handler.startLogin)
+	routerGroup.GET(googleLoginCallbackURL, handler.callback)
+}
@@ -0,0 +1,160 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/config"
+	"trego-backend/database"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeProvider is an identity provider that checks the PKCE verifier and
+// nonce of each login against what its authorization URL carried
+type fakeProvider struct {
+	email string
+
+	mu     sync.Mutex
+	logins map[string]fakeLogin
+}
+
+// fakeLogin is what an authorization URL of the fake provider carried
+type fakeLogin struct {
+	nonce     string
+	challenge string
+}
+
+func (p *fakeProvider) AuthCodeURL(_ context.Context, state, nonce, codeChallenge string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logins[state] = fakeLogin{nonce: nonce, challenge: codeChallenge}
+	return "https://idp.example/authorize?" + url.Values{"state": {state}}.Encode(), nil
+}
+
+// Exchange accepts codes of the form "code-<state>"
+func (p *fakeProvider) Exchange(_ context.Context, code, codeVerifier, nonce string) (*auth.IDTokenClaims, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	login, ok := p.logins[strings.TrimPrefix(code, "code-")]
+	switch {
+	case !ok:
+		return nil, errors.New("unknown code")
+	case auth.CodeChallengeS256(codeVerifier) != login.challenge:
+		return nil, errors.New("PKCE verification failed")
+	case nonce != login.nonce:
+		return nil, auth.ErrInvalidIDToken
+	}
+	return &auth.IDTokenClaims{Subject: "idp-user", Email: p.email, EmailVerified: true, Name: "Login Test"}, nil
+}
+
+// TestGoogleLoginCallback runs logins through the login and callback
+// handlers against the database configured with the DB_* variables. It is
+// skipped without DB_HOST.
+func TestGoogleLoginCallback(t *testing.T) {
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("DB_HOST is not set; skipping database test")
+	}
+	if err := database.Connect(); err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+	if err := database.RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	db := database.GetDB()
+	provider := &fakeProvider{email: "login-" + uuid.New().String()[:8] + "@trego.test", logins: map[string]fakeLogin{}}
+	defer func() {
+		users := repository.NewUserRepository(db)
+		if user, err := users.UpsertByEmail(context.Background(), "Login Test", provider.email, nil); err == nil {
+			users.Delete(context.Background(), user.UserID)
+		}
+	}()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	conf := &config.Config{FrontendURL: "https://trego.example"}
+	tokens := auth.NewTokenManager(repository.NewAuthRepository(db), auth.TokenConfig{AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour})
+	setupGoogleLoginHandler(router.Group("/api/v1"), conf, db, provider, tokens)
+
+	// start begins a login and returns its state
+	start := func(t *testing.T) string {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/google-login", nil))
+		var body struct {
+			RedirectURL string `json:"redirectUrl"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil || recorder.Code != http.StatusOK {
+			t.Fatalf("Starting the login answered %d %s", recorder.Code, recorder.Body)
+		}
+		redirect, err := url.Parse(body.RedirectURL)
+		if err != nil || redirect.Query().Get("state") == "" {
+			t.Fatalf("Login redirect %q carries no state", body.RedirectURL)
+		}
+		return redirect.Query().Get("state")
+	}
+	// callback lands on the callback with the query and returns where it
+	// sends the browser
+	callback := func(t *testing.T, query url.Values) string {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/google-login/callback?"+query.Encode(), nil))
+		if recorder.Code != http.StatusFound {
+			t.Fatalf("Callback answered %d, want 302", recorder.Code)
+		}
+		return recorder.Header().Get("Location")
+	}
+
+	t.Run("login", func(t *testing.T) {
+		state := start(t)
+		location := callback(t, url.Values{"code": {"code-" + state}, "state": {state}})
+		if !strings.HasPrefix(location, "https://trego.example/dashboard#") || !strings.Contains(location, "access_token=") {
+			t.Errorf("Callback sent the browser to %s, want the dashboard with tokens", location)
+		}
+
+		// States are used once
+		location = callback(t, url.Values{"code": {"code-" + state}, "state": {state}})
+		if location != "https://trego.example/login?error=invalid_state" {
+			t.Errorf("Replayed callback sent the browser to %s, want invalid_state", location)
+		}
+	})
+
+	t.Run("unknown state", func(t *testing.T) {
+		state := start(t)
+		location := callback(t, url.Values{"code": {"code-" + state}, "state": {"forged"}})
+		if location != "https://trego.example/login?error=invalid_state" {
+			t.Errorf("Callback sent the browser to %s, want invalid_state", location)
+		}
+	})
+
+	t.Run("code of another login", func(t *testing.T) {
+		// The code was issued for the first login, so the verifier and
+		// nonce of the second do not match it
+		first, second := start(t), start(t)
+		location := callback(t, url.Values{"code": {"code-" + first}, "state": {second}})
+		if location != "https://trego.example/login?error=login_failed" {
+			t.Errorf("Callback sent the browser to %s, want login_failed", location)
+		}
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		location := callback(t, url.Values{"error": {"access_denied"}})
+		if location != "https://trego.example/login?error=access_denied" {
+			t.Errorf("Callback sent the browser to %s, want access_denied", location)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type holidayAPIHandler struct {
+	Conf     *config.Config
+	Holidays *repository.HolidayRepository
+}
+
+// @Summary		List holidays
+// @Description	Lists the holiday calendar series with skip_holidays do not play on. Public
+// @Tags			Series
+// @Router			/api/v1/holidays [get]
+// @Produce		json
+// @Param			from	query		string	false	"First date, YYYY-MM-DD (default: today)"
+// @Param			to		query		string	false	"Last date, YYYY-MM-DD"
+// @Success		200		{array}		models.Holiday
+func (h *holidayAPIHandler) listHolidays(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var filters models.HolidayFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	holidays, err := h.Holidays.List(ctx.Request.Context(), filters)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, holidays)
+}
+
+// @Summary		Add holiday
+// @Description	Adds a date to the holiday calendar. Series only skip it for occurrences not created yet. Admin only
+// @Tags			Series
+// @Router			/api/v1/holidays [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			holiday	body		models.CreateHolidayRequest	true	"Holiday"
+// @Success		201		{object}	models.Holiday
+func (h *holidayAPIHandler) createHoliday(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CreateHolidayRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	holiday, err := h.Holidays.Create(ctx.Request.Context(), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Holiday created", logger.Field{Key: "date", Value: holiday.Date})
+	ctx.JSON(http.StatusCreated, holiday)
+}
+
+// @Summary		Delete holiday
+// @Description	Removes a date from the holiday calendar. Admin only
+// @Tags			Series
+// @Router			/api/v1/holidays/{date} [delete]
+// @Security		BearerAuth
+// @Param			date	path	string	true	"Date, YYYY-MM-DD"
+// @Success		204
+func (h *holidayAPIHandler) deleteHoliday(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	date := ctx.Param("date")
+	if err := h.Holidays.Delete(ctx.Request.Context(), date); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Holiday deleted", logger.Field{Key: "date", Value: date})
+	ctx.Status(http.StatusNoContent)
+}
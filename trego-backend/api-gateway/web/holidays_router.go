@@ -0,0 +1,29 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	holidaysURL = "/holidays"
+	holidayURL  = "/holidays/:date"
+)
+
+func setupHolidayHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &holidayAPIHandler{
+		Conf:     conf,
+		Holidays: repository.NewHolidayRepository(db),
+	}
+	routerGroup.GET(holidaysURL, handler.listHolidays)
+	routerGroup.POST(holidaysURL, ginmiddleware.RequireAdmin(), handler.createHoliday)
+	routerGroup.DELETE(holidayURL, ginmiddleware.RequireAdmin(), handler.deleteHoliday)
+}
@@ -0,0 +1,289 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type invitationAPIHandler struct {
+	Conf        *config.Config
+	Games       *repository.GameRepository
+	Invitations *repository.InvitationRepository
+	InviteLinks *auth.InviteLinkSigner
+}
+
+// @Summary		Invite to game
+// @Description	Invites a user by user_id or email to a game. Host only. Emails of existing accounts
+// @Description	are resolved to the user; other addresses are matched when they sign up
+// @Tags			Invitations
+// @Router			/api/v1/games/{id}/invitations [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id			path		string							true	"Game ID"
+// @Param			invitation	body		models.CreateInvitationRequest	true	"Invitee"
+// @Success		201			{object}	models.Invitation
+// @Failure		409			{object}	string	"{"error": "user has already been invited to this game"}"
+func (h *invitationAPIHandler) createInvitation(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CreateInvitationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	invitation, err := h.Invitations.Create(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Invitation created",
+		logger.Field{Key: "game_id", Value: invitation.GameID},
+		logger.Field{Key: "invitation_id", Value: invitation.InvitationID},
+	)
+	ctx.JSON(http.StatusCreated, invitation)
+}
+
+// @Summary		List game invitations
+// @Description	Returns all invitations of a game, newest first. Host only
+// @Tags			Invitations
+// @Router			/api/v1/games/{id}/invitations [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string	true	"Game ID"
+// @Success		200	{array}		models.Invitation
+func (h *invitationAPIHandler) listGameInvitations(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	invitations, err := h.Invitations.ListByGame(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, invitations)
+}
+
+// @Summary		Revoke invitation
+// @Description	Revokes a pending or accepted invitation. Host only. Players who already joined stay on the roster
+// @Tags			Invitations
+// @Router			/api/v1/games/{id}/invitations/{invitation_id} [delete]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id				path		string	true	"Game ID"
+// @Param			invitation_id	path		string	true	"Invitation ID"
+// @Success		200				{object}	models.Invitation
+func (h *invitationAPIHandler) revokeInvitation(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	invitation, err := h.Invitations.Revoke(ctx.Request.Context(), ctx.Param("id"), ctx.Param("invitation_id"), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Invitation revoked", logger.Field{Key: "invitation_id", Value: invitation.InvitationID})
+	ctx.JSON(http.StatusOK, invitation)
+}
+
+// @Summary		Create invite link
+// @Description	Creates a shareable invite link with an optional use limit and expiry. Host only
+// @Tags			Invitations
+// @Router			/api/v1/games/{id}/invite-links [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string							true	"Game ID"
+// @Param			link	body		models.CreateInviteLinkRequest	true	"Link settings"
+// @Success		201		{object}	models.InviteLink
+func (h *invitationAPIHandler) createInviteLink(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CreateInviteLinkRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	link, err := h.Invitations.CreateLink(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Invite link created",
+		logger.Field{Key: "game_id", Value: link.GameID},
+		logger.Field{Key: "link_id", Value: link.LinkID},
+	)
+	ctx.JSON(http.StatusCreated, h.withToken(link))
+}
+
+// @Summary		List invite links
+// @Description	Returns the invite links of a game with their use counts, newest first. Host only
+// @Tags			Invitations
+// @Router			/api/v1/games/{id}/invite-links [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string	true	"Game ID"
+// @Success		200	{array}		models.InviteLink
+func (h *invitationAPIHandler) listInviteLinks(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	links, err := h.Invitations.ListLinks(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	for i := range links {
+		links[i] = *h.withToken(&links[i])
+	}
+	ctx.JSON(http.StatusOK, links)
+}
+
+// @Summary		Revoke invite link
+// @Description	Disables an invite link. Host only. Revoking twice is a no-op
+// @Tags			Invitations
+// @Router			/api/v1/games/{id}/invite-links/{link_id} [delete]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string	true	"Game ID"
+// @Param			link_id	path		string	true	"Invite link ID"
+// @Success		200		{object}	models.InviteLink
+func (h *invitationAPIHandler) revokeInviteLink(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	link, err := h.Invitations.RevokeLink(ctx.Request.Context(), ctx.Param("id"), ctx.Param("link_id"), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Invite link revoked", logger.Field{Key: "link_id", Value: link.LinkID})
+	ctx.JSON(http.StatusOK, link)
+}
+
+// @Summary		Preview invite link
+// @Description	Returns the game behind an invite link token so it can be shown before joining.
+// @Description	Join with POST /api/v1/games/{id}/join and the token as invite_token
+// @Tags			Invitations
+// @Router			/api/v1/invite-links/{token} [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			token	path		string	true	"Invite link token"
+// @Success		200		{object}	models.InviteLinkPreview
+// @Failure		403		{object}	string	"{"error": "invalid invite token"}"
+// @Failure		409		{object}	string	"{"error": "invite link has expired"}"
+func (h *invitationAPIHandler) previewInviteLink(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	linkID, err := h.InviteLinks.Verify(ctx.Param("token"))
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "invalid invite token"})
+		return
+	}
+
+	link, game, err := h.Invitations.GetUsableLink(ctx.Request.Context(), linkID)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, models.InviteLinkPreview{Link: *link, Game: *game})
+}
+
+// @Summary		List my invitations
+// @Description	Returns the caller's pending invitations to upcoming games with each game, soonest first
+// @Tags			Invitations
+// @Router			/api/v1/users/me/invitations [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Success		200	{array}	models.Invitation
+func (h *invitationAPIHandler) listMyInvitations(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	invitations, err := h.Invitations.ListForUser(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, invitations)
+}
+
+// @Summary		Accept invitation
+// @Description	Accepts an invitation and joins the game, or its waitlist when the game is full (202)
+// @Tags			Invitations
+// @Router			/api/v1/invitations/{invitation_id}/accept [post]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			invitation_id	path		string	true	"Invitation ID"
+// @Success		201				{object}	models.Game
+// @Success		202				{object}	models.WaitlistEntry
+// @Failure		409				{object}	string	"{"error": "invitation is already declined"}"
+func (h *invitationAPIHandler) acceptInvitation(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := ginmiddleware.GetUserIDFromContext(ctx)
+	invitation, err := h.Invitations.GetForInvitee(ctx.Request.Context(), ctx.Param("invitation_id"), userID)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	if invitation.Status != "pending" {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "invitation is already " + invitation.Status})
+		return
+	}
+
+	// Joining accepts the invitation in the same transaction
+	result, err := h.Games.Join(ctx.Request.Context(), invitation.GameID, userID, repository.JoinOptions{})
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Invitation accepted", logger.Field{Key: "invitation_id", Value: invitation.InvitationID})
+	switch {
+	case result.Waitlist != nil:
+		ctx.JSON(http.StatusAccepted, result.Waitlist)
+	case result.Joined:
+		ctx.JSON(http.StatusCreated, result.Game)
+	default:
+		ctx.JSON(http.StatusOK, result.Game)
+	}
+}
+
+// @Summary		Decline invitation
+// @Description	Declines a pending invitation
+// @Tags			Invitations
+// @Router			/api/v1/invitations/{invitation_id}/decline [post]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			invitation_id	path		string	true	"Invitation ID"
+// @Success		200				{object}	models.Invitation
+func (h *invitationAPIHandler) declineInvitation(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	invitation, err := h.Invitations.Decline(ctx.Request.Context(), ctx.Param("invitation_id"), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Invitation declined", logger.Field{Key: "invitation_id", Value: invitation.InvitationID})
+	ctx.JSON(http.StatusOK, invitation)
+}
+
+// withToken fills in the signed token and frontend URL of an invite link
+func (h *invitationAPIHandler) withToken(link *models.InviteLink) *models.InviteLink {
+	link.Token = h.InviteLinks.Sign(link.LinkID)
+	link.URL = strings.TrimRight(h.Conf.FrontendURL, "/") + "/invite/" + link.Token
+	return link
+}
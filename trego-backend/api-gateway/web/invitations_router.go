@@ -0,0 +1,48 @@
+package web
+
+import (
+	"trego-backend/api-gateway/auth"
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	gameInvitationsURL = "/games/:id/invitations"
+	gameInvitationURL  = "/games/:id/invitations/:invitation_id"
+	gameInviteLinksURL = "/games/:id/invite-links"
+	gameInviteLinkURL  = "/games/:id/invite-links/:link_id"
+
+	inviteLinkPreviewURL = "/invite-links/:token"
+	myInvitationsURL     = "/users/me/invitations"
+	invitationAcceptURL  = "/invitations/:invitation_id/accept"
+	invitationDeclineURL = "/invitations/:invitation_id/decline"
+)
+
+func setupInvitationHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, inviteLinks *auth.InviteLinkSigner, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &invitationAPIHandler{
+		Conf:        conf,
+		Games:       repository.NewGameRepository(db).WithWaitlistCutoff(conf.WaitlistCutoff),
+		Invitations: repository.NewInvitationRepository(db),
+		InviteLinks: inviteLinks,
+	}
+	routerGroup.POST(gameInvitationsURL, ginmiddleware.RequireAuth(), handler.createInvitation)
+	routerGroup.GET(gameInvitationsURL, ginmiddleware.RequireAuth(), handler.listGameInvitations)
+	routerGroup.DELETE(gameInvitationURL, ginmiddleware.RequireAuth(), handler.revokeInvitation)
+
+	routerGroup.POST(gameInviteLinksURL, ginmiddleware.RequireAuth(), handler.createInviteLink)
+	routerGroup.GET(gameInviteLinksURL, ginmiddleware.RequireAuth(), handler.listInviteLinks)
+	routerGroup.DELETE(gameInviteLinkURL, ginmiddleware.RequireAuth(), handler.revokeInviteLink)
+	routerGroup.GET(inviteLinkPreviewURL, ginmiddleware.RequireAuth(), handler.previewInviteLink)
+
+	routerGroup.GET(myInvitationsURL, ginmiddleware.RequireAuth(), handler.listMyInvitations)
+	routerGroup.POST(invitationAcceptURL, ginmiddleware.RequireAuth(), handler.acceptInvitation)
+	routerGroup.POST(invitationDeclineURL, ginmiddleware.RequireAuth(), handler.declineInvitation)
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type jobAPIHandler struct {
+	Conf *config.Config
+	Jobs *repository.JobRepository
+}
+
+// @Summary		List background jobs
+// @Description	Returns one page of the background job queue, newest first. Filter by status=dead to see the
+// @Description	dead-lettered jobs. Pages are chained with next_cursor. Admin only
+// @Tags			Jobs
+// @Router			/api/v1/admin/jobs [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			status	query		string	false	"pending, running, completed or dead"
+// @Param			kind	query		string	false	"Job kind, e.g. reputation_recompute"
+// @Param			limit	query		int		false	"Page size (default 50, max 100)"
+// @Param			cursor	query		string	false	"Cursor from the previous page"
+// @Success		200		{object}	models.JobPage
+func (h *jobAPIHandler) listJobs(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var filters models.JobFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	page, err := h.Jobs.List(ctx.Request.Context(), filters)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
+}
+
+// @Summary		Get background job
+// @Description	Returns a background job with the errors of all its failed attempts. Admin only
+// @Tags			Jobs
+// @Router			/api/v1/admin/jobs/{job_id} [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			job_id	path		string	true	"Job ID"
+// @Success		200		{object}	models.Job
+func (h *jobAPIHandler) getJob(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	job, err := h.Jobs.Get(ctx.Request.Context(), ctx.Param("job_id"))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// @Summary		Retry background job
+// @Description	Queues a dead job to run now with a fresh set of attempts, or runs a pending job now instead of
+// @Description	at its scheduled time. The error history is kept. Admin only
+// @Tags			Jobs
+// @Router			/api/v1/admin/jobs/{job_id}/retry [post]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			job_id	path		string	true	"Job ID"
+// @Success		200		{object}	models.Job
+// @Failure		409		{object}	string	"{"error": "completed jobs cannot be retried"}"
+func (h *jobAPIHandler) retryJob(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	job, err := h.Jobs.Retry(ctx.Request.Context(), ctx.Param("job_id"))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Job retried",
+		logger.Field{Key: "job_id", Value: job.JobID},
+		logger.Field{Key: "kind", Value: job.Kind},
+		logger.Field{Key: "user_id", Value: ginmiddleware.GetUserIDFromContext(ctx)})
+	ctx.JSON(http.StatusOK, job)
+}
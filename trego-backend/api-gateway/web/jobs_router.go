@@ -0,0 +1,30 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	adminJobsURL     = "/admin/jobs"
+	adminJobURL      = "/admin/jobs/:job_id"
+	adminJobRetryURL = "/admin/jobs/:job_id/retry"
+)
+
+func setupJobHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &jobAPIHandler{
+		Conf: conf,
+		Jobs: repository.NewJobRepository(db),
+	}
+	routerGroup.GET(adminJobsURL, ginmiddleware.RequireAdmin(), handler.listJobs)
+	routerGroup.GET(adminJobURL, ginmiddleware.RequireAdmin(), handler.getJob)
+	routerGroup.POST(adminJobRetryURL, ginmiddleware.RequireAdmin(), handler.retryJob)
+}
@@ -0,0 +1,175 @@
+package web
+
+import (
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type messageAPIHandler struct {
+	Conf     *config.Config
+	Messages *repository.MessageRepository
+}
+
+// @Summary		List game messages
+// @Description	Returns one page of the game's message thread, newest first. Deleted messages are listed without
+// @Description	their body. Only the host, players on the roster and players who left can read the thread;
+// @Description	players who left only see the messages posted before they left. Pages are chained with next_cursor
+// @Tags			Messages
+// @Router			/api/v1/games/{id}/messages [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string	true	"Game ID"
+// @Param			pinned	query		bool	false	"Only pinned messages"
+// @Param			limit	query		int		false	"Page size (default 50, max 100)"
+// @Param			cursor	query		string	false	"Cursor from the previous page"
+// @Success		200		{object}	models.MessagePage
+// @Failure		403		{object}	string	"{"error": "only the host and players can use this game's messages: forbidden"}"
+func (h *messageAPIHandler) listMessages(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var filters models.MessageFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	page, err := h.Messages.List(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx), filters)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
+}
+
+// @Summary		Post game message
+// @Description	Posts a message to the game's thread. @handles mentioning the host or a player by user ID, name
+// @Description	without spaces or first name are resolved to users; ambiguous handles mention nobody.
+// @Description	Host and players only
+// @Tags			Messages
+// @Router			/api/v1/games/{id}/messages [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string						true	"Game ID"
+// @Param			message	body		models.PostMessageRequest	true	"Message"
+// @Success		201		{object}	models.GameMessage
+func (h *messageAPIHandler) postMessage(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.PostMessageRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	message, err := h.Messages.Post(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Game message posted",
+		logger.Field{Key: "game_id", Value: message.GameID},
+		logger.Field{Key: "message_id", Value: message.MessageID})
+	ctx.JSON(http.StatusCreated, message)
+}
+
+// @Summary		Edit game message
+// @Description	Replaces the body of the caller's message and resolves its mentions again. Author only
+// @Tags			Messages
+// @Router			/api/v1/games/{id}/messages/{message_id} [patch]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id			path		string						true	"Game ID"
+// @Param			message_id	path		string						true	"Message ID"
+// @Param			message		body		models.PostMessageRequest	true	"New body"
+// @Success		200			{object}	models.GameMessage
+// @Failure		409			{object}	string	"{"error": "deleted messages cannot be edited"}"
+func (h *messageAPIHandler) editMessage(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.PostMessageRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	message, err := h.Messages.Edit(ctx.Request.Context(), ctx.Param("id"), ctx.Param("message_id"), ginmiddleware.GetUserIDFromContext(ctx), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, message)
+}
+
+// @Summary		Delete game message
+// @Description	Deletes a message. Authors can delete their own messages and the host can remove anyone's, which
+// @Description	is shown as removed. The message stays in the thread without its body
+// @Tags			Messages
+// @Router			/api/v1/games/{id}/messages/{message_id} [delete]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id			path		string	true	"Game ID"
+// @Param			message_id	path		string	true	"Message ID"
+// @Success		200			{object}	models.GameMessage
+func (h *messageAPIHandler) deleteMessage(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	message, err := h.Messages.Delete(ctx.Request.Context(), ctx.Param("id"), ctx.Param("message_id"), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Game message deleted",
+		logger.Field{Key: "game_id", Value: message.GameID},
+		logger.Field{Key: "message_id", Value: message.MessageID},
+		logger.Field{Key: "removed", Value: message.Removed})
+	ctx.JSON(http.StatusOK, message)
+}
+
+// @Summary		Pin game message
+// @Description	Pins a message, listing it with the pinned filter. Host only
+// @Tags			Messages
+// @Router			/api/v1/games/{id}/messages/{message_id}/pin [put]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id			path		string	true	"Game ID"
+// @Param			message_id	path		string	true	"Message ID"
+// @Success		200			{object}	models.GameMessage
+func (h *messageAPIHandler) pinMessage(ctx *gin.Context) {
+	h.setPinned(ctx, true)
+}
+
+// @Summary		Unpin game message
+// @Description	Unpins a message. Host only
+// @Tags			Messages
+// @Router			/api/v1/games/{id}/messages/{message_id}/pin [delete]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id			path		string	true	"Game ID"
+// @Param			message_id	path		string	true	"Message ID"
+// @Success		200			{object}	models.GameMessage
+func (h *messageAPIHandler) unpinMessage(ctx *gin.Context) {
+	h.setPinned(ctx, false)
+}
+
+// setPinned pins or unpins the message named by the path
+func (h *messageAPIHandler) setPinned(ctx *gin.Context, pinned bool) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	message, err := h.Messages.SetPinned(ctx.Request.Context(), ctx.Param("id"), ctx.Param("message_id"), ginmiddleware.GetUserIDFromContext(ctx), pinned)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, message)
+}
@@ -0,0 +1,33 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	gameMessagesURL   = "/games/:id/messages"
+	gameMessageURL    = "/games/:id/messages/:message_id"
+	gameMessagePinURL = "/games/:id/messages/:message_id/pin"
+)
+
+func setupMessageHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &messageAPIHandler{
+		Conf:     conf,
+		Messages: repository.NewMessageRepository(db),
+	}
+	routerGroup.GET(gameMessagesURL, ginmiddleware.RequireAuth(), handler.listMessages)
+	routerGroup.POST(gameMessagesURL, ginmiddleware.RequireAuth(), handler.postMessage)
+	routerGroup.PATCH(gameMessageURL, ginmiddleware.RequireAuth(), handler.editMessage)
+	routerGroup.DELETE(gameMessageURL, ginmiddleware.RequireAuth(), handler.deleteMessage)
+	routerGroup.PUT(gameMessagePinURL, ginmiddleware.RequireAuth(), handler.pinMessage)
+	routerGroup.DELETE(gameMessagePinURL, ginmiddleware.RequireAuth(), handler.unpinMessage)
+}
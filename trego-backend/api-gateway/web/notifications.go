@@ -0,0 +1,229 @@
+package web
+
+import (
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/api-gateway/notify"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type notificationAPIHandler struct {
+	Conf          *config.Config
+	Notifications *repository.NotificationRepository
+	// VAPIDPublicKey is what browsers subscribe to web push with; empty
+	// when push is not configured
+	VAPIDPublicKey string
+}
+
+// @Summary		List notifications
+// @Description	Returns one page of the caller's notification inbox, newest first, with the number of unread
+// @Description	notifications. Pages are chained with next_cursor
+// @Tags			Notifications
+// @Router			/api/v1/users/me/notifications [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			unread	query		bool	false	"Only unread notifications"
+// @Param			limit	query		int		false	"Page size (default 50, max 100)"
+// @Param			cursor	query		string	false	"Cursor from the previous page"
+// @Success		200		{object}	models.NotificationPage
+func (h *notificationAPIHandler) listNotifications(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var filters models.NotificationFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	page, err := h.Notifications.List(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx), filters)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
+}
+
+// @Summary		Mark notification read
+// @Description	Marks one of the caller's notifications read
+// @Tags			Notifications
+// @Router			/api/v1/users/me/notifications/{notification_id}/read [put]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			notification_id	path		string	true	"Notification ID"
+// @Success		200				{object}	models.Notification
+func (h *notificationAPIHandler) markRead(ctx *gin.Context) {
+	h.setRead(ctx, true)
+}
+
+// @Summary		Mark notification unread
+// @Description	Marks one of the caller's notifications unread
+// @Tags			Notifications
+// @Router			/api/v1/users/me/notifications/{notification_id}/read [delete]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			notification_id	path		string	true	"Notification ID"
+// @Success		200				{object}	models.Notification
+func (h *notificationAPIHandler) markUnread(ctx *gin.Context) {
+	h.setRead(ctx, false)
+}
+
+// setRead marks the notification named by the path read or unread
+func (h *notificationAPIHandler) setRead(ctx *gin.Context, read bool) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	notification, err := h.Notifications.SetRead(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx), ctx.Param("notification_id"), read)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, notification)
+}
+
+// @Summary		Mark all notifications read
+// @Description	Marks every unread notification of the caller read and returns how many were marked
+// @Tags			Notifications
+// @Router			/api/v1/users/me/notifications/read-all [post]
+// @Produce		json
+// @Security		BearerAuth
+// @Success		200	{object}	map[string]int64
+func (h *notificationAPIHandler) markAllRead(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	marked, err := h.Notifications.MarkAllRead(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"marked": marked})
+}
+
+// @Summary		Get notification preferences
+// @Description	Returns the channels the caller is notified on besides the inbox and their quiet hours
+// @Tags			Notifications
+// @Router			/api/v1/users/me/notification-preferences [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Success		200	{object}	models.NotificationPreferences
+func (h *notificationAPIHandler) getPreferences(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	prefs, err := h.Notifications.GetPreferences(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, prefs)
+}
+
+// @Summary		Update notification preferences
+// @Description	Turns email and push notifications on or off and sets the quiet hours, local times (HH:MM) in
+// @Description	timezone during which emails and pushes are held back until the quiet hours end. Quiet hours are
+// @Description	set together and may span midnight; empty strings turn them off. The inbox is not affected
+// @Tags			Notifications
+// @Router			/api/v1/users/me/notification-preferences [patch]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			preferences	body		models.UpdateNotificationPreferencesRequest	true	"Changed preferences"
+// @Success		200			{object}	models.NotificationPreferences
+func (h *notificationAPIHandler) updatePreferences(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.UpdateNotificationPreferencesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	userID := ginmiddleware.GetUserIDFromContext(ctx)
+	prefs, err := h.Notifications.UpdatePreferences(ctx.Request.Context(), userID, req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Notification preferences updated", logger.Field{Key: "user_id", Value: userID})
+	ctx.JSON(http.StatusOK, prefs)
+}
+
+// @Summary		Add push subscription
+// @Description	Registers a browser for web push notifications. The body is the browser's
+// @Description	PushSubscription.toJSON(), subscribed with the key from /push/vapid-public-key. Registering an
+// @Description	endpoint again replaces its subscription. Endpoints must be https URLs of public hosts
+// @Tags			Notifications
+// @Router			/api/v1/users/me/push-subscriptions [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			subscription	body		models.CreatePushSubscriptionRequest	true	"Push subscription"
+// @Success		201				{object}	models.PushSubscription
+func (h *notificationAPIHandler) addPushSubscription(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CreatePushSubscriptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	if err := notify.CheckPushEndpoint(req.Endpoint, h.Conf.PushAllowedHosts); err != nil {
+		respondError(ctx, log, &repository.ValidationError{Field: "endpoint", Message: err.Error()})
+		return
+	}
+
+	userID := ginmiddleware.GetUserIDFromContext(ctx)
+	subscription, err := h.Notifications.AddPushSubscription(ctx.Request.Context(), userID, req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Push subscription added",
+		logger.Field{Key: "user_id", Value: userID},
+		logger.Field{Key: "subscription_id", Value: subscription.SubscriptionID})
+	ctx.JSON(http.StatusCreated, subscription)
+}
+
+// @Summary		Delete push subscription
+// @Description	Unregisters one of the caller's browsers from web push
+// @Tags			Notifications
+// @Router			/api/v1/users/me/push-subscriptions/{subscription_id} [delete]
+// @Security		BearerAuth
+// @Param			subscription_id	path	string	true	"Subscription ID"
+// @Success		204
+func (h *notificationAPIHandler) deletePushSubscription(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := ginmiddleware.GetUserIDFromContext(ctx)
+	subscriptionID := ctx.Param("subscription_id")
+	if err := h.Notifications.DeletePushSubscription(ctx.Request.Context(), userID, subscriptionID); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Push subscription deleted",
+		logger.Field{Key: "user_id", Value: userID},
+		logger.Field{Key: "subscription_id", Value: subscriptionID})
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary		VAPID public key
+// @Description	Returns the key browsers pass as applicationServerKey when subscribing to web push
+// @Tags			Notifications
+// @Router			/api/v1/push/vapid-public-key [get]
+// @Produce		json
+// @Success		200	{object}	map[string]string
+// @Failure		404	{object}	string	"{"error": "web push is not configured"}"
+func (h *notificationAPIHandler) getVAPIDPublicKey(ctx *gin.Context) {
+	if h.VAPIDPublicKey == "" {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "web push is not configured"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"public_key": h.VAPIDPublicKey})
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/api-gateway/notify"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	myNotificationsURL        = "/users/me/notifications"
+	myNotificationReadURL     = "/users/me/notifications/:notification_id/read"
+	myNotificationsReadAllURL = "/users/me/notifications/read-all"
+	myNotificationPrefsURL    = "/users/me/notification-preferences"
+	myPushSubscriptionsURL    = "/users/me/push-subscriptions"
+	myPushSubscriptionURL     = "/users/me/push-subscriptions/:subscription_id"
+	pushVAPIDPublicKeyURL     = "/push/vapid-public-key"
+)
+
+func setupNotificationHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, log logger.Logger, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &notificationAPIHandler{
+		Conf:          conf,
+		Notifications: repository.NewNotificationRepository(db),
+	}
+	if conf.VAPIDPrivateKey != "" {
+		push, err := notify.NewWebPushChannel(conf.VAPIDPrivateKey, conf.VAPIDSubject, conf.PushAllowedHosts)
+		if err != nil {
+			log.Error("Web push disabled", logger.Field{Key: "error", Value: err.Error()})
+		} else {
+			handler.VAPIDPublicKey = push.PublicKey()
+		}
+	}
+	routerGroup.GET(myNotificationsURL, ginmiddleware.RequireAuth(), handler.listNotifications)
+	routerGroup.PUT(myNotificationReadURL, ginmiddleware.RequireAuth(), handler.markRead)
+	routerGroup.DELETE(myNotificationReadURL, ginmiddleware.RequireAuth(), handler.markUnread)
+	routerGroup.POST(myNotificationsReadAllURL, ginmiddleware.RequireAuth(), handler.markAllRead)
+
+	routerGroup.GET(myNotificationPrefsURL, ginmiddleware.RequireAuth(), handler.getPreferences)
+	routerGroup.PATCH(myNotificationPrefsURL, ginmiddleware.RequireAuth(), handler.updatePreferences)
+
+	routerGroup.POST(myPushSubscriptionsURL, ginmiddleware.RequireAuth(), handler.addPushSubscription)
+	routerGroup.DELETE(myPushSubscriptionURL, ginmiddleware.RequireAuth(), handler.deletePushSubscription)
+	routerGroup.GET(pushVAPIDPublicKeyURL, handler.getVAPIDPublicKey)
+}
@@ -0,0 +1,113 @@
+package web
+
+import (
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ratingAPIHandler struct {
+	Conf    *config.Config
+	Ratings *repository.RatingRepository
+}
+
+// @Summary		Rate game participant
+// @Description	Rates another player or the host of a finished game for sportsmanship and skill accuracy (1-5),
+// @Description	with an optional comment. Only players who were on the roster and not marked as no-shows can rate.
+// @Description	Rating the same user again edits the rating while the edit window is open
+// @Tags			Games
+// @Router			/api/v1/games/{id}/ratings/{user_id} [put]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string					true	"Game ID"
+// @Param			user_id	path		string					true	"Rated user ID"
+// @Param			rating	body		models.RateUserRequest	true	"Rating"
+// @Success		200		{object}	models.Rating
+// @Success		201		{object}	models.Rating
+// @Failure		409		{object}	string	"{"error": "rating can no longer be edited"}"
+// @Failure		429		{object}	string	"{"error": "at most 20 ratings can be submitted per hour: rate limited"}"
+func (h *ratingAPIHandler) rateUser(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.RateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	rating, created, err := h.Ratings.Rate(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx), ctx.Param("user_id"), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Rating submitted",
+		logger.Field{Key: "game_id", Value: rating.GameID},
+		logger.Field{Key: "rating_id", Value: rating.RatingID},
+	)
+	if created {
+		ctx.JSON(http.StatusCreated, rating)
+		return
+	}
+	ctx.JSON(http.StatusOK, rating)
+}
+
+// @Summary		List my ratings in game
+// @Description	Returns the ratings the caller gave in a game
+// @Tags			Games
+// @Router			/api/v1/games/{id}/ratings [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string	true	"Game ID"
+// @Success		200	{array}		models.Rating
+func (h *ratingAPIHandler) listMyGameRatings(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	ratings, err := h.Ratings.ListByRater(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, ratings)
+}
+
+// @Summary		List received ratings
+// @Description	Returns the ratings a user received with their comments, newest first, without the raters.
+// @Description	Users can only see their own ratings unless they are admins; others see the summary on the profile
+// @Tags			Users
+// @Router			/api/v1/users/{id}/ratings [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string	true	"User ID"
+// @Param			limit	query		int		false	"Page size (default 20, max 100)"
+// @Param			offset	query		int		false	"Number of ratings to skip"
+// @Success		200		{array}		models.Rating
+func (h *ratingAPIHandler) listReceivedRatings(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := targetUserID(ctx)
+	if !canManageUser(ctx, userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "you can only view your own ratings"})
+		return
+	}
+
+	var filters models.RatingFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	ratings, err := h.Ratings.ListReceived(ctx.Request.Context(), userID, filters)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, ratings)
+}
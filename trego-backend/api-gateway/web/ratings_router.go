@@ -0,0 +1,32 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	gameRatingsURL = "/games/:id/ratings"
+	gameRatingURL  = "/games/:id/ratings/:user_id"
+	userRatingsURL = "/users/:id/ratings"
+	myRatingsURL   = "/users/me/ratings"
+)
+
+func setupRatingHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &ratingAPIHandler{
+		Conf:    conf,
+		Ratings: repository.NewRatingRepository(db).WithLimits(conf.RatingEditWindow, conf.RatingHourlyLimit),
+	}
+	routerGroup.GET(gameRatingsURL, ginmiddleware.RequireAuth(), handler.listMyGameRatings)
+	routerGroup.PUT(gameRatingURL, ginmiddleware.RequireAuth(), handler.rateUser)
+	routerGroup.GET(myRatingsURL, ginmiddleware.RequireAuth(), handler.listReceivedRatings)
+	routerGroup.GET(userRatingsURL, ginmiddleware.RequireAuth(), handler.listReceivedRatings)
+}
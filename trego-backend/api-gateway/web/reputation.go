@@ -0,0 +1,36 @@
+package web
+
+import (
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type reputationAPIHandler struct {
+	Conf       *config.Config
+	Reputation *repository.ReputationRepository
+}
+
+// @Summary		Get reputation breakdown
+// @Description	Explains a user's reputation: totals per source (attendance, hosting, cancellation)
+// @Description	and the most recent ledger entries. Reputation is the ledger total, floored at 0
+// @Tags			Users
+// @Router			/api/v1/users/{id}/reputation [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string	true	"User ID"
+// @Success		200	{object}	models.ReputationBreakdown
+func (h *reputationAPIHandler) getReputation(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	breakdown, err := h.Reputation.Breakdown(ctx.Request.Context(), targetUserID(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, breakdown)
+}
@@ -0,0 +1,28 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	userReputationURL = "/users/:id/reputation"
+	myReputationURL   = "/users/me/reputation"
+)
+
+func setupReputationHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &reputationAPIHandler{
+		Conf:       conf,
+		Reputation: repository.NewReputationRepository(db),
+	}
+	routerGroup.GET(myReputationURL, ginmiddleware.RequireAuth(), handler.getReputation)
+	routerGroup.GET(userReputationURL, ginmiddleware.RequireAuth(), handler.getReputation)
+}
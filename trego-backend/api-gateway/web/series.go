@@ -0,0 +1,220 @@
+package web
+
+import (
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type seriesAPIHandler struct {
+	Conf   *config.Config
+	Series *repository.SeriesRepository
+	Games  *repository.GameRepository
+}
+
+// @Summary		Create recurring series
+// @Description	Creates a series hosted by the caller. The game fields describe the first occurrence and recurrence
+// @Description	is a weekly RRULE, e.g. FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;COUNT=10 (UNTIL or COUNT optional).
+// @Description	Occurrences keep the local start time in timezone (default: the venue's, else UTC) and are created
+// @Description	as games up to SERIES_HORIZON ahead. skip_holidays leaves out dates on the holiday calendar
+// @Tags			Series
+// @Router			/api/v1/series [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			series	body		models.CreateSeriesRequest	true	"Series"
+// @Success		201		{object}	models.GameSeries
+// @Failure		400		{object}	string	"{"error": "recurrence: only FREQ=WEEKLY is supported", "field": "recurrence"}"
+// @Failure		409		{object}	string	"{"error": "court is already booked from ... to ..."}"
+func (h *seriesAPIHandler) createSeries(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CreateSeriesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	series, err := h.Series.Create(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Series created",
+		logger.Field{Key: "series_id", Value: series.SeriesID},
+		logger.Field{Key: "games", Value: len(series.Games)})
+	ctx.JSON(http.StatusCreated, series)
+}
+
+// @Summary		Get recurring series
+// @Description	Returns a series with its upcoming occurrences. Invite-only series are only visible to their host
+// @Description	and to users who can see one of their games
+// @Tags			Series
+// @Router			/api/v1/series/{series_id} [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			series_id	path		string	true	"Series ID"
+// @Success		200			{object}	models.GameSeries
+func (h *seriesAPIHandler) getSeries(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	series, err := h.Series.Get(ctx.Request.Context(), ctx.Param("series_id"), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, series)
+}
+
+// @Summary		Delete recurring series
+// @Description	Cancels the upcoming occurrences and deletes the series. Past games are kept. Host only
+// @Tags			Series
+// @Router			/api/v1/series/{series_id} [delete]
+// @Security		BearerAuth
+// @Param			series_id	path	string	true	"Series ID"
+// @Success		204
+func (h *seriesAPIHandler) deleteSeries(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	seriesID := ctx.Param("series_id")
+	if err := h.Series.Delete(ctx.Request.Context(), seriesID, ginmiddleware.GetUserIDFromContext(ctx)); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Series deleted", logger.Field{Key: "series_id", Value: seriesID})
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary		Edit series occurrence
+// @Description	Edits one occurrence of a series. scope=this (default) edits only this game like PATCH /games/{id}
+// @Description	and returns it. scope=following edits this occurrence and every later one and returns the series
+// @Description	holding them: earlier occurrences stay in the original series. start_time and end_time then move
+// @Description	every following occurrence to the new local time, recurrence replaces the schedule and
+// @Description	skip_holidays toggles holiday skipping. Games keep their players by moving to the new occurrence in
+// @Description	the same week; games the new schedule has no place for are cancelled. Host only
+// @Tags			Series
+// @Router			/api/v1/series/{series_id}/games/{game_id} [patch]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			series_id	path		string						true	"Series ID"
+// @Param			game_id		path		string						true	"Game ID of the occurrence"
+// @Param			scope		query		string						false	"this or following"
+// @Param			game		body		models.UpdateSeriesRequest	true	"Fields to update"
+// @Success		200			{object}	models.GameSeries
+func (h *seriesAPIHandler) updateOccurrence(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var query models.SeriesScopeQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+	var req models.UpdateSeriesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+	callerID := ginmiddleware.GetUserIDFromContext(ctx)
+
+	if query.Scope == "following" {
+		series, err := h.Series.UpdateFollowing(ctx.Request.Context(), ctx.Param("series_id"), ctx.Param("game_id"), callerID, req)
+		if err != nil {
+			respondError(ctx, log, err)
+			return
+		}
+		log.Info("Series occurrences updated",
+			logger.Field{Key: "series_id", Value: series.SeriesID},
+			logger.Field{Key: "game_id", Value: ctx.Param("game_id")})
+		ctx.JSON(http.StatusOK, series)
+		return
+	}
+
+	if req.Recurrence != nil || req.SkipHolidays != nil {
+		respondError(ctx, log, &repository.ValidationError{Field: "scope", Message: "recurrence and skip_holidays need scope=following"})
+		return
+	}
+	game, ok := h.occurrence(ctx, log)
+	if !ok {
+		return
+	}
+	game, err := h.Games.Update(ctx.Request.Context(), game.GameID, callerID, req.UpdateGameRequest)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Game updated", logger.Field{Key: "game_id", Value: game.GameID})
+	ctx.JSON(http.StatusOK, game)
+}
+
+// @Summary		Cancel series occurrence
+// @Description	Cancels one occurrence of a series (scope=this, default) or it and every later one, ending the series
+// @Description	before it (scope=following). Cancelling from the first occurrence deletes the series. Cancelled games
+// @Description	are kept with status cancelled and reason schedule_changed. Host only
+// @Tags			Series
+// @Router			/api/v1/series/{series_id}/games/{game_id} [delete]
+// @Security		BearerAuth
+// @Param			series_id	path	string	true	"Series ID"
+// @Param			game_id		path	string	true	"Game ID of the occurrence"
+// @Param			scope		query	string	false	"this or following"
+// @Success		204
+func (h *seriesAPIHandler) cancelOccurrence(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var query models.SeriesScopeQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+	callerID := ginmiddleware.GetUserIDFromContext(ctx)
+	gameID := ctx.Param("game_id")
+
+	if query.Scope == "following" {
+		if err := h.Series.CancelFollowing(ctx.Request.Context(), ctx.Param("series_id"), gameID, callerID); err != nil {
+			respondError(ctx, log, err)
+			return
+		}
+		log.Info("Series occurrences cancelled",
+			logger.Field{Key: "series_id", Value: ctx.Param("series_id")},
+			logger.Field{Key: "game_id", Value: gameID})
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+
+	if _, ok := h.occurrence(ctx, log); !ok {
+		return
+	}
+	cancel := models.CancelGameRequest{Reason: models.CancellationScheduleChanged}
+	if _, err := h.Games.Cancel(ctx.Request.Context(), gameID, callerID, cancel); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Game cancelled", logger.Field{Key: "game_id", Value: gameID})
+	ctx.Status(http.StatusNoContent)
+}
+
+// occurrence loads the game named by the game_id path parameter and responds
+// with 404 unless it belongs to the series named by series_id. It reports
+// whether the handler should continue.
+func (h *seriesAPIHandler) occurrence(ctx *gin.Context, log logger.Logger) (*models.Game, bool) {
+	game, err := h.Games.Get(ctx.Request.Context(), ctx.Param("game_id"))
+	if err != nil {
+		respondError(ctx, log, err)
+		return nil, false
+	}
+	if game.SeriesID == nil || *game.SeriesID != ctx.Param("series_id") {
+		respondError(ctx, log, &repository.NotFoundError{Resource: "game", ID: game.GameID})
+		return nil, false
+	}
+	return game, true
+}
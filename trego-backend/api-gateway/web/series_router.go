@@ -0,0 +1,35 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	seriesListURL = "/series"
+	seriesURL     = "/series/:series_id"
+	seriesGameURL = "/series/:series_id/games/:game_id"
+)
+
+func setupSeriesHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	series := repository.NewSeriesRepository(db).WithHorizon(conf.SeriesHorizon).WithWaitlistCutoff(conf.WaitlistCutoff).
+		WithReminderOffsets(conf.ReminderOffsets)
+	handler := &seriesAPIHandler{
+		Conf:   conf,
+		Series: series,
+		Games:  repository.NewGameRepository(db).WithWaitlistCutoff(conf.WaitlistCutoff).WithReminderOffsets(conf.ReminderOffsets),
+	}
+	routerGroup.POST(seriesListURL, ginmiddleware.RequireAuth(), handler.createSeries)
+	routerGroup.GET(seriesURL, ginmiddleware.RequireAuth(), handler.getSeries)
+	routerGroup.DELETE(seriesURL, ginmiddleware.RequireAuth(), handler.deleteSeries)
+	routerGroup.PATCH(seriesGameURL, ginmiddleware.RequireAuth(), handler.updateOccurrence)
+	routerGroup.DELETE(seriesGameURL, ginmiddleware.RequireAuth(), handler.cancelOccurrence)
+}
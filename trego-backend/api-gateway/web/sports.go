@@ -0,0 +1,166 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sportsCacheControl lets browsers and CDNs cache the sports catalog, which
+// changes rarely
+const sportsCacheControl = "public, max-age=300"
+
+type sportAPIHandler struct {
+	Conf   *config.Config
+	Sports *repository.SportRepository
+}
+
+// @Summary		List sports
+// @Description	Lists the sports catalog. Public and cacheable; supports If-None-Match
+// @Tags			Sports
+// @Router			/api/v1/sports [get]
+// @Produce		json
+// @Success		200	{array}	models.Sport
+// @Success		304
+func (h *sportAPIHandler) listSports(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	sports, err := h.Sports.List(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	respondCacheable(ctx, log, sports)
+}
+
+// @Summary		Get sport
+// @Description	Returns a sport from the catalog. Public and cacheable
+// @Tags			Sports
+// @Router			/api/v1/sports/{sport_name} [get]
+// @Produce		json
+// @Param			sport_name	path		string	true	"Sport name"
+// @Success		200			{object}	models.Sport
+func (h *sportAPIHandler) getSport(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	sport, err := h.Sports.Get(ctx.Request.Context(), ctx.Param("sport_name"))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	respondCacheable(ctx, log, sport)
+}
+
+// @Summary		Create sport
+// @Description	Adds a sport to the catalog
+// @Tags			Sports
+// @Router			/api/v1/sports [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			sport	body		models.CreateSportRequest	true	"Sport"
+// @Success		201		{object}	models.Sport
+// @Failure		409		{object}	string	"{"error": "sport \"Basketball\" already exists"}"
+func (h *sportAPIHandler) createSport(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CreateSportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	sport, err := h.Sports.Create(ctx.Request.Context(), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Sport created", logger.Field{Key: "sport_name", Value: sport.SportName})
+	ctx.JSON(http.StatusCreated, sport)
+}
+
+// @Summary		Update sport
+// @Description	Updates a sport's icon. An empty icon_url clears it
+// @Tags			Sports
+// @Router			/api/v1/sports/{sport_name} [patch]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			sport_name	path		string						true	"Sport name"
+// @Param			sport		body		models.UpdateSportRequest	true	"Fields to update"
+// @Success		200			{object}	models.Sport
+func (h *sportAPIHandler) updateSport(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.UpdateSportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	sport, err := h.Sports.Update(ctx.Request.Context(), ctx.Param("sport_name"), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sport)
+}
+
+// @Summary		Delete sport
+// @Description	Deletes a sport. Fails with 409 and the lists of blocking games and series while games or
+// @Description	series still use it
+// @Tags			Sports
+// @Router			/api/v1/sports/{sport_name} [delete]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			sport_name	path	string	true	"Sport name"
+// @Success		204
+// @Failure		409	{object}	string	"{"error": "sport \"Tennis\" is used by 2 game(s) and 1 series", "details": {"blocking_games": [...], "total_games": 2, "blocking_series": [...], "total_series": 1}}"
+func (h *sportAPIHandler) deleteSport(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	sportName := ctx.Param("sport_name")
+	if err := h.Sports.Delete(ctx.Request.Context(), sportName); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Sport deleted", logger.Field{Key: "sport_name", Value: sportName})
+	ctx.Status(http.StatusNoContent)
+}
+
+// respondCacheable writes a public JSON response with a content-based ETag
+// and answers 304 when the client already has the current version
+func respondCacheable(ctx *gin.Context, log logger.Logger, body interface{}) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		log.Error("Failed to encode response", logger.Field{Key: "error", Value: err.Error()})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	sum := sha256.Sum256(payload)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	ctx.Header("Cache-Control", sportsCacheControl)
+	ctx.Header("ETag", etag)
+	if ctx.GetHeader("If-None-Match") == etag {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", payload)
+}
@@ -0,0 +1,31 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	sportsURL = "/sports"
+	sportURL  = "/sports/:sport_name"
+)
+
+func setupSportHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &sportAPIHandler{
+		Conf:   conf,
+		Sports: repository.NewSportRepository(db),
+	}
+	routerGroup.GET(sportsURL, handler.listSports)
+	routerGroup.GET(sportURL, handler.getSport)
+	routerGroup.POST(sportsURL, ginmiddleware.RequireAdmin(), handler.createSport)
+	routerGroup.PATCH(sportURL, ginmiddleware.RequireAdmin(), handler.updateSport)
+	routerGroup.DELETE(sportURL, ginmiddleware.RequireAdmin(), handler.deleteSport)
+}
@@ -0,0 +1,113 @@
+package web
+
+import (
+	"net/http"
+
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary		Generate teams
+// @Description	Splits the roster into team_count teams whose sizes differ by at most one, balancing the players'
+// @Description	skill level and position for the game's sport and their reputation. Players in a keep_together
+// @Description	group share a team; players in a keep_apart group all play on different teams. Replaces the
+// @Description	previous split. Host only
+// @Tags			Games
+// @Router			/api/v1/games/{id}/teams [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string						true	"Game ID"
+// @Param			teams	body		models.GenerateTeamsRequest	true	"Team count and constraints"
+// @Success		201		{object}	models.TeamSplit
+// @Failure		400		{object}	string	"{"error": "keep_apart: a group of 3 players cannot be spread over 2 teams", "field": "keep_apart"}"
+// @Failure		409		{object}	string	"{"error": "completed games cannot be split into teams"}"
+func (h *gameAPIHandler) generateTeams(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.GenerateTeamsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	split, err := h.Teams.Generate(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Teams generated",
+		logger.Field{Key: "game_id", Value: split.GameID},
+		logger.Field{Key: "team_count", Value: split.TeamCount})
+	ctx.JSON(http.StatusCreated, split)
+}
+
+// @Summary		Get teams
+// @Description	Returns the game's team split. Players who joined after it was made are listed as unassigned
+// @Tags			Games
+// @Router			/api/v1/games/{id}/teams [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string	true	"Game ID"
+// @Success		200	{object}	models.TeamSplit
+func (h *gameAPIHandler) getTeams(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	game, ok := h.visibleGame(ctx, log)
+	if !ok {
+		return
+	}
+	split, err := h.Teams.Get(ctx.Request.Context(), game.GameID)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, split)
+}
+
+// @Summary		Shuffle teams
+// @Description	Splits the current roster again with the team count and constraints of the game's split,
+// @Description	picking a different split of similar balance when there is one. Constraints on players who
+// @Description	left are dropped. Host only
+// @Tags			Games
+// @Router			/api/v1/games/{id}/teams/shuffle [post]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string	true	"Game ID"
+// @Success		200	{object}	models.TeamSplit
+func (h *gameAPIHandler) shuffleTeams(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	split, err := h.Teams.Shuffle(ctx.Request.Context(), ctx.Param("id"), ginmiddleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Teams shuffled", logger.Field{Key: "game_id", Value: split.GameID})
+	ctx.JSON(http.StatusOK, split)
+}
+
+// @Summary		Delete teams
+// @Description	Deletes the game's team split. Host only
+// @Tags			Games
+// @Router			/api/v1/games/{id}/teams [delete]
+// @Security		BearerAuth
+// @Param			id	path	string	true	"Game ID"
+// @Success		204
+func (h *gameAPIHandler) deleteTeams(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	gameID := ctx.Param("id")
+	if err := h.Teams.Delete(ctx.Request.Context(), gameID, ginmiddleware.GetUserIDFromContext(ctx)); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Teams deleted", logger.Field{Key: "game_id", Value: gameID})
+	ctx.Status(http.StatusNoContent)
+}
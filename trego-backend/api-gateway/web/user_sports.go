@@ -0,0 +1,132 @@
+package web
+
+import (
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type userSportAPIHandler struct {
+	Conf       *config.Config
+	UserSports *repository.UserSportRepository
+}
+
+// @Summary		List user sports
+// @Description	Lists the sports a user plays, with skill level, position and the nested sport
+// @Tags			Users
+// @Router			/api/v1/users/{id}/sports [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string	true	"User ID"
+// @Success		200	{array}		models.UserSport
+func (h *userSportAPIHandler) listUserSports(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	sports, err := h.UserSports.ListByUser(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sports)
+}
+
+// @Summary		Add user sport
+// @Description	Adds a sport from the catalog to a user's profile
+// @Tags			Users
+// @Router			/api/v1/users/{id}/sports [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string						true	"User ID"
+// @Param			sport	body		models.AddUserSportRequest	true	"Sport"
+// @Success		201		{object}	models.UserSport
+// @Failure		409		{object}	string	"{"error": "user already plays Basketball"}"
+func (h *userSportAPIHandler) addUserSport(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := ctx.Param("id")
+	if !canManageUser(ctx, userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own sports"})
+		return
+	}
+
+	var req models.AddUserSportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	sport, err := h.UserSports.Add(ctx.Request.Context(), userID, req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, sport)
+}
+
+// @Summary		Update user sport
+// @Description	Partially updates the skill level or position of a user's sport
+// @Tags			Users
+// @Router			/api/v1/users/{id}/sports/{sport_name} [patch]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id			path		string							true	"User ID"
+// @Param			sport_name	path		string							true	"Sport name"
+// @Param			sport		body		models.UpdateUserSportRequest	true	"Fields to update"
+// @Success		200			{object}	models.UserSport
+func (h *userSportAPIHandler) updateUserSport(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := ctx.Param("id")
+	if !canManageUser(ctx, userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own sports"})
+		return
+	}
+
+	var req models.UpdateUserSportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	sport, err := h.UserSports.Update(ctx.Request.Context(), userID, ctx.Param("sport_name"), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sport)
+}
+
+// @Summary		Remove user sport
+// @Description	Removes a sport from a user's profile
+// @Tags			Users
+// @Router			/api/v1/users/{id}/sports/{sport_name} [delete]
+// @Security		BearerAuth
+// @Param			id			path	string	true	"User ID"
+// @Param			sport_name	path	string	true	"Sport name"
+// @Success		204
+func (h *userSportAPIHandler) removeUserSport(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := ctx.Param("id")
+	if !canManageUser(ctx, userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own sports"})
+		return
+	}
+
+	if err := h.UserSports.Remove(ctx.Request.Context(), userID, ctx.Param("sport_name")); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,30 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	userSportsURL = "/users/:id/sports"
+	userSportURL  = "/users/:id/sports/:sport_name"
+)
+
+func setupUserSportHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &userSportAPIHandler{
+		Conf:       conf,
+		UserSports: repository.NewUserSportRepository(db),
+	}
+	routerGroup.GET(userSportsURL, ginmiddleware.RequireAuth(), handler.listUserSports)
+	routerGroup.POST(userSportsURL, ginmiddleware.RequireAuth(), handler.addUserSport)
+	routerGroup.PATCH(userSportURL, ginmiddleware.RequireAuth(), handler.updateUserSport)
+	routerGroup.DELETE(userSportURL, ginmiddleware.RequireAuth(), handler.removeUserSport)
+}
@@ -0,0 +1,213 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type userAPIHandler struct {
+	Conf       *config.Config
+	Users      *repository.UserRepository
+	UserSports *repository.UserSportRepository
+	Attendance *repository.AttendanceRepository
+	Ratings    *repository.RatingRepository
+}
+
+// @Summary		Create user
+// @Description	Creates a user. Regular users are created by the login flow, so this is admin only
+// @Tags			Users
+// @Router			/api/v1/users [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			user	body		models.CreateUserRequest	true	"User"
+// @Success		201		{object}	models.User
+// @Failure		409		{object}	string	"{"error": "a user with this email already exists"}"
+func (h *userAPIHandler) createUser(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CreateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	user, err := h.Users.Create(ctx.Request.Context(), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("User created", logger.Field{Key: "created_user_id", Value: user.UserID})
+	ctx.JSON(http.StatusCreated, user)
+}
+
+// @Summary		Get user
+// @Description	Returns a user by ID, or the caller for /users/me, with their attendance summary, no-show rate
+// @Description	and average peer ratings.
+// @Description	include=sports embeds the user's sports. Other users get the public profile, without email and
+// @Description	phone number
+// @Tags			Users
+// @Router			/api/v1/users/{id} [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string	true	"User ID"
+// @Param			include	query		string	false	"Comma-separated expansions (sports)"
+// @Success		200		{object}	models.User	"models.PublicProfile for other users"
+// @Failure		404	{object}	string	"{"error": "user not found"}"
+func (h *userAPIHandler) getUser(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := targetUserID(ctx)
+	own := userID == ginmiddleware.GetUserIDFromContext(ctx)
+	var user *models.User
+	var err error
+	if own {
+		user, err = h.Users.GetOwn(ctx.Request.Context(), userID)
+	} else {
+		user, err = h.Users.GetByID(ctx.Request.Context(), userID)
+	}
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	attendance, err := h.Attendance.Stats(ctx.Request.Context(), user.UserID)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	user.Attendance = attendance
+
+	ratings, err := h.Ratings.Summary(ctx.Request.Context(), user.UserID)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	user.Ratings = ratings
+
+	if includes(ctx, "sports") {
+		sports, err := h.UserSports.ListByUser(ctx.Request.Context(), user.UserID)
+		if err != nil {
+			respondError(ctx, log, err)
+			return
+		}
+		user.Sports = sports
+	}
+
+	if !own {
+		ctx.JSON(http.StatusOK, publicProfile(user))
+		return
+	}
+	ctx.JSON(http.StatusOK, user)
+}
+
+// @Summary		Update user
+// @Description	Partially updates a user. Omitted fields are left unchanged; empty strings clear optional fields
+// @Tags			Users
+// @Router			/api/v1/users/{id} [patch]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id		path		string						true	"User ID"
+// @Param			user	body		models.UpdateUserRequest	true	"Fields to update"
+// @Success		200		{object}	models.User
+func (h *userAPIHandler) updateUser(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := targetUserID(ctx)
+	if !canManageUser(ctx, userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "you can only update your own profile"})
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	user, err := h.Users.Update(ctx.Request.Context(), userID, req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	// Admins editing someone else do not get their home coordinates back
+	if userID != ginmiddleware.GetUserIDFromContext(ctx) {
+		user.Latitude, user.Longitude = nil, nil
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// @Summary		Delete user
+// @Description	Deletes a user together with their sports, hosted games and sessions
+// @Tags			Users
+// @Router			/api/v1/users/{id} [delete]
+// @Security		BearerAuth
+// @Param			id	path	string	true	"User ID"
+// @Success		204
+func (h *userAPIHandler) deleteUser(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	userID := targetUserID(ctx)
+	if !canManageUser(ctx, userID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "you can only delete your own account"})
+		return
+	}
+
+	if err := h.Users.Delete(ctx.Request.Context(), userID); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("User deleted", logger.Field{Key: "deleted_user_id", Value: userID})
+	ctx.Status(http.StatusNoContent)
+}
+
+// targetUserID returns the :id path parameter, or the caller on /users/me routes
+func targetUserID(ctx *gin.Context) string {
+	if id := ctx.Param("id"); id != "" {
+		return id
+	}
+	return ginmiddleware.GetUserIDFromContext(ctx)
+}
+
+// publicProfile returns the part of a user's record other users may see
+func publicProfile(user *models.User) models.PublicProfile {
+	return models.PublicProfile{
+		PublicUser: models.PublicUser{
+			UserID:     user.UserID,
+			Name:       user.Name,
+			PictureURL: user.PictureURL,
+			Reputation: user.Reputation,
+		},
+		CreatedAt:  user.CreatedAt,
+		Sports:     user.Sports,
+		Attendance: user.Attendance,
+		Ratings:    user.Ratings,
+	}
+}
+
+// includes reports whether the comma-separated include query parameter names the expansion
+func includes(ctx *gin.Context, expansion string) bool {
+	for _, value := range strings.Split(ctx.Query("include"), ",") {
+		if strings.TrimSpace(value) == expansion {
+			return true
+		}
+	}
+	return false
+}
+
+// canManageUser reports whether the caller may modify the given user
+func canManageUser(ctx *gin.Context, userID string) bool {
+	return ginmiddleware.GetUserIDFromContext(ctx) == userID || ginmiddleware.IsAdminFromContext(ctx)
+}
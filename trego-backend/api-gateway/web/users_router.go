@@ -0,0 +1,39 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	usersURL  = "/users"
+	userURL   = "/users/:id"
+	userMeURL = "/users/me"
+)
+
+func setupUserHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &userAPIHandler{
+		Conf:       conf,
+		Users:      repository.NewUserRepository(db),
+		UserSports: repository.NewUserSportRepository(db),
+		Attendance: repository.NewAttendanceRepository(db),
+		Ratings:    repository.NewRatingRepository(db),
+	}
+	routerGroup.POST(usersURL, ginmiddleware.RequireAdmin(), handler.createUser)
+
+	routerGroup.GET(userMeURL, ginmiddleware.RequireAuth(), handler.getUser)
+	routerGroup.PATCH(userMeURL, ginmiddleware.RequireAuth(), handler.updateUser)
+	routerGroup.DELETE(userMeURL, ginmiddleware.RequireAuth(), handler.deleteUser)
+
+	routerGroup.GET(userURL, ginmiddleware.RequireAuth(), handler.getUser)
+	routerGroup.PATCH(userURL, ginmiddleware.RequireAuth(), handler.updateUser)
+	routerGroup.DELETE(userURL, ginmiddleware.RequireAuth(), handler.deleteUser)
+}
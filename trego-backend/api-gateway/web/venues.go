@@ -0,0 +1,317 @@
+package web
+
+import (
+	"net/http"
+
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/api-gateway/logger"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type venueAPIHandler struct {
+	Conf   *config.Config
+	Venues *repository.VenueRepository
+}
+
+// @Summary		Search venues
+// @Description	Searches venues by name or address and sport, by name, or by distance from lat/lng. Public
+// @Tags			Venues
+// @Router			/api/v1/venues [get]
+// @Produce		json
+// @Param			q			query		string	false	"Part of the name or address"
+// @Param			sport_name	query		string	false	"Sport played at the venue"
+// @Param			lat			query		number	false	"Latitude to sort by distance from"
+// @Param			lng			query		number	false	"Longitude to sort by distance from"
+// @Param			radius_km	query		number	false	"Only venues within this distance of lat/lng"
+// @Param			limit		query		int		false	"Page size (default 20, max 100)"
+// @Param			offset		query		int		false	"Number of venues to skip"
+// @Success		200			{array}		models.Venue
+func (h *venueAPIHandler) searchVenues(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var filters models.VenueFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	venues, err := h.Venues.Search(ctx.Request.Context(), filters)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, venues)
+}
+
+// @Summary		Get venue
+// @Description	Returns a venue with its courts, sports, amenities, opening hours and location aliases. Public
+// @Tags			Venues
+// @Router			/api/v1/venues/{venue_id} [get]
+// @Produce		json
+// @Param			venue_id	path		string	true	"Venue ID"
+// @Success		200			{object}	models.Venue
+func (h *venueAPIHandler) getVenue(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	venue, err := h.Venues.Get(ctx.Request.Context(), ctx.Param("venue_id"))
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, venue)
+}
+
+// @Summary		Get venue availability
+// @Description	Returns the opening hours of a venue on a day, in the venue's time zone, and for each court the
+// @Description	games booked on it and the free slots left. Free slots that already passed are left out. Public;
+// @Description	bookings only carry the game_id and title of games the caller may see, and none for anonymous callers
+// @Tags			Venues
+// @Router			/api/v1/venues/{venue_id}/availability [get]
+// @Produce		json
+// @Param			venue_id	path		string	true	"Venue ID"
+// @Param			date		query		string	true	"Day as YYYY-MM-DD in the venue's time zone"
+// @Param			court_id	query		string	false	"Only this court"
+// @Param			min_minutes	query		int		false	"Leave out free slots shorter than this"
+// @Success		200			{object}	models.VenueAvailability
+func (h *venueAPIHandler) getAvailability(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var filters models.AvailabilityFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	availability, err := h.Venues.Availability(ctx.Request.Context(), ginmiddleware.GetUserIDFromContext(ctx), ctx.Param("venue_id"), filters)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, availability)
+}
+
+// @Summary		Create venue
+// @Description	Creates a venue with its sports, opening hours and courts. Admin only
+// @Tags			Venues
+// @Router			/api/v1/venues [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			venue	body		models.CreateVenueRequest	true	"Venue"
+// @Success		201		{object}	models.Venue
+func (h *venueAPIHandler) createVenue(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CreateVenueRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	venue, err := h.Venues.Create(ctx.Request.Context(), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Venue created", logger.Field{Key: "venue_id", Value: venue.VenueID})
+	ctx.JSON(http.StatusCreated, venue)
+}
+
+// @Summary		Update venue
+// @Description	Partially updates a venue. Empty strings clear optional fields; amenities, sports and
+// @Description	opening_hours replace the stored lists. Admin only
+// @Tags			Venues
+// @Router			/api/v1/venues/{venue_id} [patch]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			venue_id	path		string						true	"Venue ID"
+// @Param			venue		body		models.UpdateVenueRequest	true	"Fields to update"
+// @Success		200			{object}	models.Venue
+func (h *venueAPIHandler) updateVenue(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.UpdateVenueRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	venue, err := h.Venues.Update(ctx.Request.Context(), ctx.Param("venue_id"), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, venue)
+}
+
+// @Summary		Delete venue
+// @Description	Deletes a venue and its courts. Its games keep their free-text location. Admin only
+// @Tags			Venues
+// @Router			/api/v1/venues/{venue_id} [delete]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			venue_id	path	string	true	"Venue ID"
+// @Success		204
+func (h *venueAPIHandler) deleteVenue(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	venueID := ctx.Param("venue_id")
+	if err := h.Venues.Delete(ctx.Request.Context(), venueID); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Venue deleted", logger.Field{Key: "venue_id", Value: venueID})
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary		Add court
+// @Description	Adds a court to a venue. Admin only
+// @Tags			Venues
+// @Router			/api/v1/venues/{venue_id}/courts [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			venue_id	path		string						true	"Venue ID"
+// @Param			court		body		models.CreateCourtRequest	true	"Court"
+// @Success		201			{object}	models.Court
+// @Failure		409			{object}	string	"{"error": "venue already has a court named \"Court 1\""}"
+func (h *venueAPIHandler) addCourt(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.CreateCourtRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	court, err := h.Venues.AddCourt(ctx.Request.Context(), ctx.Param("venue_id"), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Court added",
+		logger.Field{Key: "venue_id", Value: court.VenueID},
+		logger.Field{Key: "court_id", Value: court.CourtID},
+	)
+	ctx.JSON(http.StatusCreated, court)
+}
+
+// @Summary		Update court
+// @Description	Partially updates a court. An empty surface clears it. Admin only
+// @Tags			Venues
+// @Router			/api/v1/venues/{venue_id}/courts/{court_id} [patch]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			venue_id	path		string						true	"Venue ID"
+// @Param			court_id	path		string						true	"Court ID"
+// @Param			court		body		models.UpdateCourtRequest	true	"Fields to update"
+// @Success		200			{object}	models.Court
+func (h *venueAPIHandler) updateCourt(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.UpdateCourtRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	court, err := h.Venues.UpdateCourt(ctx.Request.Context(), ctx.Param("venue_id"), ctx.Param("court_id"), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, court)
+}
+
+// @Summary		Delete court
+// @Description	Deletes a court. Games on it stay at the venue. Admin only
+// @Tags			Venues
+// @Router			/api/v1/venues/{venue_id}/courts/{court_id} [delete]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			venue_id	path	string	true	"Venue ID"
+// @Param			court_id	path	string	true	"Court ID"
+// @Success		204
+func (h *venueAPIHandler) deleteCourt(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	courtID := ctx.Param("court_id")
+	if err := h.Venues.DeleteCourt(ctx.Request.Context(), ctx.Param("venue_id"), courtID); err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Court deleted", logger.Field{Key: "court_id", Value: courtID})
+	ctx.Status(http.StatusNoContent)
+}
+
+// @Summary		List duplicate locations
+// @Description	Groups the free-text locations of games without a venue by their normalized form, with the
+// @Description	spellings in use and the venue the group already matches, most used first. Admin only
+// @Tags			Venues
+// @Router			/api/v1/venues/duplicates [get]
+// @Produce		json
+// @Security		BearerAuth
+// @Param			limit	query		int	false	"Page size (default 50, max 500)"
+// @Param			offset	query		int	false	"Number of groups to skip"
+// @Success		200		{array}		models.LocationGroup
+func (h *venueAPIHandler) listLocationGroups(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var filters models.LocationGroupFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	groups, err := h.Venues.LocationGroups(ctx.Request.Context(), filters)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, groups)
+}
+
+// @Summary		Merge into venue
+// @Description	Folds duplicate venues into this one (their games, courts, sports and aliases move over and they
+// @Description	are deleted) and links free-text locations to it, so existing and future games at those locations
+// @Description	reference the venue. Admin only
+// @Tags			Venues
+// @Router			/api/v1/venues/{venue_id}/merge [post]
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			venue_id	path		string						true	"Target venue ID"
+// @Param			merge		body		models.MergeVenuesRequest	true	"Venues and locations to merge"
+// @Success		200			{object}	models.MergeVenuesResult
+func (h *venueAPIHandler) mergeVenues(ctx *gin.Context) {
+	log := ginmiddleware.GetLoggerFromContext(ctx)
+
+	var req models.MergeVenuesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondBindingError(ctx, err)
+		return
+	}
+
+	result, err := h.Venues.Merge(ctx.Request.Context(), ctx.Param("venue_id"), req)
+	if err != nil {
+		respondError(ctx, log, err)
+		return
+	}
+
+	log.Info("Venues merged",
+		logger.Field{Key: "venue_id", Value: result.Venue.VenueID},
+		logger.Field{Key: "merged_venues", Value: result.MergedVenues},
+		logger.Field{Key: "linked_games", Value: result.LinkedGames},
+	)
+	ctx.JSON(http.StatusOK, result)
+}
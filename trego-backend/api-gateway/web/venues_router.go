@@ -0,0 +1,44 @@
+package web
+
+import (
+	"trego-backend/api-gateway/config"
+	ginmiddleware "trego-backend/api-gateway/internal/middleware"
+	"trego-backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	venuesURL            = "/venues"
+	venueURL             = "/venues/:venue_id"
+	venueCourtsURL       = "/venues/:venue_id/courts"
+	venueCourtURL        = "/venues/:venue_id/courts/:court_id"
+	venueMergeURL        = "/venues/:venue_id/merge"
+	venueAvailabilityURL = "/venues/:venue_id/availability"
+	venueDuplicatesURL   = "/venues/duplicates"
+)
+
+func setupVenueHandler(routerGroup *gin.RouterGroup, conf *config.Config, db *pgxpool.Pool, middlewares ...gin.HandlerFunc) {
+	for _, m := range middlewares {
+		routerGroup.Use(m)
+	}
+
+	handler := &venueAPIHandler{
+		Conf:   conf,
+		Venues: repository.NewVenueRepository(db),
+	}
+	routerGroup.GET(venuesURL, handler.searchVenues)
+	routerGroup.GET(venueURL, handler.getVenue)
+	routerGroup.GET(venueAvailabilityURL, handler.getAvailability)
+	routerGroup.POST(venuesURL, ginmiddleware.RequireAdmin(), handler.createVenue)
+	routerGroup.PATCH(venueURL, ginmiddleware.RequireAdmin(), handler.updateVenue)
+	routerGroup.DELETE(venueURL, ginmiddleware.RequireAdmin(), handler.deleteVenue)
+
+	routerGroup.POST(venueCourtsURL, ginmiddleware.RequireAdmin(), handler.addCourt)
+	routerGroup.PATCH(venueCourtURL, ginmiddleware.RequireAdmin(), handler.updateCourt)
+	routerGroup.DELETE(venueCourtURL, ginmiddleware.RequireAdmin(), handler.deleteCourt)
+
+	routerGroup.GET(venueDuplicatesURL, ginmiddleware.RequireAdmin(), handler.listLocationGroups)
+	routerGroup.POST(venueMergeURL, ginmiddleware.RequireAdmin(), handler.mergeVenues)
+}
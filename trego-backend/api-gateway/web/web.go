@@ -1,17 +1,32 @@
 package web
 
 import (
+	"trego-backend/api-gateway/auth"
 	"trego-backend/api-gateway/config"
 	ginmiddleware "trego-backend/api-gateway/internal/middleware"
 	"trego-backend/api-gateway/logger"
+	"trego-backend/api-gateway/stream"
+	"trego-backend/repository"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // Options holds configuration and dependencies for the web server
 type Options struct {
 	Config *config.Config
 	Logger logger.Logger
+	DB     *pgxpool.Pool
+	// IdentityProvider overrides the OpenID Connect provider built from Config,
+	// e.g. to point the login flow at a local fake provider
+	IdentityProvider auth.Provider
+	// TokenManager issues and verifies bearer tokens; built from Config and DB if nil
+	TokenManager *auth.TokenManager
+	// InviteLinkSigner signs invite link tokens; built from Config if nil
+	InviteLinkSigner *auth.InviteLinkSigner
+	// GameEventHub wakes game event streams. The caller runs it; if nil an idle
+	// hub is built, and streams only pick up events on their heartbeat
+	GameEventHub *stream.Hub
 }
 
 // SetupRouter configures and sets up all routes for the API Gateway
@@ -20,9 +35,21 @@ func SetupRouter(routerGroup *gin.RouterGroup, optFuncs ...func(*Options)) {
 	for _, f := range optFuncs {
 		f(&opt)
 	}
+	if opt.TokenManager == nil {
+		opt.TokenManager = auth.NewTokenManager(repository.NewAuthRepository(opt.DB), auth.TokenConfig{
+			AccessTokenTTL:  opt.Config.AccessTokenTTL,
+			RefreshTokenTTL: opt.Config.RefreshTokenTTL,
+		})
+	}
+	if opt.InviteLinkSigner == nil {
+		opt.InviteLinkSigner = inviteLinkSigner(opt)
+	}
+	if opt.GameEventHub == nil {
+		opt.GameEventHub = stream.NewHub(opt.DB, opt.Logger)
+	}
 
 	// Setup basic middlewares
-	setupBasicMiddlewares(routerGroup, opt.Logger)
+	setupBasicMiddlewares(routerGroup, opt.Logger, opt.TokenManager)
 
 	// Setup health check routes
 	setupHealthCheckHandler(routerGroup, opt.Config)
@@ -31,13 +58,13 @@ func SetupRouter(routerGroup *gin.RouterGroup, optFuncs ...func(*Options)) {
 	setupDbHealthCheckHandler(routerGroup, opt.Config)
 
 	// Setup API routes
-	setupAPIRoutes(routerGroup)
+	setupAPIRoutes(routerGroup, opt)
 }
 
 // setupBasicMiddlewares configures common middlewares for all routes
-func setupBasicMiddlewares(routerGroup *gin.RouterGroup, logger logger.Logger) {
+func setupBasicMiddlewares(routerGroup *gin.RouterGroup, logger logger.Logger, tokens *auth.TokenManager) {
 	// Get ordered middleware
-	defaultMiddlewares := ginmiddleware.GetOrderedMiddleware(logger)
+	defaultMiddlewares := ginmiddleware.GetOrderedMiddleware(logger, tokens)
 	for _, middleware := range defaultMiddlewares {
 		routerGroup.Use(middleware)
 	}
@@ -54,7 +81,7 @@ func setupBasicMiddlewares(routerGroup *gin.RouterGroup, logger logger.Logger) {
 // }
 
 // setupAPIRoutes configures API routes
-func setupAPIRoutes(routerGroup *gin.RouterGroup) {
+func setupAPIRoutes(routerGroup *gin.RouterGroup, opt Options) {
 	// API v1 routes group
 	v1 := routerGroup.Group("/api/v1")
 	{
@@ -65,4 +92,83 @@ func setupAPIRoutes(routerGroup *gin.RouterGroup) {
 			})
 		})
 	}
+
+	// Setup Google (OpenID Connect) login routes
+	setupGoogleLoginHandler(v1, opt.Config, opt.DB, identityProvider(opt), opt.TokenManager)
+
+	// Setup token refresh, logout and key rotation routes
+	setupAuthHandler(v1, opt.Config, opt.TokenManager)
+
+	// Setup user routes
+	setupUserHandler(v1, opt.Config, opt.DB)
+
+	// Setup user sports profile routes
+	setupUserSportHandler(v1, opt.Config, opt.DB)
+
+	// Setup sports catalog routes
+	setupSportHandler(v1, opt.Config, opt.DB)
+
+	// Setup game routes
+	setupGameHandler(v1, opt.Config, opt.DB, opt.InviteLinkSigner, opt.GameEventHub)
+
+	// Setup game invitation and invite link routes
+	setupInvitationHandler(v1, opt.Config, opt.DB, opt.InviteLinkSigner)
+
+	// Setup attendance marking and history routes
+	setupAttendanceHandler(v1, opt.Config, opt.DB)
+
+	// Setup reputation breakdown routes
+	setupReputationHandler(v1, opt.Config, opt.DB)
+
+	// Setup post-game peer rating routes
+	setupRatingHandler(v1, opt.Config, opt.DB)
+
+	// Setup venue, court and location merge routes
+	setupVenueHandler(v1, opt.Config, opt.DB)
+
+	// Setup recurring game series routes
+	setupSeriesHandler(v1, opt.Config, opt.DB)
+
+	// Setup holiday calendar routes
+	setupHolidayHandler(v1, opt.Config, opt.DB)
+
+	// Setup iCalendar feed routes
+	setupCalendarHandler(v1, opt.Config, opt.DB)
+
+	// Setup game message thread routes
+	setupMessageHandler(v1, opt.Config, opt.DB)
+
+	// Setup notification inbox, preference and push subscription routes
+	setupNotificationHandler(v1, opt.Config, opt.DB, opt.Logger)
+
+	// Setup background job admin routes
+	setupJobHandler(v1, opt.Config, opt.DB)
+}
+
+// identityProvider returns the configured identity provider, falling back to
+// an OpenID Connect provider built from the configuration
+func identityProvider(opt Options) auth.Provider {
+	if opt.IdentityProvider != nil {
+		return opt.IdentityProvider
+	}
+
+	return auth.NewOIDCProvider(auth.ProviderConfig{
+		IssuerURL:    opt.Config.OIDCIssuerURL,
+		ClientID:     opt.Config.OIDCClientID,
+		ClientSecret: opt.Config.OIDCClientSecret,
+		RedirectURL:  opt.Config.OIDCRedirectURL,
+		JWKSURL:      opt.Config.OIDCJWKSURL,
+	})
+}
+
+// inviteLinkSigner builds the invite link signer from the configured secret.
+// Without one a random secret is used, so links stop working on restart and
+// are not shared between instances.
+func inviteLinkSigner(opt Options) *auth.InviteLinkSigner {
+	if opt.Config.InviteLinkSecret != "" {
+		return auth.NewInviteLinkSigner([]byte(opt.Config.InviteLinkSecret))
+	}
+
+	opt.Logger.Warn("INVITE_LINK_SECRET is not set; invite links will not survive a restart")
+	return auth.NewInviteLinkSigner([]byte(auth.RandomToken(32)))
 }
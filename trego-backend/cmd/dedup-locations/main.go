@@ -0,0 +1,86 @@
+// Command dedup-locations cleans up the free-text locations of games. It
+// groups the locations of games without a venue by their normalized form,
+// so "Central Park", "central park." and "Central-Park" show up together.
+//
+//	go run ./cmd/dedup-locations                 # report the groups
+//	go run ./cmd/dedup-locations -link           # link games whose location matches a venue alias
+//	go run ./cmd/dedup-locations -create -min 3  # also create a venue per unmatched group of 3+ games
+//
+// Duplicate venues are merged with POST /api/v1/venues/:venue_id/merge.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"trego-backend/database"
+	"trego-backend/models"
+	"trego-backend/repository"
+)
+
+func main() {
+	link := flag.Bool("link", false, "link games to the venues their locations are aliases of")
+	create := flag.Bool("create", false, "create a venue for each unmatched group, named by its most used spelling, and link its games")
+	minGames := flag.Int("min", 2, "only create venues for groups with at least this many games")
+	limit := flag.Int("limit", 500, "maximum number of groups to report")
+	flag.Parse()
+
+	if err := database.Connect(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.RunMigrations(); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	venues := repository.NewVenueRepository(database.GetDB())
+	ctx := context.Background()
+
+	groups, err := venues.LocationGroups(ctx, models.LocationGroupFilters{Limit: *limit})
+	if err != nil {
+		log.Fatalf("Failed to group locations: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GAMES\tVENUE\tSPELLINGS")
+	for _, g := range groups {
+		venue := "-"
+		if g.VenueID != nil {
+			venue = *g.VenueID
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", g.GameCount, venue, strings.Join(g.Spellings, " | "))
+	}
+	w.Flush()
+
+	if *create {
+		created := 0
+		for _, g := range groups {
+			if g.VenueID != nil || g.GameCount < *minGames {
+				continue
+			}
+			venue, err := venues.Create(ctx, models.CreateVenueRequest{Name: g.SuggestedName})
+			if err != nil {
+				log.Fatalf("Failed to create venue %q: %v", g.SuggestedName, err)
+			}
+			if _, err := venues.Merge(ctx, venue.VenueID, models.MergeVenuesRequest{Locations: g.Spellings}); err != nil {
+				log.Fatalf("Failed to link locations to venue %q: %v", g.SuggestedName, err)
+			}
+			created++
+		}
+		log.Printf("Created %d venues", created)
+	}
+
+	if *link || *create {
+		linked, err := venues.LinkAliasedGames(ctx)
+		if err != nil {
+			log.Fatalf("Failed to link games: %v", err)
+		}
+		log.Printf("Linked %d games to venues", linked)
+	}
+}
@@ -0,0 +1,40 @@
+// Command materialize-series creates the upcoming occurrences of every
+// recurring series as games, so they show up in game search. The gateway
+// does the same every SERIES_MATERIALIZE_INTERVAL; this runs it by hand, e.g.
+// with a longer horizon.
+//
+//	go run ./cmd/materialize-series                  # up to SERIES_HORIZON (default 8 weeks) ahead
+//	go run ./cmd/materialize-series -horizon 2160h   # up to 90 days ahead
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"trego-backend/api-gateway/config"
+	"trego-backend/database"
+	"trego-backend/repository"
+)
+
+func main() {
+	conf := config.New()
+	horizon := flag.Duration("horizon", conf.SeriesHorizon, "how far ahead to create occurrences")
+	flag.Parse()
+
+	if err := database.Connect(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.RunMigrations(); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	series := repository.NewSeriesRepository(database.GetDB()).WithHorizon(*horizon).WithReminderOffsets(conf.ReminderOffsets)
+	created, err := series.MaterializeAll(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to materialize series (%d games created before the error): %v", created, err)
+	}
+	log.Printf("Materialized series up to %s ahead; %d games created", *horizon, created)
+}
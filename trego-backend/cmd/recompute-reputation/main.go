@@ -0,0 +1,66 @@
+// Command recompute-reputation rebuilds users.reputation from the reputation
+// ledger, e.g. after ledger entries were corrected by hand.
+//
+//	go run ./cmd/recompute-reputation            # every user
+//	go run ./cmd/recompute-reputation -user <id> # a single user
+//	go run ./cmd/recompute-reputation -enqueue   # on the gateway's job queue
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"trego-backend/api-gateway/jobs"
+	"trego-backend/api-gateway/queue"
+	"trego-backend/database"
+	"trego-backend/repository"
+)
+
+func main() {
+	userID := flag.String("user", "", "only recompute this user")
+	enqueue := flag.Bool("enqueue", false, "queue the recompute for the gateway's job workers instead of running it")
+	flag.Parse()
+
+	if err := database.Connect(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.RunMigrations(); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if *enqueue {
+		args := jobs.ReputationRecomputeArgs{UserID: *userID}
+		job, enqueued, err := queue.Enqueue(ctx, repository.NewJobRepository(database.GetDB()), args,
+			queue.EnqueueOptions{UniqueKey: args.UniqueKey()})
+		if err != nil {
+			log.Fatalf("Failed to enqueue reputation recompute: %v", err)
+		}
+		if enqueued {
+			log.Printf("Queued reputation recompute as job %s", job.JobID)
+		} else {
+			log.Printf("Reputation recompute already queued as job %s", job.JobID)
+		}
+		return
+	}
+
+	reputation := repository.NewReputationRepository(database.GetDB())
+
+	if *userID != "" {
+		if err := reputation.Recompute(ctx, *userID); err != nil {
+			log.Fatalf("Failed to recompute reputation of %s: %v", *userID, err)
+		}
+		log.Printf("Recomputed reputation of %s", *userID)
+		return
+	}
+
+	changed, err := reputation.RecomputeAll(ctx)
+	if err != nil {
+		log.Fatalf("Failed to recompute reputation: %v", err)
+	}
+	log.Printf("Recomputed reputation from the ledger; %d users changed", changed)
+}
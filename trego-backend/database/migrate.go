@@ -2,10 +2,9 @@ package database
 
 import (
 	"context"
+	_ "embed"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -128,28 +127,186 @@ func getAvailableMigrations() []Migration {
 			UpSQL:       getInitialSchemaSQL(),
 			DownSQL:     getInitialSchemaDownSQL(),
 		},
+		{
+			Version:     "002_oauth_login_states",
+			Description: "Create OAuth login state table",
+			UpSQL:       getOAuthLoginStatesSQL(),
+			DownSQL:     getOAuthLoginStatesDownSQL(),
+		},
+		{
+			Version:     "003_auth_sessions",
+			Description: "Create token signing key and session tables",
+			UpSQL:       getAuthSessionsSQL(),
+			DownSQL:     getAuthSessionsDownSQL(),
+		},
+		{
+			Version:     "004_game_search_index",
+			Description: "Create keyset pagination index for game search",
+			UpSQL:       getGameSearchIndexSQL(),
+			DownSQL:     getGameSearchIndexDownSQL(),
+		},
+		{
+			Version:     "005_game_waitlist",
+			Description: "Create game waitlist table",
+			UpSQL:       getGameWaitlistSQL(),
+			DownSQL:     getGameWaitlistDownSQL(),
+		},
+		{
+			Version:     "006_game_invitations",
+			Description: "Create game invitation and invite link tables",
+			UpSQL:       getGameInvitationsSQL(),
+			DownSQL:     getGameInvitationsDownSQL(),
+		},
+		{
+			Version:     "007_attendance_marking",
+			Description: "Track when game attendance was marked",
+			UpSQL:       getAttendanceMarkingSQL(),
+			DownSQL:     getAttendanceMarkingDownSQL(),
+		},
+		{
+			Version:     "008_reputation_ledger",
+			Description: "Create reputation ledger table",
+			UpSQL:       getReputationLedgerSQL(),
+			DownSQL:     getReputationLedgerDownSQL(),
+		},
+		{
+			Version:     "009_game_ratings",
+			Description: "Create post-game ratings table",
+			UpSQL:       getGameRatingsSQL(),
+			DownSQL:     getGameRatingsDownSQL(),
+		},
+		{
+			Version:     "010_geolocation",
+			Description: "Add coordinates to games and users",
+			UpSQL:       getGeolocationSQL(),
+			DownSQL:     getGeolocationDownSQL(),
+		},
+		{
+			Version:     "011_venues",
+			Description: "Create venue, court, opening hours and alias tables",
+			UpSQL:       getVenuesSQL(),
+			DownSQL:     getVenuesDownSQL(),
+		},
+		{
+			Version:     "012_court_bookings",
+			Description: "Prevent overlapping games on the same court",
+			UpSQL:       getCourtBookingsSQL(),
+			DownSQL:     getCourtBookingsDownSQL(),
+		},
+		{
+			Version:     "013_game_series",
+			Description: "Create recurring game series and holidays",
+			UpSQL:       getGameSeriesSQL(),
+			DownSQL:     getGameSeriesDownSQL(),
+		},
+		{
+			Version:     "014_game_lifecycle",
+			Description: "Add game lifecycle status and transition timestamps",
+			UpSQL:       getGameLifecycleSQL(),
+			DownSQL:     getGameLifecycleDownSQL(),
+		},
+		{
+			Version:     "015_calendar_feeds",
+			Description: "Create per-user calendar feed tokens",
+			UpSQL:       getCalendarFeedsSQL(),
+			DownSQL:     getCalendarFeedsDownSQL(),
+		},
+		{
+			Version:     "016_game_teams",
+			Description: "Create balanced team splits for games",
+			UpSQL:       getGameTeamsSQL(),
+			DownSQL:     getGameTeamsDownSQL(),
+		},
+		{
+			Version:     "017_game_events",
+			Description: "Create the game event log with change notifications",
+			UpSQL:       getGameEventsSQL(),
+			DownSQL:     getGameEventsDownSQL(),
+		},
+		{
+			Version:     "018_game_messages",
+			Description: "Create per-game message threads with mentions",
+			UpSQL:       getGameMessagesSQL(),
+			DownSQL:     getGameMessagesDownSQL(),
+		},
+		{
+			Version:     "019_notifications",
+			Description: "Create the notification inbox, preferences, push subscriptions and delivery queue",
+			UpSQL:       getNotificationsSQL(),
+			DownSQL:     getNotificationsDownSQL(),
+		},
+		{
+			Version:     "020_game_reminders",
+			Description: "Record the reminders sent before games",
+			UpSQL:       getGameRemindersSQL(),
+			DownSQL:     getGameRemindersDownSQL(),
+		},
+		{
+			Version:     "021_jobs",
+			Description: "Create the background job queue",
+			UpSQL:       getJobsSQL(),
+			DownSQL:     getJobsDownSQL(),
+		},
+		{
+			Version:     "022_game_event_prunes",
+			Description: "Record the highest pruned game event per game",
+			UpSQL:       getGameEventPrunesSQL(),
+			DownSQL:     getGameEventPrunesDownSQL(),
+		},
+		{
+			Version:     "023_former_players",
+			Description: "Record the players who left a game's roster",
+			UpSQL:       getFormerPlayersSQL(),
+			DownSQL:     getFormerPlayersDownSQL(),
+		},
+		{
+			Version:     "024_game_event_recipients",
+			Description: "Record the recipients of events about deleted games",
+			UpSQL:       getGameEventRecipientsSQL(),
+			DownSQL:     getGameEventRecipientsDownSQL(),
+		},
+		{
+			Version:     "025_push_delivery_endpoints",
+			Description: "Record the browsers push deliveries reached",
+			UpSQL:       getPushDeliveryEndpointsSQL(),
+			DownSQL:     getPushDeliveryEndpointsDownSQL(),
+		},
+		{
+			Version:     "026_notification_jobs",
+			Description: "Queue a job for each pending notification delivery",
+			UpSQL:       getNotificationJobsSQL(),
+			DownSQL:     getNotificationJobsDownSQL(),
+		},
+		{
+			Version:     "027_calendar_feed_changes",
+			Description: "Record when games last left each calendar feed",
+			UpSQL:       getCalendarFeedChangesSQL(),
+			DownSQL:     getCalendarFeedChangesDownSQL(),
+		},
+		{
+			Version:     "028_series_sport_restrict",
+			Description: "Keep sports used by series from being deleted",
+			UpSQL:       getSeriesSportRestrictSQL(),
+			DownSQL:     getSeriesSportRestrictDownSQL(),
+		},
+		{
+			Version:     "029_delivery_leases",
+			Description: "Lease notification deliveries to the job sending them",
+			UpSQL:       getDeliveryLeasesSQL(),
+			DownSQL:     getDeliveryLeasesDownSQL(),
+		},
 	}
 }
 
+// initialSchemaSQL is schema.sql, embedded so migrations do not depend on
+// the working directory, e.g. under go test
+//
+//go:embed schema.sql
+var initialSchemaSQL string
+
 // getInitialSchemaSQL returns the initial schema SQL
 func getInitialSchemaSQL() string {
-	// Read the schema.sql file
-	schemaPath := filepath.Join("database", "schema.sql")
-	schemaSQL, err := os.ReadFile(schemaPath)
-	if err != nil {
-		log.Printf("Warning: Could not read schema.sql file: %v", err)
-		// Return a minimal schema if file can't be read
-		return `
-			CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
-			CREATE TABLE IF NOT EXISTS users (
-				user_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
-				name TEXT NOT NULL,
-				email TEXT UNIQUE NOT NULL,
-				created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-			);
-		`
-	}
-	return string(schemaSQL)
+	return initialSchemaSQL
 }
 
 // getInitialSchemaDownSQL returns the SQL to rollback the initial schema
@@ -0,0 +1,18 @@
+package database
+
+// getAttendanceMarkingSQL returns the SQL tracking when attendance was marked
+func getAttendanceMarkingSQL() string {
+	return `
+		ALTER TABLE game_players ADD COLUMN IF NOT EXISTS attendance_marked_at TIMESTAMP WITH TIME ZONE;
+
+		CREATE INDEX IF NOT EXISTS idx_game_players_user_attendance ON game_players(user_id, attendance);
+	`
+}
+
+// getAttendanceMarkingDownSQL returns the SQL to rollback attendance marking tracking
+func getAttendanceMarkingDownSQL() string {
+	return `
+		DROP INDEX IF EXISTS idx_game_players_user_attendance;
+		ALTER TABLE game_players DROP COLUMN IF EXISTS attendance_marked_at;
+	`
+}
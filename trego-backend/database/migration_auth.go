@@ -0,0 +1,34 @@
+package database
+
+// getAuthSessionsSQL returns the SQL creating the token signing key and session tables
+func getAuthSessionsSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS auth_signing_keys (
+			key_id TEXT PRIMARY KEY,
+			secret BYTEA NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			expires_at TIMESTAMP WITH TIME ZONE
+		);
+
+		CREATE TABLE IF NOT EXISTS auth_sessions (
+			session_id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			refresh_token_id TEXT NOT NULL,
+			is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_auth_sessions_user_id ON auth_sessions(user_id);
+	`
+}
+
+// getAuthSessionsDownSQL returns the SQL to rollback the token signing key and session tables
+func getAuthSessionsDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS auth_sessions CASCADE;
+		DROP TABLE IF EXISTS auth_signing_keys CASCADE;
+	`
+}
@@ -0,0 +1,47 @@
+package database
+
+// getCalendarFeedChangesSQL returns the SQL recording when games last left
+// a user's calendar feed, as the user left a game or a game they host or
+// play in was deleted. Those leave no updated_at behind, so the feed's last
+// modification would not move forward without it.
+func getCalendarFeedChangesSQL() string {
+	return `
+		ALTER TABLE calendar_feeds ADD COLUMN IF NOT EXISTS changed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+
+		CREATE OR REPLACE FUNCTION touch_player_calendar_feed()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			UPDATE calendar_feeds SET changed_at = NOW() WHERE user_id = OLD.user_id;
+			RETURN OLD;
+		END;
+		$$ language 'plpgsql';
+
+		CREATE OR REPLACE FUNCTION touch_host_calendar_feed()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			UPDATE calendar_feeds SET changed_at = NOW() WHERE user_id = OLD.host_id;
+			RETURN OLD;
+		END;
+		$$ language 'plpgsql';
+
+		DROP TRIGGER IF EXISTS touch_calendar_feed_on_player_delete ON game_players;
+		CREATE TRIGGER touch_calendar_feed_on_player_delete AFTER DELETE ON game_players
+			FOR EACH ROW EXECUTE FUNCTION touch_player_calendar_feed();
+
+		DROP TRIGGER IF EXISTS touch_calendar_feed_on_game_delete ON games;
+		CREATE TRIGGER touch_calendar_feed_on_game_delete AFTER DELETE ON games
+			FOR EACH ROW EXECUTE FUNCTION touch_host_calendar_feed();
+	`
+}
+
+// getCalendarFeedChangesDownSQL returns the SQL to rollback the calendar
+// feed changes
+func getCalendarFeedChangesDownSQL() string {
+	return `
+		DROP TRIGGER IF EXISTS touch_calendar_feed_on_player_delete ON game_players;
+		DROP TRIGGER IF EXISTS touch_calendar_feed_on_game_delete ON games;
+		DROP FUNCTION IF EXISTS touch_player_calendar_feed();
+		DROP FUNCTION IF EXISTS touch_host_calendar_feed();
+		ALTER TABLE calendar_feeds DROP COLUMN IF EXISTS changed_at;
+	`
+}
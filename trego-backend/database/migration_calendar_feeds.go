@@ -0,0 +1,22 @@
+package database
+
+// getCalendarFeedsSQL returns the SQL creating the calendar feed table. Each
+// user has at most one feed; only the SHA-256 hash of its secret token is
+// stored, so a lost feed URL is replaced rather than shown again.
+func getCalendarFeedsSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS calendar_feeds (
+			user_id TEXT PRIMARY KEY,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+		);
+	`
+}
+
+// getCalendarFeedsDownSQL returns the SQL to rollback the calendar feed table
+func getCalendarFeedsDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS calendar_feeds;
+	`
+}
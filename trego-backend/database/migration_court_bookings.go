@@ -0,0 +1,23 @@
+package database
+
+// getCourtBookingsSQL returns the SQL preventing two games from booking the
+// same court for overlapping times. btree_gist lets the exclusion constraint
+// combine equality on court_id with overlap of the time range.
+func getCourtBookingsSQL() string {
+	return `
+		CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+		ALTER TABLE games DROP CONSTRAINT IF EXISTS games_court_no_overlap;
+		ALTER TABLE games ADD CONSTRAINT games_court_no_overlap EXCLUDE USING gist (
+			court_id WITH =,
+			tstzrange(start_time, end_time, '[)') WITH &&
+		) WHERE (court_id IS NOT NULL);
+	`
+}
+
+// getCourtBookingsDownSQL returns the SQL to rollback court booking enforcement
+func getCourtBookingsDownSQL() string {
+	return `
+		ALTER TABLE games DROP CONSTRAINT IF EXISTS games_court_no_overlap;
+	`
+}
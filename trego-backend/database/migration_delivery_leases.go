@@ -0,0 +1,17 @@
+package database
+
+// getDeliveryLeasesSQL returns the SQL leasing notification deliveries to
+// the job sending them, so the claim is committed before the message is
+// sent instead of holding a row lock and a transaction open meanwhile
+func getDeliveryLeasesSQL() string {
+	return `
+		ALTER TABLE notification_deliveries ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+	`
+}
+
+// getDeliveryLeasesDownSQL returns the SQL to rollback the delivery leases
+func getDeliveryLeasesDownSQL() string {
+	return `
+		ALTER TABLE notification_deliveries DROP COLUMN IF EXISTS locked_until;
+	`
+}
@@ -0,0 +1,34 @@
+package database
+
+// getFormerPlayersSQL returns the SQL creating the record of players who left
+// a game's roster, who can still read its message thread up to left_at. It
+// is backfilled from the player_left events not yet pruned from the game
+// event log.
+func getFormerPlayersSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS game_former_players (
+			game_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			left_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (game_id, user_id),
+			FOREIGN KEY (game_id) REFERENCES games(game_id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+		);
+
+		INSERT INTO game_former_players (game_id, user_id, left_at)
+		SELECT e.game_id, e.user_id, MAX(e.created_at)
+		FROM game_events e
+		JOIN games g ON g.game_id = e.game_id
+		JOIN users u ON u.user_id = e.user_id
+		WHERE e.type = 'player_left'
+		GROUP BY e.game_id, e.user_id
+		ON CONFLICT (game_id, user_id) DO NOTHING;
+	`
+}
+
+// getFormerPlayersDownSQL returns the SQL to rollback the former players
+func getFormerPlayersDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS game_former_players;
+	`
+}
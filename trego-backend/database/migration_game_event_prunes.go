@@ -0,0 +1,21 @@
+package database
+
+// getGameEventPrunesSQL returns the SQL creating the prune watermark of the
+// game event log: the highest event ID pruned from each game. Streams
+// resuming from an earlier event have missed events and are reset.
+// Prunes that happened before this table existed are not known.
+func getGameEventPrunesSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS game_event_prunes (
+			game_id TEXT PRIMARY KEY,
+			pruned_through BIGINT NOT NULL
+		);
+	`
+}
+
+// getGameEventPrunesDownSQL returns the SQL to rollback the prune watermark
+func getGameEventPrunesDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS game_event_prunes;
+	`
+}
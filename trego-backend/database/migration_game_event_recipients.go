@@ -0,0 +1,21 @@
+package database
+
+// getGameEventRecipientsSQL returns the SQL recording, on game events, who
+// to notify and the game's time zone when the game will be gone by the time
+// the event is notified, as for the cancellation recorded when an upcoming
+// game is deleted
+func getGameEventRecipientsSQL() string {
+	return `
+		ALTER TABLE game_events ADD COLUMN IF NOT EXISTS recipients TEXT[];
+		ALTER TABLE game_events ADD COLUMN IF NOT EXISTS timezone TEXT;
+	`
+}
+
+// getGameEventRecipientsDownSQL returns the SQL to rollback the recorded
+// recipients
+func getGameEventRecipientsDownSQL() string {
+	return `
+		ALTER TABLE game_events DROP COLUMN IF EXISTS timezone;
+		ALTER TABLE game_events DROP COLUMN IF EXISTS recipients;
+	`
+}
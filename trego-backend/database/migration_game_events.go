@@ -0,0 +1,44 @@
+package database
+
+// getGameEventsSQL returns the SQL creating the game event log streamed to
+// clients. Events are not tied to the games table so a game's deletion can be
+// recorded too; old events are pruned instead. Every insert notifies the
+// game_events channel with the game ID, waking the streams of that game on
+// every gateway instance.
+func getGameEventsSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS game_events (
+			event_id BIGSERIAL PRIMARY KEY,
+			game_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			-- The player the event is about, for roster events
+			user_id TEXT,
+			-- The game as of the event, when there is one
+			game JSONB,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_game_events_game_id_event_id ON game_events(game_id, event_id);
+		CREATE INDEX IF NOT EXISTS idx_game_events_created_at ON game_events(created_at);
+
+		CREATE OR REPLACE FUNCTION notify_game_event()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			PERFORM pg_notify('game_events', NEW.game_id);
+			RETURN NEW;
+		END;
+		$$ language 'plpgsql';
+
+		DROP TRIGGER IF EXISTS notify_game_events ON game_events;
+		CREATE TRIGGER notify_game_events AFTER INSERT ON game_events
+			FOR EACH ROW EXECUTE FUNCTION notify_game_event();
+	`
+}
+
+// getGameEventsDownSQL returns the SQL to rollback the game event log
+func getGameEventsDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS game_events;
+		DROP FUNCTION IF EXISTS notify_game_event();
+	`
+}
@@ -0,0 +1,51 @@
+package database
+
+// getGameMessagesSQL returns the SQL creating game message threads and their
+// mentions. Deleted messages keep their row so the thread shows where they
+// were; deleted_by tells an author's deletion from the host's removal.
+func getGameMessagesSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS game_messages (
+			message_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+			game_id TEXT NOT NULL,
+			author_id TEXT,
+			body TEXT NOT NULL CHECK (char_length(body) BETWEEN 1 AND 2000),
+			edited_at TIMESTAMP WITH TIME ZONE,
+			pinned_at TIMESTAMP WITH TIME ZONE,
+			pinned_by TEXT,
+			deleted_at TIMESTAMP WITH TIME ZONE,
+			deleted_by TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			FOREIGN KEY (game_id) REFERENCES games(game_id) ON DELETE CASCADE,
+			FOREIGN KEY (author_id) REFERENCES users(user_id) ON DELETE SET NULL,
+			FOREIGN KEY (pinned_by) REFERENCES users(user_id) ON DELETE SET NULL,
+			FOREIGN KEY (deleted_by) REFERENCES users(user_id) ON DELETE SET NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_game_messages_game_created ON game_messages(game_id, created_at DESC, message_id DESC);
+		CREATE INDEX IF NOT EXISTS idx_game_messages_pinned ON game_messages(game_id, pinned_at) WHERE pinned_at IS NOT NULL;
+
+		CREATE TABLE IF NOT EXISTS game_message_mentions (
+			message_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			PRIMARY KEY (message_id, user_id),
+			FOREIGN KEY (message_id) REFERENCES game_messages(message_id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_game_message_mentions_user ON game_message_mentions(user_id);
+
+		DROP TRIGGER IF EXISTS update_game_messages_updated_at ON game_messages;
+		CREATE TRIGGER update_game_messages_updated_at BEFORE UPDATE ON game_messages
+			FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+	`
+}
+
+// getGameMessagesDownSQL returns the SQL to rollback game messages
+func getGameMessagesDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS game_message_mentions;
+		DROP TABLE IF EXISTS game_messages;
+	`
+}
@@ -0,0 +1,27 @@
+package database
+
+// getGameRemindersSQL returns the SQL creating the record of the reminders
+// sent for each game, one row per reminder offset. A reminder is sent by
+// whichever instance inserts its row first; moving a game's time deletes the
+// rows of reminders that fall due again.
+func getGameRemindersSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS game_reminders (
+			game_id TEXT NOT NULL,
+			-- How long before start_time the reminder is due
+			offset_seconds INTEGER NOT NULL CHECK (offset_seconds > 0),
+			-- The start_time the reminder was sent for
+			start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+			sent_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (game_id, offset_seconds),
+			FOREIGN KEY (game_id) REFERENCES games(game_id) ON DELETE CASCADE
+		);
+	`
+}
+
+// getGameRemindersDownSQL returns the SQL to rollback game reminders
+func getGameRemindersDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS game_reminders;
+	`
+}
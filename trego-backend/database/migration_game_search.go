@@ -0,0 +1,15 @@
+package database
+
+// getGameSearchIndexSQL returns the SQL creating the keyset pagination index for game search
+func getGameSearchIndexSQL() string {
+	return `
+		CREATE INDEX IF NOT EXISTS idx_games_start_time_game_id ON games(start_time, game_id);
+	`
+}
+
+// getGameSearchIndexDownSQL returns the SQL to rollback the game search index
+func getGameSearchIndexDownSQL() string {
+	return `
+		DROP INDEX IF EXISTS idx_games_start_time_game_id;
+	`
+}
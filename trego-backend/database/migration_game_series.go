@@ -0,0 +1,71 @@
+package database
+
+// getGameSeriesSQL returns the SQL creating recurring game series and the
+// holiday calendar, and linking games to the series occurrence they were
+// created for
+func getGameSeriesSQL() string {
+	return `
+		-- A series is a game template repeated by a weekly RRULE. first_start is
+		-- the first occurrence; later occurrences keep its wall-clock time in
+		-- the series time zone. Occurrences up to materialized_until exist as
+		-- games and are never created again, even if their game was deleted.
+		CREATE TABLE IF NOT EXISTS game_series (
+			series_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+			host_id TEXT NOT NULL,
+			sport_name TEXT NOT NULL,
+			title TEXT NOT NULL,
+			description TEXT,
+			location TEXT NOT NULL,
+			latitude DOUBLE PRECISION,
+			longitude DOUBLE PRECISION,
+			venue_id TEXT REFERENCES venues(venue_id) ON DELETE SET NULL,
+			court_id TEXT REFERENCES courts(court_id) ON DELETE SET NULL,
+			skill_range TEXT,
+			capacity INTEGER NOT NULL CHECK (capacity > 0),
+			skill_level TEXT CHECK (skill_level IN ('beginner', 'intermediate', 'advanced')),
+			visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'invite-only')),
+			first_start TIMESTAMP WITH TIME ZONE NOT NULL,
+			duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
+			timezone TEXT NOT NULL DEFAULT 'UTC',
+			rrule TEXT NOT NULL,
+			skip_holidays BOOLEAN NOT NULL DEFAULT FALSE,
+			materialized_until TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			FOREIGN KEY (host_id) REFERENCES users(user_id) ON DELETE CASCADE,
+			-- Sports still used by series cannot be deleted, like those used by games
+			FOREIGN KEY (sport_name) REFERENCES sports(sport_name) ON DELETE RESTRICT
+		);
+
+		-- Dates series with skip_holidays do not play on
+		CREATE TABLE IF NOT EXISTS holidays (
+			holiday_date DATE PRIMARY KEY,
+			name TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		-- series_occurrence is the slot the game was created for; it stays put
+		-- when the single game is moved
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS series_id TEXT REFERENCES game_series(series_id) ON DELETE SET NULL;
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS series_occurrence TIMESTAMP WITH TIME ZONE;
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_games_series_occurrence ON games(series_id, series_occurrence) WHERE series_id IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_game_series_host_id ON game_series(host_id);
+		CREATE INDEX IF NOT EXISTS idx_game_series_materialized_until ON game_series(materialized_until);
+
+		DROP TRIGGER IF EXISTS update_game_series_updated_at ON game_series;
+		CREATE TRIGGER update_game_series_updated_at BEFORE UPDATE ON game_series
+			FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+	`
+}
+
+// getGameSeriesDownSQL returns the SQL to rollback game series
+func getGameSeriesDownSQL() string {
+	return `
+		DROP INDEX IF EXISTS idx_games_series_occurrence;
+		ALTER TABLE games DROP COLUMN IF EXISTS series_occurrence;
+		ALTER TABLE games DROP COLUMN IF EXISTS series_id;
+		DROP TABLE IF EXISTS holidays;
+		DROP TABLE IF EXISTS game_series;
+	`
+}
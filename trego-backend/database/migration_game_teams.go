@@ -0,0 +1,43 @@
+package database
+
+// getGameTeamsSQL returns the SQL storing the team split of a game. The
+// split keeps its team count and constraints so it can be re-shuffled;
+// members reference the roster, so players who leave drop out of their team.
+func getGameTeamsSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS game_team_splits (
+			game_id TEXT PRIMARY KEY,
+			team_count INTEGER NOT NULL CHECK (team_count >= 2),
+			-- Groups of user IDs to put on the same team, e.g. [["u1", "u2"]]
+			keep_together JSONB NOT NULL DEFAULT '[]',
+			-- Groups of user IDs to put on different teams
+			keep_apart JSONB NOT NULL DEFAULT '[]',
+			created_by TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			FOREIGN KEY (game_id) REFERENCES games(game_id) ON DELETE CASCADE,
+			FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS game_team_members (
+			game_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			team_number INTEGER NOT NULL CHECK (team_number >= 1),
+			PRIMARY KEY (game_id, user_id),
+			FOREIGN KEY (game_id) REFERENCES game_team_splits(game_id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id, game_id) REFERENCES game_players(user_id, game_id) ON DELETE CASCADE
+		);
+
+		DROP TRIGGER IF EXISTS update_game_team_splits_updated_at ON game_team_splits;
+		CREATE TRIGGER update_game_team_splits_updated_at BEFORE UPDATE ON game_team_splits
+			FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+	`
+}
+
+// getGameTeamsDownSQL returns the SQL to rollback game teams
+func getGameTeamsDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS game_team_members;
+		DROP TABLE IF EXISTS game_team_splits;
+	`
+}
@@ -0,0 +1,41 @@
+package database
+
+// getGeolocationSQL returns the SQL adding coordinates to games and users.
+// Distances are computed with a pure-SQL haversine, so no extension is needed;
+// the coordinate index narrows radius and bounding-box searches first.
+func getGeolocationSQL() string {
+	return `
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+		ALTER TABLE games DROP CONSTRAINT IF EXISTS games_coordinates_check;
+		ALTER TABLE games ADD CONSTRAINT games_coordinates_check CHECK (
+			(latitude IS NULL) = (longitude IS NULL)
+			AND latitude BETWEEN -90 AND 90
+			AND longitude BETWEEN -180 AND 180
+		);
+		ALTER TABLE users DROP CONSTRAINT IF EXISTS users_coordinates_check;
+		ALTER TABLE users ADD CONSTRAINT users_coordinates_check CHECK (
+			(latitude IS NULL) = (longitude IS NULL)
+			AND latitude BETWEEN -90 AND 90
+			AND longitude BETWEEN -180 AND 180
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_games_coordinates ON games(latitude, longitude) WHERE latitude IS NOT NULL;
+	`
+}
+
+// getGeolocationDownSQL returns the SQL to rollback game and user coordinates
+func getGeolocationDownSQL() string {
+	return `
+		DROP INDEX IF EXISTS idx_games_coordinates;
+		ALTER TABLE users DROP CONSTRAINT IF EXISTS users_coordinates_check;
+		ALTER TABLE games DROP CONSTRAINT IF EXISTS games_coordinates_check;
+		ALTER TABLE users DROP COLUMN IF EXISTS longitude;
+		ALTER TABLE users DROP COLUMN IF EXISTS latitude;
+		ALTER TABLE games DROP COLUMN IF EXISTS longitude;
+		ALTER TABLE games DROP COLUMN IF EXISTS latitude;
+	`
+}
@@ -0,0 +1,55 @@
+package database
+
+// getGameInvitationsSQL returns the SQL creating the invitation and invite link tables
+func getGameInvitationsSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS game_invite_links (
+			link_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+			game_id TEXT NOT NULL,
+			created_by TEXT NOT NULL,
+			max_uses INTEGER CHECK (max_uses > 0),
+			use_count INTEGER NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			FOREIGN KEY (game_id) REFERENCES games(game_id) ON DELETE CASCADE,
+			FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE CASCADE,
+			CONSTRAINT invite_link_use_limit CHECK (max_uses IS NULL OR use_count <= max_uses)
+		);
+
+		CREATE TABLE IF NOT EXISTS game_invitations (
+			invitation_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+			game_id TEXT NOT NULL,
+			invited_by TEXT NOT NULL,
+			invitee_user_id TEXT,
+			invitee_email TEXT,
+			invite_link_id TEXT,
+			status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'revoked', 'expired')),
+			expires_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			responded_at TIMESTAMP WITH TIME ZONE,
+			FOREIGN KEY (game_id) REFERENCES games(game_id) ON DELETE CASCADE,
+			FOREIGN KEY (invited_by) REFERENCES users(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (invitee_user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (invite_link_id) REFERENCES game_invite_links(link_id) ON DELETE SET NULL,
+			CONSTRAINT invitation_has_invitee CHECK (invitee_user_id IS NOT NULL OR invitee_email IS NOT NULL)
+		);
+
+		-- A user or email has at most one open invitation per game
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_game_invitations_open_user ON game_invitations(game_id, invitee_user_id)
+			WHERE status IN ('pending', 'accepted') AND invitee_user_id IS NOT NULL;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_game_invitations_open_email ON game_invitations(game_id, invitee_email)
+			WHERE status IN ('pending', 'accepted') AND invitee_email IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_game_invitations_invitee_user_id ON game_invitations(invitee_user_id);
+		CREATE INDEX IF NOT EXISTS idx_game_invitations_invitee_email ON game_invitations(invitee_email);
+		CREATE INDEX IF NOT EXISTS idx_game_invite_links_game_id ON game_invite_links(game_id);
+	`
+}
+
+// getGameInvitationsDownSQL returns the SQL to rollback the invitation and invite link tables
+func getGameInvitationsDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS game_invitations;
+		DROP TABLE IF EXISTS game_invite_links;
+	`
+}
@@ -0,0 +1,46 @@
+package database
+
+// getJobsSQL returns the SQL creating the background job queue. Workers
+// claim pending jobs with FOR UPDATE SKIP LOCKED and hold them until
+// locked_until; jobs whose worker died are rescued once it passes. A job's
+// unique_key is unique among the pending and running jobs of its kind, so
+// finished jobs do not block enqueueing it again.
+func getJobsSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS jobs (
+			job_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+			kind TEXT NOT NULL,
+			args JSONB NOT NULL DEFAULT '{}',
+			status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'dead')),
+			unique_key TEXT,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL DEFAULT 5 CHECK (max_attempts > 0),
+			scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			locked_by TEXT,
+			locked_until TIMESTAMP WITH TIME ZONE,
+			last_error TEXT,
+			-- Every failed attempt as {attempt, error, at}
+			errors JSONB NOT NULL DEFAULT '[]',
+			completed_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_jobs_pending ON jobs(kind, scheduled_at) WHERE status = 'pending';
+		CREATE INDEX IF NOT EXISTS idx_jobs_running ON jobs(locked_until) WHERE status = 'running';
+		CREATE INDEX IF NOT EXISTS idx_jobs_status_created ON jobs(status, created_at DESC, job_id DESC);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_unique_key ON jobs(kind, unique_key)
+			WHERE unique_key IS NOT NULL AND status IN ('pending', 'running');
+
+		DROP TRIGGER IF EXISTS update_jobs_updated_at ON jobs;
+		CREATE TRIGGER update_jobs_updated_at BEFORE UPDATE ON jobs
+			FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+	`
+}
+
+// getJobsDownSQL returns the SQL to rollback the job queue
+func getJobsDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS jobs;
+	`
+}
@@ -0,0 +1,22 @@
+package database
+
+// getNotificationJobsSQL returns the SQL queueing a notification delivery
+// job for each pending delivery, now that deliveries are sent by the job
+// worker. Game reminders are queued by the gateway on start, as their
+// offsets are configuration.
+func getNotificationJobsSQL() string {
+	return `
+		INSERT INTO jobs (kind, args, scheduled_at)
+		SELECT 'notification_delivery', jsonb_build_object('notification_id', notification_id, 'channel', channel), next_attempt_at
+		FROM notification_deliveries
+		WHERE status = 'pending';
+	`
+}
+
+// getNotificationJobsDownSQL returns the SQL to rollback the notification
+// jobs, dropping the queued reminder and delivery jobs
+func getNotificationJobsDownSQL() string {
+	return `
+		DELETE FROM jobs WHERE kind IN ('notification_delivery', 'game_reminder') AND status IN ('pending', 'running');
+	`
+}
@@ -0,0 +1,89 @@
+package database
+
+// getNotificationsSQL returns the SQL creating the notification inbox, the
+// per-user channel preferences and web push subscriptions, and the delivery
+// queue of each notification's email and push copies. Game events get a
+// notified_at mark once turned into notifications; the events already in
+// the log are marked so they are not announced late.
+func getNotificationsSQL() string {
+	return `
+		ALTER TABLE game_events ADD COLUMN IF NOT EXISTS notified_at TIMESTAMP WITH TIME ZONE;
+		UPDATE game_events SET notified_at = NOW() WHERE notified_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_game_events_unnotified ON game_events(event_id) WHERE notified_at IS NULL;
+
+		CREATE TABLE IF NOT EXISTS notifications (
+			notification_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+			user_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			game_id TEXT,
+			title TEXT NOT NULL,
+			body TEXT NOT NULL,
+			-- The game event the notification was made for, so it is made once
+			event_id BIGINT,
+			read_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (event_id, user_id),
+			FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (game_id) REFERENCES games(game_id) ON DELETE SET NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(user_id, created_at DESC, notification_id DESC);
+		CREATE INDEX IF NOT EXISTS idx_notifications_user_unread ON notifications(user_id) WHERE read_at IS NULL;
+
+		CREATE TABLE IF NOT EXISTS notification_preferences (
+			user_id TEXT PRIMARY KEY,
+			email_enabled BOOLEAN NOT NULL DEFAULT true,
+			push_enabled BOOLEAN NOT NULL DEFAULT true,
+			-- Quiet hours are local times (HH:MM) in timezone; a start after the
+			-- end spans midnight
+			quiet_hours_start TEXT CHECK (quiet_hours_start ~ '^([01][0-9]|2[0-3]):[0-5][0-9]$'),
+			quiet_hours_end TEXT CHECK (quiet_hours_end ~ '^([01][0-9]|2[0-3]):[0-5][0-9]$'),
+			timezone TEXT NOT NULL DEFAULT 'UTC',
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			CHECK ((quiet_hours_start IS NULL) = (quiet_hours_end IS NULL)),
+			FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+		);
+
+		DROP TRIGGER IF EXISTS update_notification_preferences_updated_at ON notification_preferences;
+		CREATE TRIGGER update_notification_preferences_updated_at BEFORE UPDATE ON notification_preferences
+			FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+		CREATE TABLE IF NOT EXISTS push_subscriptions (
+			subscription_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+			user_id TEXT NOT NULL,
+			endpoint TEXT NOT NULL UNIQUE,
+			p256dh TEXT NOT NULL,
+			auth TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user_id ON push_subscriptions(user_id);
+
+		CREATE TABLE IF NOT EXISTS notification_deliveries (
+			notification_id TEXT NOT NULL,
+			channel TEXT NOT NULL CHECK (channel IN ('email', 'push')),
+			status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'skipped', 'failed')),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			last_error TEXT,
+			sent_at TIMESTAMP WITH TIME ZONE,
+			PRIMARY KEY (notification_id, channel),
+			FOREIGN KEY (notification_id) REFERENCES notifications(notification_id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_notification_deliveries_pending ON notification_deliveries(next_attempt_at) WHERE status = 'pending';
+	`
+}
+
+// getNotificationsDownSQL returns the SQL to rollback notifications
+func getNotificationsDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS notification_deliveries;
+		DROP TABLE IF EXISTS push_subscriptions;
+		DROP TABLE IF EXISTS notification_preferences;
+		DROP TABLE IF EXISTS notifications;
+		DROP INDEX IF EXISTS idx_game_events_unnotified;
+		ALTER TABLE game_events DROP COLUMN IF EXISTS notified_at;
+	`
+}
@@ -0,0 +1,23 @@
+package database
+
+// getOAuthLoginStatesSQL returns the SQL creating the OAuth login state table
+func getOAuthLoginStatesSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS oauth_login_states (
+			state TEXT PRIMARY KEY,
+			code_verifier TEXT NOT NULL,
+			nonce TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_oauth_login_states_expires_at ON oauth_login_states(expires_at);
+	`
+}
+
+// getOAuthLoginStatesDownSQL returns the SQL to rollback the OAuth login state table
+func getOAuthLoginStatesDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS oauth_login_states CASCADE;
+	`
+}
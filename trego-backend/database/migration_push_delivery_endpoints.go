@@ -0,0 +1,18 @@
+package database
+
+// getPushDeliveryEndpointsSQL returns the SQL recording the browsers a push
+// delivery already reached, so retrying it after some of them failed only
+// pushes to the others
+func getPushDeliveryEndpointsSQL() string {
+	return `
+		ALTER TABLE notification_deliveries ADD COLUMN IF NOT EXISTS pushed_endpoints TEXT[] NOT NULL DEFAULT '{}';
+	`
+}
+
+// getPushDeliveryEndpointsDownSQL returns the SQL to rollback the reached
+// browsers of push deliveries
+func getPushDeliveryEndpointsDownSQL() string {
+	return `
+		ALTER TABLE notification_deliveries DROP COLUMN IF EXISTS pushed_endpoints;
+	`
+}
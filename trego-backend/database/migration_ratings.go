@@ -0,0 +1,37 @@
+package database
+
+// getGameRatingsSQL returns the SQL creating the post-game ratings table
+func getGameRatingsSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS game_ratings (
+			rating_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+			game_id TEXT NOT NULL,
+			rater_id TEXT NOT NULL,
+			ratee_id TEXT NOT NULL,
+			sportsmanship SMALLINT NOT NULL CHECK (sportsmanship BETWEEN 1 AND 5),
+			skill_accuracy SMALLINT NOT NULL CHECK (skill_accuracy BETWEEN 1 AND 5),
+			comment TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (game_id, rater_id, ratee_id),
+			FOREIGN KEY (game_id) REFERENCES games(game_id) ON DELETE CASCADE,
+			FOREIGN KEY (rater_id) REFERENCES users(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (ratee_id) REFERENCES users(user_id) ON DELETE CASCADE,
+			CONSTRAINT no_self_rating CHECK (rater_id <> ratee_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_game_ratings_ratee_id ON game_ratings(ratee_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_game_ratings_rater_created ON game_ratings(rater_id, created_at);
+
+		DROP TRIGGER IF EXISTS update_game_ratings_updated_at ON game_ratings;
+		CREATE TRIGGER update_game_ratings_updated_at BEFORE UPDATE ON game_ratings
+			FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+	`
+}
+
+// getGameRatingsDownSQL returns the SQL to rollback the post-game ratings table
+func getGameRatingsDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS game_ratings;
+	`
+}
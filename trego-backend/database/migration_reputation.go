@@ -0,0 +1,27 @@
+package database
+
+// getReputationLedgerSQL returns the SQL creating the reputation ledger table
+func getReputationLedgerSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS reputation_ledger (
+			entry_id BIGSERIAL PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			source_type TEXT NOT NULL,
+			source_id TEXT NOT NULL,
+			delta INTEGER NOT NULL,
+			reason TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_reputation_ledger_user_created ON reputation_ledger(user_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_reputation_ledger_source ON reputation_ledger(user_id, source_type, source_id);
+	`
+}
+
+// getReputationLedgerDownSQL returns the SQL to rollback the reputation ledger table
+func getReputationLedgerDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS reputation_ledger;
+	`
+}
@@ -0,0 +1,22 @@
+package database
+
+// getSeriesSportRestrictSQL returns the SQL making series block the deletion
+// of their sport like games do, instead of being deleted with it. A series
+// whose first occurrence is beyond the horizon has no games yet to block it.
+func getSeriesSportRestrictSQL() string {
+	return `
+		ALTER TABLE game_series DROP CONSTRAINT IF EXISTS game_series_sport_name_fkey;
+		ALTER TABLE game_series ADD CONSTRAINT game_series_sport_name_fkey
+			FOREIGN KEY (sport_name) REFERENCES sports(sport_name) ON DELETE RESTRICT;
+	`
+}
+
+// getSeriesSportRestrictDownSQL returns the SQL to rollback the series sport
+// restriction
+func getSeriesSportRestrictDownSQL() string {
+	return `
+		ALTER TABLE game_series DROP CONSTRAINT IF EXISTS game_series_sport_name_fkey;
+		ALTER TABLE game_series ADD CONSTRAINT game_series_sport_name_fkey
+			FOREIGN KEY (sport_name) REFERENCES sports(sport_name) ON DELETE CASCADE;
+	`
+}
@@ -0,0 +1,90 @@
+package database
+
+// getVenuesSQL returns the SQL creating venues with their courts, sports,
+// opening hours and location aliases, and linking games to them
+func getVenuesSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS venues (
+			venue_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+			name TEXT NOT NULL,
+			address TEXT,
+			latitude DOUBLE PRECISION,
+			longitude DOUBLE PRECISION,
+			timezone TEXT NOT NULL DEFAULT 'UTC',
+			amenities TEXT[] NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			CONSTRAINT venues_coordinates_check CHECK (
+				(latitude IS NULL) = (longitude IS NULL)
+				AND latitude BETWEEN -90 AND 90
+				AND longitude BETWEEN -180 AND 180
+			)
+		);
+
+		CREATE TABLE IF NOT EXISTS courts (
+			court_id TEXT PRIMARY KEY DEFAULT uuid_generate_v4()::text,
+			venue_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			surface TEXT,
+			indoor BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE (venue_id, name),
+			FOREIGN KEY (venue_id) REFERENCES venues(venue_id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS venue_sports (
+			venue_id TEXT NOT NULL,
+			sport_name TEXT NOT NULL,
+			PRIMARY KEY (venue_id, sport_name),
+			FOREIGN KEY (venue_id) REFERENCES venues(venue_id) ON DELETE CASCADE,
+			FOREIGN KEY (sport_name) REFERENCES sports(sport_name) ON DELETE CASCADE
+		);
+
+		-- weekday follows Go's time.Weekday: 0 is Sunday
+		CREATE TABLE IF NOT EXISTS venue_opening_hours (
+			venue_id TEXT NOT NULL,
+			weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+			opens_at TIME NOT NULL,
+			closes_at TIME NOT NULL,
+			PRIMARY KEY (venue_id, weekday, opens_at),
+			FOREIGN KEY (venue_id) REFERENCES venues(venue_id) ON DELETE CASCADE,
+			CHECK (closes_at > opens_at)
+		);
+
+		-- Normalized free-text locations known to mean a venue, so games created
+		-- with one of them are linked to the venue
+		CREATE TABLE IF NOT EXISTS venue_aliases (
+			alias TEXT PRIMARY KEY,
+			venue_id TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			FOREIGN KEY (venue_id) REFERENCES venues(venue_id) ON DELETE CASCADE
+		);
+
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS venue_id TEXT REFERENCES venues(venue_id) ON DELETE SET NULL;
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS court_id TEXT REFERENCES courts(court_id) ON DELETE SET NULL;
+
+		CREATE INDEX IF NOT EXISTS idx_venues_name ON venues(LOWER(name));
+		CREATE INDEX IF NOT EXISTS idx_venues_coordinates ON venues(latitude, longitude) WHERE latitude IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_venue_aliases_venue_id ON venue_aliases(venue_id);
+		CREATE INDEX IF NOT EXISTS idx_venue_sports_sport_name ON venue_sports(sport_name);
+		CREATE INDEX IF NOT EXISTS idx_games_venue_id ON games(venue_id);
+		CREATE INDEX IF NOT EXISTS idx_games_court_id ON games(court_id);
+
+		DROP TRIGGER IF EXISTS update_venues_updated_at ON venues;
+		CREATE TRIGGER update_venues_updated_at BEFORE UPDATE ON venues
+			FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+	`
+}
+
+// getVenuesDownSQL returns the SQL to rollback the venue tables
+func getVenuesDownSQL() string {
+	return `
+		ALTER TABLE games DROP COLUMN IF EXISTS court_id;
+		ALTER TABLE games DROP COLUMN IF EXISTS venue_id;
+		DROP TABLE IF EXISTS venue_aliases;
+		DROP TABLE IF EXISTS venue_opening_hours;
+		DROP TABLE IF EXISTS venue_sports;
+		DROP TABLE IF EXISTS courts;
+		DROP TABLE IF EXISTS venues;
+	`
+}
@@ -0,0 +1,27 @@
+package database
+
+// getGameWaitlistSQL returns the SQL creating the game waitlist table
+func getGameWaitlistSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS game_waitlist (
+			game_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			-- seq orders the queue; it is only compared within one game
+			seq BIGSERIAL NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (game_id, user_id),
+			FOREIGN KEY (game_id) REFERENCES games(game_id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_game_waitlist_game_seq ON game_waitlist(game_id, seq);
+		CREATE INDEX IF NOT EXISTS idx_game_waitlist_user_id ON game_waitlist(user_id);
+	`
+}
+
+// getGameWaitlistDownSQL returns the SQL to rollback the game waitlist table
+func getGameWaitlistDownSQL() string {
+	return `
+		DROP TABLE IF EXISTS game_waitlist;
+	`
+}
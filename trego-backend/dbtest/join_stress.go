@@ -0,0 +1,145 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"trego-backend/database"
+	"trego-backend/models"
+	"trego-backend/repository"
+
+	"github.com/google/uuid"
+)
+
+// JoinStressResult summarizes a join stress run
+type JoinStressResult struct {
+	Joiners      int
+	Capacity     int
+	Joined       int
+	Waitlisted   int
+	RejectedFull int
+	Failed       int
+	RosterSize   int
+	Duration     time.Duration
+}
+
+// RunJoinStress creates a game with the given capacity and fires joiners
+// concurrent joins at it, released at the same instant. It fails if more
+// players than capacity got in, if the roster does not match the number of
+// successful joins, or if anyone who did not get in is missing from the
+// waitlist. The users and the game it creates are deleted again.
+// The database connection must already be open.
+func RunJoinStress(ctx context.Context, joiners, capacity int) (*JoinStressResult, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	users := repository.NewUserRepository(db)
+	games := repository.NewGameRepository(db)
+	runID := uuid.New().String()[:8]
+
+	// Every user created here is deleted at the end; the game cascades with its host
+	var userIDs []string
+	defer func() {
+		for _, id := range userIDs {
+			if err := users.Delete(context.Background(), id); err != nil {
+				log.Printf("Failed to clean up stress user %s: %v", id, err)
+			}
+		}
+	}()
+
+	host, err := users.Create(ctx, models.CreateUserRequest{
+		Name:  "Stress Host " + runID,
+		Email: fmt.Sprintf("stress-host-%s@trego.test", runID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create host: %w", err)
+	}
+	userIDs = append(userIDs, host.UserID)
+
+	start := time.Now().Add(time.Hour)
+	game, err := games.Create(ctx, host.UserID, models.CreateGameRequest{
+		SportName:  "Basketball",
+		Title:      "Join stress " + runID,
+		StartTime:  start,
+		EndTime:    start.Add(time.Hour),
+		Location:   "Stress Court",
+		Capacity:   capacity,
+		Visibility: "public",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create game: %w", err)
+	}
+
+	for i := 0; i < joiners; i++ {
+		user, err := users.Create(ctx, models.CreateUserRequest{
+			Name:  fmt.Sprintf("Stress Player %d", i),
+			Email: fmt.Sprintf("stress-%s-%d@trego.test", runID, i),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create player %d: %w", i, err)
+		}
+		userIDs = append(userIDs, user.UserID)
+	}
+	playerIDs := userIDs[1:]
+
+	result := &JoinStressResult{Joiners: joiners, Capacity: capacity}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+
+	for _, playerID := range playerIDs {
+		wg.Add(1)
+		go func(playerID string) {
+			defer wg.Done()
+			<-release
+
+			outcome, err := games.Join(ctx, game.GameID, playerID, repository.JoinOptions{})
+
+			var conflict *repository.ConflictError
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil && outcome.Waitlist != nil:
+				result.Waitlisted++
+			case err == nil:
+				result.Joined++
+			case errors.As(err, &conflict):
+				result.RejectedFull++
+			default:
+				result.Failed++
+				log.Printf("Join failed: %v", err)
+			}
+		}(playerID)
+	}
+
+	began := time.Now()
+	close(release)
+	wg.Wait()
+	result.Duration = time.Since(began)
+
+	final, err := games.Get(ctx, game.GameID)
+	if err != nil {
+		return result, fmt.Errorf("failed to reload game: %w", err)
+	}
+	result.RosterSize = final.PlayerCount
+
+	switch {
+	case result.RosterSize > capacity:
+		return result, fmt.Errorf("roster exceeded capacity: %d players for %d spots", result.RosterSize, capacity)
+	case result.RosterSize != result.Joined:
+		return result, fmt.Errorf("roster has %d players but %d joins succeeded", result.RosterSize, result.Joined)
+	case final.WaitlistCount != result.Waitlisted:
+		return result, fmt.Errorf("waitlist has %d users but %d joins were waitlisted", final.WaitlistCount, result.Waitlisted)
+	case result.Failed > 0:
+		return result, fmt.Errorf("%d joins failed unexpectedly", result.Failed)
+	case joiners >= capacity && result.RosterSize != capacity:
+		return result, fmt.Errorf("game not filled: %d players for %d spots", result.RosterSize, capacity)
+	}
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package dbtest
+
+import (
+	"context"
+	"flag"
+	"os"
+	"testing"
+
+	"trego-backend/database"
+)
+
+var (
+	stressJoiners  = flag.Int("joiners", 300, "number of concurrent joins in the join stress test")
+	stressCapacity = flag.Int("capacity", 10, "game capacity in the join stress test")
+)
+
+// TestJoinStress fires concurrent joins at a single game against the
+// database configured with DB_HOST and the other DB_* variables, and fails
+// if the roster ever ends up over capacity. It is skipped without DB_HOST.
+func TestJoinStress(t *testing.T) {
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("DB_HOST is not set; skipping database test")
+	}
+	if testing.Short() {
+		t.Skip("skipping join stress test in short mode")
+	}
+
+	if err := database.Connect(); err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.RunMigrations(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	result, err := RunJoinStress(context.Background(), *stressJoiners, *stressCapacity)
+	if result != nil {
+		t.Logf("joiners=%d capacity=%d joined=%d waitlisted=%d rejected=%d failed=%d roster=%d duration=%s",
+			result.Joiners, result.Capacity, result.Joined, result.Waitlisted, result.RejectedFull, result.Failed, result.RosterSize, result.Duration)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
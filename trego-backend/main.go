@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"trego-backend/api-gateway/config"
+	"trego-backend/api-gateway/jobs"
 	"trego-backend/api-gateway/logger"
+	"trego-backend/api-gateway/notify"
+	"trego-backend/api-gateway/queue"
+	"trego-backend/api-gateway/stream"
 	"trego-backend/api-gateway/web"
 	"trego-backend/database"
+	"trego-backend/repository"
 
 	"github.com/fvbock/endless"
 	"github.com/gin-gonic/gin"
@@ -32,13 +39,55 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Set up notifications, sent by the job worker
+	notifications := repository.NewNotificationRepository(database.GetDB())
+	notifier, err := notify.NewNotifierFromConfig(conf, notifications, logger)
+	if err != nil {
+		log.Fatalf("Failed to set up notifications: %v", err)
+	}
+
+	// Start background jobs
+	if conf.GameLifecycleInterval > 0 {
+		games := repository.NewGameRepository(database.GetDB())
+		go jobs.RunGameLifecycle(context.Background(), games, conf.GameLifecycleInterval, logger)
+	}
+	if conf.SeriesMaterializeInterval > 0 {
+		series := repository.NewSeriesRepository(database.GetDB()).WithHorizon(conf.SeriesHorizon).
+			WithReminderOffsets(conf.ReminderOffsets)
+		go jobs.RunSeriesMaterialization(context.Background(), series, conf.SeriesMaterializeInterval, logger)
+	}
+	if conf.GameEventRetention > 0 {
+		events := repository.NewGameEventRepository(database.GetDB())
+		go jobs.RunGameEventPruning(context.Background(), events, time.Hour, conf.GameEventRetention, logger)
+	}
+	if conf.NotificationInterval > 0 {
+		go jobs.RunNotifications(context.Background(), notifier, conf.NotificationInterval, logger)
+	}
+	if conf.JobPollInterval > 0 {
+		worker := queue.NewWorker(repository.NewJobRepository(database.GetDB()), logger, conf.JobPollInterval, conf.JobMaxConcurrency).
+			WithRetention(conf.JobRetention)
+		queue.Register(worker, jobs.RecomputeReputation(repository.NewReputationRepository(database.GetDB())), queue.HandlerOptions{})
+		queue.Register(worker, jobs.SendGameReminder(notifier, logger), queue.HandlerOptions{Concurrency: 2})
+		queue.Register(worker, jobs.DeliverNotification(notifier), queue.HandlerOptions{Concurrency: 10, Timeout: 2 * time.Minute})
+		go worker.Run(context.Background())
+	}
+
+	// Queue the reminders of games scheduled before their offsets were
+	// configured
+	go jobs.ScheduleGameReminders(context.Background(), notifier, logger)
+
+	// Wake game event streams on changes made by any instance
+	hub := stream.NewHub(database.GetDB(), logger)
+	go hub.Run(context.Background())
+
 	// Run the server
-	run(conf, logger)
+	run(conf, logger, hub)
 }
 
-// run sets up and starts an HTTP server with the given configurations
+// run sets up and starts an HTTP server with the given configurations and
+// game event hub
 // It blocks program execution while the server is running
-func run(conf *config.Config, logger logger.Logger) {
+func run(conf *config.Config, logger logger.Logger, hub *stream.Hub) {
 	// Set Gin mode
 	gin.SetMode(conf.GinMode)
 
@@ -53,6 +102,12 @@ func run(conf *config.Config, logger logger.Logger) {
 		func(opt *web.Options) {
 			opt.Logger = logger
 		},
+		func(opt *web.Options) {
+			opt.DB = database.GetDB()
+		},
+		func(opt *web.Options) {
+			opt.GameEventHub = hub
+		},
 	)
 
 	// Start server with graceful shutdown
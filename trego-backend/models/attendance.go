@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// AttendanceStats summarizes how reliably a user shows up to games they joined
+type AttendanceStats struct {
+	// GamesMarked counts games where the host marked the user as attended or not
+	GamesMarked int `json:"games_marked"`
+	Attended    int `json:"attended"`
+	NoShows     int `json:"no_shows"`
+	// NoShowRate is NoShows / GamesMarked, or 0 when nothing was marked
+	NoShowRate float64 `json:"no_show_rate"`
+}
+
+// AttendanceRecord is one entry of a user's attendance history
+type AttendanceRecord struct {
+	Game       Game       `json:"game"`
+	Attendance string     `json:"attendance"` // "true", "false", "none"
+	MarkedAt   *time.Time `json:"marked_at,omitempty"`
+}
+
+// AttendanceMark sets the attendance of one player
+type AttendanceMark struct {
+	UserID     string `json:"user_id" binding:"required"`
+	Attendance string `json:"attendance" binding:"required,oneof=true false none"`
+}
+
+// MarkAttendanceRequest represents the request payload for marking attendance in bulk
+type MarkAttendanceRequest struct {
+	Players []AttendanceMark `json:"players" binding:"required,min=1,dive"`
+}
+
+// AttendanceHistoryFilters represents the paging of an attendance history
+type AttendanceHistoryFilters struct {
+	Limit  int `json:"limit,omitempty" form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int `json:"offset,omitempty" form:"offset" binding:"omitempty,min=0"`
+}
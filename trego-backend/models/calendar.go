@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// CalendarFeed is a user's secret iCalendar subscription. The URLs are only
+// returned when the feed is created; the token is not stored.
+type CalendarFeed struct {
+	URL string `json:"url,omitempty"`
+	// WebcalURL is the same feed for calendar apps that subscribe to webcal:// links
+	WebcalURL string    `json:"webcal_url,omitempty"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CalendarGame is a game with the time zone its calendar event is shown in:
+// its venue's, else its series', else UTC
+type CalendarGame struct {
+	Game
+	Timezone string `json:"timezone"`
+}
+
+// CalendarVersion identifies the current content of a calendar, so clients
+// polling it can be answered with 304 Not Modified
+type CalendarVersion struct {
+	ETag         string
+	LastModified time.Time
+}
@@ -4,6 +4,26 @@ import (
 	"time"
 )
 
+// Game lifecycle statuses. A game is scheduled until start_time, in progress
+// until end_time and then completed; scheduled and in-progress games can be
+// cancelled.
+const (
+	GameStatusScheduled  = "scheduled"
+	GameStatusInProgress = "in_progress"
+	GameStatusCompleted  = "completed"
+	GameStatusCancelled  = "cancelled"
+)
+
+// Cancellation reasons a host picks from when cancelling a game
+const (
+	CancellationHostUnavailable  = "host_unavailable"
+	CancellationNotEnoughPlayers = "not_enough_players"
+	CancellationWeather          = "weather"
+	CancellationVenueUnavailable = "venue_unavailable"
+	CancellationScheduleChanged  = "schedule_changed"
+	CancellationOther            = "other"
+)
+
 // Game represents a game/event in the system
 type Game struct {
 	GameID      string         `json:"game_id" db:"game_id"`
@@ -14,16 +34,32 @@ type Game struct {
 	StartTime   time.Time      `json:"start_time" db:"start_time"`
 	EndTime     time.Time      `json:"end_time" db:"end_time"`
 	Location    string         `json:"location" db:"location"`
+	Latitude    *float64       `json:"latitude,omitempty" db:"latitude"`
+	Longitude   *float64       `json:"longitude,omitempty" db:"longitude"`
+	VenueID     *string        `json:"venue_id,omitempty" db:"venue_id"`
+	CourtID     *string        `json:"court_id,omitempty" db:"court_id"`
+	// SeriesID is set on occurrences of a recurring series
+	SeriesID    *string        `json:"series_id,omitempty" db:"series_id"`
 	SkillRange  *string        `json:"skill_range,omitempty" db:"skill_range"`
 	Capacity    int            `json:"capacity" db:"capacity"`
 	SkillLevel  *string        `json:"skill_level,omitempty" db:"skill_level"` // "beginner", "intermediate", "advanced"
 	Visibility  string         `json:"visibility" db:"visibility"`             // "public" or "invite-only"
+	Status      string         `json:"status" db:"status"`                     // "scheduled", "in_progress", "completed" or "cancelled"
+	// StartedAt, CompletedAt and CancelledAt are when the game entered each status
+	StartedAt   *time.Time     `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty" db:"completed_at"`
+	CancelledAt *time.Time     `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	CancellationReason *string `json:"cancellation_reason,omitempty" db:"cancellation_reason"`
+	CancellationNote   *string `json:"cancellation_note,omitempty" db:"cancellation_note"`
 	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at"`
-	Host        *User          `json:"host,omitempty"`
+	Host        *PublicUser    `json:"host,omitempty"`
 	Sport       *Sport         `json:"sport,omitempty"`
 	Players     []GamePlayer   `json:"players,omitempty"`
 	PlayerCount int            `json:"player_count,omitempty"`
+	WaitlistCount int          `json:"waitlist_count,omitempty"`
+	// DistanceKm is the distance from the searched point, set by geo searches
+	DistanceKm  *float64       `json:"distance_km,omitempty"`
 }
 
 // GamePlayer represents the many-to-many relationship between games and users (players)
@@ -32,7 +68,18 @@ type GamePlayer struct {
 	GameID     string    `json:"game_id" db:"game_id"`
 	Attendance string    `json:"attendance" db:"attendance"` // "true", "false", "none"
 	JoinedAt   time.Time `json:"joined_at" db:"joined_at"`
-	User       *User     `json:"user,omitempty"`
+	// AttendanceMarkedAt is when the host last marked this player's attendance
+	AttendanceMarkedAt *time.Time `json:"attendance_marked_at,omitempty" db:"attendance_marked_at"`
+	User       *PublicUser `json:"user,omitempty"`
+}
+
+// WaitlistEntry represents a user queued for a full game
+type WaitlistEntry struct {
+	GameID    string      `json:"game_id" db:"game_id"`
+	UserID    string      `json:"user_id" db:"user_id"`
+	Position  int         `json:"position"` // 1-based place in the queue
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	User      *PublicUser `json:"user,omitempty"`
 }
 
 // CreateGameRequest represents the request payload for creating a new game
@@ -42,7 +89,12 @@ type CreateGameRequest struct {
 	Description *string    `json:"description,omitempty"`
 	StartTime   time.Time  `json:"start_time" binding:"required"`
 	EndTime     time.Time  `json:"end_time" binding:"required"`
-	Location    string     `json:"location" binding:"required"`
+	Location    string     `json:"location"` // defaults to the venue's name
+	Latitude    *float64   `json:"latitude,omitempty" binding:"omitempty,min=-90,max=90"`
+	Longitude   *float64   `json:"longitude,omitempty" binding:"omitempty,min=-180,max=180"`
+	// VenueID and CourtID place the game at a venue; a court implies its venue
+	VenueID     *string    `json:"venue_id,omitempty"`
+	CourtID     *string    `json:"court_id,omitempty"`
 	SkillRange  *string    `json:"skill_range,omitempty"`
 	Capacity    int        `json:"capacity" binding:"required,min=1"`
 	SkillLevel  *string    `json:"skill_level,omitempty" binding:"omitempty,oneof=beginner intermediate advanced"`
@@ -56,12 +108,23 @@ type UpdateGameRequest struct {
 	StartTime   *time.Time `json:"start_time,omitempty"`
 	EndTime     *time.Time `json:"end_time,omitempty"`
 	Location    *string    `json:"location,omitempty"`
+	Latitude    *float64   `json:"latitude,omitempty" binding:"omitempty,min=-90,max=90"`
+	Longitude   *float64   `json:"longitude,omitempty" binding:"omitempty,min=-180,max=180"`
+	// VenueID and CourtID move the game; empty strings clear them
+	VenueID     *string    `json:"venue_id,omitempty"`
+	CourtID     *string    `json:"court_id,omitempty"`
 	SkillRange  *string    `json:"skill_range,omitempty"`
 	Capacity    *int       `json:"capacity,omitempty" binding:"omitempty,min=1"`
 	SkillLevel  *string    `json:"skill_level,omitempty" binding:"omitempty,oneof=beginner intermediate advanced"`
 	Visibility  *string    `json:"visibility,omitempty" binding:"omitempty,oneof=public invite-only"`
 }
 
+// CancelGameRequest represents the request payload for cancelling a game
+type CancelGameRequest struct {
+	Reason string  `json:"reason" binding:"required,oneof=host_unavailable not_enough_players weather venue_unavailable schedule_changed other"`
+	Note   *string `json:"note,omitempty" binding:"omitempty,max=500"`
+}
+
 // JoinGameRequest represents the request payload for joining a game
 type JoinGameRequest struct {
 	Attendance string `json:"attendance" binding:"required,oneof=true false none"`
@@ -69,13 +132,38 @@ type JoinGameRequest struct {
 
 // GameFilters represents filters for querying games
 type GameFilters struct {
-	SportName   *string    `json:"sport_name,omitempty"`
-	Location    *string    `json:"location,omitempty"`
-	SkillLevel  *string    `json:"skill_level,omitempty"`
-	Visibility  *string    `json:"visibility,omitempty"`
-	StartAfter  *time.Time `json:"start_after,omitempty"`
-	StartBefore *time.Time `json:"start_before,omitempty"`
-	HostID      *string    `json:"host_id,omitempty"`
-	Limit       int        `json:"limit,omitempty"`
-	Offset      int        `json:"offset,omitempty"`
+	SportName   *string    `json:"sport_name,omitempty" form:"sport_name"`
+	Location    *string    `json:"location,omitempty" form:"location"`
+	SkillLevel  *string    `json:"skill_level,omitempty" form:"skill_level" binding:"omitempty,oneof=beginner intermediate advanced"`
+	Visibility  *string    `json:"visibility,omitempty" form:"visibility" binding:"omitempty,oneof=public invite-only"`
+	StartAfter  *time.Time `json:"start_after,omitempty" form:"start_after"`
+	StartBefore *time.Time `json:"start_before,omitempty" form:"start_before"`
+	HostID      *string    `json:"host_id,omitempty" form:"host_id"`
+	VenueID     *string    `json:"venue_id,omitempty" form:"venue_id"`
+	SeriesID    *string    `json:"series_id,omitempty" form:"series_id"`
+	// Status limits results to games in that status; cancelled games are
+	// left out unless asked for
+	Status      *string    `json:"status,omitempty" form:"status" binding:"omitempty,oneof=scheduled in_progress completed cancelled"`
+	// Latitude and Longitude are the point results are sorted by distance from.
+	// With RadiusKm only games within that distance match; without a point,
+	// RadiusKm searches around the caller's saved coordinates
+	Latitude    *float64   `json:"lat,omitempty" form:"lat" binding:"omitempty,min=-90,max=90"`
+	Longitude   *float64   `json:"lng,omitempty" form:"lng" binding:"omitempty,min=-180,max=180"`
+	RadiusKm    *float64   `json:"radius_km,omitempty" form:"radius_km" binding:"omitempty,gt=0,max=1000"`
+	// MinLat, MinLng, MaxLat and MaxLng bound the search to a box, e.g. the
+	// visible map. MinLng > MaxLng crosses the antimeridian
+	MinLat      *float64   `json:"min_lat,omitempty" form:"min_lat" binding:"omitempty,min=-90,max=90"`
+	MinLng      *float64   `json:"min_lng,omitempty" form:"min_lng" binding:"omitempty,min=-180,max=180"`
+	MaxLat      *float64   `json:"max_lat,omitempty" form:"max_lat" binding:"omitempty,min=-90,max=90"`
+	MaxLng      *float64   `json:"max_lng,omitempty" form:"max_lng" binding:"omitempty,min=-180,max=180"`
+	Limit       int        `json:"limit,omitempty" form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset      int        `json:"offset,omitempty" form:"offset" binding:"omitempty,min=0"`
+	// Cursor is the opaque keyset cursor returned by the previous page
+	Cursor      string     `json:"cursor,omitempty" form:"cursor"`
+}
+
+// GamePage represents one page of game search results
+type GamePage struct {
+	Games      []Game  `json:"games"`
+	NextCursor *string `json:"next_cursor,omitempty"`
 }
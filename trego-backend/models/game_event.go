@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Game event types
+const (
+	GameEventPlayerJoined    = "player_joined"
+	GameEventPlayerLeft      = "player_left"
+	GameEventPlayerPromoted  = "player_promoted" // moved from the waitlist to the roster
+	GameEventWaitlistJoined  = "waitlist_joined"
+	GameEventWaitlistLeft    = "waitlist_left"
+	GameEventGameUpdated     = "game_updated"
+	GameEventGameRescheduled = "game_rescheduled" // follows game_updated when the times changed
+	GameEventGameStarted     = "game_started"
+	GameEventGameCompleted   = "game_completed"
+	GameEventGameCancelled   = "game_cancelled"
+	GameEventGameDeleted     = "game_deleted"
+)
+
+// GameEvent is a change to a game or its roster, as streamed to clients
+type GameEvent struct {
+	EventID int64  `json:"event_id" db:"event_id"`
+	GameID  string `json:"game_id" db:"game_id"`
+	Type    string `json:"type" db:"type"`
+	// UserID is the player a roster event is about
+	UserID *string `json:"user_id,omitempty" db:"user_id"`
+	// Game is the game right after the change. Lifecycle transitions and
+	// deletions carry none.
+	Game      json.RawMessage `json:"game,omitempty" db:"game"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	// Recipients and Timezone are who to notify of the event and the game's
+	// time zone, recorded when the game is deleted before it is notified
+	Recipients []string `json:"-" db:"recipients"`
+	Timezone   *string  `json:"-" db:"timezone"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+// Invitation represents an invitation of a user or email address to a game
+type Invitation struct {
+	InvitationID  string     `json:"invitation_id" db:"invitation_id"`
+	GameID        string     `json:"game_id" db:"game_id"`
+	InvitedBy     string     `json:"invited_by" db:"invited_by"`
+	InviteeUserID *string    `json:"invitee_user_id,omitempty" db:"invitee_user_id"`
+	InviteeEmail  *string    `json:"invitee_email,omitempty" db:"invitee_email"`
+	InviteLinkID  *string    `json:"invite_link_id,omitempty" db:"invite_link_id"` // set when redeemed through a link
+	Status        string     `json:"status" db:"status"`                           // "pending", "accepted", "declined", "revoked", "expired"
+	ExpiresAt     *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty" db:"responded_at"`
+	Game          *Game      `json:"game,omitempty"`
+}
+
+// InviteLink represents a shareable invitation link to a game
+type InviteLink struct {
+	LinkID    string     `json:"link_id" db:"link_id"`
+	GameID    string     `json:"game_id" db:"game_id"`
+	CreatedBy string     `json:"created_by" db:"created_by"`
+	MaxUses   *int       `json:"max_uses,omitempty" db:"max_uses"`
+	UseCount  int        `json:"use_count" db:"use_count"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	// Token and URL are the signed shareable forms of the link
+	Token string `json:"token,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// CreateInvitationRequest represents the request payload for inviting a user
+// by ID or by email address. Exactly one of them must be set.
+type CreateInvitationRequest struct {
+	UserID    *string    `json:"user_id,omitempty"`
+	Email     *string    `json:"email,omitempty" binding:"omitempty,email"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateInviteLinkRequest represents the request payload for creating an invite link
+type CreateInviteLinkRequest struct {
+	MaxUses   *int       `json:"max_uses,omitempty" binding:"omitempty,min=1"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// JoinGameOptions represents the optional request payload for joining a game
+type JoinGameOptions struct {
+	// InviteToken is a signed invite link token, needed for invite-only games
+	// the caller has not been invited to directly
+	InviteToken string `json:"invite_token,omitempty"`
+}
+
+// InviteLinkPreview represents the game behind an invite link
+type InviteLinkPreview struct {
+	Link InviteLink `json:"link"`
+	Game Game       `json:"game"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Background job statuses. Pending jobs run once scheduled_at passes; failed
+// attempts go back to pending with a backoff until the job runs out of
+// attempts and is dead-lettered as dead.
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusDead      = "dead"
+)
+
+// Job is a unit of background work run by the gateway's workers
+type Job struct {
+	JobID string `json:"job_id" db:"job_id"`
+	Kind  string `json:"kind" db:"kind"`
+	// Args are the kind's arguments as JSON
+	Args   json.RawMessage `json:"args" db:"args"`
+	Status string          `json:"status" db:"status"`
+	// UniqueKey keeps a second pending or running job of the kind with the
+	// same key from being enqueued
+	UniqueKey   *string   `json:"unique_key,omitempty" db:"unique_key"`
+	Attempts    int       `json:"attempts" db:"attempts"`
+	MaxAttempts int       `json:"max_attempts" db:"max_attempts"`
+	ScheduledAt time.Time `json:"scheduled_at" db:"scheduled_at"`
+	// LockedBy and LockedUntil identify the worker running the job and when
+	// its claim expires
+	LockedBy    *string    `json:"locked_by,omitempty" db:"locked_by"`
+	LockedUntil *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	LastError   *string    `json:"last_error,omitempty" db:"last_error"`
+	Errors      []JobError `json:"errors" db:"errors"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// JobError is a failed attempt of a job
+type JobError struct {
+	Attempt int       `json:"attempt"`
+	Error   string    `json:"error"`
+	At      time.Time `json:"at"`
+}
+
+// JobFilters represents filters for listing jobs
+type JobFilters struct {
+	Status string `json:"status,omitempty" form:"status" binding:"omitempty,oneof=pending running completed dead"`
+	Kind   string `json:"kind,omitempty" form:"kind"`
+	Limit  int    `json:"limit,omitempty" form:"limit" binding:"omitempty,min=1,max=100"`
+	// Cursor is the opaque keyset cursor returned by the previous page
+	Cursor string `json:"cursor,omitempty" form:"cursor"`
+}
+
+// JobPage represents one page of jobs, newest first
+type JobPage struct {
+	Jobs       []Job   `json:"jobs"`
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
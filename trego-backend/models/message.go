@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// GameMessage is a message in a game's thread
+type GameMessage struct {
+	MessageID string `json:"message_id" db:"message_id"`
+	GameID    string `json:"game_id" db:"game_id"`
+	// AuthorID and AuthorName are left out once the author's account is deleted
+	AuthorID   *string `json:"author_id,omitempty" db:"author_id"`
+	AuthorName *string `json:"author_name,omitempty"`
+	// Body is left out of deleted messages
+	Body *string `json:"body,omitempty" db:"body"`
+	// Mentions are the players the body mentions with @
+	Mentions  []MessageMention `json:"mentions"`
+	EditedAt  *time.Time       `json:"edited_at,omitempty" db:"edited_at"`
+	PinnedAt  *time.Time       `json:"pinned_at,omitempty" db:"pinned_at"`
+	DeletedAt *time.Time       `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Removed is set when the host deleted someone else's message
+	Removed   bool      `json:"removed,omitempty"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// MessageMention is a user mentioned in a message
+type MessageMention struct {
+	UserID string `json:"user_id" db:"user_id"`
+	Name   string `json:"name" db:"name"`
+}
+
+// PostMessageRequest represents the request payload for posting or editing a
+// message
+type PostMessageRequest struct {
+	Body string `json:"body" binding:"required,max=2000"`
+}
+
+// MessageFilters represents the paging of a game's messages
+type MessageFilters struct {
+	// Pinned limits the page to pinned messages
+	Pinned bool `json:"pinned,omitempty" form:"pinned"`
+	Limit  int  `json:"limit,omitempty" form:"limit" binding:"omitempty,min=1,max=100"`
+	// Cursor is the opaque keyset cursor returned by the previous page
+	Cursor string `json:"cursor,omitempty" form:"cursor"`
+}
+
+// MessagePage represents one page of a game's messages, newest first
+type MessagePage struct {
+	Messages   []GameMessage `json:"messages"`
+	NextCursor *string       `json:"next_cursor,omitempty"`
+}
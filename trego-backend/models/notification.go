@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+)
+
+// Channels notifications are delivered on besides the in-app inbox
+const (
+	NotificationChannelEmail = "email"
+	NotificationChannelPush  = "push"
+)
+
+// NotificationGameReminder is the type of the reminders sent before games
+const NotificationGameReminder = "game_reminder"
+
+// Notification is an entry in a user's in-app inbox. Its type is the game
+// event it was made for, e.g. player_joined or game_cancelled, or
+// game_reminder.
+type Notification struct {
+	NotificationID string `json:"notification_id" db:"notification_id"`
+	Type           string `json:"type" db:"type"`
+	// GameID is left out once the game is deleted
+	GameID    *string    `json:"game_id,omitempty" db:"game_id"`
+	Title     string     `json:"title" db:"title"`
+	Body      string     `json:"body" db:"body"`
+	ReadAt    *time.Time `json:"read_at,omitempty" db:"read_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// NotificationFilters represents the paging of a user's inbox
+type NotificationFilters struct {
+	// Unread limits the page to unread notifications
+	Unread bool `json:"unread,omitempty" form:"unread"`
+	Limit  int  `json:"limit,omitempty" form:"limit" binding:"omitempty,min=1,max=100"`
+	// Cursor is the opaque keyset cursor returned by the previous page
+	Cursor string `json:"cursor,omitempty" form:"cursor"`
+}
+
+// NotificationPage represents one page of a user's inbox, newest first
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	// UnreadCount is the number of unread notifications in the whole inbox
+	UnreadCount int     `json:"unread_count"`
+	NextCursor  *string `json:"next_cursor,omitempty"`
+}
+
+// NotificationPreferences are the channels a user is notified on besides the
+// inbox, and the quiet hours during which email and push are held back
+type NotificationPreferences struct {
+	EmailEnabled bool `json:"email_enabled" db:"email_enabled"`
+	PushEnabled  bool `json:"push_enabled" db:"push_enabled"`
+	// QuietHoursStart and QuietHoursEnd are local times (HH:MM) in Timezone;
+	// a start after the end spans midnight
+	QuietHoursStart *string `json:"quiet_hours_start,omitempty" db:"quiet_hours_start"`
+	QuietHoursEnd   *string `json:"quiet_hours_end,omitempty" db:"quiet_hours_end"`
+	Timezone        string  `json:"timezone" db:"timezone"`
+}
+
+// UpdateNotificationPreferencesRequest represents the request payload for
+// changing notification preferences. Quiet hours are set together; empty
+// strings turn them off.
+type UpdateNotificationPreferencesRequest struct {
+	EmailEnabled    *bool   `json:"email_enabled,omitempty"`
+	PushEnabled     *bool   `json:"push_enabled,omitempty"`
+	QuietHoursStart *string `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   *string `json:"quiet_hours_end,omitempty"`
+	Timezone        *string `json:"timezone,omitempty"`
+}
+
+// PushSubscription is a browser registered for web push notifications
+type PushSubscription struct {
+	SubscriptionID string    `json:"subscription_id" db:"subscription_id"`
+	Endpoint       string    `json:"endpoint" db:"endpoint"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreatePushSubscriptionRequest represents the request payload for
+// registering a browser for web push, in the shape of the browser's
+// PushSubscription.toJSON()
+type CreatePushSubscriptionRequest struct {
+	Endpoint string               `json:"endpoint" binding:"required,url"`
+	Keys     PushSubscriptionKeys `json:"keys" binding:"required"`
+}
+
+// PushSubscriptionKeys are the browser's public key and authentication
+// secret, both base64url-encoded, that push messages are encrypted for
+type PushSubscriptionKeys struct {
+	P256dh string `json:"p256dh" binding:"required"`
+	Auth   string `json:"auth" binding:"required"`
+}
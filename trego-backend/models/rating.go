@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// Rating is a player's post-game rating of another participant
+type Rating struct {
+	RatingID string `json:"rating_id" db:"rating_id"`
+	GameID   string `json:"game_id" db:"game_id"`
+	// RaterID is left out when ratings are shown to the rated user
+	RaterID       string    `json:"rater_id,omitempty" db:"rater_id"`
+	RateeID       string    `json:"ratee_id" db:"ratee_id"`
+	Sportsmanship int       `json:"sportsmanship" db:"sportsmanship"`   // 1-5
+	SkillAccuracy int       `json:"skill_accuracy" db:"skill_accuracy"` // 1-5, how well the stated skill level matched
+	Comment       *string   `json:"comment,omitempty" db:"comment"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+	// EditableUntil is when the rating can no longer be changed
+	EditableUntil time.Time `json:"editable_until"`
+}
+
+// RatingSummary aggregates the ratings a user received
+type RatingSummary struct {
+	Count         int     `json:"count"`
+	Sportsmanship float64 `json:"sportsmanship"`  // average, 0 without ratings
+	SkillAccuracy float64 `json:"skill_accuracy"` // average, 0 without ratings
+}
+
+// RateUserRequest represents the request payload for rating a game participant
+type RateUserRequest struct {
+	Sportsmanship int     `json:"sportsmanship" binding:"required,min=1,max=5"`
+	SkillAccuracy int     `json:"skill_accuracy" binding:"required,min=1,max=5"`
+	Comment       *string `json:"comment,omitempty" binding:"omitempty,max=1000"`
+}
+
+// RatingFilters represents the paging of received ratings
+type RatingFilters struct {
+	Limit  int `json:"limit,omitempty" form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int `json:"offset,omitempty" form:"offset" binding:"omitempty,min=0"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// ReputationEntry is one auditable change of a user's reputation
+type ReputationEntry struct {
+	EntryID    int64     `json:"entry_id" db:"entry_id"`
+	UserID     string    `json:"user_id" db:"user_id"`
+	SourceType string    `json:"source_type" db:"source_type"` // "attendance", "hosting", "cancellation", "peer_rating"
+	SourceID   string    `json:"source_id" db:"source_id"`     // e.g. the game the change came from
+	Delta      int       `json:"delta" db:"delta"`
+	Reason     string    `json:"reason" db:"reason"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// ReputationSourceTotal sums the ledger entries of one source type
+type ReputationSourceTotal struct {
+	SourceType string `json:"source_type"`
+	Total      int    `json:"total"`
+	Entries    int    `json:"entries"`
+}
+
+// ReputationBreakdown explains a user's reputation from their ledger
+type ReputationBreakdown struct {
+	UserID     string `json:"user_id"`
+	Reputation int    `json:"reputation"`
+	// LedgerTotal is the sum of all entries; reputation is floored at 0
+	LedgerTotal int                     `json:"ledger_total"`
+	BySource    []ReputationSourceTotal `json:"by_source"`
+	// Entries are the most recent ledger entries, newest first
+	Entries []ReputationEntry `json:"entries"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+)
+
+// GameSeries is a game repeated on a weekly schedule. Upcoming occurrences
+// are created as regular games linked by series_id.
+type GameSeries struct {
+	SeriesID    string   `json:"series_id" db:"series_id"`
+	HostID      string   `json:"host_id" db:"host_id"`
+	SportName   string   `json:"sport_name" db:"sport_name"`
+	Title       string   `json:"title" db:"title"`
+	Description *string  `json:"description,omitempty" db:"description"`
+	Location    string   `json:"location" db:"location"`
+	Latitude    *float64 `json:"latitude,omitempty" db:"latitude"`
+	Longitude   *float64 `json:"longitude,omitempty" db:"longitude"`
+	VenueID     *string  `json:"venue_id,omitempty" db:"venue_id"`
+	CourtID     *string  `json:"court_id,omitempty" db:"court_id"`
+	SkillRange  *string  `json:"skill_range,omitempty" db:"skill_range"`
+	Capacity    int      `json:"capacity" db:"capacity"`
+	SkillLevel  *string  `json:"skill_level,omitempty" db:"skill_level"`
+	Visibility  string   `json:"visibility" db:"visibility"`
+	// FirstStart is the start of the first occurrence; every occurrence
+	// starts at its wall-clock time in Timezone
+	FirstStart      time.Time `json:"first_start" db:"first_start"`
+	DurationMinutes int       `json:"duration_minutes" db:"duration_minutes"`
+	Timezone        string    `json:"timezone" db:"timezone"`
+	// Recurrence is an RRULE, e.g. "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;COUNT=10"
+	Recurrence   string `json:"recurrence" db:"rrule"`
+	SkipHolidays bool   `json:"skip_holidays" db:"skip_holidays"`
+	// MaterializedUntil is how far ahead occurrences have been created as games
+	MaterializedUntil *time.Time `json:"materialized_until,omitempty" db:"materialized_until"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+	// Games are the upcoming occurrences
+	Games []Game `json:"games,omitempty"`
+}
+
+// CreateSeriesRequest represents the request payload for creating a
+// recurring series. The game fields describe the first occurrence.
+type CreateSeriesRequest struct {
+	CreateGameRequest
+	// Recurrence is a weekly RRULE: FREQ=WEEKLY with optional INTERVAL,
+	// BYDAY and UNTIL or COUNT
+	Recurrence string `json:"recurrence" binding:"required"`
+	// Timezone keeps occurrences at the same local time across DST changes;
+	// defaults to the venue's time zone or UTC
+	Timezone     string `json:"timezone,omitempty"`
+	SkipHolidays bool   `json:"skip_holidays"`
+}
+
+// UpdateSeriesRequest represents the request payload for editing an
+// occurrence of a series. With the "this" scope only the game fields apply;
+// with "following", start_time and end_time move the occurrence and every
+// later one keeps the new local time.
+type UpdateSeriesRequest struct {
+	UpdateGameRequest
+	Recurrence   *string `json:"recurrence,omitempty"`
+	SkipHolidays *bool   `json:"skip_holidays,omitempty"`
+}
+
+// Holiday is a date series with skip_holidays do not play on
+type Holiday struct {
+	Date      string    `json:"date" db:"holiday_date"` // YYYY-MM-DD
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateHolidayRequest represents the request payload for adding a holiday
+type CreateHolidayRequest struct {
+	Date string `json:"date" binding:"required"` // YYYY-MM-DD
+	Name string `json:"name" binding:"required"`
+}
+
+// HolidayFilters represents filters for listing holidays
+type HolidayFilters struct {
+	From *string `json:"from,omitempty" form:"from"` // YYYY-MM-DD, default today
+	To   *string `json:"to,omitempty" form:"to"`     // YYYY-MM-DD
+}
+
+// SeriesScopeQuery selects the occurrences an edit or cancellation applies to
+type SeriesScopeQuery struct {
+	// Scope is "this" (default) for the one occurrence or "following" for it
+	// and every later one
+	Scope string `json:"scope,omitempty" form:"scope" binding:"omitempty,oneof=this following"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// TeamSplit is the split of a game's roster into balanced teams
+type TeamSplit struct {
+	GameID    string `json:"game_id" db:"game_id"`
+	TeamCount int    `json:"team_count" db:"team_count"`
+	// KeepTogether and KeepApart are the constraints the split respects,
+	// reused when it is re-shuffled
+	KeepTogether [][]string `json:"keep_together" db:"keep_together"`
+	KeepApart    [][]string `json:"keep_apart" db:"keep_apart"`
+	CreatedBy    *string    `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+	Teams        []Team     `json:"teams"`
+	// Unassigned are players who joined after the split
+	Unassigned []TeamMember `json:"unassigned"`
+}
+
+// Team is one side of a split
+type Team struct {
+	Number int `json:"number"` // 1-based
+	// Strength is the sum of its players' strengths
+	Strength float64      `json:"strength"`
+	Front    int          `json:"front"` // players preferring the front
+	Back     int          `json:"back"`  // players preferring the back
+	Players  []TeamMember `json:"players"`
+}
+
+// TeamMember is a player as rated for team balancing
+type TeamMember struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	// SkillLevel and Position come from the player's profile for the game's sport
+	SkillLevel *string `json:"skill_level,omitempty"`
+	Position   *string `json:"position,omitempty"`
+	Reputation int     `json:"reputation"`
+	// Strength combines skill level and reputation
+	Strength float64 `json:"strength"`
+}
+
+// GenerateTeamsRequest represents the request payload for splitting a
+// game's roster into teams
+type GenerateTeamsRequest struct {
+	TeamCount int `json:"team_count" binding:"required,min=2,max=10"`
+	// KeepTogether lists groups of user IDs that must play on the same team
+	KeepTogether [][]string `json:"keep_together,omitempty"`
+	// KeepApart lists groups of user IDs that must all play on different teams
+	KeepApart [][]string `json:"keep_apart,omitempty"`
+}
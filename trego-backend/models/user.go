@@ -12,10 +12,34 @@ type User struct {
 	PictureURL   *string    `json:"picture_url,omitempty" db:"picture_url"`
 	PhoneNumber  *string    `json:"phone_number,omitempty" db:"phone_number"`
 	Location     *string    `json:"location,omitempty" db:"location"`
+	// Latitude and Longitude are the home coordinates, only returned to the
+	// user themselves
+	Latitude     *float64   `json:"latitude,omitempty" db:"latitude"`
+	Longitude    *float64   `json:"longitude,omitempty" db:"longitude"`
 	Reputation   int        `json:"reputation" db:"reputation"`
 	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
 	Sports       []UserSport `json:"sports,omitempty"`
+	Attendance   *AttendanceStats `json:"attendance,omitempty"`
+	Ratings      *RatingSummary   `json:"ratings,omitempty"`
+}
+
+// PublicUser is what other users see of a user. Contact details and the
+// home location are only returned to the user themselves.
+type PublicUser struct {
+	UserID     string  `json:"user_id" db:"user_id"`
+	Name       string  `json:"name" db:"name"`
+	PictureURL *string `json:"picture_url,omitempty" db:"picture_url"`
+	Reputation int     `json:"reputation" db:"reputation"`
+}
+
+// PublicProfile is a user's profile as shown to other users
+type PublicProfile struct {
+	PublicUser
+	CreatedAt  time.Time        `json:"created_at"`
+	Sports     []UserSport      `json:"sports,omitempty"`
+	Attendance *AttendanceStats `json:"attendance,omitempty"`
+	Ratings    *RatingSummary   `json:"ratings,omitempty"`
 }
 
 // UserSport represents the many-to-many relationship between users and sports
@@ -35,6 +59,8 @@ type CreateUserRequest struct {
 	PictureURL  *string `json:"picture_url,omitempty"`
 	PhoneNumber *string `json:"phone_number,omitempty"`
 	Location    *string `json:"location,omitempty"`
+	Latitude    *float64 `json:"latitude,omitempty" binding:"omitempty,min=-90,max=90"`
+	Longitude   *float64 `json:"longitude,omitempty" binding:"omitempty,min=-180,max=180"`
 }
 
 // UpdateUserRequest represents the request payload for updating a user
@@ -42,18 +68,20 @@ type UpdateUserRequest struct {
 	Name        *string `json:"name,omitempty"`
 	PictureURL  *string `json:"picture_url,omitempty"`
 	PhoneNumber *string `json:"phone_number,omitempty"`
-	Location    *string `json:"location,omitempty"`
+	Location    *string `json:"location,omitempty"` // clearing the location also clears the coordinates
+	Latitude    *float64 `json:"latitude,omitempty" binding:"omitempty,min=-90,max=90"`
+	Longitude   *float64 `json:"longitude,omitempty" binding:"omitempty,min=-180,max=180"`
 }
 
 // AddUserSportRequest represents the request payload for adding a sport to a user
 type AddUserSportRequest struct {
 	SportName   string  `json:"sport_name" binding:"required"`
-	Position    *string `json:"position,omitempty"`
+	Position    *string `json:"position,omitempty" binding:"omitempty,oneof=front back"`
 	SkillLevel  string  `json:"skill_level" binding:"required,oneof=beginner intermediate advanced"`
 }
 
 // UpdateUserSportRequest represents the request payload for updating a user's sport
 type UpdateUserSportRequest struct {
-	Position   *string `json:"position,omitempty"`
-	SkillLevel *string `json:"skill_level,omitempty"`
+	Position   *string `json:"position,omitempty" binding:"omitempty,oneof=front back"`
+	SkillLevel *string `json:"skill_level,omitempty" binding:"omitempty,oneof=beginner intermediate advanced"`
 }
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"time"
+)
+
+// Venue is a place games are played at, e.g. a park or a sports hall
+type Venue struct {
+	VenueID   string   `json:"venue_id" db:"venue_id"`
+	Name      string   `json:"name" db:"name"`
+	Address   *string  `json:"address,omitempty" db:"address"`
+	Latitude  *float64 `json:"latitude,omitempty" db:"latitude"`
+	Longitude *float64 `json:"longitude,omitempty" db:"longitude"`
+	// Timezone is the IANA time zone opening hours are given in
+	Timezone     string         `json:"timezone" db:"timezone"`
+	Amenities    []string       `json:"amenities" db:"amenities"`
+	Sports       []string       `json:"sports"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" db:"updated_at"`
+	Courts       []Court        `json:"courts,omitempty"`
+	OpeningHours []OpeningHours `json:"opening_hours,omitempty"`
+	// Aliases are the normalized free-text locations linked to the venue
+	Aliases []string `json:"aliases,omitempty"`
+	// DistanceKm is the distance from the searched point, set by geo searches
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+}
+
+// Court is a bookable playing area of a venue
+type Court struct {
+	CourtID   string    `json:"court_id" db:"court_id"`
+	VenueID   string    `json:"venue_id" db:"venue_id"`
+	Name      string    `json:"name" db:"name"`
+	Surface   *string   `json:"surface,omitempty" db:"surface"` // e.g. "grass", "turf", "hardwood", "sand"
+	Indoor    bool      `json:"indoor" db:"indoor"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// OpeningHours is one opening interval of a venue on a weekday, in the
+// venue's time zone. A day can have several intervals.
+type OpeningHours struct {
+	Weekday int    `json:"weekday" binding:"min=0,max=6"` // 0 is Sunday
+	Opens   string `json:"opens" binding:"required"`      // "HH:MM"
+	Closes  string `json:"closes" binding:"required"`     // "HH:MM", "24:00" for midnight
+}
+
+// CreateVenueRequest represents the request payload for creating a venue
+type CreateVenueRequest struct {
+	Name         string               `json:"name" binding:"required"`
+	Address      *string              `json:"address,omitempty"`
+	Latitude     *float64             `json:"latitude,omitempty" binding:"omitempty,min=-90,max=90"`
+	Longitude    *float64             `json:"longitude,omitempty" binding:"omitempty,min=-180,max=180"`
+	Timezone     string               `json:"timezone,omitempty"` // default "UTC"
+	Amenities    []string             `json:"amenities,omitempty"`
+	Sports       []string             `json:"sports,omitempty"`
+	OpeningHours []OpeningHours       `json:"opening_hours,omitempty" binding:"dive"`
+	Courts       []CreateCourtRequest `json:"courts,omitempty" binding:"dive"`
+}
+
+// UpdateVenueRequest represents the request payload for updating a venue.
+// Amenities, sports and opening hours replace the stored lists when set.
+type UpdateVenueRequest struct {
+	Name         *string         `json:"name,omitempty"`
+	Address      *string         `json:"address,omitempty"`
+	Latitude     *float64        `json:"latitude,omitempty" binding:"omitempty,min=-90,max=90"`
+	Longitude    *float64        `json:"longitude,omitempty" binding:"omitempty,min=-180,max=180"`
+	Timezone     *string         `json:"timezone,omitempty"`
+	Amenities    *[]string       `json:"amenities,omitempty"`
+	Sports       *[]string       `json:"sports,omitempty"`
+	OpeningHours *[]OpeningHours `json:"opening_hours,omitempty" binding:"omitempty,dive"`
+}
+
+// CreateCourtRequest represents the request payload for adding a court to a venue
+type CreateCourtRequest struct {
+	Name    string  `json:"name" binding:"required"`
+	Surface *string `json:"surface,omitempty"`
+	Indoor  bool    `json:"indoor"`
+}
+
+// UpdateCourtRequest represents the request payload for updating a court
+type UpdateCourtRequest struct {
+	Name    *string `json:"name,omitempty"`
+	Surface *string `json:"surface,omitempty"`
+	Indoor  *bool   `json:"indoor,omitempty"`
+}
+
+// VenueFilters represents filters for querying venues
+type VenueFilters struct {
+	Query     *string  `json:"q,omitempty" form:"q"` // part of the name or address
+	SportName *string  `json:"sport_name,omitempty" form:"sport_name"`
+	Latitude  *float64 `json:"lat,omitempty" form:"lat" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"lng,omitempty" form:"lng" binding:"omitempty,min=-180,max=180"`
+	RadiusKm  *float64 `json:"radius_km,omitempty" form:"radius_km" binding:"omitempty,gt=0,max=1000"`
+	Limit     int      `json:"limit,omitempty" form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset    int      `json:"offset,omitempty" form:"offset" binding:"omitempty,min=0"`
+}
+
+// LocationGroup is a set of free-text game locations that normalize to the
+// same text, e.g. "Central Park" and "central park.", found by the
+// deduplication tool
+type LocationGroup struct {
+	// Key is the normalized location shared by the group
+	Key       string   `json:"key"`
+	Spellings []string `json:"spellings"`
+	// SuggestedName is the most used spelling
+	SuggestedName string `json:"suggested_name"`
+	GameCount     int    `json:"game_count"`
+	// VenueID is set when the group is already linked to a venue
+	VenueID *string `json:"venue_id,omitempty"`
+}
+
+// LocationGroupFilters represents the paging of the duplicate location report
+type LocationGroupFilters struct {
+	Limit  int `json:"limit,omitempty" form:"limit" binding:"omitempty,min=1,max=500"`
+	Offset int `json:"offset,omitempty" form:"offset" binding:"omitempty,min=0"`
+}
+
+// MergeVenuesRequest represents the request payload for merging duplicate
+// venues and free-text locations into a venue
+type MergeVenuesRequest struct {
+	// VenueIDs are duplicate venues folded into the target and deleted
+	VenueIDs []string `json:"venue_ids,omitempty"`
+	// Locations are free-text locations whose games are linked to the target
+	Locations []string `json:"locations,omitempty"`
+}
+
+// MergeVenuesResult reports what a merge changed
+type MergeVenuesResult struct {
+	Venue         Venue `json:"venue"`
+	MergedVenues  int   `json:"merged_venues"`
+	LinkedGames   int64 `json:"linked_games"`
+	LinkedAliases int   `json:"linked_aliases"`
+}
+
+// TimeSlot is an interval of time, optionally taken by a game
+type TimeSlot struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	GameID *string   `json:"game_id,omitempty"`
+	Title  *string   `json:"title,omitempty"`
+}
+
+// CourtAvailability lists the bookings and free slots of a court on one day
+type CourtAvailability struct {
+	CourtID string     `json:"court_id"`
+	Name    string     `json:"name"`
+	Booked  []TimeSlot `json:"booked"`
+	Free    []TimeSlot `json:"free"`
+}
+
+// VenueAvailability lists the opening hours of a venue on one day and the
+// availability of its courts within them
+type VenueAvailability struct {
+	VenueID  string `json:"venue_id"`
+	Date     string `json:"date"` // YYYY-MM-DD in the venue's time zone
+	Timezone string `json:"timezone"`
+	// Open are the opening intervals of the day; a venue without opening
+	// hours is open all day
+	Open   []TimeSlot          `json:"open"`
+	Courts []CourtAvailability `json:"courts"`
+}
+
+// AvailabilityFilters represents the query of a venue availability calendar
+type AvailabilityFilters struct {
+	Date    string  `json:"date" form:"date" binding:"required"` // YYYY-MM-DD in the venue's time zone
+	CourtID *string `json:"court_id,omitempty" form:"court_id"`
+	// MinMinutes drops free slots shorter than this
+	MinMinutes int `json:"min_minutes,omitempty" form:"min_minutes" binding:"omitempty,min=1,max=1440"`
+}
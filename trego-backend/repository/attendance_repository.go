@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// defaultAttendancePageSize is used when the history does not set a limit
+	defaultAttendancePageSize = 20
+	// defaultAttendanceLockWindow is how long after end_time attendance stays editable
+	defaultAttendanceLockWindow = 72 * time.Hour
+)
+
+// AttendanceRepository provides access to the attendance of game players
+type AttendanceRepository struct {
+	db *pgxpool.Pool
+	// lockWindow is how long after end_time the host may still mark attendance
+	lockWindow time.Duration
+}
+
+// NewAttendanceRepository creates a new attendance repository
+func NewAttendanceRepository(db *pgxpool.Pool) *AttendanceRepository {
+	return &AttendanceRepository{db: db, lockWindow: defaultAttendanceLockWindow}
+}
+
+// WithLockWindow sets how long after end_time attendance can be marked, and
+// returns the repository
+func (r *AttendanceRepository) WithLockWindow(window time.Duration) *AttendanceRepository {
+	r.lockWindow = window
+	return r
+}
+
+// Mark sets the attendance of players of a game on behalf of callerID, who
+// must be the host. Attendance can be marked from end_time until the lock
+// window closes; every listed user must be on the roster. The whole batch is
+// applied or rejected together, along with the reputation it earns players
+// and the host. Returns the updated roster.
+func (r *AttendanceRepository) Mark(ctx context.Context, gameID, callerID string, req models.MarkAttendanceRequest) ([]models.GamePlayer, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := getGame(ctx, tx, gameID, true)
+	if err != nil {
+		return nil, err
+	}
+	if game.HostID != callerID {
+		return nil, fmt.Errorf("only the host can mark attendance: %w", ErrForbidden)
+	}
+	if game.Status == models.GameStatusCancelled {
+		return nil, &ConflictError{Message: "cancelled games cannot have attendance marked"}
+	}
+
+	now := time.Now()
+	lockedAt := game.EndTime.Add(r.lockWindow)
+	switch {
+	case now.Before(game.EndTime):
+		return nil, &ConflictError{Message: "attendance can only be marked after the game has ended"}
+	case now.After(lockedAt):
+		return nil, &ConflictError{Message: fmt.Sprintf("attendance was locked at %s", lockedAt.UTC().Format(time.RFC3339))}
+	}
+
+	seen := make(map[string]bool, len(req.Players))
+	for _, mark := range req.Players {
+		if seen[mark.UserID] {
+			return nil, &ValidationError{Field: "players", Message: fmt.Sprintf("user %q is listed more than once", mark.UserID)}
+		}
+		seen[mark.UserID] = true
+
+		tag, err := tx.Exec(ctx, `
+			UPDATE game_players SET attendance = $1, attendance_marked_at = NOW()
+			WHERE game_id = $2 AND user_id = $3
+		`, mark.Attendance, gameID, mark.UserID)
+		if err != nil {
+			return nil, &DatabaseError{Op: "mark attendance", Err: err}
+		}
+		if tag.RowsAffected() == 0 {
+			return nil, &ValidationError{Field: "players", Message: fmt.Sprintf("user %q is not on the roster", mark.UserID)}
+		}
+
+		delta, reason := attendanceReputation(mark.Attendance)
+		if err := applyReputation(ctx, tx, mark.UserID, ReputationSourceAttendance, gameID, delta, reason); err != nil {
+			return nil, err
+		}
+	}
+
+	// The host earns reputation once attendance shows the game took place
+	var attended bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM game_players WHERE game_id = $1 AND attendance = 'true')`, gameID).Scan(&attended); err != nil {
+		return nil, &DatabaseError{Op: "check attendance", Err: err}
+	}
+	hosted, reason := 0, "hosted game did not take place"
+	if attended {
+		hosted, reason = reputationHosted, "hosted a game that took place"
+	}
+	if err := applyReputation(ctx, tx, game.HostID, ReputationSourceHosting, gameID, hosted, reason); err != nil {
+		return nil, err
+	}
+
+	players, err := listPlayers(ctx, tx, gameID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit attendance", Err: err}
+	}
+	return players, nil
+}
+
+// History returns the games a user played that have ended, most recent
+// first, with the attendance the host marked for them
+func (r *AttendanceRepository) History(ctx context.Context, userID string, filters models.AttendanceHistoryFilters) ([]models.AttendanceRecord, error) {
+	if err := requireUser(ctx, r.db, userID); err != nil {
+		return nil, err
+	}
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultAttendancePageSize
+	}
+
+	query := `
+		SELECT gp.attendance, gp.attendance_marked_at, ` + gameColumns + `
+		FROM game_players gp
+		JOIN games g ON g.game_id = gp.game_id
+		WHERE gp.user_id = $1 AND g.end_time <= NOW() AND g.status <> 'cancelled'
+		ORDER BY g.start_time DESC, g.game_id
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.Query(ctx, query, userID, limit, filters.Offset)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list attendance history", Err: err}
+	}
+	defer rows.Close()
+
+	records := []models.AttendanceRecord{}
+	for rows.Next() {
+		var rec models.AttendanceRecord
+		dest := append([]interface{}{&rec.Attendance, &rec.MarkedAt}, gameFields(&rec.Game)...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, &DatabaseError{Op: "scan attendance record", Err: err}
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list attendance history", Err: err}
+	}
+	return records, nil
+}
+
+// Stats returns the attendance summary of a user
+func (r *AttendanceRepository) Stats(ctx context.Context, userID string) (*models.AttendanceStats, error) {
+	var stats models.AttendanceStats
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FILTER (WHERE attendance = 'true'), COUNT(*) FILTER (WHERE attendance = 'false')
+		FROM game_players
+		WHERE user_id = $1
+	`, userID).Scan(&stats.Attended, &stats.NoShows)
+	if err != nil {
+		return nil, &DatabaseError{Op: "get attendance stats", Err: err}
+	}
+
+	stats.GamesMarked = stats.Attended + stats.NoShows
+	if stats.GamesMarked > 0 {
+		stats.NoShowRate = float64(stats.NoShows) / float64(stats.GamesMarked)
+	}
+	return &stats, nil
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SigningKey is an HMAC key used to sign Trego access and refresh tokens
+type SigningKey struct {
+	KeyID     string
+	Secret    []byte
+	CreatedAt time.Time
+	// ExpiresAt is set once a newer key replaced this one; the key keeps
+	// verifying tokens until then
+	ExpiresAt *time.Time
+}
+
+// AuthSession is a login session, i.e. one refresh-token family
+type AuthSession struct {
+	SessionID      string
+	UserID         string
+	RefreshTokenID string
+	IsAdmin        bool
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	RevokedAt      *time.Time
+}
+
+// AuthRepository stores token signing keys and login sessions
+type AuthRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewAuthRepository creates a new auth repository
+func NewAuthRepository(db *pgxpool.Pool) *AuthRepository {
+	return &AuthRepository{db: db}
+}
+
+// ListSigningKeys returns the keys that still verify tokens, newest first
+func (r *AuthRepository) ListSigningKeys(ctx context.Context) ([]SigningKey, error) {
+	query := `
+		SELECT key_id, secret, created_at, expires_at
+		FROM auth_signing_keys
+		WHERE expires_at IS NULL OR expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []SigningKey
+	for rows.Next() {
+		var k SigningKey
+		if err := rows.Scan(&k.KeyID, &k.Secret, &k.CreatedAt, &k.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RotateSigningKey inserts a new signing key and schedules all previously
+// active keys to expire after the given grace period
+func (r *AuthRepository) RotateSigningKey(ctx context.Context, key SigningKey, grace time.Duration) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	retireQuery := `
+		UPDATE auth_signing_keys
+		SET expires_at = $1
+		WHERE expires_at IS NULL
+	`
+	if _, err := tx.Exec(ctx, retireQuery, time.Now().Add(grace)); err != nil {
+		return fmt.Errorf("failed to retire signing keys: %w", err)
+	}
+
+	insertQuery := `INSERT INTO auth_signing_keys (key_id, secret) VALUES ($1, $2)`
+	if _, err := tx.Exec(ctx, insertQuery, key.KeyID, key.Secret); err != nil {
+		return fmt.Errorf("failed to insert signing key: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM auth_signing_keys WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("failed to purge expired signing keys: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// CreateSession stores a new login session
+func (r *AuthRepository) CreateSession(ctx context.Context, session AuthSession) error {
+	query := `
+		INSERT INTO auth_sessions (session_id, user_id, refresh_token_id, is_admin, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := r.db.Exec(ctx, query, session.SessionID, session.UserID, session.RefreshTokenID, session.IsAdmin, session.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// GetSession returns a session by ID
+func (r *AuthRepository) GetSession(ctx context.Context, sessionID string) (*AuthSession, error) {
+	query := `
+		SELECT session_id, user_id, refresh_token_id, is_admin, created_at, expires_at, revoked_at
+		FROM auth_sessions
+		WHERE session_id = $1
+	`
+	var s AuthSession
+	err := r.db.QueryRow(ctx, query, sessionID).Scan(
+		&s.SessionID, &s.UserID, &s.RefreshTokenID, &s.IsAdmin, &s.CreatedAt, &s.ExpiresAt, &s.RevokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return &s, nil
+}
+
+// IsSessionActive reports whether a session exists, is not revoked and has not expired
+func (r *AuthRepository) IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM auth_sessions
+			WHERE session_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		)
+	`
+	var active bool
+	if err := r.db.QueryRow(ctx, query, sessionID).Scan(&active); err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return active, nil
+}
+
+// RotateRefreshToken replaces the current refresh token of an active session.
+// It returns false if the session is revoked or oldTokenID is no longer the
+// current token, which means a refresh token is being reused.
+func (r *AuthRepository) RotateRefreshToken(ctx context.Context, sessionID, oldTokenID, newTokenID string, expiresAt time.Time) (bool, error) {
+	query := `
+		UPDATE auth_sessions
+		SET refresh_token_id = $3, expires_at = $4
+		WHERE session_id = $1 AND refresh_token_id = $2
+		  AND revoked_at IS NULL AND expires_at > NOW()
+	`
+	tag, err := r.db.Exec(ctx, query, sessionID, oldTokenID, newTokenID, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// RevokeSession revokes a single session
+func (r *AuthRepository) RevokeSession(ctx context.Context, sessionID string) error {
+	query := `UPDATE auth_sessions SET revoked_at = NOW() WHERE session_id = $1 AND revoked_at IS NULL`
+	if _, err := r.db.Exec(ctx, query, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every session of a user
+func (r *AuthRepository) RevokeUserSessions(ctx context.Context, userID string) error {
+	query := `UPDATE auth_sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := r.db.Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+	return nil
+}
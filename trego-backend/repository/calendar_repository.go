@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// calendarJoins joins the places a game's time zone comes from. It
+	// expects the games table to be aliased as g.
+	calendarJoins = `LEFT JOIN venues cv ON cv.venue_id = g.venue_id
+		LEFT JOIN game_series cs ON cs.series_id = g.series_id`
+	// calendarTimezone is the time zone a game's calendar event is shown in
+	calendarTimezone = `COALESCE(cv.timezone, cs.timezone, 'UTC')`
+	// calendarFeedGames matches the games in the feed of the user in $1:
+	// the ones they host or are on the roster of
+	calendarFeedGames = `(g.host_id = $1
+		OR EXISTS (SELECT 1 FROM game_players fgp WHERE fgp.game_id = g.game_id AND fgp.user_id = $1))`
+)
+
+// CalendarRepository provides access to calendar feeds and the games in them
+type CalendarRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewCalendarRepository creates a new calendar repository
+func NewCalendarRepository(db *pgxpool.Pool) *CalendarRepository {
+	return &CalendarRepository{db: db}
+}
+
+// CreateFeed gives the user a calendar feed reachable with token, replacing
+// the previous one so its URL stops working
+func (r *CalendarRepository) CreateFeed(ctx context.Context, userID, token string) (*models.CalendarFeed, error) {
+	if err := requireUser(ctx, r.db, userID); err != nil {
+		return nil, err
+	}
+
+	var feed models.CalendarFeed
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO calendar_feeds (user_id, token_hash) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = NOW()
+		RETURNING created_at
+	`, userID, hashCalendarToken(token)).Scan(&feed.CreatedAt)
+	if err != nil {
+		return nil, &DatabaseError{Op: "create calendar feed", Err: err}
+	}
+	return &feed, nil
+}
+
+// GetFeed returns the user's calendar feed without its URL
+func (r *CalendarRepository) GetFeed(ctx context.Context, userID string) (*models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+	err := r.db.QueryRow(ctx, `SELECT created_at FROM calendar_feeds WHERE user_id = $1`, userID).Scan(&feed.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "calendar feed", ID: userID}
+		}
+		return nil, &DatabaseError{Op: "get calendar feed", Err: err}
+	}
+	return &feed, nil
+}
+
+// DeleteFeed removes the user's calendar feed
+func (r *CalendarRepository) DeleteFeed(ctx context.Context, userID string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM calendar_feeds WHERE user_id = $1`, userID)
+	if err != nil {
+		return &DatabaseError{Op: "delete calendar feed", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return &NotFoundError{Resource: "calendar feed", ID: userID}
+	}
+	return nil
+}
+
+// FeedOwner returns the user whose calendar feed token is token
+func (r *CalendarRepository) FeedOwner(ctx context.Context, token string) (string, error) {
+	var userID string
+	err := r.db.QueryRow(ctx, `SELECT user_id FROM calendar_feeds WHERE token_hash = $1`, hashCalendarToken(token)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", &NotFoundError{Resource: "calendar feed", ID: "token"}
+		}
+		return "", &DatabaseError{Op: "get calendar feed", Err: err}
+	}
+	return userID, nil
+}
+
+// FeedVersion returns the version of the user's feed without loading it.
+// Both change whenever a game in it is updated, the user joins or leaves
+// one, or one is deleted.
+func (r *CalendarRepository) FeedVersion(ctx context.Context, userID string) (*models.CalendarVersion, error) {
+	var version models.CalendarVersion
+	var lastModified *time.Time
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(md5(string_agg(g.game_id || '|' || g.updated_at::text || '|' || `+calendarTimezone+`, ',' ORDER BY g.game_id)), ''),
+			GREATEST(MAX(g.updated_at), (SELECT MAX(joined_at) FROM game_players WHERE user_id = $1),
+				(SELECT changed_at FROM calendar_feeds WHERE user_id = $1))
+		FROM games g
+		`+calendarJoins+`
+		WHERE `+calendarFeedGames, userID).Scan(&version.ETag, &lastModified)
+	if err != nil {
+		return nil, &DatabaseError{Op: "get calendar feed version", Err: err}
+	}
+	if lastModified != nil {
+		version.LastModified = *lastModified
+	}
+	return &version, nil
+}
+
+// FeedGames returns the games in the user's feed, cancelled ones included,
+// by start time
+func (r *CalendarRepository) FeedGames(ctx context.Context, userID string) ([]models.CalendarGame, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+gameColumns+`, `+calendarTimezone+`
+		FROM games g
+		`+calendarJoins+`
+		WHERE `+calendarFeedGames+`
+		ORDER BY g.start_time, g.game_id
+	`, userID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list calendar games", Err: err}
+	}
+	defer rows.Close()
+
+	games := []models.CalendarGame{}
+	for rows.Next() {
+		var g models.CalendarGame
+		if err := rows.Scan(append(gameFields(&g.Game), &g.Timezone)...); err != nil {
+			return nil, &DatabaseError{Op: "scan calendar game", Err: err}
+		}
+		games = append(games, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list calendar games", Err: err}
+	}
+	return games, nil
+}
+
+// Game returns a single game with the time zone of its calendar event
+func (r *CalendarRepository) Game(ctx context.Context, gameID string) (*models.CalendarGame, error) {
+	var g models.CalendarGame
+	err := r.db.QueryRow(ctx, `
+		SELECT `+gameColumns+`, `+calendarTimezone+`
+		FROM games g
+		`+calendarJoins+`
+		WHERE g.game_id = $1
+	`, gameID).Scan(append(gameFields(&g.Game), &g.Timezone)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "game", ID: gameID}
+		}
+		return nil, &DatabaseError{Op: "get calendar game", Err: err}
+	}
+	return &g, nil
+}
+
+// hashCalendarToken returns the stored form of a calendar feed token
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
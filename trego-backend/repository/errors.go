@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrNotFound is returned when the requested row does not exist.
+// NotFoundError values match it with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+// ErrForbidden is returned when the caller may not act on an existing row,
+// e.g. a non-host editing a game
+var ErrForbidden = errors.New("forbidden")
+
+// ErrRateLimited is returned when the caller has done something too often
+// recently, e.g. submitted too many ratings
+var ErrRateLimited = errors.New("rate limited")
+
+// PostgreSQL error codes the repositories translate into typed errors
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	pgExclusionViolation  = "23P01"
+)
+
+// NotFoundError reports that a specific resource does not exist
+type NotFoundError struct {
+	Resource string
+	ID       string
+}
+
+// Error implements the error interface
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %q not found", e.Resource, e.ID)
+}
+
+// Is makes NotFoundError match ErrNotFound
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ValidationError reports input that the database would reject
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ConflictError reports a uniqueness or state conflict with existing data
+type ConflictError struct {
+	Message string
+	// Details optionally describes what is conflicting, e.g. blocking rows
+	Details interface{}
+}
+
+// Error implements the error interface
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// DatabaseError wraps an unexpected database failure
+type DatabaseError struct {
+	Op  string
+	Err error
+}
+
+// Error implements the error interface
+func (e *DatabaseError) Error() string {
+	return fmt.Sprintf("failed to %s: %v", e.Op, e.Err)
+}
+
+// Unwrap returns the underlying database error
+func (e *DatabaseError) Unwrap() error {
+	return e.Err
+}
+
+// pgErrorCode returns the PostgreSQL error code of err, or "" if it has none
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// pgConstraintName returns the violated constraint of err, or "" if it has none
+func pgConstraintName(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName
+	}
+	return ""
+}
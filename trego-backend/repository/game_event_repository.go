@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GameEventsChannel is the Postgres notification channel every recorded game
+// event is announced on, with the game ID as payload
+const GameEventsChannel = "game_events"
+
+// GameEventRepository provides access to the game event log
+type GameEventRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewGameEventRepository creates a new game event repository
+func NewGameEventRepository(db *pgxpool.Pool) *GameEventRepository {
+	return &GameEventRepository{db: db}
+}
+
+// After returns up to limit events of a game recorded after the event with
+// ID afterID, oldest first
+func (r *GameEventRepository) After(ctx context.Context, gameID string, afterID int64, limit int) ([]models.GameEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT event_id, game_id, type, user_id, game, created_at
+		FROM game_events
+		WHERE game_id = $1 AND event_id > $2
+		ORDER BY event_id
+		LIMIT $3
+	`, gameID, afterID, limit)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list game events", Err: err}
+	}
+	defer rows.Close()
+
+	events := []models.GameEvent{}
+	for rows.Next() {
+		var e models.GameEvent
+		if err := rows.Scan(&e.EventID, &e.GameID, &e.Type, &e.UserID, &e.Game, &e.CreatedAt); err != nil {
+			return nil, &DatabaseError{Op: "scan game event", Err: err}
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list game events", Err: err}
+	}
+	return events, nil
+}
+
+// Latest returns the ID of the last event recorded for a game, or 0. Streams
+// starting now resume from it. Every writer holds the game's row lock while
+// recording, so a game's event IDs grow in commit order and no event can
+// later appear behind it.
+func (r *GameEventRepository) Latest(ctx context.Context, gameID string) (int64, error) {
+	var latest int64
+	err := r.db.QueryRow(ctx, `SELECT COALESCE(MAX(event_id), 0) FROM game_events WHERE game_id = $1`, gameID).Scan(&latest)
+	if err != nil {
+		return 0, &DatabaseError{Op: "get latest game event", Err: err}
+	}
+	return latest, nil
+}
+
+// Retained reports whether every event of a game after afterID is still in
+// the log, i.e. a stream resuming from it misses nothing to pruning. Gaps in
+// the event IDs are not mistaken for pruned events.
+func (r *GameEventRepository) Retained(ctx context.Context, gameID string, afterID int64) (bool, error) {
+	var prunedThrough int64
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE((SELECT pruned_through FROM game_event_prunes WHERE game_id = $1), 0)
+	`, gameID).Scan(&prunedThrough)
+	if err != nil {
+		return false, &DatabaseError{Op: "check game event retention", Err: err}
+	}
+	return afterID >= prunedThrough, nil
+}
+
+// Prune deletes the events recorded before the given time, raising the prune
+// watermark of their games, and returns how many it deleted
+func (r *GameEventRepository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	var pruned int64
+	err := r.db.QueryRow(ctx, `
+		WITH pruned AS (
+			DELETE FROM game_events WHERE created_at < $1
+			RETURNING game_id, event_id
+		), watermarks AS (
+			INSERT INTO game_event_prunes (game_id, pruned_through)
+			SELECT game_id, MAX(event_id) FROM pruned GROUP BY game_id
+			ON CONFLICT (game_id) DO UPDATE
+			SET pruned_through = GREATEST(game_event_prunes.pruned_through, EXCLUDED.pruned_through)
+		)
+		SELECT COUNT(*) FROM pruned
+	`, before).Scan(&pruned)
+	if err != nil {
+		return 0, &DatabaseError{Op: "prune game events", Err: err}
+	}
+	return pruned, nil
+}
+
+// recordGameEvent appends an event to the log inside the caller's
+// transaction. Streams are notified when it commits. userID and game may be
+// nil.
+func recordGameEvent(ctx context.Context, q querier, gameID, eventType string, userID *string, game *models.Game) error {
+	var snapshot []byte
+	if game != nil {
+		var err error
+		if snapshot, err = json.Marshal(game); err != nil {
+			return &DatabaseError{Op: "encode game event", Err: err}
+		}
+	}
+	_, err := q.Exec(ctx, `
+		INSERT INTO game_events (game_id, type, user_id, game) VALUES ($1, $2, $3, $4)
+	`, gameID, eventType, userID, snapshot)
+	if err != nil {
+		return &DatabaseError{Op: "record game event", Err: err}
+	}
+	return nil
+}
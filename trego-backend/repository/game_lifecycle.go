@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// gameTransitions lists the statuses a game may move to from each status.
+// Completed and cancelled games are final.
+var gameTransitions = map[string][]string{
+	models.GameStatusScheduled:  {models.GameStatusInProgress, models.GameStatusCancelled},
+	models.GameStatusInProgress: {models.GameStatusCompleted, models.GameStatusCancelled},
+}
+
+// canTransition reports whether a game may move from one status to another
+func canTransition(from, to string) bool {
+	for _, next := range gameTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// requireGameStatus returns a conflict unless the game is in one of the
+// given statuses. action completes "... cannot <action>", e.g. "be joined".
+func requireGameStatus(game *models.Game, action string, statuses ...string) error {
+	for _, status := range statuses {
+		if game.Status == status {
+			return nil
+		}
+	}
+	return &ConflictError{Message: fmt.Sprintf("%s games cannot %s", statusLabel(game.Status), action)}
+}
+
+// statusLabel returns a status as it reads in messages
+func statusLabel(status string) string {
+	if status == models.GameStatusInProgress {
+		return "in-progress"
+	}
+	return status
+}
+
+// Cancel cancels a scheduled or in-progress game on behalf of callerID, who
+// must be the host. The game and its roster are kept with the reason.
+// Cancelling an upcoming game with players is recorded against the host's
+// reputation.
+func (r *GameRepository) Cancel(ctx context.Context, gameID, callerID string, req models.CancelGameRequest) (*models.Game, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := getGame(ctx, tx, gameID, true)
+	if err != nil {
+		return nil, err
+	}
+	if game.HostID != callerID {
+		return nil, fmt.Errorf("only the host can cancel this game: %w", ErrForbidden)
+	}
+	if err := cancelGame(ctx, tx, game, req.Reason, req.Note); err != nil {
+		return nil, err
+	}
+
+	cancelled, err := getGame(ctx, tx, gameID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit game cancellation", Err: err}
+	}
+	return cancelled, nil
+}
+
+// cancelGame moves a game locked by the transaction to cancelled, charging
+// the host reputation for an upcoming game with players
+func cancelGame(ctx context.Context, tx pgx.Tx, game *models.Game, reason string, note *string) error {
+	if !canTransition(game.Status, models.GameStatusCancelled) {
+		return &ConflictError{Message: fmt.Sprintf("%s games cannot be cancelled", statusLabel(game.Status))}
+	}
+	if delta, reputationReason := cancellationReputation(game, time.Now()); delta != 0 {
+		if err := applyReputation(ctx, tx, game.HostID, ReputationSourceCancellation, game.GameID, delta, reputationReason); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(ctx, `
+		UPDATE games SET status = $2, cancelled_at = NOW(), cancellation_reason = $3, cancellation_note = $4
+		WHERE game_id = $1
+	`, game.GameID, models.GameStatusCancelled, reason, emptyToNil(note))
+	if err != nil {
+		return &DatabaseError{Op: "cancel game", Err: err}
+	}
+
+	cancelled, err := getGame(ctx, tx, game.GameID, false)
+	if err != nil {
+		return err
+	}
+	return recordGameEvent(ctx, tx, game.GameID, models.GameEventGameCancelled, nil, cancelled)
+}
+
+// AdvanceLifecycle starts the scheduled games whose start_time has passed and
+// completes the in-progress games whose end_time has passed, and returns how
+// many games it started and completed. A game that was missed for its whole
+// run goes through both in one call. It is safe to run concurrently.
+func (r *GameRepository) AdvanceLifecycle(ctx context.Context) (started, completed int64, err error) {
+	tag, err := r.db.Exec(ctx, `
+		WITH started AS (
+			UPDATE games SET status = $1, started_at = NOW()
+			WHERE status = $2 AND start_time <= NOW()
+			RETURNING game_id
+		)
+		INSERT INTO game_events (game_id, type) SELECT game_id, $3 FROM started
+	`, models.GameStatusInProgress, models.GameStatusScheduled, models.GameEventGameStarted)
+	if err != nil {
+		return 0, 0, &DatabaseError{Op: "start games", Err: err}
+	}
+	started = tag.RowsAffected()
+
+	tag, err = r.db.Exec(ctx, `
+		WITH completed AS (
+			UPDATE games SET status = $1, completed_at = NOW()
+			WHERE status = $2 AND end_time <= NOW()
+			RETURNING game_id
+		)
+		INSERT INTO game_events (game_id, type) SELECT game_id, $3 FROM completed
+	`, models.GameStatusCompleted, models.GameStatusInProgress, models.GameEventGameCompleted)
+	if err != nil {
+		return started, 0, &DatabaseError{Op: "complete games", Err: err}
+	}
+	return started, tag.RowsAffected(), nil
+}
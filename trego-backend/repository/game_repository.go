@@ -0,0 +1,586 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// gameColumns is the column list matching scanGame. It expects the games
+// table to be aliased as g.
+const gameColumns = `g.game_id, g.host_id, g.sport_name, g.title, g.description, g.start_time, g.end_time,
+	g.location, g.latitude, g.longitude, g.venue_id, g.court_id, g.series_id, g.skill_range, g.capacity, g.skill_level, g.visibility,
+	g.status, g.started_at, g.completed_at, g.cancelled_at, g.cancellation_reason, g.cancellation_note, g.created_at, g.updated_at,
+	(SELECT COUNT(*) FROM game_players gp WHERE gp.game_id = g.game_id),
+	(SELECT COUNT(*) FROM game_waitlist gw WHERE gw.game_id = g.game_id)`
+
+// GameRepository provides access to the games table
+type GameRepository struct {
+	db *pgxpool.Pool
+	// waitlistCutoff is how long before start_time the waitlist stops being
+	// promoted automatically
+	waitlistCutoff time.Duration
+	// reminderOffsets are how long before start_time the reminders of new
+	// and moved games are scheduled
+	reminderOffsets []time.Duration
+}
+
+// NewGameRepository creates a new game repository
+func NewGameRepository(db *pgxpool.Pool) *GameRepository {
+	return &GameRepository{db: db}
+}
+
+// WithWaitlistCutoff sets how long before start_time waitlisted players stop
+// being promoted into open spots, and returns the repository
+func (r *GameRepository) WithWaitlistCutoff(cutoff time.Duration) *GameRepository {
+	r.waitlistCutoff = cutoff
+	return r
+}
+
+// WithReminderOffsets sets how long before start_time the reminders of the
+// games created and moved through the repository are scheduled, and returns
+// the repository
+func (r *GameRepository) WithReminderOffsets(offsets []time.Duration) *GameRepository {
+	r.reminderOffsets = offsets
+	return r
+}
+
+// Create inserts a game hosted by hostID and schedules its reminders. A game
+// at a venue takes the venue's name and coordinates unless it sets its own;
+// a free-text location matching a venue alias is placed at that venue.
+func (r *GameRepository) Create(ctx context.Context, hostID string, req models.CreateGameRequest) (*models.Game, error) {
+	if !req.StartTime.After(time.Now()) {
+		return nil, &ValidationError{Field: "start_time", Message: "must be in the future"}
+	}
+	game, err := prepareGame(ctx, r.db, req)
+	if err != nil {
+		return nil, err
+	}
+	if game.courtID != nil {
+		if err := checkCourtAvailable(ctx, r.db, *game.courtID, req.StartTime, req.EndTime, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		INSERT INTO games (host_id, sport_name, title, description, start_time, end_time,
+			location, latitude, longitude, venue_id, court_id, skill_range, capacity, skill_level, visibility)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING game_id
+	`
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	var gameID string
+	err = tx.QueryRow(ctx, query,
+		hostID,
+		req.SportName,
+		game.title,
+		emptyToNil(req.Description),
+		req.StartTime,
+		req.EndTime,
+		game.location,
+		game.latitude,
+		game.longitude,
+		game.venueID,
+		game.courtID,
+		emptyToNil(req.SkillRange),
+		req.Capacity,
+		emptyToNil(req.SkillLevel),
+		req.Visibility,
+	).Scan(&gameID)
+	if err != nil {
+		return nil, translateGameWriteError("create game", err)
+	}
+
+	created, err := getGame(ctx, tx, gameID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := scheduleGameReminders(ctx, tx, gameID, created.StartTime, r.reminderOffsets); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit game", Err: err}
+	}
+	return created, nil
+}
+
+// Get returns a game with its current player count
+func (r *GameRepository) Get(ctx context.Context, gameID string) (*models.Game, error) {
+	return getGame(ctx, r.db, gameID, false)
+}
+
+// Update applies a partial update on behalf of callerID, who must be the
+// host. Start and end times are validated together with the stored values,
+// and capacity may not drop below the current number of players. Raising the
+// capacity promotes waitlisted players into the new spots.
+func (r *GameRepository) Update(ctx context.Context, gameID, callerID string, req models.UpdateGameRequest) (*models.Game, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the game so joins cannot slip in while capacity is being checked
+	game, err := getGame(ctx, tx, gameID, true)
+	if err != nil {
+		return nil, err
+	}
+	if game.HostID != callerID {
+		return nil, fmt.Errorf("only the host can edit this game: %w", ErrForbidden)
+	}
+
+	updated, err := r.update(ctx, tx, game, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit game update", Err: err}
+	}
+	return updated, nil
+}
+
+// update applies a partial update to a game locked by the transaction and
+// returns the reloaded game
+func (r *GameRepository) update(ctx context.Context, tx pgx.Tx, game *models.Game, req models.UpdateGameRequest) (*models.Game, error) {
+	if err := requireGameStatus(game, "be edited", models.GameStatusScheduled); err != nil {
+		return nil, err
+	}
+	gameID := game.GameID
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	// The court and time the game will hold, checked for overlaps once known
+	courtID, start, end := game.CourtID, game.StartTime, game.EndTime
+
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return nil, &ValidationError{Field: "title", Message: "must not be empty"}
+		}
+		set("title", title)
+	}
+	if req.Description != nil {
+		set("description", emptyToNil(req.Description))
+	}
+	if req.Location != nil {
+		location := strings.TrimSpace(*req.Location)
+		if location == "" {
+			return nil, &ValidationError{Field: "location", Message: "must not be empty"}
+		}
+		set("location", location)
+	}
+	if req.Latitude != nil || req.Longitude != nil {
+		if err := validateCoordinates(req.Latitude, req.Longitude); err != nil {
+			return nil, err
+		}
+		set("latitude", *req.Latitude)
+		set("longitude", *req.Longitude)
+	}
+	if req.VenueID != nil || req.CourtID != nil {
+		venueID := game.VenueID
+		if req.VenueID != nil {
+			venueID = emptyToNil(req.VenueID)
+			if courtID != nil && (venueID == nil || game.VenueID == nil || *venueID != *game.VenueID) {
+				// A court at the old venue does not come along
+				courtID = nil
+			}
+		}
+		if req.CourtID != nil {
+			if courtID = emptyToNil(req.CourtID); courtID != nil && req.VenueID == nil {
+				// The new court decides the venue
+				venueID = nil
+			}
+		}
+		venue, err := resolveGameVenue(ctx, tx, venueID, courtID, "")
+		if err != nil {
+			return nil, err
+		}
+		if venue != nil {
+			set("venue_id", venue.VenueID)
+			set("court_id", venue.CourtID)
+			courtID = venue.CourtID
+		} else {
+			set("venue_id", nil)
+			set("court_id", nil)
+		}
+	}
+	if req.StartTime != nil || req.EndTime != nil {
+		if req.StartTime != nil {
+			start = *req.StartTime
+			if !start.Equal(game.StartTime) && !start.After(time.Now()) {
+				return nil, &ValidationError{Field: "start_time", Message: "must be in the future"}
+			}
+		}
+		if req.EndTime != nil {
+			end = *req.EndTime
+		}
+		if err := validateTimeRange(start, end); err != nil {
+			return nil, err
+		}
+		set("start_time", start)
+		set("end_time", end)
+	}
+	if req.SkillRange != nil {
+		set("skill_range", emptyToNil(req.SkillRange))
+	}
+	if req.Capacity != nil {
+		if *req.Capacity < game.PlayerCount {
+			return nil, &ValidationError{
+				Field:   "capacity",
+				Message: fmt.Sprintf("cannot be lower than the current player count (%d)", game.PlayerCount),
+			}
+		}
+		set("capacity", *req.Capacity)
+	}
+	if req.SkillLevel != nil {
+		set("skill_level", emptyToNil(req.SkillLevel))
+	}
+	if req.Visibility != nil {
+		set("visibility", *req.Visibility)
+	}
+
+	if len(sets) == 0 {
+		return game, nil
+	}
+	if courtID != nil && (req.CourtID != nil || req.VenueID != nil || req.StartTime != nil || req.EndTime != nil) {
+		if err := checkCourtAvailable(ctx, tx, *courtID, start, end, gameID); err != nil {
+			return nil, err
+		}
+	}
+
+	args = append(args, gameID)
+	query := fmt.Sprintf(`UPDATE games SET %s WHERE game_id = $%d`, strings.Join(sets, ", "), len(args))
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		return nil, translateGameWriteError("update game", err)
+	}
+
+	updated, err := getGame(ctx, tx, gameID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordGameEvent(ctx, tx, gameID, models.GameEventGameUpdated, nil, updated); err != nil {
+		return nil, err
+	}
+	if !updated.StartTime.Equal(game.StartTime) || !updated.EndTime.Equal(game.EndTime) {
+		if err := recordGameEvent(ctx, tx, gameID, models.GameEventGameRescheduled, nil, updated); err != nil {
+			return nil, err
+		}
+		if err := rearmGameReminders(ctx, tx, gameID, updated.StartTime); err != nil {
+			return nil, err
+		}
+		if err := scheduleGameReminders(ctx, tx, gameID, updated.StartTime, r.reminderOffsets); err != nil {
+			return nil, err
+		}
+	}
+	if updated.Capacity > game.Capacity || !updated.StartTime.Equal(game.StartTime) {
+		if updated, err = r.promoteWaitlist(ctx, tx, updated); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// Delete removes a game on behalf of callerID, who must be the host.
+// Its players cascade. Cancelling an upcoming game with players is recorded
+// against the host's reputation.
+func (r *GameRepository) Delete(ctx context.Context, gameID, callerID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := getGame(ctx, tx, gameID, true)
+	if err != nil {
+		return err
+	}
+	if game.HostID != callerID {
+		return fmt.Errorf("only the host can delete this game: %w", ErrForbidden)
+	}
+
+	if err := deleteGame(ctx, tx, game); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return &DatabaseError{Op: "commit game delete", Err: err}
+	}
+	return nil
+}
+
+// deleteGame deletes a game inside a transaction. Deleting an upcoming game
+// with players is a cancellation and costs the host reputation, unless the
+// game was cancelled before. Its players are notified as of a cancellation.
+func deleteGame(ctx context.Context, tx pgx.Tx, game *models.Game) error {
+	now := time.Now()
+	if delta, reason := cancellationReputation(game, now); delta != 0 {
+		if err := applyReputation(ctx, tx, game.HostID, ReputationSourceCancellation, game.GameID, delta, reason); err != nil {
+			return err
+		}
+	}
+	if game.Status == models.GameStatusScheduled && game.StartTime.After(now) {
+		if err := recordDeletedCancellation(ctx, tx, game.GameID, now); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM games WHERE game_id = $1`, game.GameID); err != nil {
+		return &DatabaseError{Op: "delete game", Err: err}
+	}
+	return recordGameEvent(ctx, tx, game.GameID, models.GameEventGameDeleted, nil, nil)
+}
+
+// recordDeletedCancellation records a game_cancelled event for an upcoming
+// game about to be deleted, with its roster, waitlist and time zone, as they
+// are gone by the time the event is notified
+func recordDeletedCancellation(ctx context.Context, tx pgx.Tx, gameID string, now time.Time) error {
+	game, err := notifiedGame(ctx, tx, gameID)
+	if err != nil {
+		return err
+	}
+	recipients, err := notifiedPlayers(ctx, tx, gameID, game.HostID, true)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	cancelled := game.Game
+	cancelled.Status = models.GameStatusCancelled
+	cancelled.CancelledAt = &now
+	snapshot, err := json.Marshal(cancelled)
+	if err != nil {
+		return &DatabaseError{Op: "encode game event", Err: err}
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO game_events (game_id, type, game, recipients, timezone) VALUES ($1, $2, $3, $4, $5)
+	`, gameID, models.GameEventGameCancelled, snapshot, recipients, game.Timezone)
+	if err != nil {
+		return &DatabaseError{Op: "record game event", Err: err}
+	}
+	return nil
+}
+
+// ListPlayers returns the roster of a game with the public part of each
+// player's user, in join order
+func (r *GameRepository) ListPlayers(ctx context.Context, gameID string) ([]models.GamePlayer, error) {
+	return listPlayers(ctx, r.db, gameID)
+}
+
+// CanView reports whether the user may see the game: it is public, or the
+// user hosts it, plays in it, is waitlisted for it or holds a usable invitation
+func (r *GameRepository) CanView(ctx context.Context, gameID, userID string) (bool, error) {
+	var visible bool
+	query := `SELECT EXISTS (SELECT 1 FROM games g WHERE g.game_id = $1 AND ` + gameVisibleTo("$2") + `)`
+	if err := r.db.QueryRow(ctx, query, gameID, userID).Scan(&visible); err != nil {
+		return false, &DatabaseError{Op: "check game visibility", Err: err}
+	}
+	return visible, nil
+}
+
+// IsPlayer reports whether the user is on the game's roster
+func (r *GameRepository) IsPlayer(ctx context.Context, gameID, userID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM game_players WHERE game_id = $1 AND user_id = $2)`, gameID, userID).Scan(&exists)
+	if err != nil {
+		return false, &DatabaseError{Op: "check game player", Err: err}
+	}
+	return exists, nil
+}
+
+// listPlayers loads the roster of a game with the public part of each
+// player's user, in join order
+func listPlayers(ctx context.Context, q querier, gameID string) ([]models.GamePlayer, error) {
+	query := `
+		SELECT gp.user_id, gp.game_id, gp.attendance, gp.joined_at, gp.attendance_marked_at, ` + publicUserColumns("u") + `
+		FROM game_players gp
+		JOIN users u ON u.user_id = gp.user_id
+		WHERE gp.game_id = $1
+		ORDER BY gp.joined_at, gp.user_id
+	`
+	rows, err := q.Query(ctx, query, gameID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list game players", Err: err}
+	}
+	defer rows.Close()
+
+	players := []models.GamePlayer{}
+	for rows.Next() {
+		var p models.GamePlayer
+		var u models.PublicUser
+		dest := append([]interface{}{&p.UserID, &p.GameID, &p.Attendance, &p.JoinedAt, &p.AttendanceMarkedAt}, publicUserFields(&u)...)
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, &DatabaseError{Op: "scan game player", Err: err}
+		}
+		p.User = &u
+		players = append(players, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list game players", Err: err}
+	}
+	return players, nil
+}
+
+// getGame loads a game, optionally locking its row for the rest of the
+// transaction. The lock is taken by a separate statement first: in READ
+// COMMITTED a single SELECT ... FOR UPDATE would compute the player count
+// from the snapshot taken before it waited for the lock, missing players
+// added by the transaction it waited on.
+func getGame(ctx context.Context, q querier, gameID string, forUpdate bool) (*models.Game, error) {
+	if forUpdate {
+		var locked string
+		err := q.QueryRow(ctx, `SELECT game_id FROM games WHERE game_id = $1 FOR UPDATE`, gameID).Scan(&locked)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, &NotFoundError{Resource: "game", ID: gameID}
+			}
+			return nil, &DatabaseError{Op: "lock game", Err: err}
+		}
+	}
+
+	query := `SELECT ` + gameColumns + ` FROM games g WHERE g.game_id = $1`
+	game, err := scanGame(q.QueryRow(ctx, query, gameID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "game", ID: gameID}
+		}
+		return nil, &DatabaseError{Op: "get game", Err: err}
+	}
+	return game, nil
+}
+
+// validateTimeRange mirrors the valid_time_range constraint so callers get a
+// field-level error instead of a constraint violation
+func validateTimeRange(start, end time.Time) error {
+	if !end.After(start) {
+		return &ValidationError{Field: "end_time", Message: "must be after start_time"}
+	}
+	return nil
+}
+
+// preparedGame is a game request validated and placed at its venue
+type preparedGame struct {
+	title, location     string
+	latitude, longitude *float64
+	venueID, courtID    *string
+	// timezone is the venue's time zone, empty when not at a venue
+	timezone string
+}
+
+// prepareGame validates the fields of a new game other than its start being
+// in the future, and resolves its venue. A game at a venue takes the venue's
+// name and coordinates unless it sets its own.
+func prepareGame(ctx context.Context, q querier, req models.CreateGameRequest) (*preparedGame, error) {
+	game := &preparedGame{
+		title:     strings.TrimSpace(req.Title),
+		location:  strings.TrimSpace(req.Location),
+		latitude:  req.Latitude,
+		longitude: req.Longitude,
+	}
+	if game.title == "" {
+		return nil, &ValidationError{Field: "title", Message: "must not be empty"}
+	}
+	if err := validateTimeRange(req.StartTime, req.EndTime); err != nil {
+		return nil, err
+	}
+	if err := validateCoordinates(req.Latitude, req.Longitude); err != nil {
+		return nil, err
+	}
+	if err := requireSport(ctx, q, req.SportName); err != nil {
+		return nil, err
+	}
+
+	venue, err := resolveGameVenue(ctx, q, emptyToNil(req.VenueID), emptyToNil(req.CourtID), game.location)
+	if err != nil {
+		return nil, err
+	}
+	if venue != nil {
+		game.venueID, game.courtID, game.timezone = &venue.VenueID, venue.CourtID, venue.Timezone
+		if game.location == "" {
+			game.location = venue.Name
+		}
+		if game.latitude == nil {
+			game.latitude, game.longitude = venue.Latitude, venue.Longitude
+		}
+	}
+	if game.location == "" {
+		return nil, &ValidationError{Field: "location", Message: "must not be empty unless venue_id or court_id is set"}
+	}
+	return game, nil
+}
+
+// translateGameWriteError maps constraint violations on games to typed errors
+func translateGameWriteError(op string, err error) error {
+	switch pgErrorCode(err) {
+	case pgCheckViolation:
+		if pgConstraintName(err) == "valid_time_range" {
+			return &ValidationError{Field: "end_time", Message: "must be after start_time"}
+		}
+		return &ValidationError{Message: "game violates constraint " + pgConstraintName(err)}
+	case pgForeignKeyViolation:
+		return &ValidationError{Message: "game references an unknown host or sport"}
+	case pgExclusionViolation:
+		// Lost a race with another booking that passed checkCourtAvailable
+		return &ConflictError{Message: "court is already booked for an overlapping time"}
+	}
+	return &DatabaseError{Op: op, Err: err}
+}
+
+// scanGame scans a row selected with gameColumns
+func scanGame(row pgx.Row) (*models.Game, error) {
+	var g models.Game
+	if err := row.Scan(gameFields(&g)...); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// gameFields returns the scan destinations matching gameColumns, so queries
+// selecting a game next to other columns can scan it in one pass
+func gameFields(g *models.Game) []interface{} {
+	return []interface{}{
+		&g.GameID,
+		&g.HostID,
+		&g.SportName,
+		&g.Title,
+		&g.Description,
+		&g.StartTime,
+		&g.EndTime,
+		&g.Location,
+		&g.Latitude,
+		&g.Longitude,
+		&g.VenueID,
+		&g.CourtID,
+		&g.SeriesID,
+		&g.SkillRange,
+		&g.Capacity,
+		&g.SkillLevel,
+		&g.Visibility,
+		&g.Status,
+		&g.StartedAt,
+		&g.CompletedAt,
+		&g.CancelledAt,
+		&g.CancellationReason,
+		&g.CancellationNote,
+		&g.CreatedAt,
+		&g.UpdatedAt,
+		&g.PlayerCount,
+		&g.WaitlistCount,
+	}
+}
@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// JoinResult is the outcome of a join request
+type JoinResult struct {
+	Game *models.Game
+	// Joined is false when the user was already on the roster or was waitlisted
+	Joined bool
+	// Waitlist is set when the game was full and the user is queued instead
+	Waitlist *models.WaitlistEntry
+}
+
+// JoinOptions carries optional join credentials
+type JoinOptions struct {
+	// InviteLinkID is the verified invite link presented by the user
+	InviteLinkID string
+}
+
+// Join adds the user to the game's roster. The game row is locked for the
+// duration of the transaction, so concurrent joins are serialized and the
+// roster can never grow past capacity. When the game is full the user is
+// queued on its waitlist instead, until the promotion cutoff; after that a
+// full game rejects the join. Invite-only games require an invitation or an
+// invite link. Joining twice is a no-op.
+func (r *GameRepository) Join(ctx context.Context, gameID, userID string, opts JoinOptions) (*JoinResult, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := getGame(ctx, tx, gameID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var alreadyJoined bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM game_players WHERE game_id = $1 AND user_id = $2)`, gameID, userID).Scan(&alreadyJoined)
+	if err != nil {
+		return nil, &DatabaseError{Op: "check game player", Err: err}
+	}
+	if alreadyJoined {
+		return &JoinResult{Game: game, Joined: false}, nil
+	}
+
+	// Waitlisted users keep their place while the game is full
+	if game.PlayerCount >= game.Capacity {
+		entry, err := getWaitlistEntry(ctx, tx, gameID, userID)
+		if err == nil {
+			return &JoinResult{Game: game, Waitlist: entry}, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	if err := requireGameStatus(game, "be joined", models.GameStatusScheduled); err != nil {
+		return nil, err
+	}
+	if !game.StartTime.After(time.Now()) {
+		return nil, &ConflictError{Message: "game has already started"}
+	}
+	if err := authorizeJoin(ctx, tx, game, userID, opts.InviteLinkID); err != nil {
+		return nil, err
+	}
+
+	if game.PlayerCount >= game.Capacity {
+		if !r.promotesWaitlist(game) {
+			return nil, &ConflictError{Message: fmt.Sprintf("game is full (%d/%d) and its waitlist has closed", game.PlayerCount, game.Capacity)}
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO game_waitlist (game_id, user_id) VALUES ($1, $2)`, gameID, userID); err != nil {
+			return nil, &DatabaseError{Op: "join waitlist", Err: err}
+		}
+		entry, err := getWaitlistEntry(ctx, tx, gameID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if game, err = getGame(ctx, tx, gameID, false); err != nil {
+			return nil, err
+		}
+		if err := recordGameEvent(ctx, tx, gameID, models.GameEventWaitlistJoined, &userID, game); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, &DatabaseError{Op: "commit join", Err: err}
+		}
+		return &JoinResult{Game: game, Waitlist: entry}, nil
+	}
+
+	// A spot is open, e.g. after the cutoff stopped promotion; a waitlisted
+	// user taking it directly leaves the queue
+	if _, err := tx.Exec(ctx, `DELETE FROM game_waitlist WHERE game_id = $1 AND user_id = $2`, gameID, userID); err != nil {
+		return nil, &DatabaseError{Op: "leave waitlist", Err: err}
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO game_players (user_id, game_id) VALUES ($1, $2)`, userID, gameID); err != nil {
+		return nil, &DatabaseError{Op: "join game", Err: err}
+	}
+
+	game, err = getGame(ctx, tx, gameID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordGameEvent(ctx, tx, gameID, models.GameEventPlayerJoined, &userID, game); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit join", Err: err}
+	}
+	return &JoinResult{Game: game, Joined: true}, nil
+}
+
+// Leave removes the user from the game's roster or waitlist. A spot freed on
+// the roster goes to the first waitlisted player until the promotion cutoff.
+// Leaving a game the user is not on is a no-op; leaving after the game
+// started is rejected.
+func (r *GameRepository) Leave(ctx context.Context, gameID, userID string) (*models.Game, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := getGame(ctx, tx, gameID, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireGameStatus(game, "be left", models.GameStatusScheduled); err != nil {
+		return nil, err
+	}
+	if !game.StartTime.After(time.Now()) {
+		return nil, &ConflictError{Message: "game has already started"}
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM game_players WHERE game_id = $1 AND user_id = $2`, gameID, userID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "leave game", Err: err}
+	}
+	left := tag.RowsAffected() > 0
+	if left {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO game_former_players (game_id, user_id) VALUES ($1, $2)
+			ON CONFLICT (game_id, user_id) DO UPDATE SET left_at = NOW()
+		`, gameID, userID)
+		if err != nil {
+			return nil, &DatabaseError{Op: "record former player", Err: err}
+		}
+	} else {
+		tag, err = tx.Exec(ctx, `DELETE FROM game_waitlist WHERE game_id = $1 AND user_id = $2`, gameID, userID)
+		if err != nil {
+			return nil, &DatabaseError{Op: "leave waitlist", Err: err}
+		}
+		if tag.RowsAffected() == 0 {
+			return game, nil
+		}
+	}
+
+	game, err = getGame(ctx, tx, gameID, false)
+	if err != nil {
+		return nil, err
+	}
+	eventType := models.GameEventWaitlistLeft
+	if left {
+		eventType = models.GameEventPlayerLeft
+	}
+	if err := recordGameEvent(ctx, tx, gameID, eventType, &userID, game); err != nil {
+		return nil, err
+	}
+	if left {
+		if game, err = r.promoteWaitlist(ctx, tx, game); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit leave", Err: err}
+	}
+	return game, nil
+}
+
+// ListWaitlist returns the waitlist of a game with the public part of each
+// user, in queue order
+func (r *GameRepository) ListWaitlist(ctx context.Context, gameID string) ([]models.WaitlistEntry, error) {
+	query := `
+		SELECT gw.game_id, gw.user_id, ROW_NUMBER() OVER (ORDER BY gw.seq), gw.created_at, ` + publicUserColumns("u") + `
+		FROM game_waitlist gw
+		JOIN users u ON u.user_id = gw.user_id
+		WHERE gw.game_id = $1
+		ORDER BY gw.seq
+	`
+	rows, err := r.db.Query(ctx, query, gameID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list waitlist", Err: err}
+	}
+	defer rows.Close()
+
+	entries := []models.WaitlistEntry{}
+	for rows.Next() {
+		var e models.WaitlistEntry
+		var u models.PublicUser
+		dest := append([]interface{}{&e.GameID, &e.UserID, &e.Position, &e.CreatedAt}, publicUserFields(&u)...)
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, &DatabaseError{Op: "scan waitlist entry", Err: err}
+		}
+		e.User = &u
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list waitlist", Err: err}
+	}
+	return entries, nil
+}
+
+// GetWaitlistEntry returns the user's place on the game's waitlist
+func (r *GameRepository) GetWaitlistEntry(ctx context.Context, gameID, userID string) (*models.WaitlistEntry, error) {
+	return getWaitlistEntry(ctx, r.db, gameID, userID)
+}
+
+// promotesWaitlist reports whether the game is still before its promotion cutoff
+func (r *GameRepository) promotesWaitlist(game *models.Game) bool {
+	return time.Now().Add(r.waitlistCutoff).Before(game.StartTime)
+}
+
+// promoteWaitlist moves waitlisted players into the open spots of a locked
+// game, first come first served, records an event for each and returns the
+// reloaded game. Nothing is promoted once the cutoff before start_time has
+// passed.
+func (r *GameRepository) promoteWaitlist(ctx context.Context, tx pgx.Tx, game *models.Game) (*models.Game, error) {
+	open := game.Capacity - game.PlayerCount
+	if open <= 0 || game.WaitlistCount == 0 || !r.promotesWaitlist(game) {
+		return game, nil
+	}
+
+	query := `
+		WITH promoted AS (
+			DELETE FROM game_waitlist
+			WHERE game_id = $1 AND user_id IN (
+				SELECT user_id FROM game_waitlist WHERE game_id = $1 ORDER BY seq LIMIT $2
+			)
+			RETURNING user_id, game_id, seq
+		)
+		INSERT INTO game_players (user_id, game_id)
+		SELECT user_id, game_id FROM promoted ORDER BY seq
+		ON CONFLICT (user_id, game_id) DO NOTHING
+		RETURNING user_id
+	`
+	rows, err := tx.Query(ctx, query, game.GameID, open)
+	if err != nil {
+		return nil, &DatabaseError{Op: "promote waitlist", Err: err}
+	}
+	var promoted []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, &DatabaseError{Op: "scan promoted player", Err: err}
+		}
+		promoted = append(promoted, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "promote waitlist", Err: err}
+	}
+
+	promotedGame, err := getGame(ctx, tx, game.GameID, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range promoted {
+		if err := recordGameEvent(ctx, tx, game.GameID, models.GameEventPlayerPromoted, &userID, promotedGame); err != nil {
+			return nil, err
+		}
+	}
+	return promotedGame, nil
+}
+
+// getWaitlistEntry loads one waitlist entry with its 1-based position
+func getWaitlistEntry(ctx context.Context, q querier, gameID, userID string) (*models.WaitlistEntry, error) {
+	query := `
+		SELECT gw.game_id, gw.user_id,
+			(SELECT COUNT(*) FROM game_waitlist ahead WHERE ahead.game_id = gw.game_id AND ahead.seq <= gw.seq),
+			gw.created_at
+		FROM game_waitlist gw
+		WHERE gw.game_id = $1 AND gw.user_id = $2
+	`
+	var e models.WaitlistEntry
+	err := q.QueryRow(ctx, query, gameID, userID).Scan(&e.GameID, &e.UserID, &e.Position, &e.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "waitlist entry", ID: userID}
+		}
+		return nil, &DatabaseError{Op: "get waitlist entry", Err: err}
+	}
+	return &e, nil
+}
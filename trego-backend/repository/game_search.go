@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// defaultGamePageSize is used when the search does not set a limit
+	defaultGamePageSize = 20
+	// maxGamePageSize caps the page size of a search
+	maxGamePageSize = 100
+)
+
+// gameCursor is the keyset position after the last game of a page. Games are
+// ordered by (start_time, game_id), which is unique and index-friendly, or by
+// (distance, start_time, game_id) in geo searches.
+type gameCursor struct {
+	Distance  *float64  `json:"d,omitempty"`
+	StartTime time.Time `json:"s"`
+	GameID    string    `json:"id"`
+}
+
+// encode returns the opaque string form of the cursor
+func (c gameCursor) encode() string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// decodeGameCursor parses a cursor produced by gameCursor.encode
+func decodeGameCursor(raw string) (*gameCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, &ValidationError{Field: "cursor", Message: "is invalid"}
+	}
+	var c gameCursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.GameID == "" {
+		return nil, &ValidationError{Field: "cursor", Message: "is invalid"}
+	}
+	return &c, nil
+}
+
+// gameVisibleTo returns a condition that keeps invite-only games hidden from
+// users who are not their host, on their roster or waitlist, or holding a
+// usable invitation. viewerParam is the placeholder holding the viewer's user ID.
+func gameVisibleTo(viewerParam string) string {
+	return fmt.Sprintf(`(g.visibility = 'public'
+		OR g.host_id = %[1]s
+		OR EXISTS (SELECT 1 FROM game_players vgp WHERE vgp.game_id = g.game_id AND vgp.user_id = %[1]s)
+		OR EXISTS (SELECT 1 FROM game_waitlist vgw WHERE vgw.game_id = g.game_id AND vgw.user_id = %[1]s)
+		OR EXISTS (SELECT 1 FROM game_invitations vgi WHERE vgi.game_id = g.game_id
+			AND %[2]s AND %[3]s))`, viewerParam, invitationHeldBy("vgi", viewerParam), invitationUsable("vgi"))
+}
+
+// Search returns one page of games matching the filters that the viewer is
+// allowed to see, ordered by start time. Without start_after only upcoming
+// games are returned; cancelled games are only returned when filtering by
+// status. Geo searches only match games with coordinates and order them by
+// distance instead. Pages are chained with the returned keyset cursor.
+func (r *GameRepository) Search(ctx context.Context, viewerID string, filters models.GameFilters) (*models.GamePage, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultGamePageSize
+	}
+	if limit > maxGamePageSize {
+		limit = maxGamePageSize
+	}
+
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions = append(conditions, gameVisibleTo(arg(viewerID)))
+
+	if filters.SportName != nil {
+		conditions = append(conditions, "g.sport_name = "+arg(*filters.SportName))
+	}
+	if filters.Location != nil {
+		conditions = append(conditions, "g.location = "+arg(strings.TrimSpace(*filters.Location)))
+	}
+	if filters.SkillLevel != nil {
+		conditions = append(conditions, "g.skill_level = "+arg(*filters.SkillLevel))
+	}
+	if filters.Visibility != nil {
+		conditions = append(conditions, "g.visibility = "+arg(*filters.Visibility))
+	}
+	if filters.HostID != nil {
+		conditions = append(conditions, "g.host_id = "+arg(*filters.HostID))
+	}
+	if filters.VenueID != nil {
+		conditions = append(conditions, "g.venue_id = "+arg(*filters.VenueID))
+	}
+	if filters.SeriesID != nil {
+		conditions = append(conditions, "g.series_id = "+arg(*filters.SeriesID))
+	}
+	if filters.Status != nil {
+		conditions = append(conditions, "g.status = "+arg(*filters.Status))
+	} else {
+		conditions = append(conditions, "g.status <> "+arg(models.GameStatusCancelled))
+	}
+
+	startAfter := time.Now()
+	if filters.StartAfter != nil {
+		startAfter = *filters.StartAfter
+	}
+	conditions = append(conditions, "g.start_time >= "+arg(startAfter))
+	if filters.StartBefore != nil {
+		conditions = append(conditions, "g.start_time < "+arg(*filters.StartBefore))
+	}
+
+	geo, err := r.geoSearch(ctx, viewerID, filters)
+	if err != nil {
+		return nil, err
+	}
+	columns, order := gameColumns, "g.start_time, g.game_id"
+	var distance string
+	if geo != nil {
+		distance = distanceSQL("g.latitude", "g.longitude", arg(geo.Latitude), arg(geo.Longitude))
+		conditions = append(conditions, "g.latitude IS NOT NULL")
+		if geo.Box != nil {
+			conditions = append(conditions, geo.Box.conditionSQL("g.latitude", "g.longitude", arg))
+		}
+		if geo.RadiusKm != nil {
+			conditions = append(conditions, distance+" <= "+arg(*geo.RadiusKm))
+		}
+		columns, order = gameColumns+", "+distance+" AS distance_km", "distance_km, g.start_time, g.game_id"
+	}
+
+	offset := 0
+	if filters.Cursor != "" {
+		cursor, err := decodeGameCursor(filters.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case (cursor.Distance != nil) != (geo != nil):
+			return nil, &ValidationError{Field: "cursor", Message: "does not belong to this search"}
+		case geo != nil:
+			conditions = append(conditions, fmt.Sprintf("(%s, g.start_time, g.game_id) > (%s, %s, %s)",
+				distance, arg(*cursor.Distance), arg(cursor.StartTime), arg(cursor.GameID)))
+		default:
+			conditions = append(conditions, fmt.Sprintf("(g.start_time, g.game_id) > (%s, %s)", arg(cursor.StartTime), arg(cursor.GameID)))
+		}
+	} else {
+		// Offsets are only honoured on the first page; later pages use the cursor
+		offset = filters.Offset
+	}
+
+	query := `SELECT ` + columns + `
+		FROM games g
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + order + `
+		LIMIT ` + arg(limit+1) + ` OFFSET ` + arg(offset)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, &DatabaseError{Op: "search games", Err: err}
+	}
+	defer rows.Close()
+
+	page := &models.GamePage{Games: []models.Game{}}
+	for rows.Next() {
+		var game models.Game
+		dest := gameFields(&game)
+		if geo != nil {
+			dest = append(dest, &game.DistanceKm)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, &DatabaseError{Op: "scan game", Err: err}
+		}
+		page.Games = append(page.Games, game)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "search games", Err: err}
+	}
+
+	if len(page.Games) > limit {
+		page.Games = page.Games[:limit]
+		last := page.Games[limit-1]
+		next := gameCursor{Distance: last.DistanceKm, StartTime: last.StartTime, GameID: last.GameID}.encode()
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+// geoSearchParams is the resolved geo part of a game search: the point games
+// are sorted by distance from, and optional radius and box limits
+type geoSearchParams struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  *float64
+	Box       *geoBox
+}
+
+// geoSearch resolves the geo filters of a search, or returns nil for a plain
+// search. Without lat/lng, a radius is centered on the viewer's saved
+// coordinates and a box is sorted from its center. A radius also adds the box
+// around it so the coordinate index narrows the candidates.
+func (r *GameRepository) geoSearch(ctx context.Context, viewerID string, filters models.GameFilters) (*geoSearchParams, error) {
+	if (filters.Latitude == nil) != (filters.Longitude == nil) {
+		return nil, &ValidationError{Field: "lat", Message: "lat and lng must be set together"}
+	}
+	boxBounds := []*float64{filters.MinLat, filters.MinLng, filters.MaxLat, filters.MaxLng}
+	boxSet := 0
+	for _, bound := range boxBounds {
+		if bound != nil {
+			boxSet++
+		}
+	}
+	if boxSet != 0 && boxSet != len(boxBounds) {
+		return nil, &ValidationError{Field: "min_lat", Message: "min_lat, min_lng, max_lat and max_lng must be set together"}
+	}
+	if filters.Latitude == nil && filters.RadiusKm == nil && boxSet == 0 {
+		return nil, nil
+	}
+
+	geo := &geoSearchParams{RadiusKm: filters.RadiusKm}
+	if boxSet > 0 {
+		if *filters.MinLat > *filters.MaxLat {
+			return nil, &ValidationError{Field: "min_lat", Message: "must not be greater than max_lat"}
+		}
+		geo.Box = &geoBox{MinLat: *filters.MinLat, MinLng: *filters.MinLng, MaxLat: *filters.MaxLat, MaxLng: *filters.MaxLng}
+	}
+
+	switch {
+	case filters.Latitude != nil:
+		geo.Latitude, geo.Longitude = *filters.Latitude, *filters.Longitude
+	case filters.RadiusKm != nil:
+		var lat, lng *float64
+		err := r.db.QueryRow(ctx, `SELECT latitude, longitude FROM users WHERE user_id = $1`, viewerID).Scan(&lat, &lng)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, &DatabaseError{Op: "get user coordinates", Err: err}
+		}
+		if lat == nil || lng == nil {
+			return nil, &ValidationError{Field: "radius_km", Message: "needs lat and lng, or coordinates on your profile"}
+		}
+		geo.Latitude, geo.Longitude = *lat, *lng
+	default:
+		geo.Latitude, geo.Longitude = geo.Box.center()
+	}
+
+	if geo.RadiusKm != nil && geo.Box == nil {
+		box := boxAround(geo.Latitude, geo.Longitude, *geo.RadiusKm)
+		geo.Box = &box
+	}
+	return geo, nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// earthRadiusKm is the mean radius of the Earth used for distances
+	earthRadiusKm = 6371.0088
+	// kmPerDegreeLatitude is the length of one degree of latitude
+	kmPerDegreeLatitude = math.Pi * earthRadiusKm / 180
+)
+
+// validateCoordinates checks that a latitude and longitude are given together
+func validateCoordinates(latitude, longitude *float64) error {
+	if (latitude == nil) != (longitude == nil) {
+		return &ValidationError{Field: "latitude", Message: "latitude and longitude must be set together"}
+	}
+	return nil
+}
+
+// distanceSQL returns a haversine expression computing the distance in km
+// between the coordinate columns lat/lng and the point in pointLat/pointLng.
+// It is plain SQL, so it needs neither PostGIS nor earthdistance.
+func distanceSQL(lat, lng, pointLat, pointLng string) string {
+	return fmt.Sprintf(`(%[5]f * 2 * ASIN(SQRT(LEAST(1,
+		POWER(SIN(RADIANS(%[1]s - %[3]s) / 2), 2)
+		+ COS(RADIANS(%[3]s)) * COS(RADIANS(%[1]s)) * POWER(SIN(RADIANS(%[2]s - %[4]s) / 2), 2)))))`,
+		lat, lng, pointLat, pointLng, earthRadiusKm)
+}
+
+// geoBox is a latitude/longitude rectangle. MinLng > MaxLng means the box
+// crosses the antimeridian.
+type geoBox struct {
+	MinLat, MinLng, MaxLat, MaxLng float64
+	// AllLongitudes is set when the box spans every longitude, e.g. a radius
+	// around a pole
+	AllLongitudes bool
+}
+
+// boxAround returns the smallest box containing every point within radiusKm
+// of the center, used to narrow radius searches with the coordinate index
+// before the exact distance is computed
+func boxAround(lat, lng, radiusKm float64) geoBox {
+	dLat := radiusKm / kmPerDegreeLatitude
+	box := geoBox{MinLat: math.Max(lat-dLat, -90), MaxLat: math.Min(lat+dLat, 90)}
+	if box.MinLat == -90 || box.MaxLat == 90 {
+		box.AllLongitudes = true
+		return box
+	}
+
+	ratio := math.Sin(radiusKm/earthRadiusKm) / math.Cos(lat*math.Pi/180)
+	if ratio >= 1 {
+		box.AllLongitudes = true
+		return box
+	}
+	dLng := math.Asin(ratio) * 180 / math.Pi
+	box.MinLng, box.MaxLng = normalizeLongitude(lng-dLng), normalizeLongitude(lng+dLng)
+	return box
+}
+
+// center returns the midpoint of the box, used to sort box searches by distance
+func (b geoBox) center() (float64, float64) {
+	lat := (b.MinLat + b.MaxLat) / 2
+	if b.AllLongitudes {
+		return lat, 0
+	}
+	maxLng := b.MaxLng
+	if b.MinLng > maxLng {
+		maxLng += 360
+	}
+	return lat, normalizeLongitude((b.MinLng + maxLng) / 2)
+}
+
+// conditionSQL returns the condition keeping coordinate columns lat/lng
+// inside the box. arg registers a value and returns its placeholder.
+func (b geoBox) conditionSQL(lat, lng string, arg func(interface{}) string) string {
+	condition := fmt.Sprintf("%s BETWEEN %s AND %s", lat, arg(b.MinLat), arg(b.MaxLat))
+	switch {
+	case b.AllLongitudes:
+		return condition + fmt.Sprintf(" AND %s IS NOT NULL", lng)
+	case b.MinLng > b.MaxLng:
+		return condition + fmt.Sprintf(" AND (%[1]s >= %[2]s OR %[1]s <= %[3]s)", lng, arg(b.MinLng), arg(b.MaxLng))
+	}
+	return condition + fmt.Sprintf(" AND %s BETWEEN %s AND %s", lng, arg(b.MinLng), arg(b.MaxLng))
+}
+
+// normalizeLongitude wraps a longitude into [-180, 180]
+func normalizeLongitude(lng float64) float64 {
+	for lng > 180 {
+		lng -= 360
+	}
+	for lng < -180 {
+		lng += 360
+	}
+	return lng
+}
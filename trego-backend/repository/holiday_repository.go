@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// HolidayRepository provides access to the holiday calendar series skip
+type HolidayRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewHolidayRepository creates a new holiday repository
+func NewHolidayRepository(db *pgxpool.Pool) *HolidayRepository {
+	return &HolidayRepository{db: db}
+}
+
+// List returns the holidays between from (default today) and to, by date
+func (r *HolidayRepository) List(ctx context.Context, filters models.HolidayFilters) ([]models.Holiday, error) {
+	from := time.Now().UTC().Format("2006-01-02")
+	if filters.From != nil {
+		date, err := parseHolidayDate("from", *filters.From)
+		if err != nil {
+			return nil, err
+		}
+		from = date
+	}
+	var to *string
+	if filters.To != nil {
+		date, err := parseHolidayDate("to", *filters.To)
+		if err != nil {
+			return nil, err
+		}
+		to = &date
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT TO_CHAR(holiday_date, 'YYYY-MM-DD'), name, created_at
+		FROM holidays
+		WHERE holiday_date >= $1::date AND ($2::date IS NULL OR holiday_date <= $2::date)
+		ORDER BY holiday_date
+	`, from, to)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list holidays", Err: err}
+	}
+	defer rows.Close()
+
+	holidays := []models.Holiday{}
+	for rows.Next() {
+		var h models.Holiday
+		if err := rows.Scan(&h.Date, &h.Name, &h.CreatedAt); err != nil {
+			return nil, &DatabaseError{Op: "scan holiday", Err: err}
+		}
+		holidays = append(holidays, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list holidays", Err: err}
+	}
+	return holidays, nil
+}
+
+// Create adds a holiday. Series only skip it for occurrences not created yet.
+func (r *HolidayRepository) Create(ctx context.Context, req models.CreateHolidayRequest) (*models.Holiday, error) {
+	date, err := parseHolidayDate("date", req.Date)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, &ValidationError{Field: "name", Message: "must not be empty"}
+	}
+
+	h := models.Holiday{Date: date, Name: name}
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO holidays (holiday_date, name) VALUES ($1::date, $2)
+		RETURNING created_at
+	`, date, name).Scan(&h.CreatedAt)
+	if err != nil {
+		if pgErrorCode(err) == pgUniqueViolation {
+			return nil, &ConflictError{Message: fmt.Sprintf("%s is already a holiday", date)}
+		}
+		return nil, &DatabaseError{Op: "create holiday", Err: err}
+	}
+	return &h, nil
+}
+
+// Delete removes a holiday
+func (r *HolidayRepository) Delete(ctx context.Context, date string) error {
+	date, err := parseHolidayDate("date", date)
+	if err != nil {
+		return err
+	}
+	tag, err := r.db.Exec(ctx, `DELETE FROM holidays WHERE holiday_date = $1::date`, date)
+	if err != nil {
+		return &DatabaseError{Op: "delete holiday", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return &NotFoundError{Resource: "holiday", ID: date}
+	}
+	return nil
+}
+
+// holidaysBetween returns the holidays between two YYYY-MM-DD dates as a set
+func holidaysBetween(ctx context.Context, q querier, from, to string) (map[string]bool, error) {
+	rows, err := q.Query(ctx, `
+		SELECT TO_CHAR(holiday_date, 'YYYY-MM-DD') FROM holidays WHERE holiday_date BETWEEN $1::date AND $2::date
+	`, from, to)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list holidays", Err: err}
+	}
+	defer rows.Close()
+
+	holidays := map[string]bool{}
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, &DatabaseError{Op: "scan holiday", Err: err}
+		}
+		holidays[date] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list holidays", Err: err}
+	}
+	return holidays, nil
+}
+
+// parseHolidayDate checks that value is a YYYY-MM-DD date
+func parseHolidayDate(field, value string) (string, error) {
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(value))
+	if err != nil {
+		return "", &ValidationError{Field: field, Message: "must be a date like 2024-12-25"}
+	}
+	return date.Format("2006-01-02"), nil
+}
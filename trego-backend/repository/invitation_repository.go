@@ -0,0 +1,526 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// invitationColumns is the column list matching invitationFields. Pending
+// invitations past their expiry are reported as expired.
+const invitationColumns = `i.invitation_id, i.game_id, i.invited_by, i.invitee_user_id, i.invitee_email, i.invite_link_id,
+	CASE WHEN i.status = 'pending' AND i.expires_at <= NOW() THEN 'expired' ELSE i.status END,
+	i.expires_at, i.created_at, i.responded_at`
+
+// inviteLinkColumns is the column list matching scanInviteLink
+const inviteLinkColumns = `l.link_id, l.game_id, l.created_by, l.max_uses, l.use_count, l.expires_at, l.revoked_at, l.created_at`
+
+// InvitationRepository provides access to game invitations and invite links
+type InvitationRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewInvitationRepository creates a new invitation repository
+func NewInvitationRepository(db *pgxpool.Pool) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+// Create invites a user, by ID or email address, to a game on behalf of
+// callerID, who must be the host. An email belonging to an existing account
+// is resolved to that user; other addresses are matched when they sign up.
+func (r *InvitationRepository) Create(ctx context.Context, gameID, callerID string, req models.CreateInvitationRequest) (*models.Invitation, error) {
+	var userID, email string
+	if req.UserID != nil {
+		userID = strings.TrimSpace(*req.UserID)
+	}
+	if req.Email != nil {
+		email = strings.ToLower(strings.TrimSpace(*req.Email))
+	}
+	switch {
+	case (userID == "") == (email == ""):
+		return nil, &ValidationError{Message: "exactly one of user_id and email is required"}
+	case req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()):
+		return nil, &ValidationError{Field: "expires_at", Message: "must be in the future"}
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := getHostedGame(ctx, tx, gameID, callerID, "only the host can invite players")
+	if err != nil {
+		return nil, err
+	}
+	if err := requireGameStatus(game, "take invitations", models.GameStatusScheduled); err != nil {
+		return nil, err
+	}
+
+	var inviteeUserID, inviteeEmail *string
+	if userID != "" {
+		if err := requireUser(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+		inviteeUserID = &userID
+	} else {
+		var existing string
+		err := tx.QueryRow(ctx, `SELECT user_id FROM users WHERE email = $1`, email).Scan(&existing)
+		switch {
+		case err == nil:
+			inviteeUserID = &existing
+		case errors.Is(err, pgx.ErrNoRows):
+			inviteeEmail = &email
+		default:
+			return nil, &DatabaseError{Op: "find user by email", Err: err}
+		}
+	}
+
+	if inviteeUserID != nil {
+		if *inviteeUserID == game.HostID {
+			return nil, &ValidationError{Field: "user_id", Message: "the host cannot invite themselves"}
+		}
+		var playing bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM game_players WHERE game_id = $1 AND user_id = $2)`, gameID, *inviteeUserID).Scan(&playing)
+		if err != nil {
+			return nil, &DatabaseError{Op: "check game player", Err: err}
+		}
+		if playing {
+			return nil, &ConflictError{Message: "user is already playing in this game"}
+		}
+	}
+
+	// Lapsed invitations must not block a new one
+	if err := expireInvitations(ctx, tx, gameID); err != nil {
+		return nil, err
+	}
+
+	var invitationID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO game_invitations (game_id, invited_by, invitee_user_id, invitee_email, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING invitation_id
+	`, gameID, callerID, inviteeUserID, inviteeEmail, req.ExpiresAt).Scan(&invitationID)
+	if err != nil {
+		if pgErrorCode(err) == pgUniqueViolation {
+			return nil, &ConflictError{Message: "user has already been invited to this game"}
+		}
+		return nil, &DatabaseError{Op: "create invitation", Err: err}
+	}
+
+	invitation, err := getInvitation(ctx, tx, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit invitation", Err: err}
+	}
+	return invitation, nil
+}
+
+// ListByGame returns all invitations of a game, newest first, on behalf of
+// callerID, who must be the host
+func (r *InvitationRepository) ListByGame(ctx context.Context, gameID, callerID string) ([]models.Invitation, error) {
+	if _, err := getHostedGame(ctx, r.db, gameID, callerID, "only the host can list invitations"); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM game_invitations i
+		WHERE i.game_id = $1
+		ORDER BY i.created_at DESC, i.invitation_id
+	`
+	rows, err := r.db.Query(ctx, query, gameID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list invitations", Err: err}
+	}
+	defer rows.Close()
+
+	invitations := []models.Invitation{}
+	for rows.Next() {
+		var inv models.Invitation
+		if err := rows.Scan(invitationFields(&inv)...); err != nil {
+			return nil, &DatabaseError{Op: "scan invitation", Err: err}
+		}
+		invitations = append(invitations, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list invitations", Err: err}
+	}
+	return invitations, nil
+}
+
+// ListForUser returns the pending, unexpired invitations addressed to the
+// user for games that have not started, with each game, soonest first
+func (r *InvitationRepository) ListForUser(ctx context.Context, userID string) ([]models.Invitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `, ` + gameColumns + `
+		FROM game_invitations i
+		JOIN games g ON g.game_id = i.game_id
+		WHERE ` + invitationHeldBy("i", "$1") + `
+			AND i.status = 'pending' AND (i.expires_at IS NULL OR i.expires_at > NOW())
+			AND g.start_time > NOW()
+		ORDER BY g.start_time, i.invitation_id
+	`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list user invitations", Err: err}
+	}
+	defer rows.Close()
+
+	invitations := []models.Invitation{}
+	for rows.Next() {
+		var inv models.Invitation
+		var game models.Game
+		if err := rows.Scan(append(invitationFields(&inv), gameFields(&game)...)...); err != nil {
+			return nil, &DatabaseError{Op: "scan invitation", Err: err}
+		}
+		inv.Game = &game
+		invitations = append(invitations, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list user invitations", Err: err}
+	}
+	return invitations, nil
+}
+
+// GetForInvitee returns an invitation addressed to the user. Invitations
+// addressed to someone else are reported as not found.
+func (r *InvitationRepository) GetForInvitee(ctx context.Context, invitationID, userID string) (*models.Invitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM game_invitations i WHERE i.invitation_id = $1 AND ` + invitationHeldBy("i", "$2")
+	var inv models.Invitation
+	if err := r.db.QueryRow(ctx, query, invitationID, userID).Scan(invitationFields(&inv)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "invitation", ID: invitationID}
+		}
+		return nil, &DatabaseError{Op: "get invitation", Err: err}
+	}
+	return &inv, nil
+}
+
+// Decline marks a pending invitation addressed to the user as declined
+func (r *InvitationRepository) Decline(ctx context.Context, invitationID, userID string) (*models.Invitation, error) {
+	query := `
+		UPDATE game_invitations i SET status = 'declined', responded_at = NOW()
+		WHERE i.invitation_id = $1 AND ` + invitationHeldBy("i", "$2") + `
+			AND i.status = 'pending' AND (i.expires_at IS NULL OR i.expires_at > NOW())
+	`
+	tag, err := r.db.Exec(ctx, query, invitationID, userID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "decline invitation", Err: err}
+	}
+
+	invitation, err := r.GetForInvitee(ctx, invitationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, &ConflictError{Message: fmt.Sprintf("invitation is already %s", invitation.Status)}
+	}
+	return invitation, nil
+}
+
+// Revoke withdraws an open invitation on behalf of callerID, who must be the
+// host. A player who already accepted stays on the roster but cannot rejoin
+// after leaving.
+func (r *InvitationRepository) Revoke(ctx context.Context, gameID, invitationID, callerID string) (*models.Invitation, error) {
+	if _, err := getHostedGame(ctx, r.db, gameID, callerID, "only the host can revoke invitations"); err != nil {
+		return nil, err
+	}
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE game_invitations SET status = 'revoked'
+		WHERE invitation_id = $1 AND game_id = $2 AND status IN ('pending', 'accepted')
+	`, invitationID, gameID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "revoke invitation", Err: err}
+	}
+
+	invitation, err := getInvitation(ctx, r.db, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.GameID != gameID {
+		return nil, &NotFoundError{Resource: "invitation", ID: invitationID}
+	}
+	if tag.RowsAffected() == 0 && invitation.Status != "revoked" {
+		return nil, &ConflictError{Message: fmt.Sprintf("invitation is already %s", invitation.Status)}
+	}
+	return invitation, nil
+}
+
+// CreateLink creates a shareable invite link for a game on behalf of
+// callerID, who must be the host
+func (r *InvitationRepository) CreateLink(ctx context.Context, gameID, callerID string, req models.CreateInviteLinkRequest) (*models.InviteLink, error) {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, &ValidationError{Field: "expires_at", Message: "must be in the future"}
+	}
+	game, err := getHostedGame(ctx, r.db, gameID, callerID, "only the host can create invite links")
+	if err != nil {
+		return nil, err
+	}
+	if err := requireGameStatus(game, "take invitations", models.GameStatusScheduled); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO game_invite_links (game_id, created_by, max_uses, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING link_id, game_id, created_by, max_uses, use_count, expires_at, revoked_at, created_at
+	`
+	link, err := scanInviteLink(r.db.QueryRow(ctx, query, gameID, callerID, req.MaxUses, req.ExpiresAt))
+	if err != nil {
+		return nil, &DatabaseError{Op: "create invite link", Err: err}
+	}
+	return link, nil
+}
+
+// ListLinks returns the invite links of a game, newest first, on behalf of
+// callerID, who must be the host
+func (r *InvitationRepository) ListLinks(ctx context.Context, gameID, callerID string) ([]models.InviteLink, error) {
+	if _, err := getHostedGame(ctx, r.db, gameID, callerID, "only the host can list invite links"); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + inviteLinkColumns + ` FROM game_invite_links l WHERE l.game_id = $1 ORDER BY l.created_at DESC, l.link_id`
+	rows, err := r.db.Query(ctx, query, gameID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list invite links", Err: err}
+	}
+	defer rows.Close()
+
+	links := []models.InviteLink{}
+	for rows.Next() {
+		link, err := scanInviteLink(rows)
+		if err != nil {
+			return nil, &DatabaseError{Op: "scan invite link", Err: err}
+		}
+		links = append(links, *link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list invite links", Err: err}
+	}
+	return links, nil
+}
+
+// RevokeLink disables an invite link on behalf of callerID, who must be the
+// host. Revoking twice is a no-op.
+func (r *InvitationRepository) RevokeLink(ctx context.Context, gameID, linkID, callerID string) (*models.InviteLink, error) {
+	if _, err := getHostedGame(ctx, r.db, gameID, callerID, "only the host can revoke invite links"); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE game_invite_links SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE link_id = $1 AND game_id = $2
+		RETURNING link_id, game_id, created_by, max_uses, use_count, expires_at, revoked_at, created_at
+	`
+	link, err := scanInviteLink(r.db.QueryRow(ctx, query, linkID, gameID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "invite link", ID: linkID}
+		}
+		return nil, &DatabaseError{Op: "revoke invite link", Err: err}
+	}
+	return link, nil
+}
+
+// GetUsableLink returns an invite link that can still be redeemed, with its
+// game. Expired, revoked and used up links are reported as conflicts.
+func (r *InvitationRepository) GetUsableLink(ctx context.Context, linkID string) (*models.InviteLink, *models.Game, error) {
+	link, err := getInviteLink(ctx, r.db, linkID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkInviteLinkUsable(link); err != nil {
+		return nil, nil, err
+	}
+	game, err := getGame(ctx, r.db, link.GameID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return link, game, nil
+}
+
+// invitationHeldBy returns a condition matching invitations addressed to the
+// user, by user ID or by the email of their account. alias names the
+// game_invitations table and userParam is the placeholder holding the user ID.
+func invitationHeldBy(alias, userParam string) string {
+	return fmt.Sprintf(`(%[1]s.invitee_user_id = %[2]s
+		OR %[1]s.invitee_email = (SELECT email FROM users WHERE user_id = %[2]s))`, alias, userParam)
+}
+
+// invitationUsable returns a condition matching invitations that let their
+// invitee join: accepted ones, and pending ones that have not expired
+func invitationUsable(alias string) string {
+	return fmt.Sprintf(`(%[1]s.status = 'accepted'
+		OR (%[1]s.status = 'pending' AND (%[1]s.expires_at IS NULL OR %[1]s.expires_at > NOW())))`, alias)
+}
+
+// authorizeJoin checks that the user may join a locked game. Anyone may join
+// public games. Invite-only games admit their host and users holding a usable
+// invitation; anyone else needs a usable invite link, which is redeemed into
+// an accepted invitation. Pending invitations of the user are accepted.
+func authorizeJoin(ctx context.Context, tx pgx.Tx, game *models.Game, userID, inviteLinkID string) error {
+	if err := expireInvitations(ctx, tx, game.GameID); err != nil {
+		return err
+	}
+
+	accept := `
+		UPDATE game_invitations i SET status = 'accepted', responded_at = NOW()
+		WHERE i.game_id = $1 AND ` + invitationHeldBy("i", "$2") + ` AND i.status = 'pending'
+	`
+	if _, err := tx.Exec(ctx, accept, game.GameID, userID); err != nil {
+		return &DatabaseError{Op: "accept invitation", Err: err}
+	}
+
+	if game.Visibility != "invite-only" || game.HostID == userID {
+		return nil
+	}
+
+	var invited bool
+	query := `SELECT EXISTS (SELECT 1 FROM game_invitations i WHERE i.game_id = $1 AND ` + invitationHeldBy("i", "$2") + ` AND i.status = 'accepted')`
+	if err := tx.QueryRow(ctx, query, game.GameID, userID).Scan(&invited); err != nil {
+		return &DatabaseError{Op: "check invitation", Err: err}
+	}
+	if invited {
+		return nil
+	}
+
+	if inviteLinkID == "" {
+		return fmt.Errorf("an invitation is required to join this game: %w", ErrForbidden)
+	}
+	link, err := getInviteLink(ctx, tx, inviteLinkID, true)
+	if errors.Is(err, ErrNotFound) || (err == nil && link.GameID != game.GameID) {
+		return fmt.Errorf("invite link is not valid for this game: %w", ErrForbidden)
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkInviteLinkUsable(link); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE game_invite_links SET use_count = use_count + 1 WHERE link_id = $1`, link.LinkID); err != nil {
+		return &DatabaseError{Op: "redeem invite link", Err: err}
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO game_invitations (game_id, invited_by, invitee_user_id, invite_link_id, status, responded_at)
+		VALUES ($1, $2, $3, $4, 'accepted', NOW())
+	`, game.GameID, link.CreatedBy, userID, link.LinkID)
+	if err != nil {
+		return &DatabaseError{Op: "record invite link redemption", Err: err}
+	}
+	return nil
+}
+
+// expireInvitations marks the game's pending invitations past their expiry as expired
+func expireInvitations(ctx context.Context, q querier, gameID string) error {
+	_, err := q.Exec(ctx, `
+		UPDATE game_invitations SET status = 'expired'
+		WHERE game_id = $1 AND status = 'pending' AND expires_at <= NOW()
+	`, gameID)
+	if err != nil {
+		return &DatabaseError{Op: "expire invitations", Err: err}
+	}
+	return nil
+}
+
+// checkInviteLinkUsable returns a ConflictError if the link can no longer be redeemed
+func checkInviteLinkUsable(link *models.InviteLink) error {
+	switch {
+	case link.RevokedAt != nil:
+		return &ConflictError{Message: "invite link has been revoked"}
+	case link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()):
+		return &ConflictError{Message: "invite link has expired"}
+	case link.MaxUses != nil && link.UseCount >= *link.MaxUses:
+		return &ConflictError{Message: "invite link has been used up"}
+	}
+	return nil
+}
+
+// getHostedGame loads a game and returns ErrForbidden with the given reason
+// unless callerID hosts it
+func getHostedGame(ctx context.Context, q querier, gameID, callerID, reason string) (*models.Game, error) {
+	game, err := getGame(ctx, q, gameID, false)
+	if err != nil {
+		return nil, err
+	}
+	if game.HostID != callerID {
+		return nil, fmt.Errorf("%s: %w", reason, ErrForbidden)
+	}
+	return game, nil
+}
+
+// getInvitation loads an invitation by ID
+func getInvitation(ctx context.Context, q querier, invitationID string) (*models.Invitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM game_invitations i WHERE i.invitation_id = $1`
+	var inv models.Invitation
+	if err := q.QueryRow(ctx, query, invitationID).Scan(invitationFields(&inv)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "invitation", ID: invitationID}
+		}
+		return nil, &DatabaseError{Op: "get invitation", Err: err}
+	}
+	return &inv, nil
+}
+
+// getInviteLink loads an invite link, optionally locking it so concurrent
+// redemptions cannot exceed max_uses
+func getInviteLink(ctx context.Context, q querier, linkID string, forUpdate bool) (*models.InviteLink, error) {
+	query := `SELECT ` + inviteLinkColumns + ` FROM game_invite_links l WHERE l.link_id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	link, err := scanInviteLink(q.QueryRow(ctx, query, linkID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "invite link", ID: linkID}
+		}
+		return nil, &DatabaseError{Op: "get invite link", Err: err}
+	}
+	return link, nil
+}
+
+// invitationFields returns the scan destinations matching invitationColumns
+func invitationFields(inv *models.Invitation) []interface{} {
+	return []interface{}{
+		&inv.InvitationID,
+		&inv.GameID,
+		&inv.InvitedBy,
+		&inv.InviteeUserID,
+		&inv.InviteeEmail,
+		&inv.InviteLinkID,
+		&inv.Status,
+		&inv.ExpiresAt,
+		&inv.CreatedAt,
+		&inv.RespondedAt,
+	}
+}
+
+// scanInviteLink scans a row selected with inviteLinkColumns
+func scanInviteLink(row pgx.Row) (*models.InviteLink, error) {
+	var l models.InviteLink
+	err := row.Scan(
+		&l.LinkID,
+		&l.GameID,
+		&l.CreatedBy,
+		&l.MaxUses,
+		&l.UseCount,
+		&l.ExpiresAt,
+		&l.RevokedAt,
+		&l.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
@@ -0,0 +1,358 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// defaultJobPageSize is used when the listing does not set a limit
+	defaultJobPageSize = 50
+	// maxJobPageSize caps the page size of a listing
+	maxJobPageSize = 100
+	// defaultJobMaxAttempts is how many attempts jobs get unless enqueued
+	// with their own limit
+	defaultJobMaxAttempts = 5
+)
+
+// jobColumns are the columns scanned by scanJob
+const jobColumns = `job_id, kind, args, status, unique_key, attempts, max_attempts, scheduled_at, locked_by,
+	locked_until, last_error, errors, completed_at, created_at, updated_at`
+
+// JobRepository provides access to the background job queue
+type JobRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *pgxpool.Pool) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// NewJob describes a job to enqueue
+type NewJob struct {
+	Kind string
+	// Args are the kind's arguments as JSON
+	Args []byte
+	// UniqueKey, if set, makes enqueueing a no-op while a pending or running
+	// job of the kind has the same key
+	UniqueKey string
+	// MaxAttempts defaults to 5
+	MaxAttempts int
+	// ScheduledAt defaults to now
+	ScheduledAt time.Time
+}
+
+// jobCursor is the keyset position after the last job of a page. Jobs are
+// ordered newest first by (created_at, job_id).
+type jobCursor struct {
+	CreatedAt time.Time `json:"c"`
+	JobID     string    `json:"id"`
+}
+
+// encode returns the opaque string form of the cursor
+func (c jobCursor) encode() string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// decodeJobCursor parses a cursor produced by jobCursor.encode
+func decodeJobCursor(raw string) (*jobCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, &ValidationError{Field: "cursor", Message: "is invalid"}
+	}
+	var c jobCursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.JobID == "" {
+		return nil, &ValidationError{Field: "cursor", Message: "is invalid"}
+	}
+	return &c, nil
+}
+
+// Enqueue adds a job to the queue and returns it. If the job has a unique
+// key held by a pending or running job of its kind, that job is returned
+// instead and enqueued is false.
+func (r *JobRepository) Enqueue(ctx context.Context, job NewJob) (*models.Job, bool, error) {
+	return enqueueJob(ctx, r.db, job)
+}
+
+// enqueueJob adds a job to the queue like Enqueue, inside the caller's
+// transaction when q is one, so the job only exists if the work that
+// enqueued it commits
+func enqueueJob(ctx context.Context, q querier, job NewJob) (*models.Job, bool, error) {
+	if job.Kind == "" {
+		return nil, false, &ValidationError{Field: "kind", Message: "is required"}
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = defaultJobMaxAttempts
+	}
+	if job.ScheduledAt.IsZero() {
+		job.ScheduledAt = time.Now()
+	}
+	if len(job.Args) == 0 {
+		job.Args = []byte(`{}`)
+	}
+	uniqueKey := emptyToNil(&job.UniqueKey)
+
+	// A unique job finishing between the insert and the lookup frees its
+	// key, so try again
+	for {
+		enqueued, err := scanJob(q.QueryRow(ctx, `
+			INSERT INTO jobs (kind, args, unique_key, max_attempts, scheduled_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'running') DO NOTHING
+			RETURNING `+jobColumns,
+			job.Kind, job.Args, uniqueKey, job.MaxAttempts, job.ScheduledAt))
+		if err == nil {
+			return enqueued, true, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, &DatabaseError{Op: "enqueue job", Err: err}
+		}
+
+		existing, err := scanJob(q.QueryRow(ctx, `
+			SELECT `+jobColumns+` FROM jobs
+			WHERE kind = $1 AND unique_key = $2 AND status IN ('pending', 'running')
+		`, job.Kind, uniqueKey))
+		if err == nil {
+			return existing, false, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, &DatabaseError{Op: "get unique job", Err: err}
+		}
+	}
+}
+
+// Claim marks up to limit due pending jobs of a kind running for the worker
+// until lease passes, counting an attempt, and returns them oldest first.
+// Jobs are claimed with SKIP LOCKED, so concurrent workers never claim the
+// same job.
+func (r *JobRepository) Claim(ctx context.Context, kind, workerID string, limit int, lease time.Duration) ([]models.Job, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE jobs j SET status = $1, attempts = j.attempts + 1, locked_by = $2,
+			locked_until = NOW() + make_interval(secs => $3::float8)
+		FROM (
+			SELECT job_id FROM jobs
+			WHERE kind = $4 AND status = $5 AND scheduled_at <= NOW()
+			ORDER BY scheduled_at, job_id
+			LIMIT $6
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE j.job_id = due.job_id
+		RETURNING `+prefixColumns("j", jobColumns),
+		models.JobStatusRunning, workerID, lease.Seconds(), kind, models.JobStatusPending, limit)
+	if err != nil {
+		return nil, &DatabaseError{Op: "claim jobs", Err: err}
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, &DatabaseError{Op: "scan job", Err: err}
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "claim jobs", Err: err}
+	}
+	return jobs, nil
+}
+
+// Complete marks a job the worker holds completed. It reports false if the
+// worker lost the job, e.g. because its lease expired and it was rescued.
+func (r *JobRepository) Complete(ctx context.Context, jobID, workerID string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE jobs SET status = $3, completed_at = NOW(), locked_by = NULL, locked_until = NULL
+		WHERE job_id = $1 AND status = $4 AND locked_by = $2
+	`, jobID, workerID, models.JobStatusCompleted, models.JobStatusRunning)
+	if err != nil {
+		return false, &DatabaseError{Op: "complete job", Err: err}
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Fail records a failed attempt of a job the worker holds. The job runs
+// again at retryAt, or is dead-lettered if retryAt is nil. It reports false
+// if the worker lost the job.
+func (r *JobRepository) Fail(ctx context.Context, jobID, workerID, message string, retryAt *time.Time) (bool, error) {
+	status := models.JobStatusDead
+	if retryAt != nil {
+		status = models.JobStatusPending
+	}
+	tag, err := r.db.Exec(ctx, `
+		UPDATE jobs SET status = $3, scheduled_at = COALESCE($4, scheduled_at), last_error = $5,
+			errors = errors || jsonb_build_array(jsonb_build_object('attempt', attempts, 'error', $5::text, 'at', NOW())),
+			locked_by = NULL, locked_until = NULL
+		WHERE job_id = $1 AND status = $6 AND locked_by = $2
+	`, jobID, workerID, status, retryAt, message, models.JobStatusRunning)
+	if err != nil {
+		return false, &DatabaseError{Op: "fail job", Err: err}
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RescueExpired returns running jobs whose lease expired, because their
+// worker died or hung, to the queue, dead-lettering those out of attempts,
+// and returns how many it rescued
+func (r *JobRepository) RescueExpired(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE jobs SET status = CASE WHEN attempts >= max_attempts THEN $1 ELSE $2 END,
+			scheduled_at = NOW(), last_error = $3,
+			errors = errors || jsonb_build_array(jsonb_build_object('attempt', attempts, 'error', $3::text, 'at', NOW())),
+			locked_by = NULL, locked_until = NULL
+		WHERE status = $4 AND locked_until < NOW()
+	`, models.JobStatusDead, models.JobStatusPending, "worker lease expired", models.JobStatusRunning)
+	if err != nil {
+		return 0, &DatabaseError{Op: "rescue expired jobs", Err: err}
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PruneCompleted deletes the jobs completed before the given time and
+// returns how many it deleted
+func (r *JobRepository) PruneCompleted(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM jobs WHERE status = $1 AND completed_at < $2
+	`, models.JobStatusCompleted, before)
+	if err != nil {
+		return 0, &DatabaseError{Op: "prune completed jobs", Err: err}
+	}
+	return tag.RowsAffected(), nil
+}
+
+// List returns one page of jobs matching the filters, newest first. Pages
+// are chained with the returned keyset cursor.
+func (r *JobRepository) List(ctx context.Context, filters models.JobFilters) (*models.JobPage, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultJobPageSize
+	}
+	if limit > maxJobPageSize {
+		limit = maxJobPageSize
+	}
+
+	conditions := `TRUE`
+	args := []interface{}{}
+	if filters.Status != "" {
+		args = append(args, filters.Status)
+		conditions += fmt.Sprintf(` AND status = $%d`, len(args))
+	}
+	if filters.Kind != "" {
+		args = append(args, filters.Kind)
+		conditions += fmt.Sprintf(` AND kind = $%d`, len(args))
+	}
+	if filters.Cursor != "" {
+		cursor, err := decodeJobCursor(filters.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, cursor.CreatedAt, cursor.JobID)
+		conditions += fmt.Sprintf(` AND (created_at, job_id) < ($%d, $%d)`, len(args)-1, len(args))
+	}
+	args = append(args, limit+1)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM jobs
+		WHERE %s
+		ORDER BY created_at DESC, job_id DESC
+		LIMIT $%d
+	`, jobColumns, conditions, len(args))
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list jobs", Err: err}
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, &DatabaseError{Op: "scan job", Err: err}
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list jobs", Err: err}
+	}
+
+	page := &models.JobPage{Jobs: jobs}
+	if len(jobs) > limit {
+		page.Jobs = jobs[:limit]
+		last := page.Jobs[limit-1]
+		next := jobCursor{CreatedAt: last.CreatedAt, JobID: last.JobID}.encode()
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+// Get returns a single job
+func (r *JobRepository) Get(ctx context.Context, jobID string) (*models.Job, error) {
+	job, err := scanJob(r.db.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE job_id = $1`, jobID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "job", ID: jobID}
+		}
+		return nil, &DatabaseError{Op: "get job", Err: err}
+	}
+	return job, nil
+}
+
+// Retry queues a dead job to run now with a fresh set of attempts, or runs a
+// pending job now instead of at its scheduled time. Its error history is
+// kept.
+func (r *JobRepository) Retry(ctx context.Context, jobID string) (*models.Job, error) {
+	job, err := scanJob(r.db.QueryRow(ctx, `
+		UPDATE jobs SET status = $2, scheduled_at = NOW(),
+			attempts = CASE WHEN status = $3 THEN 0 ELSE attempts END
+		WHERE job_id = $1 AND status IN ($2, $3)
+		RETURNING `+jobColumns,
+		jobID, models.JobStatusPending, models.JobStatusDead))
+	if err == nil {
+		return job, nil
+	}
+
+	if pgErrorCode(err) == pgUniqueViolation {
+		return nil, &ConflictError{Message: "a pending or running job already has this job's unique key"}
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, &DatabaseError{Op: "retry job", Err: err}
+	}
+	existing, err := r.Get(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &ConflictError{Message: fmt.Sprintf("%s jobs cannot be retried", existing.Status)}
+}
+
+// prefixColumns qualifies a comma-separated column list with a table alias
+func prefixColumns(alias, columns string) string {
+	var qualified []string
+	for _, column := range strings.Split(columns, ",") {
+		qualified = append(qualified, alias+"."+strings.TrimSpace(column))
+	}
+	return strings.Join(qualified, ", ")
+}
+
+// scanJob scans a row selected with jobColumns
+func scanJob(row pgx.Row) (*models.Job, error) {
+	var j models.Job
+	err := row.Scan(&j.JobID, &j.Kind, &j.Args, &j.Status, &j.UniqueKey, &j.Attempts, &j.MaxAttempts, &j.ScheduledAt,
+		&j.LockedBy, &j.LockedUntil, &j.LastError, &j.Errors, &j.CompletedAt, &j.CreatedAt, &j.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
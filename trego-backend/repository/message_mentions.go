@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"trego-backend/models"
+)
+
+// mentionPattern matches @handle at the start of the body or after a
+// character that cannot be part of a word, so emails are not mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])@([\p{L}\p{N}_.\-]+)`)
+
+// resolveMentions returns the members the body mentions, in order of first
+// mention. A handle matches a member's user ID, their name without spaces
+// or their first name, ignoring case; handles matching several members
+// mention nobody.
+func resolveMentions(body string, members []models.MessageMention) []models.MessageMention {
+	handles := map[string]map[int]bool{}
+	addHandle := func(handle string, member int) {
+		handle = strings.ToLower(handle)
+		if handle == "" {
+			return
+		}
+		if handles[handle] == nil {
+			handles[handle] = map[int]bool{}
+		}
+		handles[handle][member] = true
+	}
+	for i, m := range members {
+		addHandle(m.UserID, i)
+		words := strings.FieldsFunc(m.Name, unicode.IsSpace)
+		if len(words) > 0 {
+			addHandle(strings.Join(words, ""), i)
+			addHandle(words[0], i)
+		}
+	}
+
+	mentions := []models.MessageMention{}
+	seen := map[int]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(match[1])
+		matched := handles[handle]
+		if matched == nil {
+			// Sentence punctuation after a handle is not part of it
+			matched = handles[strings.TrimRight(handle, ".-")]
+		}
+		if len(matched) != 1 {
+			continue
+		}
+		for member := range matched {
+			if !seen[member] {
+				seen[member] = true
+				mentions = append(mentions, members[member])
+			}
+		}
+	}
+	return mentions
+}
@@ -0,0 +1,470 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// defaultMessagePageSize is used when the listing does not set a limit
+	defaultMessagePageSize = 50
+	// maxMessagePageSize caps the page size of a listing
+	maxMessagePageSize = 100
+)
+
+// messageColumns are the columns scanned by scanMessage. It expects
+// game_messages to be aliased as m and the author's users row as a.
+const messageColumns = `m.message_id, m.game_id, m.author_id, a.name, m.body, m.edited_at, m.pinned_at,
+	m.deleted_at, m.deleted_by, m.created_at`
+
+// MessageRepository provides access to the message threads of games
+type MessageRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewMessageRepository creates a new message repository
+func NewMessageRepository(db *pgxpool.Pool) *MessageRepository {
+	return &MessageRepository{db: db}
+}
+
+// messageCursor is the keyset position after the last message of a page.
+// Messages are ordered newest first by (created_at, message_id).
+type messageCursor struct {
+	CreatedAt time.Time `json:"c"`
+	MessageID string    `json:"id"`
+}
+
+// encode returns the opaque string form of the cursor
+func (c messageCursor) encode() string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// decodeMessageCursor parses a cursor produced by messageCursor.encode
+func decodeMessageCursor(raw string) (*messageCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, &ValidationError{Field: "cursor", Message: "is invalid"}
+	}
+	var c messageCursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.MessageID == "" {
+		return nil, &ValidationError{Field: "cursor", Message: "is invalid"}
+	}
+	return &c, nil
+}
+
+// List returns one page of a game's messages, newest first, to userID, who
+// must be the host, on the roster or a player who left. Players who left only
+// see the messages posted before they left. Pages are chained with the
+// returned keyset cursor; pinned messages are not moved to the top but can be
+// listed on their own with filters.Pinned.
+func (r *MessageRepository) List(ctx context.Context, gameID, userID string, filters models.MessageFilters) (*models.MessagePage, error) {
+	leftAt, err := threadReadCutoff(ctx, r.db, gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultMessagePageSize
+	}
+	if limit > maxMessagePageSize {
+		limit = maxMessagePageSize
+	}
+
+	conditions := `m.game_id = $1`
+	args := []interface{}{gameID}
+	if leftAt != nil {
+		args = append(args, *leftAt)
+		conditions += fmt.Sprintf(` AND m.created_at <= $%d`, len(args))
+	}
+	if filters.Pinned {
+		conditions += ` AND m.pinned_at IS NOT NULL AND m.deleted_at IS NULL`
+	}
+	if filters.Cursor != "" {
+		cursor, err := decodeMessageCursor(filters.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, cursor.CreatedAt, cursor.MessageID)
+		conditions += fmt.Sprintf(` AND (m.created_at, m.message_id) < ($%d, $%d)`, len(args)-1, len(args))
+	}
+	args = append(args, limit+1)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM game_messages m
+		LEFT JOIN users a ON a.user_id = m.author_id
+		WHERE %s
+		ORDER BY m.created_at DESC, m.message_id DESC
+		LIMIT $%d
+	`, messageColumns, conditions, len(args))
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list game messages", Err: err}
+	}
+	defer rows.Close()
+
+	messages := []models.GameMessage{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, &DatabaseError{Op: "scan game message", Err: err}
+		}
+		messages = append(messages, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list game messages", Err: err}
+	}
+	rows.Close()
+
+	page := &models.MessagePage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		last := page.Messages[limit-1]
+		next := messageCursor{CreatedAt: last.CreatedAt, MessageID: last.MessageID}.encode()
+		page.NextCursor = &next
+	}
+	if err := loadMentions(ctx, r.db, page.Messages); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Post adds a message by userID, who must be the host or on the roster, to
+// the game's thread
+func (r *MessageRepository) Post(ctx context.Context, gameID, userID string, req models.PostMessageRequest) (*models.GameMessage, error) {
+	body, err := messageBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := threadGame(ctx, tx, gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var messageID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO game_messages (game_id, author_id, body) VALUES ($1, $2, $3) RETURNING message_id
+	`, gameID, userID, body).Scan(&messageID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "post game message", Err: err}
+	}
+	if err := saveMentions(ctx, tx, game, messageID, body); err != nil {
+		return nil, err
+	}
+
+	message, err := getMessage(ctx, tx, gameID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit game message", Err: err}
+	}
+	return message, nil
+}
+
+// Edit replaces the body of a message on behalf of its author, who must
+// still be the host or on the roster. Mentions are resolved again.
+func (r *MessageRepository) Edit(ctx context.Context, gameID, messageID, userID string, req models.PostMessageRequest) (*models.GameMessage, error) {
+	body, err := messageBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := threadGame(ctx, tx, gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+	message, err := getMessage(ctx, tx, gameID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.AuthorID == nil || *message.AuthorID != userID {
+		return nil, fmt.Errorf("only the author can edit this message: %w", ErrForbidden)
+	}
+	if message.DeletedAt != nil {
+		return nil, &ConflictError{Message: "deleted messages cannot be edited"}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE game_messages SET body = $2, edited_at = NOW() WHERE message_id = $1`, messageID, body)
+	if err != nil {
+		return nil, &DatabaseError{Op: "edit game message", Err: err}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM game_message_mentions WHERE message_id = $1`, messageID); err != nil {
+		return nil, &DatabaseError{Op: "clear message mentions", Err: err}
+	}
+	if err := saveMentions(ctx, tx, game, messageID, body); err != nil {
+		return nil, err
+	}
+
+	edited, err := getMessage(ctx, tx, gameID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit game message", Err: err}
+	}
+	return edited, nil
+}
+
+// Delete deletes a message on behalf of its author, or removes it on behalf
+// of the host. The message stays in the thread without its body, and is
+// unpinned. Deleting a deleted message is a no-op.
+func (r *MessageRepository) Delete(ctx context.Context, gameID, messageID, userID string) (*models.GameMessage, error) {
+	game, err := threadGame(ctx, r.db, gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+	message, err := getMessage(ctx, r.db, gameID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	isAuthor := message.AuthorID != nil && *message.AuthorID == userID
+	if !isAuthor && game.HostID != userID {
+		return nil, fmt.Errorf("only the author or the host can delete this message: %w", ErrForbidden)
+	}
+
+	_, err = r.db.Exec(ctx, `
+		UPDATE game_messages SET deleted_at = NOW(), deleted_by = $2, pinned_at = NULL, pinned_by = NULL
+		WHERE message_id = $1 AND deleted_at IS NULL
+	`, messageID, userID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "delete game message", Err: err}
+	}
+	return getMessage(ctx, r.db, gameID, messageID)
+}
+
+// SetPinned pins or unpins a message on behalf of callerID, who must be the
+// host. Deleted messages cannot be pinned.
+func (r *MessageRepository) SetPinned(ctx context.Context, gameID, messageID, callerID string, pinned bool) (*models.GameMessage, error) {
+	game, err := getGame(ctx, r.db, gameID, false)
+	if err != nil {
+		return nil, err
+	}
+	if game.HostID != callerID {
+		return nil, fmt.Errorf("only the host can pin messages: %w", ErrForbidden)
+	}
+	message, err := getMessage(ctx, r.db, gameID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.DeletedAt != nil && pinned {
+		return nil, &ConflictError{Message: "deleted messages cannot be pinned"}
+	}
+
+	query := `UPDATE game_messages SET pinned_at = NOW(), pinned_by = $2 WHERE message_id = $1 AND pinned_at IS NULL`
+	args := []interface{}{messageID, callerID}
+	if !pinned {
+		query = `UPDATE game_messages SET pinned_at = NULL, pinned_by = NULL WHERE message_id = $1`
+		args = args[:1]
+	}
+	if _, err := r.db.Exec(ctx, query, args...); err != nil {
+		return nil, &DatabaseError{Op: "pin game message", Err: err}
+	}
+	return getMessage(ctx, r.db, gameID, messageID)
+}
+
+// threadGame loads a game whose thread userID wants to write to and checks
+// that they are its host or on its roster. Players who left lose access.
+func threadGame(ctx context.Context, q querier, gameID, userID string) (*models.Game, error) {
+	game, err := getGame(ctx, q, gameID, false)
+	if err != nil {
+		return nil, err
+	}
+	if game.HostID == userID {
+		return game, nil
+	}
+	var member bool
+	err = q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM game_players WHERE game_id = $1 AND user_id = $2)`, gameID, userID).Scan(&member)
+	if err != nil {
+		return nil, &DatabaseError{Op: "check game player", Err: err}
+	}
+	if !member {
+		return nil, fmt.Errorf("only the host and players can use this game's messages: %w", ErrForbidden)
+	}
+	return game, nil
+}
+
+// threadReadCutoff checks that userID can read a game's thread, as its host,
+// on its roster or as a player who left, and returns when they left. Players
+// who left lose access to the messages posted after that; it is nil for the
+// host and the roster.
+func threadReadCutoff(ctx context.Context, q querier, gameID, userID string) (*time.Time, error) {
+	game, err := getGame(ctx, q, gameID, false)
+	if err != nil {
+		return nil, err
+	}
+	if game.HostID == userID {
+		return nil, nil
+	}
+	var member bool
+	var leftAt *time.Time
+	err = q.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM game_players WHERE game_id = $1 AND user_id = $2),
+			(SELECT left_at FROM game_former_players WHERE game_id = $1 AND user_id = $2)
+	`, gameID, userID).Scan(&member, &leftAt)
+	if err != nil {
+		return nil, &DatabaseError{Op: "check game player", Err: err}
+	}
+	if member {
+		return nil, nil
+	}
+	if leftAt == nil {
+		return nil, fmt.Errorf("only the host and players can use this game's messages: %w", ErrForbidden)
+	}
+	return leftAt, nil
+}
+
+// messageBody trims a message body and rejects blank ones
+func messageBody(body string) (string, error) {
+	trimmed := emptyToNil(&body)
+	if trimmed == nil {
+		return "", &ValidationError{Field: "body", Message: "must not be blank"}
+	}
+	return *trimmed, nil
+}
+
+// getMessage loads a message of a game with its mentions
+func getMessage(ctx context.Context, q querier, gameID, messageID string) (*models.GameMessage, error) {
+	query := `SELECT ` + messageColumns + `
+		FROM game_messages m
+		LEFT JOIN users a ON a.user_id = m.author_id
+		WHERE m.message_id = $1 AND m.game_id = $2`
+	message, err := scanMessage(q.QueryRow(ctx, query, messageID, gameID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "message", ID: messageID}
+		}
+		return nil, &DatabaseError{Op: "get game message", Err: err}
+	}
+	messages := []models.GameMessage{*message}
+	if err := loadMentions(ctx, q, messages); err != nil {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+// scanMessage scans a row of messageColumns, hiding the body of deleted
+// messages
+func scanMessage(row pgx.Row) (*models.GameMessage, error) {
+	var m models.GameMessage
+	var deletedBy *string
+	err := row.Scan(&m.MessageID, &m.GameID, &m.AuthorID, &m.AuthorName, &m.Body, &m.EditedAt, &m.PinnedAt,
+		&m.DeletedAt, &deletedBy, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if m.DeletedAt != nil {
+		m.Body = nil
+		m.Removed = deletedBy != nil && (m.AuthorID == nil || *deletedBy != *m.AuthorID)
+	}
+	m.Mentions = []models.MessageMention{}
+	return &m, nil
+}
+
+// loadMentions fills in the mentions of the messages. Deleted messages
+// mention nobody.
+func loadMentions(ctx context.Context, q querier, messages []models.GameMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	index := make(map[string]int, len(messages))
+	ids := make([]string, 0, len(messages))
+	for i, m := range messages {
+		if m.DeletedAt == nil {
+			index[m.MessageID] = i
+			ids = append(ids, m.MessageID)
+		}
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT mm.message_id, u.user_id, u.name
+		FROM game_message_mentions mm
+		JOIN users u ON u.user_id = mm.user_id
+		WHERE mm.message_id = ANY($1)
+		ORDER BY mm.message_id, u.name, u.user_id
+	`, ids)
+	if err != nil {
+		return &DatabaseError{Op: "list message mentions", Err: err}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID string
+		var mention models.MessageMention
+		if err := rows.Scan(&messageID, &mention.UserID, &mention.Name); err != nil {
+			return &DatabaseError{Op: "scan message mention", Err: err}
+		}
+		m := &messages[index[messageID]]
+		m.Mentions = append(m.Mentions, mention)
+	}
+	if err := rows.Err(); err != nil {
+		return &DatabaseError{Op: "list message mentions", Err: err}
+	}
+	return nil
+}
+
+// saveMentions resolves the mentions in a message body against the game's
+// host and roster and stores them
+func saveMentions(ctx context.Context, tx pgx.Tx, game *models.Game, messageID, body string) error {
+	rows, err := tx.Query(ctx, `
+		SELECT u.user_id, u.name FROM users u
+		WHERE u.user_id = $2
+			OR u.user_id IN (SELECT user_id FROM game_players WHERE game_id = $1)
+	`, game.GameID, game.HostID)
+	if err != nil {
+		return &DatabaseError{Op: "list thread members", Err: err}
+	}
+	defer rows.Close()
+
+	members := []models.MessageMention{}
+	for rows.Next() {
+		var m models.MessageMention
+		if err := rows.Scan(&m.UserID, &m.Name); err != nil {
+			return &DatabaseError{Op: "scan thread member", Err: err}
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return &DatabaseError{Op: "list thread members", Err: err}
+	}
+	rows.Close()
+
+	mentions := resolveMentions(body, members)
+	if len(mentions) == 0 {
+		return nil
+	}
+	userIDs := make([]string, len(mentions))
+	for i, m := range mentions {
+		userIDs[i] = m.UserID
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO game_message_mentions (message_id, user_id) SELECT $1, UNNEST($2::text[])
+	`, messageID, userIDs)
+	if err != nil {
+		return &DatabaseError{Op: "save message mentions", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,525 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// maxDeliveryAttempts is how many failed attempts give up on a delivery
+	maxDeliveryAttempts = 5
+	// deliveryRetryDelay is the wait after the first failed attempt; it
+	// doubles with each further one
+	deliveryRetryDelay = time.Minute
+	// deliveryLease is how long a claimed delivery is reserved for the job
+	// sending it, longer than a delivery job may run
+	deliveryLease = 5 * time.Minute
+)
+
+// Delivery statuses
+const (
+	deliveryPending = "pending"
+	deliverySent    = "sent"
+	deliverySkipped = "skipped"
+	deliveryFailed  = "failed"
+)
+
+// GameEventNotice is a game event that has not been turned into
+// notifications yet, with what they are made from
+type GameEventNotice struct {
+	Event models.GameEvent
+	// Game is the game as of the event, with the time zone it is played in
+	Game models.CalendarGame
+	// ActorName is the name of the player a roster event is about
+	ActorName string
+	// Recipients are the users notified of the event
+	Recipients []string
+}
+
+// NotificationContent is the title and body of the notifications made for an
+// event
+type NotificationContent struct {
+	Title string
+	Body  string
+}
+
+// NotificationDeliveryJob is the queue job sending a notification on a
+// channel. One is enqueued with each delivery and again for each retry.
+type NotificationDeliveryJob struct {
+	NotificationID string `json:"notification_id"`
+	Channel        string `json:"channel"`
+}
+
+// Kind names the notification delivery job
+func (NotificationDeliveryJob) Kind() string { return "notification_delivery" }
+
+// PendingDelivery is a notification due to be sent on a channel
+type PendingDelivery struct {
+	Notification models.Notification
+	Channel      string
+	// Attempts is how many attempts failed before
+	Attempts    int
+	UserID      string
+	Name        string
+	Email       string
+	Preferences models.NotificationPreferences
+	// PushEndpoints are the user's browsers that earlier attempts did not
+	// reach, for push deliveries
+	PushEndpoints []PushEndpoint
+	// pushed are the endpoints earlier attempts reached
+	pushed []string
+	// lease is when the claim on the delivery runs out
+	lease time.Time
+}
+
+// PushEndpoint is a browser registered for web push with the keys its
+// messages are encrypted for
+type PushEndpoint struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+// DeliveryOutcome is the result of sending a pending delivery. The zero value
+// means it was sent.
+type DeliveryOutcome struct {
+	// Skipped is set when the user does not get the notification on the
+	// channel, e.g. because they turned it off; Err may say why
+	Skipped bool
+	// DeferUntil postpones the delivery without counting an attempt, e.g.
+	// until the user's quiet hours end
+	DeferUntil *time.Time
+	// Err is a failed attempt, retried with backoff until
+	// maxDeliveryAttempts
+	Err error
+	// Pushed are the push endpoints a failed attempt reached; retries do
+	// not push to them again
+	Pushed []string
+}
+
+// HandleGameEvents turns up to limit game events not handled yet into
+// notifications in the inboxes of the users concerned, queued for delivery
+// on the given channels. It returns how many events it handled and how many
+// notifications it made:
+//   - player_joined and player_left notify the host, unless the host is the
+//     player
+//   - player_promoted notifies the promoted player
+//   - game_cancelled and game_rescheduled notify the roster and waitlist,
+//     or the recipients recorded with the event if the game was deleted
+//
+// Other events are marked handled without notifying anyone. compose returns
+// the content of an event's notifications. Events are claimed with SKIP
+// LOCKED, so instances running this concurrently handle each event once.
+func (r *NotificationRepository) HandleGameEvents(ctx context.Context, limit int, channels []string, compose func(GameEventNotice) (NotificationContent, error)) (int, int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, 0, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT event_id, game_id, type, user_id, game, created_at, recipients, timezone
+		FROM game_events
+		WHERE notified_at IS NULL
+		ORDER BY event_id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return 0, 0, &DatabaseError{Op: "claim game events", Err: err}
+	}
+	defer rows.Close()
+
+	events := []models.GameEvent{}
+	for rows.Next() {
+		var e models.GameEvent
+		if err := rows.Scan(&e.EventID, &e.GameID, &e.Type, &e.UserID, &e.Game, &e.CreatedAt, &e.Recipients, &e.Timezone); err != nil {
+			return 0, 0, &DatabaseError{Op: "scan game event", Err: err}
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, &DatabaseError{Op: "claim game events", Err: err}
+	}
+	rows.Close()
+	if len(events) == 0 {
+		return 0, 0, nil
+	}
+
+	var made int64
+	eventIDs := make([]int64, 0, len(events))
+	for _, event := range events {
+		eventIDs = append(eventIDs, event.EventID)
+
+		notice, err := gameEventNotice(ctx, tx, event)
+		if err != nil {
+			return 0, 0, err
+		}
+		if notice == nil || len(notice.Recipients) == 0 {
+			continue
+		}
+		content, err := compose(*notice)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		count, err := createNotifications(ctx, tx, notice.Recipients, event.Type, event.GameID, content, &event.EventID, channels)
+		if err != nil {
+			return 0, 0, err
+		}
+		made += count
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE game_events SET notified_at = NOW() WHERE event_id = ANY($1)
+	`, eventIDs); err != nil {
+		return 0, 0, &DatabaseError{Op: "mark game events notified", Err: err}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, &DatabaseError{Op: "commit transaction", Err: err}
+	}
+	return len(events), made, nil
+}
+
+// gameEventNotice gathers who to notify of an event and what about, or
+// returns nil for events nobody is notified of
+func gameEventNotice(ctx context.Context, q querier, event models.GameEvent) (*GameEventNotice, error) {
+	switch event.Type {
+	case models.GameEventPlayerJoined, models.GameEventPlayerLeft, models.GameEventPlayerPromoted,
+		models.GameEventGameCancelled, models.GameEventGameRescheduled:
+	default:
+		return nil, nil
+	}
+
+	if event.Recipients != nil {
+		return recordedNotice(event)
+	}
+	game, err := notifiedGame(ctx, q, event.GameID)
+	if err != nil {
+		// Deleted games have nobody left to notify
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	notice := GameEventNotice{Event: event, Game: *game}
+	// Each notification describes the game as the event left it, e.g. the
+	// start time it was moved to then
+	if len(event.Game) > 0 {
+		if err := json.Unmarshal(event.Game, &notice.Game.Game); err != nil {
+			return nil, &DatabaseError{Op: "decode game event", Err: err}
+		}
+	}
+
+	if event.UserID != nil {
+		err := q.QueryRow(ctx, `SELECT name FROM users WHERE user_id = $1`, *event.UserID).Scan(&notice.ActorName)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, &DatabaseError{Op: "get notified player", Err: err}
+		}
+	}
+
+	switch event.Type {
+	case models.GameEventPlayerJoined, models.GameEventPlayerLeft:
+		if event.UserID != nil && *event.UserID != notice.Game.HostID {
+			notice.Recipients = []string{notice.Game.HostID}
+		}
+	case models.GameEventPlayerPromoted:
+		if event.UserID != nil {
+			notice.Recipients = []string{*event.UserID}
+		}
+	default:
+		if notice.Recipients, err = notifiedPlayers(ctx, q, event.GameID, notice.Game.HostID, true); err != nil {
+			return nil, err
+		}
+	}
+	return &notice, nil
+}
+
+// recordedNotice builds the notice of an event that carries its recipients
+// and the game as of the event, as recorded for games that were deleted
+func recordedNotice(event models.GameEvent) (*GameEventNotice, error) {
+	notice := GameEventNotice{Event: event, Recipients: event.Recipients}
+	if err := json.Unmarshal(event.Game, &notice.Game.Game); err != nil {
+		return nil, &DatabaseError{Op: "decode game event", Err: err}
+	}
+	notice.Game.Timezone = "UTC"
+	if event.Timezone != nil {
+		notice.Game.Timezone = *event.Timezone
+	}
+	return &notice, nil
+}
+
+// notifiedGame loads a game with the time zone it is played in
+func notifiedGame(ctx context.Context, q querier, gameID string) (*models.CalendarGame, error) {
+	var g models.CalendarGame
+	err := q.QueryRow(ctx, `
+		SELECT `+gameColumns+`, `+calendarTimezone+`
+		FROM games g
+		`+calendarJoins+`
+		WHERE g.game_id = $1
+	`, gameID).Scan(append(gameFields(&g.Game), &g.Timezone)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "game", ID: gameID}
+		}
+		return nil, &DatabaseError{Op: "get notified game", Err: err}
+	}
+	return &g, nil
+}
+
+// notifiedPlayers lists the players on a game's roster, and its waitlist if
+// asked, except the excluded user
+func notifiedPlayers(ctx context.Context, q querier, gameID, excludedID string, waitlist bool) ([]string, error) {
+	query := `SELECT user_id FROM game_players WHERE game_id = $1 AND user_id <> $2`
+	if waitlist {
+		query += ` UNION SELECT user_id FROM game_waitlist WHERE game_id = $1 AND user_id <> $2`
+	}
+	rows, err := q.Query(ctx, query, gameID, excludedID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list notified players", Err: err}
+	}
+	defer rows.Close()
+
+	userIDs := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, &DatabaseError{Op: "scan notified player", Err: err}
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list notified players", Err: err}
+	}
+	return userIDs, nil
+}
+
+// createNotifications puts a notification in the inbox of every recipient
+// and queues it for delivery on the channels, enqueueing a delivery job for
+// each, and returns how many it made. Notifications made for a game event
+// are made once per recipient.
+func createNotifications(ctx context.Context, q querier, recipients []string, notificationType, gameID string, content NotificationContent, eventID *int64, channels []string) (int64, error) {
+	var made int64
+	err := q.QueryRow(ctx, `
+		WITH made AS (
+			INSERT INTO notifications (user_id, type, game_id, title, body, event_id)
+			SELECT recipient, $2, $3, $4, $5, $6 FROM UNNEST($1::text[]) AS recipient
+			ON CONFLICT (event_id, user_id) DO NOTHING
+			RETURNING notification_id
+		), queued AS (
+			INSERT INTO notification_deliveries (notification_id, channel)
+			SELECT notification_id, channel FROM made, UNNEST($7::text[]) AS channel
+			RETURNING notification_id, channel
+		), enqueued AS (
+			INSERT INTO jobs (kind, args)
+			SELECT $8, jsonb_build_object('notification_id', notification_id, 'channel', channel) FROM queued
+		)
+		SELECT COUNT(*) FROM made
+	`, recipients, notificationType, gameID, content.Title, content.Body, eventID, channels, NotificationDeliveryJob{}.Kind()).Scan(&made)
+	if err != nil {
+		return 0, &DatabaseError{Op: "create notifications", Err: err}
+	}
+	return made, nil
+}
+
+// DeliverNotification sends the delivery of a delivery job with send and
+// records the outcome. The delivery is claimed with a lease and the claim is
+// committed before sending, so no transaction stays open while it is sent
+// and it is never sent twice at once. A delivery that stays pending,
+// deferred or to be retried, gets a job for its next attempt along with the
+// outcome. A delivery that is not pending or not due is left alone; one
+// leased by another job gets a job for when the lease runs out.
+func (r *NotificationRepository) DeliverNotification(ctx context.Context, job NotificationDeliveryJob, send func(PendingDelivery) DeliveryOutcome) error {
+	d, err := r.claimDelivery(ctx, job)
+	if err != nil || d == nil {
+		return err
+	}
+
+	var outcome DeliveryOutcome
+	// A push retried after every browser it missed unsubscribed has reached
+	// all the browsers left
+	if d.Channel != models.NotificationChannelPush || len(d.pushed) == 0 || len(d.PushEndpoints) > 0 {
+		outcome = send(*d)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	next, err := recordDeliveryOutcome(ctx, tx, *d, outcome)
+	if err != nil {
+		return err
+	}
+	if next != nil {
+		if err := enqueueDeliveryJob(ctx, tx, job, *next); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return &DatabaseError{Op: "commit transaction", Err: err}
+	}
+	return nil
+}
+
+// claimDelivery leases the delivery of a delivery job for deliveryLease and
+// loads it, or returns nil if it is not due or leased by another job
+func (r *NotificationRepository) claimDelivery(ctx context.Context, job NotificationDeliveryJob) (*PendingDelivery, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	var d PendingDelivery
+	var leasedUntil *time.Time
+	n, p := &d.Notification, &d.Preferences
+	err = tx.QueryRow(ctx, `
+		SELECT d.channel, d.attempts, n.notification_id, n.type, n.game_id, n.title, n.body, n.read_at, n.created_at,
+			u.user_id, u.name, u.email, COALESCE(p.email_enabled, true), COALESCE(p.push_enabled, true),
+			p.quiet_hours_start, p.quiet_hours_end, COALESCE(p.timezone, 'UTC'), d.pushed_endpoints,
+			CASE WHEN d.locked_until > NOW() THEN d.locked_until END
+		FROM notification_deliveries d
+		JOIN notifications n ON n.notification_id = d.notification_id
+		JOIN users u ON u.user_id = n.user_id
+		LEFT JOIN notification_preferences p ON p.user_id = n.user_id
+		WHERE d.notification_id = $1 AND d.channel = $2 AND d.status = $3 AND d.next_attempt_at <= NOW()
+		FOR UPDATE OF d SKIP LOCKED
+	`, job.NotificationID, job.Channel, deliveryPending).Scan(&d.Channel, &d.Attempts, &n.NotificationID, &n.Type, &n.GameID, &n.Title, &n.Body, &n.ReadAt, &n.CreatedAt,
+		&d.UserID, &d.Name, &d.Email, &p.EmailEnabled, &p.PushEnabled, &p.QuietHoursStart, &p.QuietHoursEnd, &p.Timezone, &d.pushed, &leasedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &DatabaseError{Op: "claim notification delivery", Err: err}
+	}
+
+	// The job holding the lease may never record an outcome, e.g. when its
+	// instance stopped, so look again once the lease runs out
+	if leasedUntil != nil {
+		if err := enqueueDeliveryJob(ctx, tx, job, *leasedUntil); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, &DatabaseError{Op: "commit transaction", Err: err}
+		}
+		return nil, nil
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE notification_deliveries SET locked_until = NOW() + make_interval(secs => $3::float8)
+		WHERE notification_id = $1 AND channel = $2
+		RETURNING locked_until
+	`, job.NotificationID, job.Channel, deliveryLease.Seconds()).Scan(&d.lease)
+	if err != nil {
+		return nil, &DatabaseError{Op: "lease notification delivery", Err: err}
+	}
+	if d.Channel == models.NotificationChannelPush {
+		if d.PushEndpoints, err = pushEndpoints(ctx, tx, d.UserID, d.pushed); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit transaction", Err: err}
+	}
+	return &d, nil
+}
+
+// enqueueDeliveryJob queues a delivery job again, for at
+func enqueueDeliveryJob(ctx context.Context, q querier, job NotificationDeliveryJob, at time.Time) error {
+	args, err := json.Marshal(job)
+	if err != nil {
+		return &DatabaseError{Op: "encode notification delivery job", Err: err}
+	}
+	_, _, err = enqueueJob(ctx, q, NewJob{Kind: job.Kind(), Args: args, ScheduledAt: at})
+	return err
+}
+
+// recordDeliveryOutcome updates a claimed delivery with the outcome of
+// sending it and releases its lease, and returns when its next attempt is
+// due if it stays pending. The outcome is dropped if the lease ran out and
+// another job claimed the delivery since.
+func recordDeliveryOutcome(ctx context.Context, q querier, d PendingDelivery, outcome DeliveryOutcome) (*time.Time, error) {
+	var lastError *string
+	if outcome.Err != nil {
+		message := outcome.Err.Error()
+		lastError = &message
+	}
+
+	var next *time.Time
+	var tag pgconn.CommandTag
+	var err error
+	switch {
+	case outcome.DeferUntil != nil:
+		next = outcome.DeferUntil
+		tag, err = q.Exec(ctx, `
+			UPDATE notification_deliveries SET next_attempt_at = $4, locked_until = NULL
+			WHERE notification_id = $1 AND channel = $2 AND locked_until = $3
+		`, d.Notification.NotificationID, d.Channel, d.lease, *outcome.DeferUntil)
+	case outcome.Skipped:
+		tag, err = q.Exec(ctx, `
+			UPDATE notification_deliveries SET status = $4, last_error = $5, locked_until = NULL
+			WHERE notification_id = $1 AND channel = $2 AND locked_until = $3
+		`, d.Notification.NotificationID, d.Channel, d.lease, deliverySkipped, lastError)
+	case outcome.Err != nil:
+		status, retryAt := deliveryPending, time.Now().Add(deliveryRetryDelay<<d.Attempts)
+		if d.Attempts+1 >= maxDeliveryAttempts {
+			status = deliveryFailed
+		} else {
+			next = &retryAt
+		}
+		tag, err = q.Exec(ctx, `
+			UPDATE notification_deliveries SET status = $4, attempts = attempts + 1, next_attempt_at = $5, last_error = $6,
+				pushed_endpoints = pushed_endpoints || $7::text[], locked_until = NULL
+			WHERE notification_id = $1 AND channel = $2 AND locked_until = $3
+		`, d.Notification.NotificationID, d.Channel, d.lease, status, retryAt, lastError, outcome.Pushed)
+	default:
+		tag, err = q.Exec(ctx, `
+			UPDATE notification_deliveries SET status = $4, attempts = attempts + 1, sent_at = NOW(), last_error = NULL,
+				locked_until = NULL
+			WHERE notification_id = $1 AND channel = $2 AND locked_until = $3
+		`, d.Notification.NotificationID, d.Channel, d.lease, deliverySent)
+	}
+	if err != nil {
+		return nil, &DatabaseError{Op: "record notification delivery", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, nil
+	}
+	return next, nil
+}
+
+// pushEndpoints lists the browsers a user registered for web push, except
+// the excluded endpoints
+func pushEndpoints(ctx context.Context, q querier, userID string, excluded []string) ([]PushEndpoint, error) {
+	rows, err := q.Query(ctx, `
+		SELECT endpoint, p256dh, auth FROM push_subscriptions
+		WHERE user_id = $1 AND endpoint <> ALL(COALESCE($2::text[], '{}'))
+		ORDER BY created_at
+	`, userID, excluded)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list push subscriptions", Err: err}
+	}
+	defer rows.Close()
+
+	endpoints := []PushEndpoint{}
+	for rows.Next() {
+		var e PushEndpoint
+		if err := rows.Scan(&e.Endpoint, &e.P256dh, &e.Auth); err != nil {
+			return nil, &DatabaseError{Op: "scan push subscription", Err: err}
+		}
+		endpoints = append(endpoints, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list push subscriptions", Err: err}
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"trego-backend/models"
+)
+
+// GameReminderNotice is a reminder due for a game, with what its
+// notifications are made from
+type GameReminderNotice struct {
+	Game models.CalendarGame
+	// Offset is how long before start_time the reminder was due
+	Offset time.Duration
+	// Recipients are the players on the roster
+	Recipients []string
+}
+
+// GameReminderJob is the queue job reminding the roster of a game at an
+// offset before its start. Jobs are enqueued when a game is created or moved,
+// scheduled for when the reminder is due; a job whose game moved since does
+// nothing.
+type GameReminderJob struct {
+	GameID string `json:"game_id"`
+	// OffsetSeconds is how long before start_time the reminder is due
+	OffsetSeconds int `json:"offset_seconds"`
+	// StartTime is the start_time the reminder was scheduled for
+	StartTime time.Time `json:"start_time"`
+}
+
+// Kind names the game reminder job
+func (GameReminderJob) Kind() string { return "game_reminder" }
+
+// UniqueKey keeps one job queued per reminder of a game and start time
+func (j GameReminderJob) UniqueKey() string {
+	return fmt.Sprintf("%s:%d:%d", j.GameID, j.OffsetSeconds, j.StartTime.Unix())
+}
+
+// SendGameReminder notifies the roster of the game of a reminder job, and
+// returns how many notifications it made. Sent reminders are recorded per
+// game and offset, so each is sent once. Nothing is sent for a game that
+// was deleted, cancelled, started or moved since the job was enqueued, or
+// when the reminder at another of the offsets, closer to start_time, is due
+// too, e.g. for a game created an hour before it starts. compose returns the
+// content of the reminder's notifications, which are queued for delivery on
+// the given channels.
+func (r *NotificationRepository) SendGameReminder(ctx context.Context, job GameReminderJob, offsets []time.Duration, channels []string, compose func(GameReminderNotice) (NotificationContent, error)) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := notifiedGame(ctx, tx, job.GameID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	now := time.Now()
+	if game.Status != models.GameStatusScheduled || game.StartTime.Unix() != job.StartTime.Unix() || !game.StartTime.After(now) {
+		return 0, nil
+	}
+	offset := time.Duration(job.OffsetSeconds) * time.Second
+	superseded := slices.ContainsFunc(offsets, func(o time.Duration) bool {
+		return o < offset && !game.StartTime.Add(-o).After(now)
+	})
+
+	// Record the reminder; an instance inserting a row another one holds
+	// uncommitted waits for it and then skips the reminder
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO game_reminders (game_id, offset_seconds, start_time) VALUES ($1, $2, $3)
+		ON CONFLICT (game_id, offset_seconds) DO NOTHING
+	`, job.GameID, job.OffsetSeconds, game.StartTime)
+	if err != nil {
+		return 0, &DatabaseError{Op: "record game reminder", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, nil
+	}
+
+	var made int64
+	if !superseded {
+		recipients, err := notifiedPlayers(ctx, tx, job.GameID, "", false)
+		if err != nil {
+			return 0, err
+		}
+		if len(recipients) > 0 {
+			content, err := compose(GameReminderNotice{Game: *game, Offset: offset, Recipients: recipients})
+			if err != nil {
+				return 0, err
+			}
+			if made, err = createNotifications(ctx, tx, recipients, models.NotificationGameReminder, job.GameID, content, nil, channels); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, &DatabaseError{Op: "commit transaction", Err: err}
+	}
+	return made, nil
+}
+
+// ScheduleReminders enqueues the reminder jobs of every upcoming scheduled
+// game at the offsets, and returns how many games it scheduled. Reminders
+// already queued or sent are not repeated, so it is safe to run on every
+// start, e.g. to schedule the offsets added to the configuration since the
+// games were created.
+func (r *NotificationRepository) ScheduleReminders(ctx context.Context, offsets []time.Duration) (int, error) {
+	if len(offsets) == 0 {
+		return 0, nil
+	}
+	rows, err := r.db.Query(ctx, `
+		SELECT game_id, start_time FROM games WHERE status = $1 AND start_time > NOW()
+	`, models.GameStatusScheduled)
+	if err != nil {
+		return 0, &DatabaseError{Op: "list upcoming games", Err: err}
+	}
+	defer rows.Close()
+
+	type upcoming struct {
+		gameID string
+		start  time.Time
+	}
+	games := []upcoming{}
+	for rows.Next() {
+		var g upcoming
+		if err := rows.Scan(&g.gameID, &g.start); err != nil {
+			return 0, &DatabaseError{Op: "scan upcoming game", Err: err}
+		}
+		games = append(games, g)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, &DatabaseError{Op: "list upcoming games", Err: err}
+	}
+	rows.Close()
+
+	for _, g := range games {
+		if err := scheduleGameReminders(ctx, r.db, g.gameID, g.start, offsets); err != nil {
+			return 0, err
+		}
+	}
+	return len(games), nil
+}
+
+// scheduleGameReminders enqueues a reminder job of a game for each offset
+// before start, inside the caller's transaction. Of the reminders already
+// due, only the one closest to start is enqueued, to run now.
+func scheduleGameReminders(ctx context.Context, q querier, gameID string, start time.Time, offsets []time.Duration) error {
+	now := time.Now()
+	if !start.After(now) {
+		return nil
+	}
+	var due time.Duration
+	for _, offset := range offsets {
+		at := start.Add(-offset)
+		if !at.After(now) {
+			if due == 0 || offset < due {
+				due = offset
+			}
+			continue
+		}
+		if err := enqueueGameReminder(ctx, q, gameID, start, offset, at); err != nil {
+			return err
+		}
+	}
+	if due > 0 {
+		return enqueueGameReminder(ctx, q, gameID, start, due, now)
+	}
+	return nil
+}
+
+// enqueueGameReminder enqueues the reminder job of a game at an offset
+func enqueueGameReminder(ctx context.Context, q querier, gameID string, start time.Time, offset time.Duration, at time.Time) error {
+	job := GameReminderJob{GameID: gameID, OffsetSeconds: int(offset.Seconds()), StartTime: start}
+	args, err := json.Marshal(job)
+	if err != nil {
+		return &DatabaseError{Op: "encode game reminder job", Err: err}
+	}
+	_, _, err = enqueueJob(ctx, q, NewJob{Kind: job.Kind(), Args: args, UniqueKey: job.UniqueKey(), ScheduledAt: at})
+	return err
+}
+
+// rearmGameReminders deletes the sent reminders of a game whose time moved
+// that fall due again at the new start time, so they are sent for it
+func rearmGameReminders(ctx context.Context, q querier, gameID string, start time.Time) error {
+	_, err := q.Exec(ctx, `
+		DELETE FROM game_reminders
+		WHERE game_id = $1 AND $2::timestamptz - make_interval(secs => offset_seconds) > NOW()
+	`, gameID, start)
+	if err != nil {
+		return &DatabaseError{Op: "rearm game reminders", Err: err}
+	}
+	return nil
+}
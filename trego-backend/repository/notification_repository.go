@@ -0,0 +1,339 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// defaultNotificationPageSize is used when the listing does not set a limit
+	defaultNotificationPageSize = 50
+	// maxNotificationPageSize caps the page size of a listing
+	maxNotificationPageSize = 100
+)
+
+// notificationColumns are the columns scanned by scanNotification
+const notificationColumns = `notification_id, type, game_id, title, body, read_at, created_at`
+
+// NotificationRepository provides access to users' notification inboxes,
+// their notification preferences and push subscriptions, and the delivery
+// of notifications on other channels
+type NotificationRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *pgxpool.Pool) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// notificationCursor is the keyset position after the last notification of a
+// page. Notifications are ordered newest first by (created_at, notification_id).
+type notificationCursor struct {
+	CreatedAt      time.Time `json:"c"`
+	NotificationID string    `json:"id"`
+}
+
+// encode returns the opaque string form of the cursor
+func (c notificationCursor) encode() string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// decodeNotificationCursor parses a cursor produced by notificationCursor.encode
+func decodeNotificationCursor(raw string) (*notificationCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, &ValidationError{Field: "cursor", Message: "is invalid"}
+	}
+	var c notificationCursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.NotificationID == "" {
+		return nil, &ValidationError{Field: "cursor", Message: "is invalid"}
+	}
+	return &c, nil
+}
+
+// List returns one page of the user's inbox, newest first, with the number of
+// unread notifications. Pages are chained with the returned keyset cursor.
+func (r *NotificationRepository) List(ctx context.Context, userID string, filters models.NotificationFilters) (*models.NotificationPage, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultNotificationPageSize
+	}
+	if limit > maxNotificationPageSize {
+		limit = maxNotificationPageSize
+	}
+
+	conditions := `user_id = $1`
+	args := []interface{}{userID}
+	if filters.Unread {
+		conditions += ` AND read_at IS NULL`
+	}
+	if filters.Cursor != "" {
+		cursor, err := decodeNotificationCursor(filters.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, cursor.CreatedAt, cursor.NotificationID)
+		conditions += ` AND (created_at, notification_id) < ($2, $3)`
+	}
+	args = append(args, limit+1)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM notifications
+		WHERE %s
+		ORDER BY created_at DESC, notification_id DESC
+		LIMIT $%d
+	`, notificationColumns, conditions, len(args))
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list notifications", Err: err}
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, &DatabaseError{Op: "scan notification", Err: err}
+		}
+		notifications = append(notifications, *n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list notifications", Err: err}
+	}
+	rows.Close()
+
+	page := &models.NotificationPage{Notifications: notifications}
+	if len(notifications) > limit {
+		page.Notifications = notifications[:limit]
+		last := page.Notifications[limit-1]
+		next := notificationCursor{CreatedAt: last.CreatedAt, NotificationID: last.NotificationID}.encode()
+		page.NextCursor = &next
+	}
+	err = r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL
+	`, userID).Scan(&page.UnreadCount)
+	if err != nil {
+		return nil, &DatabaseError{Op: "count unread notifications", Err: err}
+	}
+	return page, nil
+}
+
+// SetRead marks one of the user's notifications read or unread. Marking a
+// read notification read again keeps the time it was first read.
+func (r *NotificationRepository) SetRead(ctx context.Context, userID, notificationID string, read bool) (*models.Notification, error) {
+	readAt := `NULL`
+	if read {
+		readAt = `COALESCE(read_at, NOW())`
+	}
+	n, err := scanNotification(r.db.QueryRow(ctx, `
+		UPDATE notifications SET read_at = `+readAt+`
+		WHERE notification_id = $1 AND user_id = $2
+		RETURNING `+notificationColumns, notificationID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "notification", ID: notificationID}
+		}
+		return nil, &DatabaseError{Op: "mark notification read", Err: err}
+	}
+	return n, nil
+}
+
+// MarkAllRead marks every unread notification of the user read and returns
+// how many it marked
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, &DatabaseError{Op: "mark notifications read", Err: err}
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetPreferences returns the user's notification preferences, or the
+// defaults (every channel on, no quiet hours, UTC) if they never set any
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID string) (*models.NotificationPreferences, error) {
+	return getNotificationPreferences(ctx, r.db, userID)
+}
+
+// UpdatePreferences changes the fields of the user's notification
+// preferences that are set in req
+func (r *NotificationRepository) UpdatePreferences(ctx context.Context, userID string, req models.UpdateNotificationPreferencesRequest) (*models.NotificationPreferences, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize concurrent updates, which merge into the stored preferences
+	var locked string
+	err = tx.QueryRow(ctx, `SELECT user_id FROM users WHERE user_id = $1 FOR UPDATE`, userID).Scan(&locked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "user", ID: userID}
+		}
+		return nil, &DatabaseError{Op: "lock user", Err: err}
+	}
+	prefs, err := getNotificationPreferences(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.EmailEnabled != nil {
+		prefs.EmailEnabled = *req.EmailEnabled
+	}
+	if req.PushEnabled != nil {
+		prefs.PushEnabled = *req.PushEnabled
+	}
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		if err := validateTimezone(timezone); err != nil {
+			return nil, err
+		}
+		prefs.Timezone = timezone
+	}
+	if req.QuietHoursStart != nil || req.QuietHoursEnd != nil {
+		if prefs.QuietHoursStart, prefs.QuietHoursEnd, err = quietHours(req.QuietHoursStart, req.QuietHoursEnd); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO notification_preferences (user_id, email_enabled, push_enabled, quiet_hours_start, quiet_hours_end, timezone)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET email_enabled = EXCLUDED.email_enabled, push_enabled = EXCLUDED.push_enabled,
+			quiet_hours_start = EXCLUDED.quiet_hours_start, quiet_hours_end = EXCLUDED.quiet_hours_end,
+			timezone = EXCLUDED.timezone
+	`, userID, prefs.EmailEnabled, prefs.PushEnabled, prefs.QuietHoursStart, prefs.QuietHoursEnd, prefs.Timezone)
+	if err != nil {
+		return nil, &DatabaseError{Op: "update notification preferences", Err: err}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit transaction", Err: err}
+	}
+	return prefs, nil
+}
+
+// quietHours validates a new pair of quiet hours, which are set together.
+// Two empty times turn quiet hours off.
+func quietHours(start, end *string) (*string, *string, error) {
+	if start == nil || end == nil {
+		return nil, nil, &ValidationError{Field: "quiet_hours", Message: "quiet_hours_start and quiet_hours_end must be set together"}
+	}
+	start, end = emptyToNil(start), emptyToNil(end)
+	if start == nil && end == nil {
+		return nil, nil, nil
+	}
+	if start == nil || end == nil {
+		return nil, nil, &ValidationError{Field: "quiet_hours", Message: "quiet_hours_start and quiet_hours_end must both be set or both be empty"}
+	}
+	if _, err := time.Parse("15:04", *start); err != nil {
+		return nil, nil, &ValidationError{Field: "quiet_hours_start", Message: fmt.Sprintf("invalid time %q, expected HH:MM", *start)}
+	}
+	if _, err := time.Parse("15:04", *end); err != nil {
+		return nil, nil, &ValidationError{Field: "quiet_hours_end", Message: fmt.Sprintf("invalid time %q, expected HH:MM", *end)}
+	}
+	if *start == *end {
+		return nil, nil, &ValidationError{Field: "quiet_hours_end", Message: "must differ from quiet_hours_start"}
+	}
+	return start, end, nil
+}
+
+// getNotificationPreferences loads a user's notification preferences,
+// falling back to the defaults
+func getNotificationPreferences(ctx context.Context, q querier, userID string) (*models.NotificationPreferences, error) {
+	prefs := models.NotificationPreferences{EmailEnabled: true, PushEnabled: true, Timezone: "UTC"}
+	err := q.QueryRow(ctx, `
+		SELECT email_enabled, push_enabled, quiet_hours_start, quiet_hours_end, timezone
+		FROM notification_preferences WHERE user_id = $1
+	`, userID).Scan(&prefs.EmailEnabled, &prefs.PushEnabled, &prefs.QuietHoursStart, &prefs.QuietHoursEnd, &prefs.Timezone)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, &DatabaseError{Op: "get notification preferences", Err: err}
+	}
+	return &prefs, nil
+}
+
+// AddPushSubscription registers a browser of the user for web push. A browser
+// subscribing again, possibly for another user, replaces its subscription.
+func (r *NotificationRepository) AddPushSubscription(ctx context.Context, userID string, req models.CreatePushSubscriptionRequest) (*models.PushSubscription, error) {
+	p256dh, err := pushKey("keys.p256dh", req.Keys.P256dh, 65)
+	if err != nil {
+		return nil, err
+	}
+	if p256dh[0] != 0x04 {
+		return nil, &ValidationError{Field: "keys.p256dh", Message: "must be an uncompressed P-256 public key"}
+	}
+	if _, err := pushKey("keys.auth", req.Keys.Auth, 16); err != nil {
+		return nil, err
+	}
+
+	var s models.PushSubscription
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO push_subscriptions (user_id, endpoint, p256dh, auth) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (endpoint) DO UPDATE SET user_id = EXCLUDED.user_id, p256dh = EXCLUDED.p256dh,
+			auth = EXCLUDED.auth, created_at = NOW()
+		RETURNING subscription_id, endpoint, created_at
+	`, userID, req.Endpoint, strings.TrimRight(req.Keys.P256dh, "="), strings.TrimRight(req.Keys.Auth, "=")).
+		Scan(&s.SubscriptionID, &s.Endpoint, &s.CreatedAt)
+	if err != nil {
+		return nil, &DatabaseError{Op: "add push subscription", Err: err}
+	}
+	return &s, nil
+}
+
+// pushKey decodes a base64url key of a push subscription, padded or not,
+// and checks its length
+func pushKey(field, value string, size int) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil || len(key) != size {
+		return nil, &ValidationError{Field: field, Message: fmt.Sprintf("must be %d base64url-encoded bytes", size)}
+	}
+	return key, nil
+}
+
+// DeletePushSubscription unregisters one of the user's browsers
+func (r *NotificationRepository) DeletePushSubscription(ctx context.Context, userID, subscriptionID string) error {
+	tag, err := r.db.Exec(ctx, `
+		DELETE FROM push_subscriptions WHERE subscription_id = $1 AND user_id = $2
+	`, subscriptionID, userID)
+	if err != nil {
+		return &DatabaseError{Op: "delete push subscription", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return &NotFoundError{Resource: "push subscription", ID: subscriptionID}
+	}
+	return nil
+}
+
+// DeletePushEndpoint removes the subscription of an endpoint the push
+// service reported gone
+func (r *NotificationRepository) DeletePushEndpoint(ctx context.Context, endpoint string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM push_subscriptions WHERE endpoint = $1`, endpoint); err != nil {
+		return &DatabaseError{Op: "delete push endpoint", Err: err}
+	}
+	return nil
+}
+
+// scanNotification scans a row selected with notificationColumns
+func scanNotification(row pgx.Row) (*models.Notification, error) {
+	var n models.Notification
+	err := row.Scan(&n.NotificationID, &n.Type, &n.GameID, &n.Title, &n.Body, &n.ReadAt, &n.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OAuthLoginState is the server-side half of an in-flight OAuth login
+type OAuthLoginState struct {
+	State        string
+	CodeVerifier string
+	Nonce        string
+	ExpiresAt    time.Time
+}
+
+// OAuthStateRepository stores OAuth login states between the login start and
+// the provider callback. States live in the database so that the callback may
+// land on a different gateway instance than the one that started the login.
+type OAuthStateRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewOAuthStateRepository creates a new OAuth state repository
+func NewOAuthStateRepository(db *pgxpool.Pool) *OAuthStateRepository {
+	return &OAuthStateRepository{db: db}
+}
+
+// Save stores a new login state and removes expired ones
+func (r *OAuthStateRepository) Save(ctx context.Context, state OAuthLoginState) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM oauth_login_states WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("failed to purge expired login states: %w", err)
+	}
+
+	query := `
+		INSERT INTO oauth_login_states (state, code_verifier, nonce, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	if _, err := r.db.Exec(ctx, query, state.State, state.CodeVerifier, state.Nonce, state.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to save login state: %w", err)
+	}
+	return nil
+}
+
+// Consume deletes and returns a login state so that it can only be used once.
+// It returns ErrNotFound if the state is unknown or has expired.
+func (r *OAuthStateRepository) Consume(ctx context.Context, state string) (*OAuthLoginState, error) {
+	query := `
+		DELETE FROM oauth_login_states
+		WHERE state = $1
+		RETURNING state, code_verifier, nonce, expires_at
+	`
+
+	var s OAuthLoginState
+	err := r.db.QueryRow(ctx, query, state).Scan(&s.State, &s.CodeVerifier, &s.Nonce, &s.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to consume login state: %w", err)
+	}
+
+	if time.Now().After(s.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// querier is implemented by both *pgxpool.Pool and pgx.Tx, so helpers can run
+// inside or outside a transaction
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// requireSport returns a ValidationError if the sport is not in the catalog
+func requireSport(ctx context.Context, q querier, sportName string) error {
+	var exists bool
+	if err := q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM sports WHERE sport_name = $1)`, sportName).Scan(&exists); err != nil {
+		return &DatabaseError{Op: "check sport", Err: err}
+	}
+	if !exists {
+		return &ValidationError{Field: "sport_name", Message: fmt.Sprintf("unknown sport %q", sportName)}
+	}
+	return nil
+}
+
+// requireUser returns a NotFoundError if the user does not exist
+func requireUser(ctx context.Context, q querier, userID string) error {
+	var exists bool
+	if err := q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE user_id = $1)`, userID).Scan(&exists); err != nil {
+		return &DatabaseError{Op: "check user", Err: err}
+	}
+	if !exists {
+		return &NotFoundError{Resource: "user", ID: userID}
+	}
+	return nil
+}
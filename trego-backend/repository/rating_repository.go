@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ratingColumns is the column list matching ratingFields
+const ratingColumns = `r.rating_id, r.game_id, r.rater_id, r.ratee_id, r.sportsmanship, r.skill_accuracy, r.comment, r.created_at, r.updated_at`
+
+const (
+	// defaultRatingEditWindow is how long after submission a rating can be changed
+	defaultRatingEditWindow = 24 * time.Hour
+	// defaultRatingHourlyLimit caps the new ratings one user can submit per hour
+	defaultRatingHourlyLimit = 20
+	// defaultRatingPageSize is used when a rating list does not set a limit
+	defaultRatingPageSize = 20
+)
+
+// RatingRepository provides access to post-game peer ratings
+type RatingRepository struct {
+	db *pgxpool.Pool
+	// editWindow is how long after submission a rating can be changed
+	editWindow time.Duration
+	// hourlyLimit caps the new ratings one user can submit per hour
+	hourlyLimit int
+}
+
+// NewRatingRepository creates a new rating repository
+func NewRatingRepository(db *pgxpool.Pool) *RatingRepository {
+	return &RatingRepository{db: db, editWindow: defaultRatingEditWindow, hourlyLimit: defaultRatingHourlyLimit}
+}
+
+// WithLimits sets the edit window and hourly submission limit of ratings,
+// and returns the repository
+func (r *RatingRepository) WithLimits(editWindow time.Duration, hourlyLimit int) *RatingRepository {
+	r.editWindow = editWindow
+	r.hourlyLimit = hourlyLimit
+	return r
+}
+
+// Rate records raterID's rating of rateeID in a game, or updates it while
+// the edit window is open. Once the game has ended, players can rate the
+// other players and the host; players marked as no-shows can neither rate
+// nor be rated. New ratings are rate limited per rater. The rating feeds the
+// ratee's reputation. Created reports whether the rating is new.
+func (r *RatingRepository) Rate(ctx context.Context, gameID, raterID, rateeID string, req models.RateUserRequest) (rating *models.Rating, created bool, err error) {
+	if raterID == rateeID {
+		return nil, false, &ValidationError{Field: "user_id", Message: "you cannot rate yourself"}
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, false, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := getGame(ctx, tx, gameID, false)
+	if err != nil {
+		return nil, false, err
+	}
+	if game.Status == models.GameStatusCancelled {
+		return nil, false, &ConflictError{Message: "cancelled games cannot be rated"}
+	}
+	if time.Now().Before(game.EndTime) {
+		return nil, false, &ConflictError{Message: "ratings open once the game has ended"}
+	}
+
+	if took, err := tookPart(ctx, tx, gameID, raterID); err != nil {
+		return nil, false, err
+	} else if !took {
+		return nil, false, fmt.Errorf("only players of this game can rate: %w", ErrForbidden)
+	}
+	if rateeID != game.HostID {
+		if took, err := tookPart(ctx, tx, gameID, rateeID); err != nil {
+			return nil, false, err
+		} else if !took {
+			return nil, false, &ValidationError{Field: "user_id", Message: "user did not take part in this game"}
+		}
+	}
+
+	var comment *string
+	if req.Comment != nil {
+		trimmed := strings.TrimSpace(*req.Comment)
+		comment = emptyToNil(&trimmed)
+	}
+
+	existing, err := getRating(ctx, tx, `r.game_id = $1 AND r.rater_id = $2 AND r.ratee_id = $3 FOR UPDATE`, gameID, raterID, rateeID)
+	switch {
+	case err == nil:
+		if time.Now().After(existing.CreatedAt.Add(r.editWindow)) {
+			return nil, false, &ConflictError{Message: "rating can no longer be edited"}
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE game_ratings SET sportsmanship = $1, skill_accuracy = $2, comment = $3
+			WHERE rating_id = $4
+		`, req.Sportsmanship, req.SkillAccuracy, comment, existing.RatingID)
+		if err != nil {
+			return nil, false, &DatabaseError{Op: "update rating", Err: err}
+		}
+		rating, err = getRating(ctx, tx, `r.rating_id = $1`, existing.RatingID)
+
+	case errors.Is(err, ErrNotFound):
+		var recent int
+		err = tx.QueryRow(ctx, `
+			SELECT COUNT(*) FROM game_ratings WHERE rater_id = $1 AND created_at > NOW() - INTERVAL '1 hour'
+		`, raterID).Scan(&recent)
+		if err != nil {
+			return nil, false, &DatabaseError{Op: "count recent ratings", Err: err}
+		}
+		if recent >= r.hourlyLimit {
+			return nil, false, fmt.Errorf("at most %d ratings can be submitted per hour: %w", r.hourlyLimit, ErrRateLimited)
+		}
+
+		var ratingID string
+		err = tx.QueryRow(ctx, `
+			INSERT INTO game_ratings (game_id, rater_id, ratee_id, sportsmanship, skill_accuracy, comment)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING rating_id
+		`, gameID, raterID, rateeID, req.Sportsmanship, req.SkillAccuracy, comment).Scan(&ratingID)
+		if err != nil {
+			if pgErrorCode(err) == pgUniqueViolation {
+				// A concurrent request rated the same user first
+				return nil, false, &ConflictError{Message: "rating was submitted concurrently, retry to edit it"}
+			}
+			return nil, false, &DatabaseError{Op: "create rating", Err: err}
+		}
+		rating, err = getRating(ctx, tx, `r.rating_id = $1`, ratingID)
+		created = true
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	delta, reason := ratingReputation(rating.Sportsmanship)
+	if err := applyReputation(ctx, tx, rateeID, ReputationSourcePeerRating, rating.RatingID, delta, reason); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, &DatabaseError{Op: "commit rating", Err: err}
+	}
+	rating.EditableUntil = rating.CreatedAt.Add(r.editWindow)
+	return rating, created, nil
+}
+
+// ListByRater returns the ratings raterID gave in a game
+func (r *RatingRepository) ListByRater(ctx context.Context, gameID, raterID string) ([]models.Rating, error) {
+	query := `SELECT ` + ratingColumns + ` FROM game_ratings r WHERE r.game_id = $1 AND r.rater_id = $2 ORDER BY r.created_at, r.rating_id`
+	return r.list(ctx, query, gameID, raterID)
+}
+
+// ListReceived returns the ratings a user received, newest first, without
+// revealing who gave them
+func (r *RatingRepository) ListReceived(ctx context.Context, userID string, filters models.RatingFilters) ([]models.Rating, error) {
+	if err := requireUser(ctx, r.db, userID); err != nil {
+		return nil, err
+	}
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultRatingPageSize
+	}
+
+	query := `SELECT ` + ratingColumns + ` FROM game_ratings r WHERE r.ratee_id = $1 ORDER BY r.created_at DESC, r.rating_id LIMIT $2 OFFSET $3`
+	ratings, err := r.list(ctx, query, userID, limit, filters.Offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ratings {
+		ratings[i].RaterID = ""
+	}
+	return ratings, nil
+}
+
+// Summary aggregates the ratings a user received
+func (r *RatingRepository) Summary(ctx context.Context, userID string) (*models.RatingSummary, error) {
+	var summary models.RatingSummary
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(AVG(sportsmanship), 0)::float8, COALESCE(AVG(skill_accuracy), 0)::float8
+		FROM game_ratings
+		WHERE ratee_id = $1
+	`, userID).Scan(&summary.Count, &summary.Sportsmanship, &summary.SkillAccuracy)
+	if err != nil {
+		return nil, &DatabaseError{Op: "summarize ratings", Err: err}
+	}
+	return &summary, nil
+}
+
+// list runs a query selecting ratingColumns
+func (r *RatingRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.Rating, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list ratings", Err: err}
+	}
+	defer rows.Close()
+
+	ratings := []models.Rating{}
+	for rows.Next() {
+		var rating models.Rating
+		if err := rows.Scan(ratingFields(&rating)...); err != nil {
+			return nil, &DatabaseError{Op: "scan rating", Err: err}
+		}
+		rating.EditableUntil = rating.CreatedAt.Add(r.editWindow)
+		ratings = append(ratings, rating)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list ratings", Err: err}
+	}
+	return ratings, nil
+}
+
+// tookPart reports whether the user was on the game's roster and not marked as a no-show
+func tookPart(ctx context.Context, q querier, gameID, userID string) (bool, error) {
+	var took bool
+	err := q.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM game_players WHERE game_id = $1 AND user_id = $2 AND attendance IS DISTINCT FROM 'false')
+	`, gameID, userID).Scan(&took)
+	if err != nil {
+		return false, &DatabaseError{Op: "check game player", Err: err}
+	}
+	return took, nil
+}
+
+// getRating loads one rating matching the condition on game_ratings r
+func getRating(ctx context.Context, q querier, condition string, args ...interface{}) (*models.Rating, error) {
+	var rating models.Rating
+	err := q.QueryRow(ctx, `SELECT `+ratingColumns+` FROM game_ratings r WHERE `+condition, args...).Scan(ratingFields(&rating)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "rating", ID: fmt.Sprint(args[0])}
+		}
+		return nil, &DatabaseError{Op: "get rating", Err: err}
+	}
+	return &rating, nil
+}
+
+// ratingFields returns the scan destinations matching ratingColumns
+func ratingFields(rating *models.Rating) []interface{} {
+	return []interface{}{
+		&rating.RatingID,
+		&rating.GameID,
+		&rating.RaterID,
+		&rating.RateeID,
+		&rating.Sportsmanship,
+		&rating.SkillAccuracy,
+		&rating.Comment,
+		&rating.CreatedAt,
+		&rating.UpdatedAt,
+	}
+}
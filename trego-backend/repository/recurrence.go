@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxRecurrenceInterval caps INTERVAL, e.g. 2 for every other week
+	maxRecurrenceInterval = 52
+	// maxRecurrenceCount caps COUNT
+	maxRecurrenceCount = 520
+)
+
+// rruleWeekdays maps RRULE day codes to weekdays
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// recurrence is the subset of an RFC 5545 RRULE series support:
+// FREQ=WEEKLY with INTERVAL, BYDAY and either UNTIL or COUNT. Weeks start on
+// Monday.
+type recurrence struct {
+	interval int
+	// weekdays are the days of each week with an occurrence, Monday first;
+	// empty means the weekday of the first occurrence
+	weekdays []time.Weekday
+	// until is the last instant an occurrence may start at
+	until *time.Time
+	// untilDate is the last local date an occurrence may fall on, for UNTIL
+	// given as a date
+	untilDate string
+	// count is the number of occurrences, 0 for no limit
+	count int
+}
+
+// parseRecurrence parses an RRULE such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU"
+// or "RRULE:FREQ=WEEKLY;COUNT=10"
+func parseRecurrence(rule string) (*recurrence, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return &ValidationError{Field: "recurrence", Message: fmt.Sprintf(format, args...)}
+	}
+
+	rule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:")
+	rec := &recurrence{interval: 1}
+	var freq string
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, invalid("%q is not a KEY=VALUE pair", part)
+		}
+		switch key {
+		case "FREQ":
+			freq = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxRecurrenceInterval {
+				return nil, invalid("INTERVAL must be between 1 and %d", maxRecurrenceInterval)
+			}
+			rec.interval = n
+		case "BYDAY":
+			seen := map[time.Weekday]bool{}
+			for _, code := range strings.Split(value, ",") {
+				day, ok := rruleWeekdays[code]
+				if !ok {
+					return nil, invalid("BYDAY takes days like MO,TH, not %q", code)
+				}
+				if !seen[day] {
+					seen[day] = true
+					rec.weekdays = append(rec.weekdays, day)
+				}
+			}
+			sort.Slice(rec.weekdays, func(i, j int) bool {
+				return mondayOffset(rec.weekdays[i]) < mondayOffset(rec.weekdays[j])
+			})
+		case "UNTIL":
+			if until, err := time.Parse("20060102T150405Z", value); err == nil {
+				rec.until = &until
+			} else if date, err := time.Parse("20060102", value); err == nil {
+				rec.untilDate = date.Format("2006-01-02")
+			} else {
+				return nil, invalid("UNTIL must look like 20240531 or 20240531T180000Z")
+			}
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxRecurrenceCount {
+				return nil, invalid("COUNT must be between 1 and %d", maxRecurrenceCount)
+			}
+			rec.count = n
+		case "WKST":
+			if value != "MO" {
+				return nil, invalid("only WKST=MO is supported")
+			}
+		default:
+			return nil, invalid("%s is not supported", key)
+		}
+	}
+
+	switch {
+	case freq == "":
+		return nil, invalid("FREQ is required")
+	case freq != "WEEKLY":
+		return nil, invalid("only FREQ=WEEKLY is supported")
+	case rec.count > 0 && (rec.until != nil || rec.untilDate != ""):
+		return nil, invalid("UNTIL and COUNT cannot be combined")
+	}
+	return rec, nil
+}
+
+// String formats the recurrence as a canonical RRULE
+func (rec *recurrence) String() string {
+	parts := []string{"FREQ=WEEKLY"}
+	if rec.interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", rec.interval))
+	}
+	if len(rec.weekdays) > 0 {
+		codes := make([]string, len(rec.weekdays))
+		for i, day := range rec.weekdays {
+			codes[i] = strings.ToUpper(day.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	switch {
+	case rec.until != nil:
+		parts = append(parts, "UNTIL="+rec.until.UTC().Format("20060102T150405Z"))
+	case rec.untilDate != "":
+		parts = append(parts, "UNTIL="+strings.ReplaceAll(rec.untilDate, "-", ""))
+	case rec.count > 0:
+		parts = append(parts, fmt.Sprintf("COUNT=%d", rec.count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// occurrences returns the start of every occurrence from first up to and
+// including to, in order. Occurrences keep the wall-clock time of first in
+// loc, so they do not drift across DST changes. Occurrences later removed,
+// e.g. for holidays, still count toward COUNT, as RFC 5545 EXDATEs do.
+func (rec *recurrence) occurrences(first time.Time, loc *time.Location, to time.Time) []time.Time {
+	first = first.In(loc)
+	weekdays := rec.weekdays
+	if len(weekdays) == 0 {
+		weekdays = []time.Weekday{first.Weekday()}
+	}
+	weekStart := time.Date(first.Year(), first.Month(), first.Day()-mondayOffset(first.Weekday()), 0, 0, 0, 0, loc)
+
+	var starts []time.Time
+	for week := 0; ; week += rec.interval {
+		for _, day := range weekdays {
+			start := time.Date(weekStart.Year(), weekStart.Month(), weekStart.Day()+7*week+mondayOffset(day),
+				first.Hour(), first.Minute(), first.Second(), 0, loc)
+			switch {
+			case start.Before(first):
+				continue
+			case start.After(to),
+				rec.until != nil && start.After(*rec.until),
+				rec.untilDate != "" && start.Format("2006-01-02") > rec.untilDate:
+				return starts
+			}
+			starts = append(starts, start)
+			if rec.count > 0 && len(starts) == rec.count {
+				return starts
+			}
+		}
+	}
+}
+
+// mondayOffset returns how many days day comes after Monday
+func mondayOffset(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Reputation source types recorded in the ledger
+const (
+	ReputationSourceAttendance   = "attendance"
+	ReputationSourceHosting      = "hosting"
+	ReputationSourceCancellation = "cancellation"
+	ReputationSourcePeerRating   = "peer_rating"
+)
+
+// Reputation rules. Every change is stored as a ledger entry, and a user's
+// reputation is the sum of their entries, floored at 0.
+const (
+	// reputationAttended is earned by a player marked as attended
+	reputationAttended = 2
+	// reputationNoShow is charged to a player marked as a no-show
+	reputationNoShow = -5
+	// reputationHosted is earned by a host once attendance shows the game took place
+	reputationHosted = 3
+	// reputationCancellation is charged to a host cancelling a game with players
+	reputationCancellation = -3
+	// reputationLateCancellation replaces reputationCancellation within lateCancellationWindow of the start
+	reputationLateCancellation = -10
+	// lateCancellationWindow is how close to start_time a cancellation counts as late
+	lateCancellationWindow = 24 * time.Hour
+	// reputationPerSportsmanshipPoint is earned per sportsmanship point above
+	// a neutral 3 in a peer rating, and charged per point below it
+	reputationPerSportsmanshipPoint = 1
+	// reputationEntriesShown caps the entries returned with a breakdown
+	reputationEntriesShown = 50
+)
+
+// ReputationRepository provides access to the reputation ledger
+type ReputationRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewReputationRepository creates a new reputation repository
+func NewReputationRepository(db *pgxpool.Pool) *ReputationRepository {
+	return &ReputationRepository{db: db}
+}
+
+// Breakdown explains a user's reputation with totals per source type and the
+// most recent ledger entries
+func (r *ReputationRepository) Breakdown(ctx context.Context, userID string) (*models.ReputationBreakdown, error) {
+	breakdown := &models.ReputationBreakdown{
+		UserID:   userID,
+		BySource: []models.ReputationSourceTotal{},
+		Entries:  []models.ReputationEntry{},
+	}
+	if err := r.db.QueryRow(ctx, `SELECT reputation FROM users WHERE user_id = $1`, userID).Scan(&breakdown.Reputation); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "user", ID: userID}
+		}
+		return nil, &DatabaseError{Op: "get reputation", Err: err}
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT source_type, SUM(delta), COUNT(*)
+		FROM reputation_ledger
+		WHERE user_id = $1
+		GROUP BY source_type
+		ORDER BY source_type
+	`, userID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "sum reputation ledger", Err: err}
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var total models.ReputationSourceTotal
+		if err := rows.Scan(&total.SourceType, &total.Total, &total.Entries); err != nil {
+			return nil, &DatabaseError{Op: "scan reputation total", Err: err}
+		}
+		breakdown.LedgerTotal += total.Total
+		breakdown.BySource = append(breakdown.BySource, total)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "sum reputation ledger", Err: err}
+	}
+
+	rows, err = r.db.Query(ctx, `
+		SELECT entry_id, user_id, source_type, source_id, delta, reason, created_at
+		FROM reputation_ledger
+		WHERE user_id = $1
+		ORDER BY created_at DESC, entry_id DESC
+		LIMIT $2
+	`, userID, reputationEntriesShown)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list reputation ledger", Err: err}
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e models.ReputationEntry
+		if err := rows.Scan(&e.EntryID, &e.UserID, &e.SourceType, &e.SourceID, &e.Delta, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, &DatabaseError{Op: "scan reputation entry", Err: err}
+		}
+		breakdown.Entries = append(breakdown.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list reputation ledger", Err: err}
+	}
+	return breakdown, nil
+}
+
+// RecomputeAll rebuilds users.reputation of every user from the ledger and
+// returns how many users changed
+func (r *ReputationRepository) RecomputeAll(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE users u SET reputation = totals.reputation
+		FROM (
+			SELECT u2.user_id, GREATEST(0, COALESCE(SUM(l.delta), 0)) AS reputation
+			FROM users u2
+			LEFT JOIN reputation_ledger l ON l.user_id = u2.user_id
+			GROUP BY u2.user_id
+		) totals
+		WHERE u.user_id = totals.user_id AND u.reputation IS DISTINCT FROM totals.reputation
+	`)
+	if err != nil {
+		return 0, &DatabaseError{Op: "recompute reputation", Err: err}
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Recompute rebuilds users.reputation of one user from the ledger
+func (r *ReputationRepository) Recompute(ctx context.Context, userID string) error {
+	if err := requireUser(ctx, r.db, userID); err != nil {
+		return err
+	}
+	return refreshReputation(ctx, r.db, userID)
+}
+
+// applyReputation sets the net contribution of one source, e.g. the
+// attendance of a user at one game, to delta. Instead of rewriting history it
+// appends an adjusting entry for the difference, so re-marking attendance
+// leaves an audit trail and repeating the same call is a no-op.
+func applyReputation(ctx context.Context, q querier, userID, sourceType, sourceID string, delta int, reason string) error {
+	var current int
+	err := q.QueryRow(ctx, `
+		SELECT COALESCE(SUM(delta), 0) FROM reputation_ledger
+		WHERE user_id = $1 AND source_type = $2 AND source_id = $3
+	`, userID, sourceType, sourceID).Scan(&current)
+	if err != nil {
+		return &DatabaseError{Op: "sum reputation source", Err: err}
+	}
+	if current == delta {
+		return nil
+	}
+
+	_, err = q.Exec(ctx, `
+		INSERT INTO reputation_ledger (user_id, source_type, source_id, delta, reason)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, sourceType, sourceID, delta-current, reason)
+	if err != nil {
+		return &DatabaseError{Op: "record reputation entry", Err: err}
+	}
+	return refreshReputation(ctx, q, userID)
+}
+
+// refreshReputation recomputes users.reputation of one user from the ledger
+func refreshReputation(ctx context.Context, q querier, userID string) error {
+	_, err := q.Exec(ctx, `
+		UPDATE users SET reputation = GREATEST(0, COALESCE((SELECT SUM(delta) FROM reputation_ledger WHERE user_id = $1), 0))
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return &DatabaseError{Op: "update reputation", Err: err}
+	}
+	return nil
+}
+
+// attendanceReputation returns the reputation rule for a marked attendance
+func attendanceReputation(attendance string) (int, string) {
+	switch attendance {
+	case "true":
+		return reputationAttended, "attended a game"
+	case "false":
+		return reputationNoShow, "did not show up to a game"
+	}
+	return 0, "attendance cleared"
+}
+
+// ratingReputation returns the reputation rule for a received peer rating
+func ratingReputation(sportsmanship int) (int, string) {
+	return (sportsmanship - 3) * reputationPerSportsmanshipPoint, fmt.Sprintf("rated %d/5 for sportsmanship by a player", sportsmanship)
+}
+
+// cancellationReputation returns the reputation rule for a host cancelling a
+// game, or 0 if the cancellation affects nobody or was already charged
+func cancellationReputation(game *models.Game, now time.Time) (int, string) {
+	switch {
+	case game.Status == models.GameStatusCancelled:
+		return 0, ""
+	case game.PlayerCount == 0 || !game.StartTime.After(now):
+		return 0, ""
+	case game.StartTime.Sub(now) < lateCancellationWindow:
+		return reputationLateCancellation, "cancelled a game with players less than 24h before the start"
+	}
+	return reputationCancellation, "cancelled a game with players"
+}
@@ -0,0 +1,859 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// seriesColumns is the column list matching seriesFields. It expects the
+// game_series table to be aliased as s.
+const seriesColumns = `s.series_id, s.host_id, s.sport_name, s.title, s.description, s.location, s.latitude, s.longitude,
+	s.venue_id, s.court_id, s.skill_range, s.capacity, s.skill_level, s.visibility,
+	s.first_start, s.duration_minutes, s.timezone, s.rrule, s.skip_holidays, s.materialized_until, s.created_at, s.updated_at`
+
+// defaultSeriesHorizon is how far ahead occurrences are created as games
+const defaultSeriesHorizon = 8 * 7 * 24 * time.Hour
+
+// SeriesRepository provides access to recurring game series
+type SeriesRepository struct {
+	db *pgxpool.Pool
+	// games applies edits to occurrences the way single game edits are applied
+	games *GameRepository
+	// horizon is how far ahead occurrences are created as games
+	horizon time.Duration
+}
+
+// NewSeriesRepository creates a new series repository
+func NewSeriesRepository(db *pgxpool.Pool) *SeriesRepository {
+	return &SeriesRepository{db: db, games: NewGameRepository(db), horizon: defaultSeriesHorizon}
+}
+
+// WithHorizon sets how far ahead occurrences are created as games, and
+// returns the repository
+func (r *SeriesRepository) WithHorizon(horizon time.Duration) *SeriesRepository {
+	r.horizon = horizon
+	return r
+}
+
+// WithWaitlistCutoff sets how long before start_time waitlisted players stop
+// being promoted when an edit opens spots, and returns the repository
+func (r *SeriesRepository) WithWaitlistCutoff(cutoff time.Duration) *SeriesRepository {
+	r.games.WithWaitlistCutoff(cutoff)
+	return r
+}
+
+// WithReminderOffsets sets how long before start_time the reminders of the
+// occurrences created and moved are scheduled, and returns the repository
+func (r *SeriesRepository) WithReminderOffsets(offsets []time.Duration) *SeriesRepository {
+	r.games.WithReminderOffsets(offsets)
+	return r
+}
+
+// Create inserts a series hosted by hostID whose first occurrence is the game
+// described by the request, and creates its occurrences within the horizon.
+// Occurrences keep the local start time in the series time zone. A court
+// already booked at any of those occurrences is a conflict.
+func (r *SeriesRepository) Create(ctx context.Context, hostID string, req models.CreateSeriesRequest) (*models.GameSeries, error) {
+	if !req.StartTime.After(time.Now()) {
+		return nil, &ValidationError{Field: "start_time", Message: "must be in the future"}
+	}
+	rec, err := parseRecurrence(req.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := prepareGame(ctx, tx, req.CreateGameRequest)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := seriesDuration(req.StartTime, req.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	timezone := strings.TrimSpace(req.Timezone)
+	if timezone == "" {
+		timezone = game.timezone
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if err := validateTimezone(timezone); err != nil {
+		return nil, err
+	}
+	loc, _ := time.LoadLocation(timezone)
+	if len(rec.occurrences(req.StartTime, loc, req.StartTime)) == 0 {
+		return nil, &ValidationError{Field: "recurrence", Message: "ends before start_time"}
+	}
+
+	var seriesID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO game_series (host_id, sport_name, title, description, location, latitude, longitude, venue_id, court_id,
+			skill_range, capacity, skill_level, visibility, first_start, duration_minutes, timezone, rrule, skip_holidays)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING series_id
+	`,
+		hostID,
+		req.SportName,
+		game.title,
+		emptyToNil(req.Description),
+		game.location,
+		game.latitude,
+		game.longitude,
+		game.venueID,
+		game.courtID,
+		emptyToNil(req.SkillRange),
+		req.Capacity,
+		emptyToNil(req.SkillLevel),
+		req.Visibility,
+		req.StartTime,
+		int(duration/time.Minute),
+		timezone,
+		rec.String(),
+		req.SkipHolidays,
+	).Scan(&seriesID)
+	if err != nil {
+		return nil, translateGameWriteError("create series", err)
+	}
+
+	series, err := getSeries(ctx, tx, seriesID, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := materializeSeries(ctx, tx, series, time.Now().Add(r.horizon), true, r.games.reminderOffsets); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit series", Err: err}
+	}
+	return r.Get(ctx, seriesID, hostID)
+}
+
+// Get returns a series with the upcoming occurrences the viewer may see.
+// Invite-only series are only visible to viewers who can see one of their
+// games.
+func (r *SeriesRepository) Get(ctx context.Context, seriesID, viewerID string) (*models.GameSeries, error) {
+	series, err := getSeries(ctx, r.db, seriesID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + gameColumns + `
+		FROM games g
+		WHERE g.series_id = $1 AND g.start_time > NOW() AND ` + gameVisibleTo("$2") + `
+		ORDER BY g.start_time, g.game_id
+	`
+	rows, err := r.db.Query(ctx, query, seriesID, viewerID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list series games", Err: err}
+	}
+	defer rows.Close()
+
+	series.Games = []models.Game{}
+	for rows.Next() {
+		game, err := scanGame(rows)
+		if err != nil {
+			return nil, &DatabaseError{Op: "scan series game", Err: err}
+		}
+		series.Games = append(series.Games, *game)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list series games", Err: err}
+	}
+
+	if series.Visibility != "public" && series.HostID != viewerID && len(series.Games) == 0 {
+		// Do not reveal that the invite-only series exists
+		return nil, &NotFoundError{Resource: "series", ID: seriesID}
+	}
+	return series, nil
+}
+
+// UpdateFollowing edits an occurrence and every later one on behalf of
+// callerID, who must be the host. The series is split at the occurrence: the
+// earlier occurrences stay with it and the edited ones move to a new series
+// returned here, unless the occurrence is the first, in which case the whole
+// series is edited. The game fields are applied to every moved game.
+// start_time and end_time set the new local time of all of them, and
+// recurrence replaces the schedule. Each game keeps its players by moving to
+// the occurrence in the same week of the new schedule, counted from the
+// edited occurrence; games left without one are cancelled.
+func (r *SeriesRepository) UpdateFollowing(ctx context.Context, seriesID, gameID, callerID string, req models.UpdateSeriesRequest) (*models.GameSeries, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	series, err := getSeries(ctx, tx, seriesID, true)
+	if err != nil {
+		return nil, err
+	}
+	if series.HostID != callerID {
+		return nil, fmt.Errorf("only the host can edit this series: %w", ErrForbidden)
+	}
+	occurrence, err := seriesOccurrence(ctx, tx, seriesID, gameID)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := parseRecurrence(series.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(series.Timezone)
+	if err != nil {
+		return nil, &DatabaseError{Op: "load series time zone", Err: err}
+	}
+
+	// The schedule of the edited occurrences
+	start := occurrence
+	if req.StartTime != nil {
+		if !req.StartTime.After(time.Now()) {
+			return nil, &ValidationError{Field: "start_time", Message: "must be in the future"}
+		}
+		start = *req.StartTime
+	}
+	duration := time.Duration(series.DurationMinutes) * time.Minute
+	if req.EndTime != nil {
+		if duration, err = seriesDuration(start, *req.EndTime); err != nil {
+			return nil, err
+		}
+	}
+	index := len(rec.occurrences(series.FirstStart, loc, occurrence)) - 1
+	var newRec *recurrence
+	if req.Recurrence != nil {
+		if newRec, err = parseRecurrence(*req.Recurrence); err != nil {
+			return nil, err
+		}
+	} else {
+		copied := *rec
+		newRec = &copied
+		if rec.count > 0 {
+			newRec.count = rec.count - index
+		}
+		if len(rec.weekdays) <= 1 && start.In(loc).Weekday() != occurrence.In(loc).Weekday() {
+			// A single game day follows the moved occurrence
+			newRec.weekdays = nil
+		}
+	}
+	if len(newRec.occurrences(start, loc, start)) == 0 {
+		return nil, &ValidationError{Field: "recurrence", Message: "ends before start_time"}
+	}
+	skipHolidays := series.SkipHolidays
+	if req.SkipHolidays != nil {
+		skipHolidays = *req.SkipHolidays
+	}
+
+	targetID := seriesID
+	if index == 0 {
+		_, err = tx.Exec(ctx, `
+			UPDATE game_series SET first_start = $2, duration_minutes = $3, rrule = $4, skip_holidays = $5
+			WHERE series_id = $1
+		`, seriesID, start, int(duration/time.Minute), newRec.String(), skipHolidays)
+		if err != nil {
+			return nil, &DatabaseError{Op: "update series", Err: err}
+		}
+	} else {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO game_series (host_id, sport_name, title, description, location, latitude, longitude, venue_id, court_id,
+				skill_range, capacity, skill_level, visibility, first_start, duration_minutes, timezone, rrule, skip_holidays, materialized_until)
+			SELECT host_id, sport_name, title, description, location, latitude, longitude, venue_id, court_id,
+				skill_range, capacity, skill_level, visibility, $2, $3, timezone, $4, $5, materialized_until
+			FROM game_series WHERE series_id = $1
+			RETURNING series_id
+		`, seriesID, start, int(duration/time.Minute), newRec.String(), skipHolidays).Scan(&targetID)
+		if err != nil {
+			return nil, &DatabaseError{Op: "split series", Err: err}
+		}
+		if err := endSeriesBefore(ctx, tx, seriesID, rec, occurrence); err != nil {
+			return nil, err
+		}
+	}
+
+	retime := req.StartTime != nil || req.EndTime != nil || req.Recurrence != nil
+	edited, fresh, err := r.moveFollowing(ctx, tx, series, targetID, occurrence, start, duration, newRec, loc, retime, req.UpdateGameRequest)
+	if err != nil {
+		return nil, err
+	}
+	if columns := seriesTemplateColumns(req.UpdateGameRequest); len(columns) > 0 {
+		// The edited occurrence passed the game validation; the new games copy it
+		sets := make([]string, len(columns))
+		for i, column := range columns {
+			sets[i] = fmt.Sprintf("%[1]s = g.%[1]s", column)
+		}
+		_, err := tx.Exec(ctx, `UPDATE game_series s SET `+strings.Join(sets, ", ")+`
+			FROM games g WHERE s.series_id = $1 AND g.game_id = $2`, targetID, edited)
+		if err != nil {
+			return nil, &DatabaseError{Op: "update series template", Err: err}
+		}
+	}
+
+	target, err := getSeries(ctx, tx, targetID, true)
+	if err != nil {
+		return nil, err
+	}
+	// Days the new schedule adds within what was already created
+	holidays := map[string]bool{}
+	if len(fresh) > 0 {
+		if holidays, err = seriesHolidays(ctx, tx, target, loc, fresh[len(fresh)-1]); err != nil {
+			return nil, err
+		}
+	}
+	for _, freshStart := range fresh {
+		if freshStart.After(time.Now()) && !holidays[freshStart.In(loc).Format("2006-01-02")] {
+			if _, err := insertOccurrence(ctx, tx, targetID, freshStart, freshStart.Add(duration), r.games.reminderOffsets); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := materializeSeries(ctx, tx, target, time.Now().Add(r.horizon), false, r.games.reminderOffsets); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit series update", Err: err}
+	}
+	return r.Get(ctx, targetID, callerID)
+}
+
+// CancelFollowing ends a series before one of its occurrences on behalf of
+// callerID, who must be the host, and cancels that occurrence and every later
+// one. Cancelling from the first occurrence deletes the series.
+func (r *SeriesRepository) CancelFollowing(ctx context.Context, seriesID, gameID, callerID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	series, err := getSeries(ctx, tx, seriesID, true)
+	if err != nil {
+		return err
+	}
+	if series.HostID != callerID {
+		return fmt.Errorf("only the host can edit this series: %w", ErrForbidden)
+	}
+	occurrence, err := seriesOccurrence(ctx, tx, seriesID, gameID)
+	if err != nil {
+		return err
+	}
+	rec, err := parseRecurrence(series.Recurrence)
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(series.Timezone)
+	if err != nil {
+		return &DatabaseError{Op: "load series time zone", Err: err}
+	}
+
+	if len(rec.occurrences(series.FirstStart, loc, occurrence)) <= 1 {
+		err = deleteSeries(ctx, tx, seriesID)
+	} else if err = endSeriesBefore(ctx, tx, seriesID, rec, occurrence); err == nil {
+		err = cancelSeriesGames(ctx, tx, seriesID, occurrence)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return &DatabaseError{Op: "commit series cancellation", Err: err}
+	}
+	return nil
+}
+
+// Delete cancels the upcoming occurrences of a series on behalf of callerID,
+// who must be the host, and deletes the series. Its games are kept.
+func (r *SeriesRepository) Delete(ctx context.Context, seriesID, callerID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	series, err := getSeries(ctx, tx, seriesID, true)
+	if err != nil {
+		return err
+	}
+	if series.HostID != callerID {
+		return fmt.Errorf("only the host can delete this series: %w", ErrForbidden)
+	}
+	if err := deleteSeries(ctx, tx, seriesID); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return &DatabaseError{Op: "commit series delete", Err: err}
+	}
+	return nil
+}
+
+// MaterializeAll creates the occurrences of every series up to the horizon,
+// and returns how many games were created. Occurrences whose court is
+// already booked are skipped.
+func (r *SeriesRepository) MaterializeAll(ctx context.Context) (int64, error) {
+	until := time.Now().Add(r.horizon)
+	rows, err := r.db.Query(ctx, `
+		SELECT series_id FROM game_series
+		WHERE materialized_until IS NULL OR materialized_until < $1
+		ORDER BY series_id
+	`, until)
+	if err != nil {
+		return 0, &DatabaseError{Op: "list series to materialize", Err: err}
+	}
+	var seriesIDs []string
+	for rows.Next() {
+		var seriesID string
+		if err := rows.Scan(&seriesID); err != nil {
+			rows.Close()
+			return 0, &DatabaseError{Op: "scan series", Err: err}
+		}
+		seriesIDs = append(seriesIDs, seriesID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, &DatabaseError{Op: "list series to materialize", Err: err}
+	}
+
+	var created int64
+	for _, seriesID := range seriesIDs {
+		n, err := r.materialize(ctx, seriesID, until)
+		if err != nil {
+			return created, err
+		}
+		created += n
+	}
+	return created, nil
+}
+
+// materialize creates the occurrences of one series up to until in its own
+// transaction. A series deleted meanwhile is skipped.
+func (r *SeriesRepository) materialize(ctx context.Context, seriesID string, until time.Time) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	series, err := getSeries(ctx, tx, seriesID, true)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	created, err := materializeSeries(ctx, tx, series, until, false, r.games.reminderOffsets)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, &DatabaseError{Op: "commit series materialization", Err: err}
+	}
+	return created, nil
+}
+
+// moveFollowing moves the games of a series from the occurrence on to the
+// target series, pairing each with the occurrence of the new schedule in the
+// same week and place within the week, counted from the edited occurrence
+// and the new start respectively. Paired games get the edit, and with retime
+// the new times; unpaired games are cancelled. Returns the ID of the edited
+// occurrence's game and the occurrences of the new schedule, up to what the
+// old one had created, in slots the old schedule did not have. Slots whose
+// occurrence was cancelled stay cancelled.
+func (r *SeriesRepository) moveFollowing(ctx context.Context, tx pgx.Tx, series *models.GameSeries, targetID string,
+	occurrence, start time.Time, duration time.Duration, newRec *recurrence, loc *time.Location,
+	retime bool, req models.UpdateGameRequest) (string, []time.Time, error) {
+	rec, err := parseRecurrence(series.Recurrence)
+	if err != nil {
+		return "", nil, err
+	}
+	bound := occurrence
+	if series.MaterializedUntil != nil && series.MaterializedUntil.After(bound) {
+		bound = *series.MaterializedUntil
+	}
+
+	// Slots of the old schedule, by occurrence
+	oldSlots := map[int64]occurrenceSlot{}
+	hadSlot := map[occurrenceSlot]bool{}
+	var oldStarts []time.Time
+	for _, s := range rec.occurrences(series.FirstStart, loc, bound) {
+		if !s.Before(occurrence) {
+			oldStarts = append(oldStarts, s)
+		}
+	}
+	lastWeek := 0
+	for i, slot := range occurrenceSlots(oldStarts, loc) {
+		oldSlots[oldStarts[i].UnixNano()] = slot
+		hadSlot[slot] = true
+		lastWeek = slot.week
+	}
+
+	// Occurrences of the new schedule, by slot
+	newStarts := newRec.occurrences(start, loc, weekStart(start, loc).AddDate(0, 0, 7*(lastWeek+1)))
+	newBySlot := map[occurrenceSlot]time.Time{}
+	var fresh []time.Time
+	for i, slot := range occurrenceSlots(newStarts, loc) {
+		newBySlot[slot] = newStarts[i]
+		if !hadSlot[slot] && !newStarts[i].After(bound) {
+			fresh = append(fresh, newStarts[i])
+		}
+	}
+
+	games, err := lockSeriesGames(ctx, tx, series.SeriesID, occurrence)
+	if err != nil {
+		return "", nil, err
+	}
+	// Clear the slots first so games can swap occurrences without tripping
+	// the unique index
+	if _, err := tx.Exec(ctx, `
+		UPDATE games SET series_occurrence = NULL WHERE series_id = $1 AND series_occurrence >= $2
+	`, series.SeriesID, occurrence); err != nil {
+		return "", nil, &DatabaseError{Op: "clear series occurrences", Err: err}
+	}
+
+	var editedID string
+	for _, g := range games {
+		slot, known := oldSlots[g.occurrence.UnixNano()]
+		newStart, paired := newBySlot[slot]
+		if !known || !paired {
+			if err := cancelGame(ctx, tx, g.game, models.CancellationScheduleChanged, nil); err != nil {
+				return "", nil, err
+			}
+			continue
+		}
+
+		gameReq := req
+		gameReq.StartTime, gameReq.EndTime = nil, nil
+		if retime {
+			end := newStart.Add(duration)
+			gameReq.StartTime, gameReq.EndTime = &newStart, &end
+		}
+		if _, err := r.games.update(ctx, tx, g.game, gameReq); err != nil {
+			return "", nil, err
+		}
+		_, err := tx.Exec(ctx, `UPDATE games SET series_id = $2, series_occurrence = $3 WHERE game_id = $1`,
+			g.game.GameID, targetID, newStart)
+		if err != nil {
+			return "", nil, &DatabaseError{Op: "move series game", Err: err}
+		}
+		if g.occurrence.Equal(occurrence) {
+			editedID = g.game.GameID
+		}
+	}
+	return editedID, fresh, nil
+}
+
+// materializeSeries creates the occurrences of a series after its
+// materialized_until up to until as games, skipping past occurrences and, if
+// the series skips them, holidays. With strict set a court already booked at
+// an occurrence is a conflict; otherwise the occurrence is skipped. Returns
+// how many games were created.
+func materializeSeries(ctx context.Context, tx pgx.Tx, series *models.GameSeries, until time.Time, strict bool, reminderOffsets []time.Duration) (int64, error) {
+	rec, err := parseRecurrence(series.Recurrence)
+	if err != nil {
+		return 0, err
+	}
+	loc, err := time.LoadLocation(series.Timezone)
+	if err != nil {
+		return 0, &DatabaseError{Op: "load series time zone", Err: err}
+	}
+	if until.Before(series.FirstStart) {
+		until = series.FirstStart
+	}
+	holidays, err := seriesHolidays(ctx, tx, series, loc, until)
+	if err != nil {
+		return 0, err
+	}
+
+	duration := time.Duration(series.DurationMinutes) * time.Minute
+	var created int64
+	for _, start := range rec.occurrences(series.FirstStart, loc, until) {
+		switch {
+		case series.MaterializedUntil != nil && !start.After(*series.MaterializedUntil),
+			!start.After(time.Now()),
+			holidays[start.In(loc).Format("2006-01-02")]:
+			continue
+		}
+		end := start.Add(duration)
+		if strict && series.CourtID != nil {
+			if err := checkCourtAvailable(ctx, tx, *series.CourtID, start, end, ""); err != nil {
+				return 0, err
+			}
+		}
+		n, err := insertOccurrence(ctx, tx, series.SeriesID, start, end, reminderOffsets)
+		if err != nil {
+			return 0, err
+		}
+		created += n
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE game_series SET materialized_until = $2
+		WHERE series_id = $1 AND (materialized_until IS NULL OR materialized_until < $2)
+	`, series.SeriesID, until)
+	if err != nil {
+		return 0, &DatabaseError{Op: "advance series materialization", Err: err}
+	}
+	if series.MaterializedUntil == nil || series.MaterializedUntil.Before(until) {
+		series.MaterializedUntil = &until
+	}
+	return created, nil
+}
+
+// insertOccurrence creates the game of one occurrence from the series
+// template and schedules its reminders. An occurrence that already has a
+// game, or whose court is booked, is skipped. Returns how many games were
+// created.
+func insertOccurrence(ctx context.Context, q querier, seriesID string, start, end time.Time, reminderOffsets []time.Duration) (int64, error) {
+	var gameID string
+	err := q.QueryRow(ctx, `
+		INSERT INTO games (host_id, sport_name, title, description, start_time, end_time, location, latitude, longitude,
+			venue_id, court_id, skill_range, capacity, skill_level, visibility, series_id, series_occurrence)
+		SELECT host_id, sport_name, title, description, $2, $3, location, latitude, longitude,
+			venue_id, court_id, skill_range, capacity, skill_level, visibility, series_id, $2
+		FROM game_series WHERE series_id = $1
+		ON CONFLICT DO NOTHING
+		RETURNING game_id
+	`, seriesID, start, end).Scan(&gameID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, translateGameWriteError("create series game", err)
+	}
+	if err := scheduleGameReminders(ctx, q, gameID, start, reminderOffsets); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// seriesHolidays returns the holidays up to until a series skips, as
+// YYYY-MM-DD dates, or nothing when it does not skip holidays
+func seriesHolidays(ctx context.Context, q querier, series *models.GameSeries, loc *time.Location, until time.Time) (map[string]bool, error) {
+	if !series.SkipHolidays {
+		return map[string]bool{}, nil
+	}
+	return holidaysBetween(ctx, q, time.Now().In(loc).Format("2006-01-02"), until.In(loc).Format("2006-01-02"))
+}
+
+// endSeriesBefore ends a series right before one of its occurrences
+func endSeriesBefore(ctx context.Context, q querier, seriesID string, rec *recurrence, occurrence time.Time) error {
+	ended := *rec
+	until := occurrence.Add(-time.Second)
+	ended.until, ended.untilDate, ended.count = &until, "", 0
+	if _, err := q.Exec(ctx, `UPDATE game_series SET rrule = $2 WHERE series_id = $1`, seriesID, ended.String()); err != nil {
+		return &DatabaseError{Op: "end series", Err: err}
+	}
+	return nil
+}
+
+// cancelSeriesGames cancels the games of a series from the occurrence on
+// that have not started
+func cancelSeriesGames(ctx context.Context, tx pgx.Tx, seriesID string, from time.Time) error {
+	games, err := lockSeriesGames(ctx, tx, seriesID, from)
+	if err != nil {
+		return err
+	}
+	for _, g := range games {
+		if g.game.StartTime.After(time.Now()) {
+			if err := cancelGame(ctx, tx, g.game, models.CancellationScheduleChanged, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteSeries cancels the upcoming games of a series and deletes it. The
+// games stay, detached from the series.
+func deleteSeries(ctx context.Context, tx pgx.Tx, seriesID string) error {
+	if err := cancelSeriesGames(ctx, tx, seriesID, time.Time{}); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM game_series WHERE series_id = $1`, seriesID); err != nil {
+		return &DatabaseError{Op: "delete series", Err: err}
+	}
+	return nil
+}
+
+// seriesGame is a game of a series with the occurrence it was created for
+type seriesGame struct {
+	game       *models.Game
+	occurrence time.Time
+}
+
+// lockSeriesGames locks and loads the games of a series from the occurrence
+// on that are not cancelled, in occurrence order
+func lockSeriesGames(ctx context.Context, tx pgx.Tx, seriesID string, from time.Time) ([]seriesGame, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT game_id, series_occurrence FROM games
+		WHERE series_id = $1 AND series_occurrence >= $2 AND status <> 'cancelled'
+		ORDER BY series_occurrence
+		FOR UPDATE
+	`, seriesID, from)
+	if err != nil {
+		return nil, &DatabaseError{Op: "lock series games", Err: err}
+	}
+	var locked []seriesGame
+	var gameIDs []string
+	for rows.Next() {
+		var gameID string
+		var g seriesGame
+		if err := rows.Scan(&gameID, &g.occurrence); err != nil {
+			rows.Close()
+			return nil, &DatabaseError{Op: "scan series game", Err: err}
+		}
+		locked = append(locked, g)
+		gameIDs = append(gameIDs, gameID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "lock series games", Err: err}
+	}
+
+	for i, gameID := range gameIDs {
+		if locked[i].game, err = getGame(ctx, tx, gameID, false); err != nil {
+			return nil, err
+		}
+	}
+	return locked, nil
+}
+
+// seriesOccurrence returns the occurrence a game of the series was created
+// for. It must not have started yet or been cancelled.
+func seriesOccurrence(ctx context.Context, q querier, seriesID, gameID string) (time.Time, error) {
+	var occurrence time.Time
+	var status string
+	err := q.QueryRow(ctx, `
+		SELECT series_occurrence, status FROM games WHERE game_id = $1 AND series_id = $2 AND series_occurrence IS NOT NULL
+	`, gameID, seriesID).Scan(&occurrence, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, &NotFoundError{Resource: "game", ID: gameID}
+		}
+		return time.Time{}, &DatabaseError{Op: "get series occurrence", Err: err}
+	}
+	if !occurrence.After(time.Now()) {
+		return time.Time{}, &ConflictError{Message: "occurrences that already started cannot be changed"}
+	}
+	if status == models.GameStatusCancelled {
+		return time.Time{}, &ConflictError{Message: "cancelled occurrences cannot be changed"}
+	}
+	return occurrence, nil
+}
+
+// seriesDuration returns the length of the occurrences of a series
+func seriesDuration(start, end time.Time) (time.Duration, error) {
+	if err := validateTimeRange(start, end); err != nil {
+		return 0, err
+	}
+	duration := end.Sub(start)
+	if duration%time.Minute != 0 {
+		return 0, &ValidationError{Field: "end_time", Message: "must be a whole number of minutes after start_time"}
+	}
+	return duration, nil
+}
+
+// seriesTemplateColumns returns the game_series columns set by a game edit
+func seriesTemplateColumns(req models.UpdateGameRequest) []string {
+	var columns []string
+	add := func(set bool, names ...string) {
+		if set {
+			columns = append(columns, names...)
+		}
+	}
+	add(req.Title != nil, "title")
+	add(req.Description != nil, "description")
+	add(req.Location != nil, "location")
+	add(req.Latitude != nil || req.Longitude != nil, "latitude", "longitude")
+	add(req.VenueID != nil || req.CourtID != nil, "venue_id", "court_id")
+	add(req.SkillRange != nil, "skill_range")
+	add(req.Capacity != nil, "capacity")
+	add(req.SkillLevel != nil, "skill_level")
+	add(req.Visibility != nil, "visibility")
+	return columns
+}
+
+// occurrenceSlot places an occurrence by the week it falls in, counted from
+// the week of the first occurrence, and its place within that week
+type occurrenceSlot struct {
+	week, place int
+}
+
+// occurrenceSlots returns the slot of each of the ordered occurrences
+func occurrenceSlots(starts []time.Time, loc *time.Location) []occurrenceSlot {
+	slots := make([]occurrenceSlot, len(starts))
+	if len(starts) == 0 {
+		return slots
+	}
+	first := weekStart(starts[0], loc)
+	for i, start := range starts {
+		week := int(weekStart(start, loc).Sub(first).Hours()+12) / (7 * 24)
+		slots[i] = occurrenceSlot{week: week}
+		if i > 0 && slots[i-1].week == week {
+			slots[i].place = slots[i-1].place + 1
+		}
+	}
+	return slots
+}
+
+// weekStart returns the local midnight starting the Monday of t's week
+func weekStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day()-mondayOffset(t.Weekday()), 0, 0, 0, 0, loc)
+}
+
+// getSeries loads a series, optionally locking its row for the rest of the
+// transaction
+func getSeries(ctx context.Context, q querier, seriesID string, forUpdate bool) (*models.GameSeries, error) {
+	query := `SELECT ` + seriesColumns + ` FROM game_series s WHERE s.series_id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	var series models.GameSeries
+	if err := q.QueryRow(ctx, query, seriesID).Scan(seriesFields(&series)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "series", ID: seriesID}
+		}
+		return nil, &DatabaseError{Op: "get series", Err: err}
+	}
+	return &series, nil
+}
+
+// seriesFields returns the scan destinations matching seriesColumns
+func seriesFields(s *models.GameSeries) []interface{} {
+	return []interface{}{
+		&s.SeriesID,
+		&s.HostID,
+		&s.SportName,
+		&s.Title,
+		&s.Description,
+		&s.Location,
+		&s.Latitude,
+		&s.Longitude,
+		&s.VenueID,
+		&s.CourtID,
+		&s.SkillRange,
+		&s.Capacity,
+		&s.SkillLevel,
+		&s.Visibility,
+		&s.FirstStart,
+		&s.DurationMinutes,
+		&s.Timezone,
+		&s.Recurrence,
+		&s.SkipHolidays,
+		&s.MaterializedUntil,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	}
+}
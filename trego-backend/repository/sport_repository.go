@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxBlockingGames caps how many blocking games, and how many blocking
+// series, a delete conflict lists
+const maxBlockingGames = 50
+
+// BlockingGame is a game that prevents its sport from being deleted
+type BlockingGame struct {
+	GameID    string    `json:"game_id"`
+	Title     string    `json:"title"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// BlockingSeries is a game series that prevents its sport from being deleted
+type BlockingSeries struct {
+	SeriesID   string    `json:"series_id"`
+	Title      string    `json:"title"`
+	FirstStart time.Time `json:"first_start"`
+}
+
+// SportDeleteConflict describes why a sport cannot be deleted
+type SportDeleteConflict struct {
+	BlockingGames  []BlockingGame   `json:"blocking_games"`
+	TotalGames     int              `json:"total_games"`
+	BlockingSeries []BlockingSeries `json:"blocking_series"`
+	TotalSeries    int              `json:"total_series"`
+}
+
+// SportRepository provides access to the sports catalog
+type SportRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewSportRepository creates a new sport repository
+func NewSportRepository(db *pgxpool.Pool) *SportRepository {
+	return &SportRepository{db: db}
+}
+
+// List returns every sport ordered by name
+func (r *SportRepository) List(ctx context.Context) ([]models.Sport, error) {
+	rows, err := r.db.Query(ctx, `SELECT sport_name, icon_url, created_at FROM sports ORDER BY sport_name`)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list sports", Err: err}
+	}
+	defer rows.Close()
+
+	sports := []models.Sport{}
+	for rows.Next() {
+		var s models.Sport
+		if err := rows.Scan(&s.SportName, &s.IconURL, &s.CreatedAt); err != nil {
+			return nil, &DatabaseError{Op: "scan sport", Err: err}
+		}
+		sports = append(sports, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list sports", Err: err}
+	}
+	return sports, nil
+}
+
+// Get returns a sport by name
+func (r *SportRepository) Get(ctx context.Context, sportName string) (*models.Sport, error) {
+	var s models.Sport
+	err := r.db.QueryRow(ctx, `SELECT sport_name, icon_url, created_at FROM sports WHERE sport_name = $1`, sportName).
+		Scan(&s.SportName, &s.IconURL, &s.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "sport", ID: sportName}
+		}
+		return nil, &DatabaseError{Op: "get sport", Err: err}
+	}
+	return &s, nil
+}
+
+// Create adds a sport to the catalog
+func (r *SportRepository) Create(ctx context.Context, req models.CreateSportRequest) (*models.Sport, error) {
+	name := strings.TrimSpace(req.SportName)
+	if name == "" {
+		return nil, &ValidationError{Field: "sport_name", Message: "must not be empty"}
+	}
+
+	var s models.Sport
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO sports (sport_name, icon_url)
+		VALUES ($1, $2)
+		RETURNING sport_name, icon_url, created_at
+	`, name, emptyToNil(req.IconURL)).Scan(&s.SportName, &s.IconURL, &s.CreatedAt)
+	if err != nil {
+		if pgErrorCode(err) == pgUniqueViolation {
+			return nil, &ConflictError{Message: fmt.Sprintf("sport %q already exists", name)}
+		}
+		return nil, &DatabaseError{Op: "create sport", Err: err}
+	}
+	return &s, nil
+}
+
+// Update changes a sport's icon. A nil icon is left unchanged; an empty one clears it.
+func (r *SportRepository) Update(ctx context.Context, sportName string, req models.UpdateSportRequest) (*models.Sport, error) {
+	if req.IconURL == nil {
+		return r.Get(ctx, sportName)
+	}
+
+	var s models.Sport
+	err := r.db.QueryRow(ctx, `
+		UPDATE sports SET icon_url = $1
+		WHERE sport_name = $2
+		RETURNING sport_name, icon_url, created_at
+	`, emptyToNil(req.IconURL), sportName).Scan(&s.SportName, &s.IconURL, &s.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "sport", ID: sportName}
+		}
+		return nil, &DatabaseError{Op: "update sport", Err: err}
+	}
+	return &s, nil
+}
+
+// Delete removes a sport from the catalog. games.sport_name and
+// game_series.sport_name are ON DELETE RESTRICT, so a sport that still has
+// games or series cannot be deleted; the returned ConflictError lists the
+// blocking games and series. user_sports rows cascade.
+func (r *SportRepository) Delete(ctx context.Context, sportName string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the sport so no game can reference it between the check and the delete
+	var locked string
+	err = tx.QueryRow(ctx, `SELECT sport_name FROM sports WHERE sport_name = $1 FOR UPDATE`, sportName).Scan(&locked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &NotFoundError{Resource: "sport", ID: sportName}
+		}
+		return &DatabaseError{Op: "lock sport", Err: err}
+	}
+
+	conflict, err := blockingGames(ctx, tx, sportName)
+	if err != nil {
+		return err
+	}
+	if err := addBlockingSeries(ctx, tx, sportName, conflict); err != nil {
+		return err
+	}
+	if conflict.TotalGames > 0 || conflict.TotalSeries > 0 {
+		return &ConflictError{
+			Message: fmt.Sprintf("sport %q is used by %d game(s) and %d series", sportName, conflict.TotalGames, conflict.TotalSeries),
+			Details: conflict,
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM sports WHERE sport_name = $1`, sportName); err != nil {
+		if pgErrorCode(err) == pgForeignKeyViolation {
+			return &ConflictError{Message: fmt.Sprintf("sport %q is used by existing games or series", sportName)}
+		}
+		return &DatabaseError{Op: "delete sport", Err: err}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return &DatabaseError{Op: "commit sport delete", Err: err}
+	}
+	return nil
+}
+
+// blockingGames returns the games that reference a sport
+func blockingGames(ctx context.Context, tx pgx.Tx, sportName string) (*SportDeleteConflict, error) {
+	conflict := &SportDeleteConflict{BlockingGames: []BlockingGame{}, BlockingSeries: []BlockingSeries{}}
+
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM games WHERE sport_name = $1`, sportName).Scan(&conflict.TotalGames); err != nil {
+		return nil, &DatabaseError{Op: "count games", Err: err}
+	}
+	if conflict.TotalGames == 0 {
+		return conflict, nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT game_id, title, start_time
+		FROM games
+		WHERE sport_name = $1
+		ORDER BY start_time DESC
+		LIMIT $2
+	`, sportName, maxBlockingGames)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list blocking games", Err: err}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g BlockingGame
+		if err := rows.Scan(&g.GameID, &g.Title, &g.StartTime); err != nil {
+			return nil, &DatabaseError{Op: "scan blocking game", Err: err}
+		}
+		conflict.BlockingGames = append(conflict.BlockingGames, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list blocking games", Err: err}
+	}
+	return conflict, nil
+}
+
+// addBlockingSeries adds the game series that reference a sport to conflict
+func addBlockingSeries(ctx context.Context, tx pgx.Tx, sportName string, conflict *SportDeleteConflict) error {
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM game_series WHERE sport_name = $1`, sportName).Scan(&conflict.TotalSeries); err != nil {
+		return &DatabaseError{Op: "count series", Err: err}
+	}
+	if conflict.TotalSeries == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT series_id, title, first_start
+		FROM game_series
+		WHERE sport_name = $1
+		ORDER BY first_start DESC
+		LIMIT $2
+	`, sportName, maxBlockingGames)
+	if err != nil {
+		return &DatabaseError{Op: "list blocking series", Err: err}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s BlockingSeries
+		if err := rows.Scan(&s.SeriesID, &s.Title, &s.FirstStart); err != nil {
+			return &DatabaseError{Op: "scan blocking series", Err: err}
+		}
+		conflict.BlockingSeries = append(conflict.BlockingSeries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return &DatabaseError{Op: "list blocking series", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"trego-backend/models"
+)
+
+const (
+	// teamDefaultSkill is the skill of players without a profile for the
+	// sport: intermediate
+	teamDefaultSkill = 2
+	// teamReputationCap is the reputation at which a player's reputation
+	// bonus stops growing
+	teamReputationCap = 50
+	// teamReputationWeight is the strength bonus of a player at the cap, so
+	// reputation only breaks ties between players of one skill level
+	teamReputationWeight = 0.5
+	// teamPositionWeight is how much an uneven spread of positions costs
+	// compared to uneven strength
+	teamPositionWeight = 0.5
+	// teamAttempts is how many randomized splits are built; the result is
+	// picked among the best of them
+	teamAttempts = 64
+	// teamTolerance is how much worse than the best split a split may be and
+	// still be picked, so shuffles vary
+	teamTolerance = 0.5
+	// teamImprovePasses caps the local search over each split
+	teamImprovePasses = 20
+)
+
+// teamSkillPoints converts skill levels to strength
+var teamSkillPoints = map[string]float64{"beginner": 1, "intermediate": 2, "advanced": 3}
+
+// playerStrength combines a player's skill level for the sport with a
+// bonus for reputation
+func playerStrength(skillLevel *string, reputation int) float64 {
+	skill := float64(teamDefaultSkill)
+	if skillLevel != nil {
+		if points, ok := teamSkillPoints[*skillLevel]; ok {
+			skill = points
+		}
+	}
+	bonus := teamReputationWeight * float64(min(max(reputation, 0), teamReputationCap)) / teamReputationCap
+	return math.Round((skill+bonus)*100) / 100
+}
+
+// teamUnit is a group of players that must play on the same team
+type teamUnit struct {
+	players     []int
+	strength    float64
+	front, back int
+}
+
+// teamBalancer splits players into teams of sizes differing by at most one,
+// keeping units together and conflicting units apart, and evens out the
+// teams' strength and positions
+type teamBalancer struct {
+	players   []models.TeamMember
+	units     []teamUnit
+	conflicts []map[int]bool // per unit, the units it must not share a team with
+	teamCount int
+	// minSize is the smallest team size; extra teams have one more player
+	minSize, extra int
+}
+
+// newTeamBalancer validates the constraints against the players and groups
+// the players into units
+func newTeamBalancer(players []models.TeamMember, teamCount int, together, apart [][]string) (*teamBalancer, error) {
+	if len(players) < teamCount {
+		return nil, &ValidationError{Field: "team_count", Message: fmt.Sprintf("needs at least %d players, the roster has %d", teamCount, len(players))}
+	}
+	index := make(map[string]int, len(players))
+	for i, p := range players {
+		index[p.UserID] = i
+	}
+	lookup := func(field, userID string) (int, error) {
+		i, ok := index[userID]
+		if !ok {
+			return 0, &ValidationError{Field: field, Message: fmt.Sprintf("user %q is not on the roster", userID)}
+		}
+		return i, nil
+	}
+
+	// Union the players kept together
+	parent := make([]int, len(players))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, group := range together {
+		for j, userID := range group {
+			i, err := lookup("keep_together", userID)
+			if err != nil {
+				return nil, err
+			}
+			if j > 0 {
+				first, _ := lookup("keep_together", group[0])
+				parent[find(i)] = find(first)
+			}
+		}
+	}
+
+	b := &teamBalancer{
+		players:   players,
+		teamCount: teamCount,
+		minSize:   len(players) / teamCount,
+		extra:     len(players) % teamCount,
+	}
+	maxSize := b.minSize
+	if b.extra > 0 {
+		maxSize++
+	}
+	unitOf := make([]int, len(players))
+	unitByRoot := map[int]int{}
+	for i, p := range players {
+		root := find(i)
+		u, ok := unitByRoot[root]
+		if !ok {
+			u = len(b.units)
+			unitByRoot[root] = u
+			b.units = append(b.units, teamUnit{})
+		}
+		unitOf[i] = u
+		unit := &b.units[u]
+		unit.players = append(unit.players, i)
+		unit.strength += p.Strength
+		switch {
+		case p.Position == nil:
+		case *p.Position == "front":
+			unit.front++
+		case *p.Position == "back":
+			unit.back++
+		}
+	}
+	for _, unit := range b.units {
+		if len(unit.players) > maxSize {
+			return nil, &ValidationError{Field: "keep_together", Message: fmt.Sprintf("a group of %d players does not fit teams of at most %d", len(unit.players), maxSize)}
+		}
+	}
+
+	b.conflicts = make([]map[int]bool, len(b.units))
+	for u := range b.conflicts {
+		b.conflicts[u] = map[int]bool{}
+	}
+	for _, group := range apart {
+		if len(group) > teamCount {
+			return nil, &ValidationError{Field: "keep_apart", Message: fmt.Sprintf("a group of %d players cannot be spread over %d teams", len(group), teamCount)}
+		}
+		for j, userID := range group {
+			i, err := lookup("keep_apart", userID)
+			if err != nil {
+				return nil, err
+			}
+			for _, otherID := range group[:j] {
+				other, _ := lookup("keep_apart", otherID)
+				if other == i {
+					continue
+				}
+				if unitOf[i] == unitOf[other] {
+					return nil, &ValidationError{Field: "keep_apart", Message: fmt.Sprintf("users %q and %q are also kept together", otherID, userID)}
+				}
+				b.conflicts[unitOf[i]][unitOf[other]] = true
+				b.conflicts[unitOf[other]][unitOf[i]] = true
+			}
+		}
+	}
+	return b, nil
+}
+
+// split returns the team of every player. It builds randomized splits and
+// picks one at random among those within teamTolerance of the best,
+// preferring ones that differ from avoid (a previous split, may be nil).
+func (b *teamBalancer) split(rng *rand.Rand, avoid []int) ([]int, error) {
+	type candidate struct {
+		teams []int
+		cost  float64
+	}
+	var candidates []candidate
+	best := math.Inf(1)
+	for attempt := 0; attempt < teamAttempts; attempt++ {
+		unitTeams := b.greedy(rng)
+		if unitTeams == nil {
+			continue
+		}
+		b.improve(unitTeams)
+		cost := b.cost(unitTeams)
+		candidates = append(candidates, candidate{teams: b.playerTeams(unitTeams), cost: cost})
+		best = math.Min(best, cost)
+	}
+	if len(candidates) == 0 {
+		return nil, &ValidationError{Field: "keep_apart", Message: fmt.Sprintf("the constraints cannot be met with %d teams", b.teamCount)}
+	}
+
+	var good, fresh [][]int
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if c.cost > best+teamTolerance {
+			continue
+		}
+		key := partitionKey(c.teams)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		good = append(good, c.teams)
+		if avoid == nil || key != partitionKey(avoid) {
+			fresh = append(fresh, c.teams)
+		}
+	}
+	if len(fresh) > 0 {
+		good = fresh
+	}
+	teams := good[rng.IntN(len(good))]
+	return b.numberTeams(teams), nil
+}
+
+// greedy places the units in random order, largest first, each on the
+// weakest team it fits. Returns the team of every unit, or nil if the
+// constraints left a unit without a team.
+func (b *teamBalancer) greedy(rng *rand.Rand) []int {
+	order := rng.Perm(len(b.units))
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(b.units[order[i]].players) > len(b.units[order[j]].players)
+	})
+
+	unitTeams := make([]int, len(b.units))
+	sizes := make([]int, b.teamCount)
+	strengths := make([]float64, b.teamCount)
+	full := 0
+	for _, u := range order {
+		size := len(b.units[u].players)
+		chosen := -1
+		for t := 0; t < b.teamCount; t++ {
+			switch newSize := sizes[t] + size; {
+			case newSize > b.minSize+1, newSize == b.minSize+1 && full >= b.extra:
+				continue
+			}
+			if b.conflictsWithTeam(u, t, unitTeams, order) {
+				continue
+			}
+			if chosen < 0 || strengths[t] < strengths[chosen] || (strengths[t] == strengths[chosen] && sizes[t] < sizes[chosen]) {
+				chosen = t
+			}
+		}
+		if chosen < 0 {
+			return nil
+		}
+		unitTeams[u] = chosen
+		sizes[chosen] += size
+		strengths[chosen] += b.units[u].strength
+		if sizes[chosen] == b.minSize+1 {
+			full++
+		}
+	}
+	return unitTeams
+}
+
+// conflictsWithTeam reports whether unit u must not join team t, given the
+// units placed before it in order
+func (b *teamBalancer) conflictsWithTeam(u, t int, unitTeams, order []int) bool {
+	for _, other := range order {
+		if other == u {
+			return false
+		}
+		if unitTeams[other] == t && b.conflicts[u][other] {
+			return true
+		}
+	}
+	return false
+}
+
+// improve swaps units of equal size between teams while that lowers the
+// cost. Units are never moved on their own, so team sizes stay as greedy
+// left them.
+func (b *teamBalancer) improve(unitTeams []int) {
+	for pass := 0; pass < teamImprovePasses; pass++ {
+		improved := false
+		cost := b.cost(unitTeams)
+		for a := range b.units {
+			for c := a + 1; c < len(b.units); c++ {
+				ta, tc := unitTeams[a], unitTeams[c]
+				if ta == tc || len(b.units[a].players) != len(b.units[c].players) {
+					continue
+				}
+				unitTeams[a], unitTeams[c] = tc, ta
+				if b.valid(unitTeams, a) && b.valid(unitTeams, c) {
+					if next := b.cost(unitTeams); next < cost-1e-9 {
+						cost = next
+						improved = true
+						continue
+					}
+				}
+				unitTeams[a], unitTeams[c] = ta, tc
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+// valid reports whether unit u shares its team with no conflicting unit
+func (b *teamBalancer) valid(unitTeams []int, u int) bool {
+	for other := range b.conflicts[u] {
+		if unitTeams[other] == unitTeams[u] {
+			return false
+		}
+	}
+	return true
+}
+
+// cost measures how uneven the teams are: the squared deviations of their
+// strengths from the mean, plus those of their front and back players
+// weighted by teamPositionWeight
+func (b *teamBalancer) cost(unitTeams []int) float64 {
+	strengths := make([]float64, b.teamCount)
+	fronts := make([]float64, b.teamCount)
+	backs := make([]float64, b.teamCount)
+	var total, totalFront, totalBack float64
+	for u, t := range unitTeams {
+		unit := b.units[u]
+		strengths[t] += unit.strength
+		fronts[t] += float64(unit.front)
+		backs[t] += float64(unit.back)
+		total += unit.strength
+		totalFront += float64(unit.front)
+		totalBack += float64(unit.back)
+	}
+	n := float64(b.teamCount)
+	var cost float64
+	for t := 0; t < b.teamCount; t++ {
+		cost += math.Pow(strengths[t]-total/n, 2)
+		cost += teamPositionWeight * (math.Pow(fronts[t]-totalFront/n, 2) + math.Pow(backs[t]-totalBack/n, 2))
+	}
+	return cost
+}
+
+// playerTeams expands the team of every unit to the team of every player
+func (b *teamBalancer) playerTeams(unitTeams []int) []int {
+	teams := make([]int, len(b.players))
+	for u, unit := range b.units {
+		for _, p := range unit.players {
+			teams[p] = unitTeams[u]
+		}
+	}
+	return teams
+}
+
+// numberTeams renumbers teams from strongest to weakest
+func (b *teamBalancer) numberTeams(teams []int) []int {
+	strengths := make([]float64, b.teamCount)
+	for p, t := range teams {
+		strengths[t] += b.players[p].Strength
+	}
+	order := make([]int, b.teamCount)
+	for t := range order {
+		order[t] = t
+	}
+	sort.SliceStable(order, func(i, j int) bool { return strengths[order[i]] > strengths[order[j]] })
+	number := make([]int, b.teamCount)
+	for n, t := range order {
+		number[t] = n
+	}
+	numbered := make([]int, len(teams))
+	for p, t := range teams {
+		numbered[p] = number[t]
+	}
+	return numbered
+}
+
+// partitionKey identifies which players share a team, whatever the team
+// numbers
+func partitionKey(teams []int) string {
+	label := map[int]int{}
+	var key strings.Builder
+	for _, t := range teams {
+		l, ok := label[t]
+		if !ok {
+			l = len(label)
+			label[t] = l
+		}
+		fmt.Fprintf(&key, "%d,", l)
+	}
+	return key.String()
+}
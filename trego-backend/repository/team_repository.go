@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TeamRepository provides access to the team splits of games
+type TeamRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewTeamRepository creates a new team repository
+func NewTeamRepository(db *pgxpool.Pool) *TeamRepository {
+	return &TeamRepository{db: db}
+}
+
+// teamSplitGame loads and locks a game whose teams callerID, who must be the
+// host, is about to change
+func teamSplitGame(ctx context.Context, tx pgx.Tx, gameID, callerID, action string) (*models.Game, error) {
+	game, err := getGame(ctx, tx, gameID, true)
+	if err != nil {
+		return nil, err
+	}
+	if game.HostID != callerID {
+		return nil, fmt.Errorf("only the host can %s: %w", action, ErrForbidden)
+	}
+	if err := requireGameStatus(game, "be split into teams", models.GameStatusScheduled, models.GameStatusInProgress); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// Generate splits the game's roster into balanced teams on behalf of
+// callerID, who must be the host, replacing any previous split
+func (r *TeamRepository) Generate(ctx context.Context, gameID, callerID string, req models.GenerateTeamsRequest) (*models.TeamSplit, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := teamSplitGame(ctx, tx, gameID, callerID, "split this game into teams")
+	if err != nil {
+		return nil, err
+	}
+	members, _, err := teamMembers(ctx, tx, game)
+	if err != nil {
+		return nil, err
+	}
+	split := &models.TeamSplit{
+		GameID:       gameID,
+		TeamCount:    req.TeamCount,
+		KeepTogether: req.KeepTogether,
+		KeepApart:    req.KeepApart,
+	}
+	if err := saveTeamSplit(ctx, tx, split, callerID, members, nil); err != nil {
+		return nil, err
+	}
+
+	saved, err := getTeamSplit(ctx, tx, game)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit team split", Err: err}
+	}
+	return saved, nil
+}
+
+// Shuffle splits the game's current roster again with the team count and
+// constraints of its split, preferring a split different from the current
+// one. Constraints on players who left are dropped.
+func (r *TeamRepository) Shuffle(ctx context.Context, gameID, callerID string) (*models.TeamSplit, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	game, err := teamSplitGame(ctx, tx, gameID, callerID, "shuffle the teams of this game")
+	if err != nil {
+		return nil, err
+	}
+	split, err := getTeamSplitRow(ctx, tx, gameID)
+	if err != nil {
+		return nil, err
+	}
+	members, current, err := teamMembers(ctx, tx, game)
+	if err != nil {
+		return nil, err
+	}
+
+	// Avoid the current split only if it still covers the whole roster
+	var avoid []int
+	for i, team := range current {
+		if team == nil {
+			avoid = nil
+			break
+		}
+		if avoid == nil {
+			avoid = make([]int, len(current))
+		}
+		avoid[i] = *team
+	}
+	onRoster := make(map[string]bool, len(members))
+	for _, m := range members {
+		onRoster[m.UserID] = true
+	}
+	split.KeepTogether = rosterGroups(split.KeepTogether, onRoster)
+	split.KeepApart = rosterGroups(split.KeepApart, onRoster)
+	if err := saveTeamSplit(ctx, tx, split, callerID, members, avoid); err != nil {
+		return nil, err
+	}
+
+	saved, err := getTeamSplit(ctx, tx, game)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit team split", Err: err}
+	}
+	return saved, nil
+}
+
+// Get returns the team split of a game with the players who joined since
+func (r *TeamRepository) Get(ctx context.Context, gameID string) (*models.TeamSplit, error) {
+	game, err := getGame(ctx, r.db, gameID, false)
+	if err != nil {
+		return nil, err
+	}
+	return getTeamSplit(ctx, r.db, game)
+}
+
+// Delete removes the team split of a game on behalf of callerID, who must be
+// the host
+func (r *TeamRepository) Delete(ctx context.Context, gameID, callerID string) error {
+	game, err := getGame(ctx, r.db, gameID, false)
+	if err != nil {
+		return err
+	}
+	if game.HostID != callerID {
+		return fmt.Errorf("only the host can delete the teams of this game: %w", ErrForbidden)
+	}
+
+	tag, err := r.db.Exec(ctx, `DELETE FROM game_team_splits WHERE game_id = $1`, gameID)
+	if err != nil {
+		return &DatabaseError{Op: "delete team split", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return &NotFoundError{Resource: "team split", ID: gameID}
+	}
+	return nil
+}
+
+// saveTeamSplit balances the players with the split's team count and
+// constraints and stores the result, replacing the previous split. avoid is
+// the 0-based team of every player in a split to steer away from, or nil.
+func saveTeamSplit(ctx context.Context, tx pgx.Tx, split *models.TeamSplit, callerID string, members []models.TeamMember, avoid []int) error {
+	if split.KeepTogether == nil {
+		split.KeepTogether = [][]string{}
+	}
+	if split.KeepApart == nil {
+		split.KeepApart = [][]string{}
+	}
+
+	balancer, err := newTeamBalancer(members, split.TeamCount, split.KeepTogether, split.KeepApart)
+	if err != nil {
+		return err
+	}
+	rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	teams, err := balancer.split(rng, avoid)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO game_team_splits (game_id, team_count, keep_together, keep_apart, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (game_id) DO UPDATE SET
+			team_count = EXCLUDED.team_count,
+			keep_together = EXCLUDED.keep_together,
+			keep_apart = EXCLUDED.keep_apart,
+			created_by = EXCLUDED.created_by
+	`, split.GameID, split.TeamCount, split.KeepTogether, split.KeepApart, callerID)
+	if err != nil {
+		return &DatabaseError{Op: "save team split", Err: err}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM game_team_members WHERE game_id = $1`, split.GameID); err != nil {
+		return &DatabaseError{Op: "clear team members", Err: err}
+	}
+
+	userIDs := make([]string, len(members))
+	numbers := make([]int, len(members))
+	for i, m := range members {
+		userIDs[i] = m.UserID
+		numbers[i] = teams[i] + 1
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO game_team_members (game_id, user_id, team_number)
+		SELECT $1, m.user_id, m.team_number FROM UNNEST($2::text[], $3::int[]) AS m(user_id, team_number)
+	`, split.GameID, userIDs, numbers)
+	if err != nil {
+		return &DatabaseError{Op: "save team members", Err: err}
+	}
+	return nil
+}
+
+// getTeamSplitRow loads the stored split of a game without its teams
+func getTeamSplitRow(ctx context.Context, q querier, gameID string) (*models.TeamSplit, error) {
+	var split models.TeamSplit
+	err := q.QueryRow(ctx, `
+		SELECT game_id, team_count, keep_together, keep_apart, created_by, created_at, updated_at
+		FROM game_team_splits WHERE game_id = $1
+	`, gameID).Scan(&split.GameID, &split.TeamCount, &split.KeepTogether, &split.KeepApart,
+		&split.CreatedBy, &split.CreatedAt, &split.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "team split", ID: gameID}
+		}
+		return nil, &DatabaseError{Op: "get team split", Err: err}
+	}
+	return &split, nil
+}
+
+// getTeamSplit loads the split of a game with its teams. Players who joined
+// after the split are listed as unassigned.
+func getTeamSplit(ctx context.Context, q querier, game *models.Game) (*models.TeamSplit, error) {
+	split, err := getTeamSplitRow(ctx, q, game.GameID)
+	if err != nil {
+		return nil, err
+	}
+	members, current, err := teamMembers(ctx, q, game)
+	if err != nil {
+		return nil, err
+	}
+
+	split.Teams = make([]models.Team, split.TeamCount)
+	for i := range split.Teams {
+		split.Teams[i] = models.Team{Number: i + 1, Players: []models.TeamMember{}}
+	}
+	split.Unassigned = []models.TeamMember{}
+	for i, m := range members {
+		if current[i] == nil || *current[i] >= split.TeamCount {
+			split.Unassigned = append(split.Unassigned, m)
+			continue
+		}
+		team := &split.Teams[*current[i]]
+		team.Players = append(team.Players, m)
+		team.Strength += m.Strength
+		if m.Position != nil {
+			switch *m.Position {
+			case "front":
+				team.Front++
+			case "back":
+				team.Back++
+			}
+		}
+	}
+	for i := range split.Teams {
+		split.Teams[i].Strength = math.Round(split.Teams[i].Strength*100) / 100
+	}
+	return split, nil
+}
+
+// teamMembers loads the roster of a game rated for team balancing, in join
+// order, with the 0-based team each player is on in the stored split (nil if
+// none)
+func teamMembers(ctx context.Context, q querier, game *models.Game) ([]models.TeamMember, []*int, error) {
+	rows, err := q.Query(ctx, `
+		SELECT u.user_id, u.name, us.skill_level, us.position, u.reputation, tm.team_number
+		FROM game_players gp
+		JOIN users u ON u.user_id = gp.user_id
+		LEFT JOIN user_sports us ON us.user_id = gp.user_id AND us.sport_name = $2
+		LEFT JOIN game_team_members tm ON tm.game_id = gp.game_id AND tm.user_id = gp.user_id
+		WHERE gp.game_id = $1
+		ORDER BY gp.joined_at, gp.user_id
+	`, game.GameID, game.SportName)
+	if err != nil {
+		return nil, nil, &DatabaseError{Op: "list team members", Err: err}
+	}
+	defer rows.Close()
+
+	members := []models.TeamMember{}
+	var teams []*int
+	for rows.Next() {
+		var m models.TeamMember
+		var team *int
+		if err := rows.Scan(&m.UserID, &m.Name, &m.SkillLevel, &m.Position, &m.Reputation, &team); err != nil {
+			return nil, nil, &DatabaseError{Op: "scan team member", Err: err}
+		}
+		m.Strength = playerStrength(m.SkillLevel, m.Reputation)
+		if team != nil {
+			*team--
+		}
+		members = append(members, m)
+		teams = append(teams, team)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, &DatabaseError{Op: "list team members", Err: err}
+	}
+	return members, teams, nil
+}
+
+// rosterGroups drops the users not on the roster from constraint groups, and
+// the groups left with fewer than two users
+func rosterGroups(groups [][]string, onRoster map[string]bool) [][]string {
+	kept := [][]string{}
+	for _, group := range groups {
+		var members []string
+		for _, userID := range group {
+			if onRoster[userID] {
+				members = append(members, userID)
+			}
+		}
+		if len(members) >= 2 {
+			kept = append(kept, members)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// userColumns is the column list matching scanUser. It leaves out the home
+// coordinates, which only ownUserColumns selects.
+const userColumns = `user_id, name, email, picture_url, phone_number, location, reputation, created_at, updated_at`
+
+// ownUserColumns is the column list matching scanOwnUser, for returning a
+// user's record to the user themselves
+const ownUserColumns = userColumns + `, latitude, longitude`
+
+// UserRepository provides access to the users table
+type UserRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewUserRepository creates a new user repository
+func NewUserRepository(db *pgxpool.Pool) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// UpsertByEmail creates the user with the given email or refreshes the name
+// and picture of the existing one. It is used by the login flow, where the
+// identity provider is the source of truth for these fields.
+func (r *UserRepository) UpsertByEmail(ctx context.Context, name, email string, pictureURL *string) (*models.User, error) {
+	query := `
+		INSERT INTO users (name, email, picture_url)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (email) DO UPDATE
+		SET name = EXCLUDED.name,
+		    picture_url = COALESCE(EXCLUDED.picture_url, users.picture_url)
+		RETURNING ` + userColumns
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, name, email, pictureURL))
+	if err != nil {
+		return nil, &DatabaseError{Op: "upsert user", Err: err}
+	}
+	return user, nil
+}
+
+// Create inserts a new user. It returns a ConflictError if the email is taken.
+func (r *UserRepository) Create(ctx context.Context, req models.CreateUserRequest) (*models.User, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, &ValidationError{Field: "name", Message: "must not be empty"}
+	}
+	if err := validateCoordinates(req.Latitude, req.Longitude); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO users (name, email, picture_url, phone_number, location, latitude, longitude)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + userColumns
+
+	user, err := scanUser(r.db.QueryRow(ctx, query,
+		name,
+		strings.ToLower(strings.TrimSpace(req.Email)),
+		emptyToNil(req.PictureURL),
+		emptyToNil(req.PhoneNumber),
+		emptyToNil(req.Location),
+		req.Latitude,
+		req.Longitude,
+	))
+	if err != nil {
+		if pgErrorCode(err) == pgUniqueViolation {
+			return nil, &ConflictError{Message: "a user with this email already exists"}
+		}
+		return nil, &DatabaseError{Op: "create user", Err: err}
+	}
+	return user, nil
+}
+
+// GetByID returns the user with the given ID, without their home coordinates
+func (r *UserRepository) GetByID(ctx context.Context, userID string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE user_id = $1`
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "user", ID: userID}
+		}
+		return nil, &DatabaseError{Op: "get user", Err: err}
+	}
+	return user, nil
+}
+
+// GetOwn returns the user with the given ID including their home
+// coordinates, for showing the user their own record
+func (r *UserRepository) GetOwn(ctx context.Context, userID string) (*models.User, error) {
+	query := `SELECT ` + ownUserColumns + ` FROM users WHERE user_id = $1`
+
+	user, err := scanOwnUser(r.db.QueryRow(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "user", ID: userID}
+		}
+		return nil, &DatabaseError{Op: "get user", Err: err}
+	}
+	return user, nil
+}
+
+// Update applies a partial update. Nil fields are left unchanged; empty
+// strings clear the optional fields. Clearing the location also clears the
+// coordinates. The updated user is returned with their home coordinates.
+func (r *UserRepository) Update(ctx context.Context, userID string, req models.UpdateUserRequest) (*models.User, error) {
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, &ValidationError{Field: "name", Message: "must not be empty"}
+		}
+		set("name", name)
+	}
+	if req.PictureURL != nil {
+		set("picture_url", emptyToNil(req.PictureURL))
+	}
+	if req.PhoneNumber != nil {
+		set("phone_number", emptyToNil(req.PhoneNumber))
+	}
+	if req.Location != nil {
+		location := emptyToNil(req.Location)
+		set("location", location)
+		if location == nil && req.Latitude == nil {
+			set("latitude", nil)
+			set("longitude", nil)
+		}
+	}
+	if req.Latitude != nil || req.Longitude != nil {
+		if err := validateCoordinates(req.Latitude, req.Longitude); err != nil {
+			return nil, err
+		}
+		set("latitude", *req.Latitude)
+		set("longitude", *req.Longitude)
+	}
+
+	if len(sets) == 0 {
+		return r.GetOwn(ctx, userID)
+	}
+
+	args = append(args, userID)
+	query := fmt.Sprintf(`UPDATE users SET %s WHERE user_id = $%d RETURNING %s`,
+		strings.Join(sets, ", "), len(args), ownUserColumns)
+
+	user, err := scanOwnUser(r.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "user", ID: userID}
+		}
+		return nil, &DatabaseError{Op: "update user", Err: err}
+	}
+	return user, nil
+}
+
+// Delete removes a user. Their sports, games and sessions cascade.
+func (r *UserRepository) Delete(ctx context.Context, userID string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM users WHERE user_id = $1`, userID)
+	if err != nil {
+		return &DatabaseError{Op: "delete user", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return &NotFoundError{Resource: "user", ID: userID}
+	}
+	return nil
+}
+
+// publicUserColumns returns the columns of models.PublicUser qualified with a
+// table alias, matching publicUserFields
+func publicUserColumns(alias string) string {
+	return fmt.Sprintf("%[1]s.user_id, %[1]s.name, %[1]s.picture_url, %[1]s.reputation", alias)
+}
+
+// publicUserFields returns the scan destinations matching publicUserColumns
+func publicUserFields(u *models.PublicUser) []interface{} {
+	return []interface{}{&u.UserID, &u.Name, &u.PictureURL, &u.Reputation}
+}
+
+// emptyToNil maps a blank optional string to NULL
+func emptyToNil(value *string) *string {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	return &trimmed
+}
+
+// scanUser scans a row selected with userColumns
+func scanUser(row pgx.Row) (*models.User, error) {
+	var u models.User
+	if err := row.Scan(userFields(&u)...); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// scanOwnUser scans a row selected with ownUserColumns
+func scanOwnUser(row pgx.Row) (*models.User, error) {
+	var u models.User
+	if err := row.Scan(append(userFields(&u), &u.Latitude, &u.Longitude)...); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// userFields returns the scan destinations matching userColumns, so queries
+// selecting a user next to other columns can scan it in one pass
+func userFields(u *models.User) []interface{} {
+	return []interface{}{
+		&u.UserID,
+		&u.Name,
+		&u.Email,
+		&u.PictureURL,
+		&u.PhoneNumber,
+		&u.Location,
+		&u.Reputation,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	}
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// userSportColumns is the column list matching scanUserSport
+const userSportColumns = `us.user_id, us.sport_name, us.position, us.skill_level, us.created_at, s.sport_name, s.icon_url, s.created_at`
+
+// UserSportRepository provides access to the user_sports junction table
+type UserSportRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewUserSportRepository creates a new user sport repository
+func NewUserSportRepository(db *pgxpool.Pool) *UserSportRepository {
+	return &UserSportRepository{db: db}
+}
+
+// ListByUser returns the sports of a user with the nested sport, ordered by name
+func (r *UserSportRepository) ListByUser(ctx context.Context, userID string) ([]models.UserSport, error) {
+	if err := requireUser(ctx, r.db, userID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + userSportColumns + `
+		FROM user_sports us
+		JOIN sports s ON s.sport_name = us.sport_name
+		WHERE us.user_id = $1
+		ORDER BY us.sport_name
+	`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list user sports", Err: err}
+	}
+	defer rows.Close()
+
+	sports := []models.UserSport{}
+	for rows.Next() {
+		us, err := scanUserSport(rows)
+		if err != nil {
+			return nil, &DatabaseError{Op: "scan user sport", Err: err}
+		}
+		sports = append(sports, *us)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list user sports", Err: err}
+	}
+	return sports, nil
+}
+
+// Get returns one sport of a user
+func (r *UserSportRepository) Get(ctx context.Context, userID, sportName string) (*models.UserSport, error) {
+	query := `
+		SELECT ` + userSportColumns + `
+		FROM user_sports us
+		JOIN sports s ON s.sport_name = us.sport_name
+		WHERE us.user_id = $1 AND us.sport_name = $2
+	`
+	us, err := scanUserSport(r.db.QueryRow(ctx, query, userID, sportName))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "user sport", ID: sportName}
+		}
+		return nil, &DatabaseError{Op: "get user sport", Err: err}
+	}
+	return us, nil
+}
+
+// Add links a sport to a user. The sport must exist in the sports catalog.
+func (r *UserSportRepository) Add(ctx context.Context, userID string, req models.AddUserSportRequest) (*models.UserSport, error) {
+	if err := requireUser(ctx, r.db, userID); err != nil {
+		return nil, err
+	}
+	if err := requireSport(ctx, r.db, req.SportName); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO user_sports (user_id, sport_name, position, skill_level)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := r.db.Exec(ctx, query, userID, req.SportName, emptyToNil(req.Position), req.SkillLevel)
+	if err != nil {
+		switch pgErrorCode(err) {
+		case pgUniqueViolation:
+			return nil, &ConflictError{Message: fmt.Sprintf("user already plays %s", req.SportName)}
+		case pgForeignKeyViolation:
+			// The user or sport was deleted between the checks and the insert
+			return nil, &NotFoundError{Resource: "user or sport", ID: userID + "/" + req.SportName}
+		}
+		return nil, &DatabaseError{Op: "add user sport", Err: err}
+	}
+
+	return r.Get(ctx, userID, req.SportName)
+}
+
+// Update applies a partial update to a user's sport. Nil fields are left
+// unchanged; an empty position clears it.
+func (r *UserSportRepository) Update(ctx context.Context, userID, sportName string, req models.UpdateUserSportRequest) (*models.UserSport, error) {
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.Position != nil {
+		set("position", emptyToNil(req.Position))
+	}
+	if req.SkillLevel != nil {
+		set("skill_level", *req.SkillLevel)
+	}
+
+	if len(sets) == 0 {
+		return r.Get(ctx, userID, sportName)
+	}
+
+	args = append(args, userID, sportName)
+	query := fmt.Sprintf(`UPDATE user_sports SET %s WHERE user_id = $%d AND sport_name = $%d`,
+		strings.Join(sets, ", "), len(args)-1, len(args))
+
+	tag, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		return nil, &DatabaseError{Op: "update user sport", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, &NotFoundError{Resource: "user sport", ID: sportName}
+	}
+
+	return r.Get(ctx, userID, sportName)
+}
+
+// Remove unlinks a sport from a user
+func (r *UserSportRepository) Remove(ctx context.Context, userID, sportName string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM user_sports WHERE user_id = $1 AND sport_name = $2`, userID, sportName)
+	if err != nil {
+		return &DatabaseError{Op: "remove user sport", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return &NotFoundError{Resource: "user sport", ID: sportName}
+	}
+	return nil
+}
+
+// scanUserSport scans a row selected with userSportColumns
+func scanUserSport(row pgx.Row) (*models.UserSport, error) {
+	var us models.UserSport
+	var sport models.Sport
+	err := row.Scan(
+		&us.UserID,
+		&us.SportName,
+		&us.Position,
+		&us.SkillLevel,
+		&us.CreatedAt,
+		&sport.SportName,
+		&sport.IconURL,
+		&sport.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	us.Sport = &sport
+	return &us, nil
+}
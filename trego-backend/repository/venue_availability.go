@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CourtBookingConflict describes the game already holding a court
+type CourtBookingConflict struct {
+	CourtID string          `json:"court_id"`
+	Booking models.TimeSlot `json:"booking"`
+}
+
+// Availability returns the opening hours of a venue on a day and, for each
+// court, the games booked on it and the free slots left within the opening
+// hours. Slots that already passed are left out. Bookings only name the game
+// when the viewer may see it; invite-only games of others, and every game
+// for anonymous viewers with an empty viewerID, show as busy time only.
+func (r *VenueRepository) Availability(ctx context.Context, viewerID, venueID string, filters models.AvailabilityFilters) (*models.VenueAvailability, error) {
+	venue, err := getVenue(ctx, r.db, venueID, false)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(venue.Timezone)
+	if err != nil {
+		return nil, &DatabaseError{Op: "load venue time zone", Err: err}
+	}
+	day, err := time.ParseInLocation("2006-01-02", filters.Date, loc)
+	if err != nil {
+		return nil, &ValidationError{Field: "date", Message: "must be a date like 2024-05-31"}
+	}
+	dayEnd := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+
+	courts := venue.Courts
+	if filters.CourtID != nil {
+		courts = nil
+		for _, court := range venue.Courts {
+			if court.CourtID == *filters.CourtID {
+				courts = append(courts, court)
+			}
+		}
+		if len(courts) == 0 {
+			return nil, &NotFoundError{Resource: "court", ID: *filters.CourtID}
+		}
+	}
+
+	availability := &models.VenueAvailability{
+		VenueID:  venue.VenueID,
+		Date:     day.Format("2006-01-02"),
+		Timezone: venue.Timezone,
+		Open:     openIntervals(day, dayEnd, venue.OpeningHours),
+		Courts:   []models.CourtAvailability{},
+	}
+
+	courtIDs := make([]string, len(courts))
+	for i, court := range courts {
+		courtIDs[i] = court.CourtID
+	}
+	bookings, err := courtBookings(ctx, r.db, viewerID, courtIDs, day, dayEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	minLength := time.Duration(filters.MinMinutes) * time.Minute
+	now := time.Now()
+	for _, court := range courts {
+		booked := bookings[court.CourtID]
+		if booked == nil {
+			booked = []models.TimeSlot{}
+		}
+		availability.Courts = append(availability.Courts, models.CourtAvailability{
+			CourtID: court.CourtID,
+			Name:    court.Name,
+			Booked:  booked,
+			Free:    freeSlots(availability.Open, booked, now, minLength),
+		})
+	}
+	return availability, nil
+}
+
+// checkCourtAvailable returns a ConflictError naming the game that already
+// books the court for part of [start, end). The game being edited, if any,
+// and cancelled games are ignored. The exclusion constraint on games enforces the same rule
+// against concurrent bookings; this check only gives a better error.
+func checkCourtAvailable(ctx context.Context, q querier, courtID string, start, end time.Time, gameID string) error {
+	var booking models.TimeSlot
+	err := q.QueryRow(ctx, `
+		SELECT game_id, title, start_time, end_time
+		FROM games
+		WHERE court_id = $1 AND game_id <> $2 AND status <> 'cancelled'
+			AND tstzrange(start_time, end_time, '[)') && tstzrange($3, $4, '[)')
+		ORDER BY start_time
+		LIMIT 1
+	`, courtID, gameID, start, end).Scan(&booking.GameID, &booking.Title, &booking.Start, &booking.End)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return &DatabaseError{Op: "check court bookings", Err: err}
+	}
+	return &ConflictError{
+		Message: fmt.Sprintf("court is already booked from %s to %s",
+			booking.Start.UTC().Format(time.RFC3339), booking.End.UTC().Format(time.RFC3339)),
+		Details: CourtBookingConflict{CourtID: courtID, Booking: booking},
+	}
+}
+
+// courtBookings returns the games not cancelled on the courts overlapping
+// [from, to), by court in start order. The ID and title of games the viewer
+// may not see are left out.
+func courtBookings(ctx context.Context, q querier, viewerID string, courtIDs []string, from, to time.Time) (map[string][]models.TimeSlot, error) {
+	bookings := make(map[string][]models.TimeSlot, len(courtIDs))
+	if len(courtIDs) == 0 {
+		return bookings, nil
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT court_id, CASE WHEN visible THEN game_id END, CASE WHEN visible THEN title END, start_time, end_time
+		FROM (
+			SELECT g.court_id, g.game_id, g.title, g.start_time, g.end_time,
+				$4::text <> '' AND `+gameVisibleTo("$4")+` AS visible
+			FROM games g
+			WHERE g.court_id = ANY($1) AND g.status <> 'cancelled'
+				AND tstzrange(g.start_time, g.end_time, '[)') && tstzrange($2, $3, '[)')
+		) bookings
+		ORDER BY start_time, game_id
+	`, courtIDs, from, to, viewerID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list court bookings", Err: err}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var courtID string
+		var slot models.TimeSlot
+		if err := rows.Scan(&courtID, &slot.GameID, &slot.Title, &slot.Start, &slot.End); err != nil {
+			return nil, &DatabaseError{Op: "scan court booking", Err: err}
+		}
+		bookings[courtID] = append(bookings[courtID], slot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list court bookings", Err: err}
+	}
+	return bookings, nil
+}
+
+// openIntervals returns the opening intervals of the day starting at day,
+// or the whole day when the venue has no opening hours. Times are built from
+// the wall clock, so days with a DST change come out right.
+func openIntervals(day, dayEnd time.Time, hours []models.OpeningHours) []models.TimeSlot {
+	if len(hours) == 0 {
+		return []models.TimeSlot{{Start: day, End: dayEnd}}
+	}
+
+	at := func(clock string) time.Time {
+		offset, _ := parseClock(clock)
+		hour, minute := int(offset/time.Hour), int(offset%time.Hour/time.Minute)
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+	}
+	open := []models.TimeSlot{}
+	for _, h := range hours {
+		if h.Weekday == int(day.Weekday()) {
+			open = append(open, models.TimeSlot{Start: at(h.Opens), End: at(h.Closes)})
+		}
+	}
+	return open
+}
+
+// freeSlots subtracts the bookings, sorted by start, from the open
+// intervals. Slots are cut off at now, and slots shorter than minLength are
+// dropped.
+func freeSlots(open, bookings []models.TimeSlot, now time.Time, minLength time.Duration) []models.TimeSlot {
+	free := []models.TimeSlot{}
+	add := func(start, end time.Time) {
+		if start.Before(now) {
+			start = now.Truncate(time.Minute).Add(time.Minute)
+		}
+		if end.Sub(start) > 0 && end.Sub(start) >= minLength {
+			free = append(free, models.TimeSlot{Start: start, End: end})
+		}
+	}
+
+	for _, interval := range open {
+		cursor := interval.Start
+		for _, b := range bookings {
+			if !b.End.After(cursor) || !b.Start.Before(interval.End) {
+				continue
+			}
+			if b.Start.After(cursor) {
+				add(cursor, b.Start)
+			}
+			cursor = b.End
+			if !cursor.Before(interval.End) {
+				break
+			}
+		}
+		if cursor.Before(interval.End) {
+			add(cursor, interval.End)
+		}
+	}
+	return free
+}
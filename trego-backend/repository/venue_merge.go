@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultLocationGroupPageSize is used when the duplicate report does not set a limit
+const defaultLocationGroupPageSize = 50
+
+// normalizedLocationSQL returns the SQL normalizing a free-text location for
+// matching: lower case, with every run of punctuation and spaces collapsed
+// into one space, so "Central Park", "central  park." and "Central-Park"
+// compare equal
+func normalizedLocationSQL(expr string) string {
+	return fmt.Sprintf(`TRIM(REGEXP_REPLACE(LOWER(%s), '[^[:alnum:]]+', ' ', 'g'))`, expr)
+}
+
+// LocationGroups reports the free-text locations of games without a venue,
+// grouped by their normalized form with the spellings in use, most used
+// first. Groups whose form is already an alias carry the venue they match.
+func (r *VenueRepository) LocationGroups(ctx context.Context, filters models.LocationGroupFilters) ([]models.LocationGroup, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultLocationGroupPageSize
+	}
+
+	query := `
+		SELECT n.key, ARRAY_AGG(DISTINCT n.location ORDER BY n.location), MODE() WITHIN GROUP (ORDER BY n.location), COUNT(*),
+			(SELECT va.venue_id FROM venue_aliases va WHERE va.alias = n.key)
+		FROM (
+			SELECT ` + normalizedLocationSQL("g.location") + ` AS key, g.location
+			FROM games g
+			WHERE g.venue_id IS NULL
+		) n
+		WHERE n.key <> ''
+		GROUP BY n.key
+		ORDER BY COUNT(*) DESC, n.key
+		LIMIT $1 OFFSET $2
+	`
+	rows, err := r.db.Query(ctx, query, limit, filters.Offset)
+	if err != nil {
+		return nil, &DatabaseError{Op: "group locations", Err: err}
+	}
+	defer rows.Close()
+
+	groups := []models.LocationGroup{}
+	for rows.Next() {
+		var g models.LocationGroup
+		if err := rows.Scan(&g.Key, &g.Spellings, &g.SuggestedName, &g.GameCount, &g.VenueID); err != nil {
+			return nil, &DatabaseError{Op: "scan location group", Err: err}
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "group locations", Err: err}
+	}
+	return groups, nil
+}
+
+// LinkAliasedGames links games without a venue to the venue whose alias
+// matches their location, and returns how many games were linked
+func (r *VenueRepository) LinkAliasedGames(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE games g SET venue_id = va.venue_id
+		FROM venue_aliases va
+		WHERE g.venue_id IS NULL AND va.alias = `+normalizedLocationSQL("g.location"))
+	if err != nil {
+		return 0, &DatabaseError{Op: "link aliased games", Err: err}
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Merge folds duplicate venues into the target venue and links free-text
+// locations to it. Merged venues move their games, courts, sports and
+// aliases to the target, fill in its missing address, coordinates and
+// opening hours, and are deleted; their names become aliases. Each location
+// becomes an alias of the target, and games without a venue at that location
+// are linked to it. Everything happens in one transaction.
+func (r *VenueRepository) Merge(ctx context.Context, targetID string, req models.MergeVenuesRequest) (*models.MergeVenuesResult, error) {
+	if len(req.VenueIDs) == 0 && len(req.Locations) == 0 {
+		return nil, &ValidationError{Field: "venue_ids", Message: "name at least one venue or location to merge"}
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := getVenue(ctx, tx, targetID, true); err != nil {
+		return nil, err
+	}
+
+	result := &models.MergeVenuesResult{}
+	for _, sourceID := range cleanList(req.VenueIDs) {
+		if sourceID == targetID {
+			return nil, &ValidationError{Field: "venue_ids", Message: "cannot merge a venue into itself"}
+		}
+		linked, err := mergeVenue(ctx, tx, targetID, sourceID)
+		if err != nil {
+			return nil, err
+		}
+		result.MergedVenues++
+		result.LinkedGames += linked
+	}
+
+	for _, location := range cleanList(req.Locations) {
+		alias, err := linkVenueAlias(ctx, tx, targetID, location, true)
+		if err != nil {
+			return nil, err
+		}
+		if alias == "" {
+			return nil, &ValidationError{Field: "locations", Message: fmt.Sprintf("location %q has no letters or digits", location)}
+		}
+		result.LinkedAliases++
+
+		tag, err := tx.Exec(ctx, `
+			UPDATE games SET venue_id = $1
+			WHERE venue_id IS NULL AND `+normalizedLocationSQL("location")+` = $2
+		`, targetID, alias)
+		if err != nil {
+			return nil, &DatabaseError{Op: "link games to venue", Err: err}
+		}
+		result.LinkedGames += tag.RowsAffected()
+	}
+
+	venue, err := getVenue(ctx, tx, targetID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit venue merge", Err: err}
+	}
+	result.Venue = *venue
+	return result, nil
+}
+
+// mergeVenue moves everything of the source venue to the target and deletes
+// the source. Courts whose name the target already uses get the source's
+// name appended. Returns how many games moved.
+func mergeVenue(ctx context.Context, tx pgx.Tx, targetID, sourceID string) (int64, error) {
+	source, err := getVenue(ctx, tx, sourceID, true)
+	if err != nil {
+		var notFound *NotFoundError
+		if errors.As(err, &notFound) {
+			return 0, &ValidationError{Field: "venue_ids", Message: fmt.Sprintf("venue %q not found", sourceID)}
+		}
+		return 0, err
+	}
+
+	statements := []struct {
+		op    string
+		query string
+	}{
+		{"merge venue details", `
+			UPDATE venues t SET
+				address = COALESCE(t.address, s.address),
+				latitude = COALESCE(t.latitude, s.latitude),
+				longitude = COALESCE(t.longitude, s.longitude),
+				amenities = ARRAY(SELECT DISTINCT a FROM UNNEST(t.amenities || s.amenities) a ORDER BY a)
+			FROM venues s
+			WHERE t.venue_id = $1 AND s.venue_id = $2`},
+		{"merge venue sports", `
+			INSERT INTO venue_sports (venue_id, sport_name)
+			SELECT $1, sport_name FROM venue_sports WHERE venue_id = $2
+			ON CONFLICT DO NOTHING`},
+		{"merge opening hours", `
+			INSERT INTO venue_opening_hours (venue_id, weekday, opens_at, closes_at)
+			SELECT $1, weekday, opens_at, closes_at FROM venue_opening_hours
+			WHERE venue_id = $2 AND NOT EXISTS (SELECT 1 FROM venue_opening_hours WHERE venue_id = $1)`},
+		{"move courts", `
+			UPDATE courts c SET venue_id = $1,
+				name = CASE WHEN EXISTS (SELECT 1 FROM courts tc WHERE tc.venue_id = $1 AND tc.name = c.name)
+					THEN c.name || ' (' || (SELECT name FROM venues WHERE venue_id = $2) || ')'
+					ELSE c.name END
+			WHERE c.venue_id = $2`},
+		{"move venue aliases", `UPDATE venue_aliases SET venue_id = $1 WHERE venue_id = $2`},
+	}
+	for _, s := range statements {
+		if _, err := tx.Exec(ctx, s.query, targetID, sourceID); err != nil {
+			if pgErrorCode(err) == pgUniqueViolation {
+				return 0, &ConflictError{Message: fmt.Sprintf("cannot merge venue %q: court names collide", source.Name)}
+			}
+			return 0, &DatabaseError{Op: s.op, Err: err}
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `UPDATE games SET venue_id = $1 WHERE venue_id = $2`, targetID, sourceID)
+	if err != nil {
+		return 0, &DatabaseError{Op: "move games", Err: err}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM venues WHERE venue_id = $1`, sourceID); err != nil {
+		return 0, &DatabaseError{Op: "delete merged venue", Err: err}
+	}
+	if _, err := linkVenueAlias(ctx, tx, targetID, source.Name, true); err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// linkVenueAlias records the normalized form of a location as an alias of
+// the venue. An alias already held by another venue is moved when override
+// is set and kept otherwise. Returns the alias, or "" when the location
+// normalizes to nothing or the alias stayed with another venue.
+func linkVenueAlias(ctx context.Context, q querier, venueID, location string, override bool) (string, error) {
+	conflict := `DO NOTHING`
+	if override {
+		conflict = `DO UPDATE SET venue_id = EXCLUDED.venue_id`
+	}
+
+	var alias string
+	err := q.QueryRow(ctx, `
+		INSERT INTO venue_aliases (alias, venue_id)
+		SELECT n.alias, $2 FROM (SELECT `+normalizedLocationSQL("$1::text")+` AS alias) n
+		WHERE n.alias <> ''
+		ON CONFLICT (alias) `+conflict+`
+		RETURNING alias
+	`, location, venueID).Scan(&alias)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", &DatabaseError{Op: "link venue alias", Err: err}
+	}
+	return alias, nil
+}
+
+// venueForLocation returns the venue whose alias matches a free-text
+// location, or nil
+func venueForLocation(ctx context.Context, q querier, location string) (*string, error) {
+	var venueID string
+	err := q.QueryRow(ctx, `SELECT venue_id FROM venue_aliases WHERE alias = `+normalizedLocationSQL("$1::text"), location).Scan(&venueID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, &DatabaseError{Op: "match venue alias", Err: err}
+	}
+	return &venueID, nil
+}
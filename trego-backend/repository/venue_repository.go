@@ -0,0 +1,658 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	// Embed the time zone database so venue time zones resolve on hosts
+	// without one
+	_ "time/tzdata"
+
+	"trego-backend/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// venueColumns is the column list matching venueFields. It expects the
+// venues table to be aliased as v.
+const venueColumns = `v.venue_id, v.name, v.address, v.latitude, v.longitude, v.timezone, v.amenities,
+	ARRAY(SELECT vs.sport_name FROM venue_sports vs WHERE vs.venue_id = v.venue_id ORDER BY vs.sport_name),
+	v.created_at, v.updated_at`
+
+// courtColumns is the column list matching courtFields
+const courtColumns = `court_id, venue_id, name, surface, indoor, created_at`
+
+// defaultVenuePageSize is used when a venue search does not set a limit
+const defaultVenuePageSize = 20
+
+// VenueRepository provides access to venues and their courts
+type VenueRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewVenueRepository creates a new venue repository
+func NewVenueRepository(db *pgxpool.Pool) *VenueRepository {
+	return &VenueRepository{db: db}
+}
+
+// Create inserts a venue with its sports, opening hours and courts. The
+// venue's name is recorded as an alias so games at that location link to it.
+func (r *VenueRepository) Create(ctx context.Context, req models.CreateVenueRequest) (*models.Venue, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, &ValidationError{Field: "name", Message: "must not be empty"}
+	}
+	if err := validateCoordinates(req.Latitude, req.Longitude); err != nil {
+		return nil, err
+	}
+	timezone := strings.TrimSpace(req.Timezone)
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if err := validateTimezone(timezone); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	var venueID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO venues (name, address, latitude, longitude, timezone, amenities)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING venue_id
+	`, name, emptyToNil(req.Address), req.Latitude, req.Longitude, timezone, cleanList(req.Amenities)).Scan(&venueID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "create venue", Err: err}
+	}
+
+	if err := replaceVenueSports(ctx, tx, venueID, req.Sports); err != nil {
+		return nil, err
+	}
+	if err := replaceOpeningHours(ctx, tx, venueID, req.OpeningHours); err != nil {
+		return nil, err
+	}
+	for _, court := range req.Courts {
+		if _, err := addCourt(ctx, tx, venueID, court); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := linkVenueAlias(ctx, tx, venueID, name, false); err != nil {
+		return nil, err
+	}
+
+	venue, err := getVenue(ctx, tx, venueID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit venue", Err: err}
+	}
+	return venue, nil
+}
+
+// Get returns a venue with its courts, opening hours and aliases
+func (r *VenueRepository) Get(ctx context.Context, venueID string) (*models.Venue, error) {
+	return getVenue(ctx, r.db, venueID, false)
+}
+
+// Search returns venues matching the filters with their sports, by name, or
+// by distance when lat/lng is set
+func (r *VenueRepository) Search(ctx context.Context, filters models.VenueFilters) ([]models.Venue, error) {
+	if (filters.Latitude == nil) != (filters.Longitude == nil) {
+		return nil, &ValidationError{Field: "lat", Message: "lat and lng must be set together"}
+	}
+	if filters.RadiusKm != nil && filters.Latitude == nil {
+		return nil, &ValidationError{Field: "radius_km", Message: "needs lat and lng"}
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultVenuePageSize
+	}
+
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filters.Query != nil && strings.TrimSpace(*filters.Query) != "" {
+		pattern := arg("%" + strings.TrimSpace(*filters.Query) + "%")
+		conditions = append(conditions, fmt.Sprintf("(v.name ILIKE %[1]s OR v.address ILIKE %[1]s)", pattern))
+	}
+	if filters.SportName != nil {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM venue_sports fvs WHERE fvs.venue_id = v.venue_id AND fvs.sport_name = "+arg(*filters.SportName)+")")
+	}
+
+	columns, order := venueColumns, "LOWER(v.name), v.venue_id"
+	if filters.Latitude != nil {
+		distance := distanceSQL("v.latitude", "v.longitude", arg(*filters.Latitude), arg(*filters.Longitude))
+		conditions = append(conditions, "v.latitude IS NOT NULL")
+		if filters.RadiusKm != nil {
+			box := boxAround(*filters.Latitude, *filters.Longitude, *filters.RadiusKm)
+			conditions = append(conditions, box.conditionSQL("v.latitude", "v.longitude", arg), distance+" <= "+arg(*filters.RadiusKm))
+		}
+		columns, order = venueColumns+", "+distance+" AS distance_km", "distance_km, v.venue_id"
+	}
+
+	query := `SELECT ` + columns + `
+		FROM venues v
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + order + `
+		LIMIT ` + arg(limit) + ` OFFSET ` + arg(filters.Offset)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, &DatabaseError{Op: "search venues", Err: err}
+	}
+	defer rows.Close()
+
+	venues := []models.Venue{}
+	for rows.Next() {
+		var v models.Venue
+		dest := venueFields(&v)
+		if filters.Latitude != nil {
+			dest = append(dest, &v.DistanceKm)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, &DatabaseError{Op: "scan venue", Err: err}
+		}
+		venues = append(venues, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "search venues", Err: err}
+	}
+	return venues, nil
+}
+
+// Update applies a partial update. Nil fields are left unchanged; empty
+// strings clear the optional fields, and lists replace the stored ones.
+func (r *VenueRepository) Update(ctx context.Context, venueID string, req models.UpdateVenueRequest) (*models.Venue, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, &DatabaseError{Op: "begin transaction", Err: err}
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := getVenue(ctx, tx, venueID, true); err != nil {
+		return nil, err
+	}
+
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, &ValidationError{Field: "name", Message: "must not be empty"}
+		}
+		set("name", name)
+		if _, err := linkVenueAlias(ctx, tx, venueID, name, false); err != nil {
+			return nil, err
+		}
+	}
+	if req.Address != nil {
+		set("address", emptyToNil(req.Address))
+	}
+	if req.Latitude != nil || req.Longitude != nil {
+		if err := validateCoordinates(req.Latitude, req.Longitude); err != nil {
+			return nil, err
+		}
+		set("latitude", *req.Latitude)
+		set("longitude", *req.Longitude)
+	}
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		if err := validateTimezone(timezone); err != nil {
+			return nil, err
+		}
+		set("timezone", timezone)
+	}
+	if req.Amenities != nil {
+		set("amenities", cleanList(*req.Amenities))
+	}
+
+	if len(sets) > 0 {
+		args = append(args, venueID)
+		query := fmt.Sprintf(`UPDATE venues SET %s WHERE venue_id = $%d`, strings.Join(sets, ", "), len(args))
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return nil, &DatabaseError{Op: "update venue", Err: err}
+		}
+	}
+	if req.Sports != nil {
+		if err := replaceVenueSports(ctx, tx, venueID, *req.Sports); err != nil {
+			return nil, err
+		}
+	}
+	if req.OpeningHours != nil {
+		if err := replaceOpeningHours(ctx, tx, venueID, *req.OpeningHours); err != nil {
+			return nil, err
+		}
+	}
+
+	venue, err := getVenue(ctx, tx, venueID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, &DatabaseError{Op: "commit venue update", Err: err}
+	}
+	return venue, nil
+}
+
+// Delete removes a venue with its courts. Games keep their free-text
+// location and lose the venue reference.
+func (r *VenueRepository) Delete(ctx context.Context, venueID string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM venues WHERE venue_id = $1`, venueID)
+	if err != nil {
+		return &DatabaseError{Op: "delete venue", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return &NotFoundError{Resource: "venue", ID: venueID}
+	}
+	return nil
+}
+
+// AddCourt adds a court to a venue
+func (r *VenueRepository) AddCourt(ctx context.Context, venueID string, req models.CreateCourtRequest) (*models.Court, error) {
+	if _, err := getVenue(ctx, r.db, venueID, false); err != nil {
+		return nil, err
+	}
+	return addCourt(ctx, r.db, venueID, req)
+}
+
+// UpdateCourt applies a partial update to a court of a venue
+func (r *VenueRepository) UpdateCourt(ctx context.Context, venueID, courtID string, req models.UpdateCourtRequest) (*models.Court, error) {
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, &ValidationError{Field: "name", Message: "must not be empty"}
+		}
+		set("name", name)
+	}
+	if req.Surface != nil {
+		set("surface", emptyToNil(req.Surface))
+	}
+	if req.Indoor != nil {
+		set("indoor", *req.Indoor)
+	}
+	if len(sets) == 0 {
+		return getCourt(ctx, r.db, venueID, courtID)
+	}
+
+	args = append(args, venueID, courtID)
+	query := fmt.Sprintf(`UPDATE courts SET %s WHERE venue_id = $%d AND court_id = $%d RETURNING %s`,
+		strings.Join(sets, ", "), len(args)-1, len(args), courtColumns)
+
+	var court models.Court
+	if err := r.db.QueryRow(ctx, query, args...).Scan(courtFields(&court)...); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, &NotFoundError{Resource: "court", ID: courtID}
+		case pgErrorCode(err) == pgUniqueViolation:
+			return nil, &ConflictError{Message: fmt.Sprintf("venue already has a court named %q", *req.Name)}
+		}
+		return nil, &DatabaseError{Op: "update court", Err: err}
+	}
+	return &court, nil
+}
+
+// DeleteCourt removes a court of a venue. Games on it keep the venue.
+func (r *VenueRepository) DeleteCourt(ctx context.Context, venueID, courtID string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM courts WHERE venue_id = $1 AND court_id = $2`, venueID, courtID)
+	if err != nil {
+		return &DatabaseError{Op: "delete court", Err: err}
+	}
+	if tag.RowsAffected() == 0 {
+		return &NotFoundError{Resource: "court", ID: courtID}
+	}
+	return nil
+}
+
+// getVenue loads a venue with its courts, opening hours and aliases,
+// optionally locking its row for the rest of the transaction
+func getVenue(ctx context.Context, q querier, venueID string, forUpdate bool) (*models.Venue, error) {
+	query := `SELECT ` + venueColumns + ` FROM venues v WHERE v.venue_id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE OF v`
+	}
+
+	var venue models.Venue
+	if err := q.QueryRow(ctx, query, venueID).Scan(venueFields(&venue)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "venue", ID: venueID}
+		}
+		return nil, &DatabaseError{Op: "get venue", Err: err}
+	}
+
+	var err error
+	if venue.Courts, err = listCourts(ctx, q, venueID); err != nil {
+		return nil, err
+	}
+	if venue.OpeningHours, err = listOpeningHours(ctx, q, venueID); err != nil {
+		return nil, err
+	}
+	if venue.Aliases, err = listVenueAliases(ctx, q, venueID); err != nil {
+		return nil, err
+	}
+	return &venue, nil
+}
+
+// listCourts returns the courts of a venue by name
+func listCourts(ctx context.Context, q querier, venueID string) ([]models.Court, error) {
+	rows, err := q.Query(ctx, `SELECT `+courtColumns+` FROM courts WHERE venue_id = $1 ORDER BY name`, venueID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list courts", Err: err}
+	}
+	defer rows.Close()
+
+	courts := []models.Court{}
+	for rows.Next() {
+		var court models.Court
+		if err := rows.Scan(courtFields(&court)...); err != nil {
+			return nil, &DatabaseError{Op: "scan court", Err: err}
+		}
+		courts = append(courts, court)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list courts", Err: err}
+	}
+	return courts, nil
+}
+
+// listVenueAliases returns the normalized locations linked to a venue
+func listVenueAliases(ctx context.Context, q querier, venueID string) ([]string, error) {
+	rows, err := q.Query(ctx, `SELECT alias FROM venue_aliases WHERE venue_id = $1 ORDER BY alias`, venueID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list venue aliases", Err: err}
+	}
+	defer rows.Close()
+
+	aliases := []string{}
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, &DatabaseError{Op: "scan venue alias", Err: err}
+		}
+		aliases = append(aliases, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list venue aliases", Err: err}
+	}
+	return aliases, nil
+}
+
+// listOpeningHours returns the opening hours of a venue by weekday and time
+func listOpeningHours(ctx context.Context, q querier, venueID string) ([]models.OpeningHours, error) {
+	rows, err := q.Query(ctx, `
+		SELECT weekday, TO_CHAR(opens_at, 'HH24:MI'), TO_CHAR(closes_at, 'HH24:MI')
+		FROM venue_opening_hours
+		WHERE venue_id = $1
+		ORDER BY weekday, opens_at
+	`, venueID)
+	if err != nil {
+		return nil, &DatabaseError{Op: "list opening hours", Err: err}
+	}
+	defer rows.Close()
+
+	hours := []models.OpeningHours{}
+	for rows.Next() {
+		var h models.OpeningHours
+		if err := rows.Scan(&h.Weekday, &h.Opens, &h.Closes); err != nil {
+			return nil, &DatabaseError{Op: "scan opening hours", Err: err}
+		}
+		hours = append(hours, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &DatabaseError{Op: "list opening hours", Err: err}
+	}
+	return hours, nil
+}
+
+// getCourt loads a court of a venue
+func getCourt(ctx context.Context, q querier, venueID, courtID string) (*models.Court, error) {
+	var court models.Court
+	err := q.QueryRow(ctx, `SELECT `+courtColumns+` FROM courts WHERE venue_id = $1 AND court_id = $2`, venueID, courtID).
+		Scan(courtFields(&court)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "court", ID: courtID}
+		}
+		return nil, &DatabaseError{Op: "get court", Err: err}
+	}
+	return &court, nil
+}
+
+// addCourt inserts a court into a venue
+func addCourt(ctx context.Context, q querier, venueID string, req models.CreateCourtRequest) (*models.Court, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, &ValidationError{Field: "name", Message: "court name must not be empty"}
+	}
+
+	var court models.Court
+	err := q.QueryRow(ctx, `
+		INSERT INTO courts (venue_id, name, surface, indoor)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+courtColumns,
+		venueID, name, emptyToNil(req.Surface), req.Indoor,
+	).Scan(courtFields(&court)...)
+	if err != nil {
+		if pgErrorCode(err) == pgUniqueViolation {
+			return nil, &ConflictError{Message: fmt.Sprintf("venue already has a court named %q", name)}
+		}
+		return nil, &DatabaseError{Op: "create court", Err: err}
+	}
+	return &court, nil
+}
+
+// replaceVenueSports sets the sports played at a venue
+func replaceVenueSports(ctx context.Context, q querier, venueID string, sports []string) error {
+	if _, err := q.Exec(ctx, `DELETE FROM venue_sports WHERE venue_id = $1`, venueID); err != nil {
+		return &DatabaseError{Op: "clear venue sports", Err: err}
+	}
+	for _, sportName := range cleanList(sports) {
+		if err := requireSport(ctx, q, sportName); err != nil {
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				validationErr.Field = "sports"
+			}
+			return err
+		}
+		if _, err := q.Exec(ctx, `INSERT INTO venue_sports (venue_id, sport_name) VALUES ($1, $2)`, venueID, sportName); err != nil {
+			return &DatabaseError{Op: "add venue sport", Err: err}
+		}
+	}
+	return nil
+}
+
+// replaceOpeningHours sets the opening hours of a venue. Intervals on the
+// same weekday must not overlap.
+func replaceOpeningHours(ctx context.Context, q querier, venueID string, hours []models.OpeningHours) error {
+	sorted := make([]models.OpeningHours, len(hours))
+	copy(sorted, hours)
+	for i, h := range sorted {
+		opens, err := parseClock(h.Opens)
+		if err != nil || opens == 24*time.Hour {
+			return &ValidationError{Field: "opening_hours", Message: fmt.Sprintf("invalid opening time %q, expected HH:MM", h.Opens)}
+		}
+		closes, err := parseClock(h.Closes)
+		if err != nil {
+			return &ValidationError{Field: "opening_hours", Message: fmt.Sprintf("invalid closing time %q, expected HH:MM", h.Closes)}
+		}
+		if closes <= opens {
+			return &ValidationError{Field: "opening_hours", Message: fmt.Sprintf("closing time %s must be after opening time %s", h.Closes, h.Opens)}
+		}
+		// Zero-padded, so "9:00" sorts and compares before "10:00" below
+		sorted[i].Opens, sorted[i].Closes = formatClock(opens), formatClock(closes)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Weekday != sorted[j].Weekday {
+			return sorted[i].Weekday < sorted[j].Weekday
+		}
+		return sorted[i].Opens < sorted[j].Opens
+	})
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		if prev.Weekday == cur.Weekday && cur.Opens < prev.Closes {
+			return &ValidationError{Field: "opening_hours", Message: fmt.Sprintf("intervals %s-%s and %s-%s overlap", prev.Opens, prev.Closes, cur.Opens, cur.Closes)}
+		}
+	}
+
+	if _, err := q.Exec(ctx, `DELETE FROM venue_opening_hours WHERE venue_id = $1`, venueID); err != nil {
+		return &DatabaseError{Op: "clear opening hours", Err: err}
+	}
+	for _, h := range sorted {
+		_, err := q.Exec(ctx, `
+			INSERT INTO venue_opening_hours (venue_id, weekday, opens_at, closes_at)
+			VALUES ($1, $2, $3::text::time, $4::text::time)
+		`, venueID, h.Weekday, h.Opens, h.Closes)
+		if err != nil {
+			return &DatabaseError{Op: "add opening hours", Err: err}
+		}
+	}
+	return nil
+}
+
+// parseClock parses an "HH:MM" time of day, accepting "24:00" for the end of
+// the day, into the offset from midnight
+func parseClock(value string) (time.Duration, error) {
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// formatClock writes an offset from midnight as a zero-padded "HH:MM"
+func formatClock(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset.Hours()), int(offset.Minutes())%60)
+}
+
+// validateTimezone returns a ValidationError unless the name is an IANA time zone
+func validateTimezone(name string) error {
+	if name == "" || name == "Local" {
+		return &ValidationError{Field: "timezone", Message: "must be an IANA time zone, e.g. Europe/Lisbon"}
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return &ValidationError{Field: "timezone", Message: fmt.Sprintf("unknown time zone %q", name)}
+	}
+	return nil
+}
+
+// cleanList trims the values of a list and drops blanks and duplicates
+func cleanList(values []string) []string {
+	cleaned := []string{}
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		cleaned = append(cleaned, value)
+	}
+	return cleaned
+}
+
+// venueFields returns the scan destinations matching venueColumns
+func venueFields(v *models.Venue) []interface{} {
+	return []interface{}{
+		&v.VenueID,
+		&v.Name,
+		&v.Address,
+		&v.Latitude,
+		&v.Longitude,
+		&v.Timezone,
+		&v.Amenities,
+		&v.Sports,
+		&v.CreatedAt,
+		&v.UpdatedAt,
+	}
+}
+
+// courtFields returns the scan destinations matching courtColumns
+func courtFields(c *models.Court) []interface{} {
+	return []interface{}{
+		&c.CourtID,
+		&c.VenueID,
+		&c.Name,
+		&c.Surface,
+		&c.Indoor,
+		&c.CreatedAt,
+	}
+}
+
+// gameVenue is the venue, and optionally the court, a game is placed at
+type gameVenue struct {
+	VenueID   string
+	CourtID   *string
+	Name      string
+	Latitude  *float64
+	Longitude *float64
+	Timezone  string
+}
+
+// resolveGameVenue checks the venue and court a game is placed at. A court
+// implies its venue, and a venue given with a court must be the court's.
+// Without either, a location matching a venue alias places the game at that
+// venue. Returns nil when the game is not at a venue.
+func resolveGameVenue(ctx context.Context, q querier, venueID, courtID *string, location string) (*gameVenue, error) {
+	if courtID != nil {
+		var courtVenueID string
+		err := q.QueryRow(ctx, `SELECT venue_id FROM courts WHERE court_id = $1`, *courtID).Scan(&courtVenueID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, &ValidationError{Field: "court_id", Message: fmt.Sprintf("court %q not found", *courtID)}
+			}
+			return nil, &DatabaseError{Op: "get court", Err: err}
+		}
+		if venueID != nil && *venueID != courtVenueID {
+			return nil, &ValidationError{Field: "court_id", Message: "court does not belong to the venue"}
+		}
+		venueID = &courtVenueID
+	}
+	if venueID == nil && location != "" {
+		matched, err := venueForLocation(ctx, q, location)
+		if err != nil {
+			return nil, err
+		}
+		venueID = matched
+	}
+	if venueID == nil {
+		return nil, nil
+	}
+
+	venue := &gameVenue{VenueID: *venueID, CourtID: courtID}
+	err := q.QueryRow(ctx, `SELECT name, latitude, longitude, timezone FROM venues WHERE venue_id = $1`, *venueID).
+		Scan(&venue.Name, &venue.Latitude, &venue.Longitude, &venue.Timezone)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &ValidationError{Field: "venue_id", Message: fmt.Sprintf("venue %q not found", *venueID)}
+		}
+		return nil, &DatabaseError{Op: "get venue", Err: err}
+	}
+	return venue, nil
+}